@@ -7,9 +7,34 @@ var (
 	ErrWeakPassword          = errors.New("weak-password")
 	ErrPasswordTooLong       = errors.New("password-too-long")
 	ErrInvalidUsernameFormat = errors.New("invalid-username-format")
+	ErrReservedUsername      = errors.New("reserved-username")
 )
 
 // Login errors
 var (
 	ErrIncorrectPassword = errors.New("incorrect-password")
+	// an account without a password has to sign in with its provider again,
+	// or give a code of its authenticator
+	ErrReauthRequired = errors.New("reauth-required")
+)
+
+// Guest errors
+var (
+	ErrNoGuestNickname = errors.New("no-guest-nickname")
+	ErrNotGuest        = errors.New("not-a-guest")
+)
+
+// Two-factor errors
+var (
+	ErrIncorrectCode   = errors.New("incorrect-code")
+	ErrMFATokenInvalid = errors.New("mfa-token-invalid")
+)
+
+// OpenID Connect errors
+var (
+	ErrUnknownProvider     = errors.New("unknown-provider")
+	ErrProviderUnavailable = errors.New("provider-unavailable")
+	ErrOIDCStateInvalid    = errors.New("oidc-state-invalid")
+	ErrOIDCLoginRejected   = errors.New("oidc-login-rejected")
+	ErrOIDCSignupInvalid   = errors.New("oidc-signup-invalid")
 )
@@ -3,10 +3,14 @@ package auth
 import (
 	"api/domain"
 	"context"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -26,17 +30,191 @@ var (
 	ErrPasswordTooLongStr       = "password-too-long"
 	ErrInvalidUsernameFormatStr = "invalid-username-format"
 	ErrAccountCreatedButNoToken = "account-created-but-no-token"
+	ErrReservedUsernameStr      = "reserved-username"
+	ErrGuestNotAllowedStr       = "guest-not-allowed"
+	ErrNotGuestStr              = "not-a-guest"
+	ErrBadTokenStr              = "bad-token"
+	ErrTooManyAttemptsStr       = "too-many-attempts"
+	ErrServerBusyStr            = "server-busy"
+	ErrIncorrectPasswordStr     = "incorrect-password"
+	ErrUsernameChangeTooSoonStr = "username-change-too-soon"
+	ErrUserNotFoundStr          = "user-not-found"
+	ErrMFARequiredStr           = "mfa-required"
+	ErrMFATokenInvalidStr       = "mfa-token-invalid"
+	ErrIncorrectCodeStr         = "incorrect-code"
+	ErrTOTPNotEnrolledStr       = "totp-not-enrolled"
+	ErrTOTPAlreadyEnabledStr    = "totp-already-enabled"
+	ErrUnknownProviderStr       = "unknown-provider"
+	ErrProviderUnavailableStr   = "provider-unavailable"
+	ErrOIDCStateInvalidStr      = "oidc-state-invalid"
+	ErrOIDCLoginRejectedStr     = "oidc-login-rejected"
+	ErrOIDCSignupInvalidStr     = "oidc-signup-invalid"
+	ErrReauthRequiredStr        = "reauth-required"
+	ErrIdentityTakenStr         = "identity-taken"
+	ErrMissingPermissionStr     = "missing-permission"
+	ErrInvalidRoleStr           = "invalid-role"
 )
 
+// RefreshCookieName is the cookie of the refresh token, only sent to the
+// auth routes.
+const RefreshCookieName = "refresh_token"
+
+// MFACookieName is the cookie of a login waiting for its second factor.
+const MFACookieName = "mfa_token"
+
+// OIDCStateCookieName ties the callback of a provider to the browser that
+// went to it.
+const OIDCStateCookieName = "oidc_state"
+
+// OIDCSignupCookieName is the cookie of a first login through a provider,
+// waiting for its username.
+const OIDCSignupCookieName = "oidc_signup"
+
+// OIDCReauthCookieName is the cookie of a fresh login with a provider,
+// confirming the next change to an account without a password.
+const OIDCReauthCookieName = "oidc_reauth"
+
 type authHandler struct {
-	authService  AuthService
-	cookieMaxAge time.Duration
+	authService   AuthService
+	cookieMaxAge  time.Duration
+	loginThrottle *LoginThrottle
+	// where the provider callbacks send the player back to
+	frontendURL string
 }
 
 func NewAuthHandler(service AuthService, cookieMaxAge time.Duration) *authHandler {
 	return &authHandler{authService: service, cookieMaxAge: cookieMaxAge}
 }
 
+// SetLoginThrottle slows down repeated failed logins, none when unset.
+func (ah *authHandler) SetLoginThrottle(throttle *LoginThrottle) {
+	ah.loginThrottle = throttle
+}
+
+// SetFrontendURL is where players land after signing in with a provider.
+func (ah *authHandler) SetFrontendURL(frontendURL string) {
+	ah.frontendURL = strings.TrimSuffix(frontendURL, "/")
+}
+
+// throttleLogin answers 429 when the attempt must wait, before any password
+// is hashed. An attempt let through must end in loginFailed, loginCancelled
+// or loginSucceeded.
+func (ah *authHandler) throttleLogin(ctx *gin.Context, username string) bool {
+	if ah.loginThrottle == nil {
+		return false
+	}
+	clientIP := ctx.ClientIP()
+	wait := ah.loginThrottle.Attempt(ctx.Request.Context(), username, clientIP, time.Now())
+	if wait <= 0 {
+		return false
+	}
+
+	slog.Warn("Login: throttled attempt",
+		"ip", clientIP,
+		"user_agent", ctx.Request.UserAgent(),
+		"username", username,
+		"retry_after_s", wait.Seconds(),
+	)
+	seconds := int((wait + time.Second - 1) / time.Second)
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+	ctx.String(http.StatusTooManyRequests, ErrTooManyAttemptsStr)
+	ctx.Abort()
+	return true
+}
+
+// loginFailed counts a wrong password or username against the throttle.
+func (ah *authHandler) loginFailed(ctx *gin.Context, username string) {
+	if ah.loginThrottle == nil {
+		return
+	}
+	clientIP := ctx.ClientIP()
+	usernameLocked, ipLocked := ah.loginThrottle.Fail(ctx.Request.Context(), username, clientIP, time.Now())
+	if usernameLocked || ipLocked {
+		slog.Warn("Login: lockout after repeated failures",
+			"ip", clientIP,
+			"user_agent", ctx.Request.UserAgent(),
+			"username", username,
+			"username_locked", usernameLocked,
+			"ip_locked", ipLocked,
+		)
+	}
+}
+
+// loginCancelled takes back an attempt that neither failed nor succeeded.
+func (ah *authHandler) loginCancelled(ctx *gin.Context, username string) {
+	if ah.loginThrottle == nil {
+		return
+	}
+	ah.loginThrottle.Cancel(ctx.Request.Context(), username, ctx.ClientIP())
+}
+
+// loginSucceeded clears the failures of the username.
+func (ah *authHandler) loginSucceeded(ctx *gin.Context, username string) {
+	if ah.loginThrottle == nil {
+		return
+	}
+	ah.loginThrottle.Succeed(ctx.Request.Context(), username, ctx.ClientIP())
+}
+
+// throttleReauth throttles the password confirming a change to the account
+// like a login of it, by its username and the client IP. The attempt let
+// through ends with reauthEnded.
+func (ah *authHandler) throttleReauth(ctx *gin.Context, op string) (string, bool) {
+	if ah.loginThrottle == nil {
+		return "", false
+	}
+	username, err := ah.authService.Username(ctx.Request.Context(), ctx.GetString("id"))
+	if err != nil {
+		accountFailed(ctx, op, err)
+		return "", true
+	}
+	return username, ah.throttleLogin(ctx, username)
+}
+
+// proof confirms a change with the password or code of the request, or the
+// reauth token of a fresh login with a provider.
+func proof(ctx *gin.Context, password, code string) Proof {
+	reauthToken, _ := ctx.Cookie(OIDCReauthCookieName)
+	return Proof{Password: password, Code: code, ReauthToken: reauthToken}
+}
+
+// reauthEnded settles the attempt of throttleReauth with the result of the
+// change, a reauth token is spent with its success.
+func (ah *authHandler) reauthEnded(ctx *gin.Context, username string, err error) {
+	switch {
+	case err == nil:
+		ah.loginSucceeded(ctx, username)
+		if _, cookieErr := ctx.Cookie(OIDCReauthCookieName); cookieErr == nil {
+			ctx.SetCookie(OIDCReauthCookieName, "", -1, "/auth", "", true, true)
+		}
+	case errors.Is(err, ErrIncorrectPassword), errors.Is(err, ErrIncorrectCode),
+		errors.Is(err, domain.ErrTOTPCodeReused), errors.Is(err, domain.ErrRecoveryCodeUsed):
+		ah.loginFailed(ctx, username)
+	default:
+		ah.loginCancelled(ctx, username)
+	}
+}
+
+// setSession hands the tokens of a session to the client.
+func (ah *authHandler) setSession(ctx *gin.Context, tokens Tokens) {
+	ctx.SetCookie("token", tokens.Access, int(ah.cookieMaxAge.Seconds()), "/", "", true, true)
+	ctx.SetCookie(RefreshCookieName, tokens.Refresh, int(RefreshTokenAge.Seconds()), "/auth", "", true, true)
+	ctx.SetSameSite(http.SameSiteNoneMode)
+}
+
+func (ah *authHandler) clearSession(ctx *gin.Context) {
+	ctx.SetCookie("token", "", -1, "/", "", true, true)
+	ctx.SetCookie(RefreshCookieName, "", -1, "/auth", "", true, true)
+}
+
+// cookieAge is the age of the token cookie of the id, guests' are short lived.
+func (ah *authHandler) cookieAge(id string) int {
+	if domain.IsGuestId(id) {
+		return int(GuestTokenAge.Seconds())
+	}
+	return int(ah.cookieMaxAge.Seconds())
+}
+
 func (ah *authHandler) RequireAuthMiddleware(trollTime time.Duration) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token, err := ctx.Cookie("token")
@@ -69,6 +247,7 @@ func (ah *authHandler) RequireAuthMiddleware(trollTime time.Duration) gin.Handle
 
 			switch {
 			case errors.Is(err, domain.ErrInvalidSigningAlg),
+				errors.Is(err, domain.ErrUnknownSigningKey),
 				errors.Is(err, domain.ErrInvalidTokenSignature),
 				errors.Is(err, domain.ErrCorruptedToken):
 
@@ -107,6 +286,47 @@ func (ah *authHandler) RequireAuthMiddleware(trollTime time.Duration) gin.Handle
 	}
 }
 
+// RequireAccountMiddleware turns guests away from what needs a user row, it
+// goes after RequireAuthMiddleware.
+func (ah *authHandler) RequireAccountMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if domain.IsGuestId(ctx.GetString("id")) {
+			ctx.String(http.StatusForbidden, ErrGuestNotAllowedStr)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequirePermission lets through users whose role has every permission, it
+// goes after RequireAuthMiddleware and puts the role in the context.
+func (ah *authHandler) RequirePermission(permissions ...domain.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId := ctx.GetString("id")
+		role, err := ah.authService.Role(ctx.Request.Context(), userId)
+		if err != nil {
+			accountFailed(ctx, "RequirePermission", err)
+			return
+		}
+
+		if !role.Can(permissions...) {
+			slog.Warn("RequirePermission: denied",
+				"ip", ctx.ClientIP(),
+				"user_id", userId,
+				"role", role,
+				"permissions", permissions,
+				"path", ctx.FullPath(),
+			)
+			ctx.String(http.StatusForbidden, ErrMissingPermissionStr)
+			ctx.Abort()
+			return
+		}
+		ctx.Set("role", role)
+		ctx.Next()
+	}
+}
+
 func (ah *authHandler) LoginHandler(ctx *gin.Context) {
 	var loginCredentials struct {
 		Username string `json:"username"`
@@ -121,13 +341,22 @@ func (ah *authHandler) LoginHandler(ctx *gin.Context) {
 		return
 	}
 
+	if ah.throttleLogin(ctx, loginCredentials.Username) {
+		return
+	}
+
 	reqCtx := ctx.Request.Context()
 
-	token, err := ah.authService.Login(reqCtx, loginCredentials.Username, loginCredentials.Password)
+	tokens, err := ah.authService.Login(reqCtx, loginCredentials.Username, loginCredentials.Password)
 
 	if err != nil {
 		clientIP := ctx.ClientIP()
 		userAgent := ctx.Request.UserAgent()
+		if errors.Is(err, ErrIncorrectPassword) || errors.Is(err, domain.ErrUserNotFound) {
+			ah.loginFailed(ctx, loginCredentials.Username)
+		} else {
+			ah.loginCancelled(ctx, loginCredentials.Username)
+		}
 		switch {
 		case errors.Is(err, ErrIncorrectPassword), errors.Is(err, domain.ErrUserNotFound):
 			ctx.String(http.StatusUnauthorized, ErrInvalidCredentialsStr)
@@ -138,6 +367,9 @@ func (ah *authHandler) LoginHandler(ctx *gin.Context) {
 		case errors.Is(err, context.Canceled):
 			ctx.Status(499)
 			ctx.Abort()
+		case errors.Is(err, domain.ErrServerBusy):
+			serverBusy(ctx, "Login", loginCredentials.Username)
+			ctx.Abort()
 
 		case errors.Is(err, domain.UnexpectedDatabaseError):
 			slog.Error("Login: Database returned an unexpected error",
@@ -191,8 +423,67 @@ func (ah *authHandler) LoginHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.SetCookie("token", token, int(ah.cookieMaxAge.Seconds()), "/", "", true, true)
-	ctx.SetSameSite(http.SameSiteNoneMode)
+	if tokens.MFA != "" {
+		// the username throttle stays until the code passes too
+		ah.loginCancelled(ctx, loginCredentials.Username)
+		ctx.SetSameSite(http.SameSiteNoneMode)
+		ctx.SetCookie(MFACookieName, tokens.MFA, int(MFAPendingAge.Seconds()), "/auth", "", true, true)
+		ctx.String(http.StatusAccepted, ErrMFARequiredStr)
+		return
+	}
+
+	ah.loginSucceeded(ctx, loginCredentials.Username)
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusOK)
+}
+
+// LoginMFAHandler is the second step of a login with 2FA, the code goes with
+// the MFA cookie of the first.
+func (ah *authHandler) LoginMFAHandler(ctx *gin.Context) {
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	mfaToken, err := ctx.Cookie(MFACookieName)
+	if err != nil || mfaToken == "" {
+		ctx.String(http.StatusUnauthorized, ErrMFATokenInvalidStr)
+		ctx.Abort()
+		return
+	}
+
+	// codes are guessed per IP, the username isn't known here
+	if ah.throttleLogin(ctx, "") {
+		return
+	}
+
+	tokens, err := ah.authService.CompleteMFA(ctx.Request.Context(), mfaToken, request.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrMFATokenInvalid):
+			ah.loginCancelled(ctx, "")
+			ctx.SetCookie(MFACookieName, "", -1, "/auth", "", true, true)
+			ctx.String(http.StatusUnauthorized, ErrMFATokenInvalidStr)
+			ctx.Abort()
+		case errors.Is(err, ErrIncorrectCode), errors.Is(err, domain.ErrTOTPCodeReused), errors.Is(err, domain.ErrRecoveryCodeUsed):
+			ah.loginFailed(ctx, "")
+			ctx.String(http.StatusUnauthorized, ErrIncorrectCodeStr)
+			ctx.Abort()
+		default:
+			ah.loginCancelled(ctx, "")
+			accountFailed(ctx, "LoginMFA", err)
+		}
+		return
+	}
+
+	ah.loginSucceeded(ctx, "")
+	ctx.SetCookie(MFACookieName, "", -1, "/auth", "", true, true)
+	ah.setSession(ctx, tokens)
 	ctx.Status(http.StatusOK)
 }
 
@@ -212,87 +503,154 @@ func (ah *authHandler) SignupHandler(ctx *gin.Context) {
 
 	reqCtx := ctx.Request.Context()
 
-	token, err := ah.authService.Signup(reqCtx, signupCredentials.Username, signupCredentials.Password)
+	tokens, err := ah.authService.Signup(reqCtx, signupCredentials.Username, signupCredentials.Password)
 
 	if err != nil {
-		clientIP := ctx.ClientIP()
-		userAgent := ctx.Request.UserAgent()
+		signupFailed(ctx, "Signup", err, signupCredentials.Username, signupCredentials.Password)
+		return
+	}
 
-		switch {
-		case errors.Is(err, domain.ErrDuplicateUsername):
-			ctx.String(http.StatusConflict, ErrUsernameAlreadyExistsStr)
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusCreated)
+}
 
-		case errors.Is(err, ErrWeakPassword):
-			ctx.String(http.StatusBadRequest, ErrWeakPasswordStr)
+// serverBusy answers a request that waited too long for password hashing.
+func serverBusy(ctx *gin.Context, op, username string) {
+	slog.Warn(op+": password hashing queue is full",
+		"ip", ctx.ClientIP(),
+		"user_agent", ctx.Request.UserAgent(),
+		"username", username,
+	)
+	ctx.Header("Retry-After", "1")
+	ctx.String(http.StatusServiceUnavailable, ErrServerBusyStr)
+}
 
-		case errors.Is(err, ErrPasswordTooLong):
-			ctx.String(http.StatusBadRequest, ErrPasswordTooLongStr)
+// signupFailed answers a signup that failed, op names the handler in logs.
+func signupFailed(ctx *gin.Context, op string, err error, username, password string) {
+	clientIP := ctx.ClientIP()
+	userAgent := ctx.Request.UserAgent()
 
-		case errors.Is(err, ErrInvalidUsernameFormat):
-			ctx.String(http.StatusBadRequest, ErrInvalidUsernameFormatStr)
+	switch {
+	case errors.Is(err, domain.ErrDuplicateUsername):
+		ctx.String(http.StatusConflict, ErrUsernameAlreadyExistsStr)
 
-		case errors.Is(err, context.DeadlineExceeded):
-			ctx.String(http.StatusGatewayTimeout, ErrServerTimeoutStr)
+	case errors.Is(err, ErrWeakPassword):
+		ctx.String(http.StatusBadRequest, ErrWeakPasswordStr)
 
-		case errors.Is(err, context.Canceled):
-			ctx.Status(499)
+	case errors.Is(err, ErrPasswordTooLong):
+		ctx.String(http.StatusBadRequest, ErrPasswordTooLongStr)
 
-		case errors.Is(err, domain.UnexpectedDatabaseError):
-			slog.Error("Signup: Database returned an unexpected error",
-				"error", err.Error(),
-				"ip", clientIP,
-				"user_agent", userAgent,
-				"username", signupCredentials.Username,
-			)
-			ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+	case errors.Is(err, ErrInvalidUsernameFormat):
+		ctx.String(http.StatusBadRequest, ErrInvalidUsernameFormatStr)
 
-		case errors.Is(err, domain.UnexpectedPasswordHashingError):
-			var mem runtime.MemStats
-			runtime.ReadMemStats(&mem)
-			slog.Error("Signup: Password hashing error",
-				"error", err.Error(),
-				"ip", clientIP,
-				"user_agent", userAgent,
-				"username", signupCredentials.Username,
-				"password_len", utf8.RuneCountInString(signupCredentials.Password),
-				"mem_alloc_mb", (mem.Alloc/1024)/1024,
-				"mem_sys_mb", (mem.Sys/1024)/1024,
-			)
-			ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+	case errors.Is(err, ErrReservedUsername):
+		ctx.String(http.StatusBadRequest, ErrReservedUsernameStr)
 
-		case errors.Is(err, domain.UnexpectedTokenGenerationError):
-			slog.Error("Signup: Token generation error",
-				"error", err.Error(),
+	case errors.Is(err, context.DeadlineExceeded):
+		ctx.String(http.StatusGatewayTimeout, ErrServerTimeoutStr)
+
+	case errors.Is(err, context.Canceled):
+		ctx.Status(499)
+
+	case errors.Is(err, domain.ErrServerBusy):
+		serverBusy(ctx, op, username)
+
+	case errors.Is(err, domain.UnexpectedDatabaseError):
+		slog.Error(op+": Database returned an unexpected error",
+			"error", err.Error(),
+			"ip", clientIP,
+			"user_agent", userAgent,
+			"username", username,
+		)
+		ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+
+	case errors.Is(err, domain.UnexpectedPasswordHashingError):
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		slog.Error(op+": Password hashing error",
+			"error", err.Error(),
+			"ip", clientIP,
+			"user_agent", userAgent,
+			"username", username,
+			"password_len", utf8.RuneCountInString(password),
+			"mem_alloc_mb", (mem.Alloc/1024)/1024,
+			"mem_sys_mb", (mem.Sys/1024)/1024,
+		)
+		ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+
+	case errors.Is(err, domain.UnexpectedTokenGenerationError):
+		slog.Error(op+": Token generation error",
+			"error", err.Error(),
+			"ip", clientIP,
+			"user_agent", userAgent,
+			"username", username,
+		)
+		ctx.String(http.StatusInternalServerError, ErrAccountCreatedButNoToken)
+
+	default:
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		slog.Error(op+": Unknown unexpected error",
+			"error", err.Error(),
+			"ip", clientIP,
+			"user_agent", userAgent,
+			"username", username,
+			"password_len", utf8.RuneCountInString(password),
+			"mem_alloc_mb", (mem.Alloc/1024)/1024,
+			"mem_sys_mb", (mem.Sys/1024)/1024,
+		)
+		ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+	}
+	ctx.Abort()
+}
+
+// RefreshSessionHandler rotates the refresh token of the session. Guests have
+// none, their access token is re-signed instead.
+func (ah *authHandler) RefreshSessionHandler(ctx *gin.Context) {
+	refreshToken, err := ctx.Cookie(RefreshCookieName)
+	if err != nil {
+		ah.refreshGuest(ctx)
+		return
+	}
+
+	tokens, err := ah.authService.Refresh(ctx.Request.Context(), refreshToken)
+	if err != nil {
+		clientIP := ctx.ClientIP()
+		userAgent := ctx.Request.UserAgent()
+		switch {
+		case errors.Is(err, domain.ErrRefreshTokenReused):
+			slog.Warn("Refresh: Spent refresh token reused, its session was revoked",
 				"ip", clientIP,
 				"user_agent", userAgent,
-				"username", signupCredentials.Username,
 			)
-			ctx.String(http.StatusInternalServerError, ErrAccountCreatedButNoToken)
-
+			ah.clearSession(ctx)
+			ctx.String(http.StatusUnauthorized, ErrBadTokenStr)
+		case errors.Is(err, domain.ErrRefreshTokenNotFound):
+			ah.clearSession(ctx)
+			ctx.String(http.StatusUnauthorized, ErrBadTokenStr)
+		case errors.Is(err, domain.ErrExpiredToken):
+			ah.clearSession(ctx)
+			ctx.String(http.StatusUnauthorized, ErrExpiredTokenStr)
+		case errors.Is(err, context.DeadlineExceeded):
+			ctx.String(http.StatusGatewayTimeout, ErrServerTimeoutStr)
+		case errors.Is(err, context.Canceled):
+			ctx.Status(499)
 		default:
-			var mem runtime.MemStats
-			runtime.ReadMemStats(&mem)
-			slog.Error("Signup: Unknown unexpected error",
-				"error", err.Error(),
+			slog.Error("Refresh: Failed to rotate the refresh token",
 				"ip", clientIP,
 				"user_agent", userAgent,
-				"username", signupCredentials.Username,
-				"password_len", utf8.RuneCountInString(signupCredentials.Password),
-				"mem_alloc_mb", (mem.Alloc/1024)/1024,
-				"mem_sys_mb", (mem.Sys/1024)/1024,
+				"error", err.Error(),
 			)
-			ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+			ctx.Status(http.StatusInternalServerError)
 		}
-		ctx.Abort()
 		return
 	}
 
-	ctx.SetCookie("token", token, int(ah.cookieMaxAge.Seconds()), "/", "", true, true)
-	ctx.SetSameSite(http.SameSiteNoneMode)
-	ctx.Status(http.StatusCreated)
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusOK)
 }
 
-func (ah *authHandler) RefreshSessionHandler(ctx *gin.Context) {
+func (ah *authHandler) refreshGuest(ctx *gin.Context) {
 	token, err := ctx.Cookie("token")
 	if err != nil {
 		ctx.String(http.StatusUnauthorized, "unauthenticated")
@@ -324,7 +682,13 @@ func (ah *authHandler) RefreshSessionHandler(ctx *gin.Context) {
 			"error", err.Error(),
 			"token", redactedToken,
 		)
-		ctx.String(http.StatusUnauthorized, "bad-token")
+		ctx.String(http.StatusUnauthorized, ErrBadTokenStr)
+		return
+	}
+
+	// accounts only refresh with their refresh token
+	if !domain.IsGuestId(id) {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
 		return
 	}
 
@@ -342,11 +706,530 @@ func (ah *authHandler) RefreshSessionHandler(ctx *gin.Context) {
 		return
 	}
 
-	ctx.SetCookie("token", newToken, int(ah.cookieMaxAge.Seconds()), "/", "", true, true)
+	ctx.SetCookie("token", newToken, ah.cookieAge(id), "/", "", true, true)
 	ctx.SetSameSite(http.SameSiteNoneMode)
 	ctx.Status(http.StatusOK)
 }
 
+// GuestHandler starts a guest session and answers with its nickname.
+func (ah *authHandler) GuestHandler(ctx *gin.Context) {
+	token, nickname, err := ah.authService.Guest(ctx.Request.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			ctx.String(http.StatusGatewayTimeout, ErrServerTimeoutStr)
+		case errors.Is(err, context.Canceled):
+			ctx.Status(499)
+		default:
+			slog.Error("Guest: Failed to start a guest session",
+				"error", err.Error(),
+				"ip", ctx.ClientIP(),
+				"user_agent", ctx.Request.UserAgent(),
+			)
+			ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+		}
+		ctx.Abort()
+		return
+	}
+
+	ctx.SetCookie("token", token, int(GuestTokenAge.Seconds()), "/", "", true, true)
+	ctx.SetSameSite(http.SameSiteNoneMode)
+	ctx.JSON(http.StatusCreated, gin.H{"nickname": nickname})
+}
+
+// UpgradeHandler turns the guest of the session into an account, it goes
+// after RequireAuthMiddleware. Leaving the username out keeps the nickname.
+func (ah *authHandler) UpgradeHandler(ctx *gin.Context) {
+	var upgradeCredentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	err := ctx.ShouldBindJSON(&upgradeCredentials)
+
+	if err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	tokens, err := ah.authService.Upgrade(ctx.Request.Context(), ctx.GetString("id"), upgradeCredentials.Username, upgradeCredentials.Password)
+
+	if err != nil {
+		if errors.Is(err, ErrNotGuest) {
+			ctx.String(http.StatusConflict, ErrNotGuestStr)
+			ctx.Abort()
+			return
+		}
+		signupFailed(ctx, "Upgrade", err, upgradeCredentials.Username, upgradeCredentials.Password)
+		return
+	}
+
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusCreated)
+}
+
+func (ah *authHandler) ChangePasswordHandler(ctx *gin.Context) {
+	var passwords struct {
+		CurrentPassword string `json:"current_password"`
+		// for accounts without a password
+		Code        string `json:"code"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := ctx.ShouldBindJSON(&passwords); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	username, throttled := ah.throttleReauth(ctx, "ChangePassword")
+	if throttled {
+		return
+	}
+
+	tokens, err := ah.authService.ChangePassword(ctx.Request.Context(), ctx.GetString("id"), proof(ctx, passwords.CurrentPassword, passwords.Code), passwords.NewPassword)
+	ah.reauthEnded(ctx, username, err)
+	if err != nil {
+		accountFailed(ctx, "ChangePassword", err)
+		return
+	}
+
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusOK)
+}
+
+func (ah *authHandler) ChangeUsernameHandler(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	if err := ah.authService.ChangeUsername(ctx.Request.Context(), ctx.GetString("id"), request.Username); err != nil {
+		accountFailed(ctx, "ChangeUsername", err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (ah *authHandler) DeleteAccountHandler(ctx *gin.Context) {
+	var request struct {
+		Password string `json:"password"`
+		// for accounts without a password
+		Code string `json:"code"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	username, throttled := ah.throttleReauth(ctx, "DeleteAccount")
+	if throttled {
+		return
+	}
+
+	err := ah.authService.DeleteAccount(ctx.Request.Context(), ctx.GetString("id"), proof(ctx, request.Password, request.Code))
+	ah.reauthEnded(ctx, username, err)
+	if err != nil {
+		accountFailed(ctx, "DeleteAccount", err)
+		return
+	}
+	ah.clearSession(ctx)
+	ctx.Status(http.StatusOK)
+}
+
+// EnrollTOTPHandler answers the secret to add to an authenticator app, 2FA
+// is on once a code of it is confirmed.
+func (ah *authHandler) EnrollTOTPHandler(ctx *gin.Context) {
+	secret, uri, err := ah.authService.EnrollTOTP(ctx.Request.Context(), ctx.GetString("id"))
+	if err != nil {
+		accountFailed(ctx, "EnrollTOTP", err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"secret": secret, "uri": uri})
+}
+
+func (ah *authHandler) ConfirmTOTPHandler(ctx *gin.Context) {
+	var request struct {
+		Code string `json:"code"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	recoveryCodes, err := ah.authService.ConfirmTOTP(ctx.Request.Context(), ctx.GetString("id"), request.Code)
+	if err != nil {
+		accountFailed(ctx, "ConfirmTOTP", err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+func (ah *authHandler) DisableTOTPHandler(ctx *gin.Context) {
+	var request struct {
+		Password string `json:"password"`
+		// for accounts without a password
+		Code string `json:"code"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	username, throttled := ah.throttleReauth(ctx, "DisableTOTP")
+	if throttled {
+		return
+	}
+
+	err := ah.authService.DisableTOTP(ctx.Request.Context(), ctx.GetString("id"), proof(ctx, request.Password, request.Code))
+	ah.reauthEnded(ctx, username, err)
+	if err != nil {
+		accountFailed(ctx, "DisableTOTP", err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// SetRoleHandler gives the user of the username param a role, admins only.
+func (ah *authHandler) SetRoleHandler(ctx *gin.Context) {
+	var request struct {
+		Role domain.Role `json:"role"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	username := ctx.Param("username")
+	if err := ah.authService.SetRole(ctx.Request.Context(), username, request.Role); err != nil {
+		if errors.Is(err, domain.ErrInvalidRole) {
+			ctx.String(http.StatusBadRequest, ErrInvalidRoleStr)
+			ctx.Abort()
+			return
+		}
+		accountFailed(ctx, "SetRole", err)
+		return
+	}
+
+	slog.Info("SetRole: role given",
+		"by_user_id", ctx.GetString("id"),
+		"username", username,
+		"role", request.Role,
+	)
+	ctx.Status(http.StatusOK)
+}
+
+// accountFailed answers a change to an account that failed, op names the
+// handler in logs.
+func accountFailed(ctx *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, ErrIncorrectPassword):
+		ctx.String(http.StatusForbidden, ErrIncorrectPasswordStr)
+
+	case errors.Is(err, ErrReauthRequired):
+		ctx.String(http.StatusForbidden, ErrReauthRequiredStr)
+
+	case errors.Is(err, ErrUnknownProvider):
+		ctx.String(http.StatusNotFound, ErrUnknownProviderStr)
+
+	case errors.Is(err, ErrProviderUnavailable):
+		ctx.String(http.StatusBadGateway, ErrProviderUnavailableStr)
+
+	case errors.Is(err, domain.ErrUserNotFound):
+		ctx.String(http.StatusNotFound, ErrUserNotFoundStr)
+
+	case errors.Is(err, domain.ErrDuplicateUsername):
+		ctx.String(http.StatusConflict, ErrUsernameAlreadyExistsStr)
+
+	case errors.Is(err, domain.ErrUsernameChangeTooSoon):
+		ctx.String(http.StatusTooManyRequests, ErrUsernameChangeTooSoonStr)
+
+	case errors.Is(err, ErrIncorrectCode):
+		ctx.String(http.StatusForbidden, ErrIncorrectCodeStr)
+
+	case errors.Is(err, domain.ErrTOTPNotEnrolled):
+		ctx.String(http.StatusConflict, ErrTOTPNotEnrolledStr)
+
+	case errors.Is(err, domain.ErrTOTPAlreadyEnabled):
+		ctx.String(http.StatusConflict, ErrTOTPAlreadyEnabledStr)
+
+	case errors.Is(err, ErrWeakPassword):
+		ctx.String(http.StatusBadRequest, ErrWeakPasswordStr)
+
+	case errors.Is(err, ErrPasswordTooLong):
+		ctx.String(http.StatusBadRequest, ErrPasswordTooLongStr)
+
+	case errors.Is(err, ErrInvalidUsernameFormat):
+		ctx.String(http.StatusBadRequest, ErrInvalidUsernameFormatStr)
+
+	case errors.Is(err, ErrReservedUsername):
+		ctx.String(http.StatusBadRequest, ErrReservedUsernameStr)
+
+	case errors.Is(err, context.DeadlineExceeded):
+		ctx.String(http.StatusGatewayTimeout, ErrServerTimeoutStr)
+
+	case errors.Is(err, context.Canceled):
+		ctx.Status(499)
+
+	case errors.Is(err, domain.ErrServerBusy):
+		serverBusy(ctx, op, "")
+
+	default:
+		slog.Error(op+": Unexpected error",
+			"error", err.Error(),
+			"ip", ctx.ClientIP(),
+			"user_agent", ctx.Request.UserAgent(),
+			"user_id", ctx.GetString("id"),
+		)
+		ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+	}
+	ctx.Abort()
+}
+
+// OIDCProvidersHandler lists the providers players can sign in with.
+func (ah *authHandler) OIDCProvidersHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"providers": ah.authService.OIDCProviders()})
+}
+
+// StartOIDCHandler sends the player to the provider. It is navigated to, not
+// fetched, so it's registered before the origin check.
+func (ah *authHandler) StartOIDCHandler(ctx *gin.Context) {
+	authURL, state, err := ah.authService.StartOIDC(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		ah.oidcFailed(ctx, "StartOIDC", err)
+		return
+	}
+
+	// Lax, the callback is a navigation from the provider's site
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(OIDCStateCookieName, state, int(OIDCFlowAge.Seconds()), "/auth", "", true, true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// StartOIDCLinkHandler answers the URL of the provider a signed in user goes
+// to, to sign in with it from then on.
+func (ah *authHandler) StartOIDCLinkHandler(ctx *gin.Context) {
+	var request struct {
+		Password string `json:"password"`
+		// for accounts without a password
+		Code string `json:"code"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	username, throttled := ah.throttleReauth(ctx, "StartOIDCLink")
+	if throttled {
+		return
+	}
+
+	authURL, state, err := ah.authService.StartOIDCLink(ctx.Request.Context(), ctx.Param("provider"), ctx.GetString("id"), proof(ctx, request.Password, request.Code))
+	ah.reauthEnded(ctx, username, err)
+	if err != nil {
+		accountFailed(ctx, "StartOIDCLink", err)
+		return
+	}
+	oidcStarted(ctx, authURL, state)
+}
+
+// StartOIDCReauthHandler answers the URL of the provider an account without
+// a password signs in with again, to confirm a change.
+func (ah *authHandler) StartOIDCReauthHandler(ctx *gin.Context) {
+	authURL, state, err := ah.authService.StartOIDCReauth(ctx.Request.Context(), ctx.Param("provider"), ctx.GetString("id"))
+	if err != nil {
+		accountFailed(ctx, "StartOIDCReauth", err)
+		return
+	}
+	oidcStarted(ctx, authURL, state)
+}
+
+// oidcStarted answers a flow started by a fetch, the frontend navigates to
+// the URL itself.
+func oidcStarted(ctx *gin.Context, authURL, state string) {
+	// None, it is set by a fetch from the frontend's origin
+	ctx.SetSameSite(http.SameSiteNoneMode)
+	ctx.SetCookie(OIDCStateCookieName, state, int(OIDCFlowAge.Seconds()), "/auth", "", true, true)
+	ctx.JSON(http.StatusOK, gin.H{"url": authURL})
+}
+
+// OIDCCallbackHandler is where the provider sends the player back. A linked
+// identity lands signed in, or on the page for its code with 2FA on, a new
+// one on the page to pick its username. Links and reauths go back home.
+func (ah *authHandler) OIDCCallbackHandler(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+	state := ctx.Query("state")
+	cookieState, _ := ctx.Cookie(OIDCStateCookieName)
+	ctx.SetCookie(OIDCStateCookieName, "", -1, "/auth", "", true, true)
+
+	// a state another browser started is a login forced on this one
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		ah.oidcFailed(ctx, "OIDCCallback", ErrOIDCStateInvalid)
+		return
+	}
+	if ctx.Query("error") != "" {
+		ah.oidcFailed(ctx, "OIDCCallback", fmt.Errorf("%w: %s", ErrOIDCLoginRejected, ctx.Query("error")))
+		return
+	}
+
+	result, err := ah.authService.FinishOIDC(ctx.Request.Context(), provider, state, ctx.Query("code"))
+	if err != nil {
+		ah.oidcFailed(ctx, "OIDCCallback", err)
+		return
+	}
+
+	// None, the frontend posts the username, code or change from its own
+	// origin
+	ctx.SetSameSite(http.SameSiteNoneMode)
+	switch {
+	case result.SignupToken != "":
+		ctx.SetCookie(OIDCSignupCookieName, result.SignupToken, int(OIDCSignupAge.Seconds()), "/auth", "", true, true)
+		ctx.Redirect(http.StatusFound, ah.frontendURL+"/oidc-signup?"+url.Values{"username": {result.Username}}.Encode())
+	case result.Tokens.MFA != "":
+		ctx.SetCookie(MFACookieName, result.Tokens.MFA, int(MFAPendingAge.Seconds()), "/auth", "", true, true)
+		ctx.Redirect(http.StatusFound, ah.frontendURL+"/login?"+url.Values{"mfa": {"required"}}.Encode())
+	case result.Linked:
+		ctx.Redirect(http.StatusFound, ah.frontendURL+"/?"+url.Values{"linked": {provider}}.Encode())
+	case result.ReauthToken != "":
+		ctx.SetCookie(OIDCReauthCookieName, result.ReauthToken, int(OIDCReauthAge.Seconds()), "/auth", "", true, true)
+		ctx.Redirect(http.StatusFound, ah.frontendURL+"/?"+url.Values{"reauth": {provider}}.Encode())
+	default:
+		ah.setSession(ctx, result.Tokens)
+		ctx.Redirect(http.StatusFound, ah.frontendURL+"/")
+	}
+}
+
+// OIDCSignupHandler creates the account of a first login through a provider
+// under the username picked.
+func (ah *authHandler) OIDCSignupHandler(ctx *gin.Context) {
+	var request struct {
+		Username string `json:"username"`
+	}
+
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidRequestFormatStr)
+		ctx.Abort()
+		return
+	}
+
+	signupToken, err := ctx.Cookie(OIDCSignupCookieName)
+	if err != nil || signupToken == "" {
+		ctx.String(http.StatusUnauthorized, ErrOIDCSignupInvalidStr)
+		ctx.Abort()
+		return
+	}
+
+	tokens, err := ah.authService.CompleteOIDCSignup(ctx.Request.Context(), signupToken, request.Username)
+	if err != nil {
+		if errors.Is(err, ErrOIDCSignupInvalid) {
+			ctx.SetCookie(OIDCSignupCookieName, "", -1, "/auth", "", true, true)
+			ctx.String(http.StatusUnauthorized, ErrOIDCSignupInvalidStr)
+			ctx.Abort()
+			return
+		}
+		accountFailed(ctx, "OIDCSignup", err)
+		return
+	}
+
+	ctx.SetCookie(OIDCSignupCookieName, "", -1, "/auth", "", true, true)
+	ah.setSession(ctx, tokens)
+	ctx.Status(http.StatusCreated)
+}
+
+// oidcFailed sends the player back to the login page with the reason, the
+// routes of the flow are navigations.
+func (ah *authHandler) oidcFailed(ctx *gin.Context, op string, err error) {
+	reason := ErrUnknownStr
+	switch {
+	case errors.Is(err, ErrUnknownProvider):
+		reason = ErrUnknownProviderStr
+	case errors.Is(err, ErrOIDCStateInvalid):
+		reason = ErrOIDCStateInvalidStr
+	case errors.Is(err, ErrOIDCLoginRejected):
+		reason = ErrOIDCLoginRejectedStr
+	case errors.Is(err, domain.ErrIdentityTaken):
+		reason = ErrIdentityTakenStr
+	case errors.Is(err, ErrProviderUnavailable):
+		reason = ErrProviderUnavailableStr
+	case errors.Is(err, context.DeadlineExceeded):
+		reason = ErrServerTimeoutStr
+	}
+
+	log := slog.Warn
+	if reason == ErrUnknownStr || reason == ErrProviderUnavailableStr {
+		log = slog.Error
+	}
+	log(op+": Sign in through provider failed",
+		"error", err.Error(),
+		"provider", ctx.Param("provider"),
+		"ip", ctx.ClientIP(),
+		"user_agent", ctx.Request.UserAgent(),
+	)
+	ctx.Redirect(http.StatusFound, ah.frontendURL+"/login?"+url.Values{"error": {reason}}.Encode())
+	ctx.Abort()
+}
+
+// JWKSHandler serves the public keys for other services to verify our tokens
+// with. Caches may keep them a while, keys are retired long after they stop
+// signing.
+func JWKSHandler(publisher KeyPublisher) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		jwks, err := publisher.PublicJWKS()
+		if err != nil {
+			slog.Error("JWKS: Failed to encode the public keys", "error", err.Error())
+			ctx.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx.Header("Cache-Control", "public, max-age=300")
+		ctx.Data(http.StatusOK, "application/jwk-set+json", jwks)
+	}
+}
+
+// LogoutHandler revokes the session and clears its cookies, whether the
+// revocation worked or not.
 func (ah *authHandler) LogoutHandler(ctx *gin.Context) {
-	ctx.SetCookie("token", "", -1, "/", "", true, true)
+	if refreshToken, err := ctx.Cookie(RefreshCookieName); err == nil {
+		if err := ah.authService.Logout(ctx.Request.Context(), refreshToken); err != nil && !errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			slog.Error("Logout: Failed to revoke the session",
+				"ip", ctx.ClientIP(),
+				"user_agent", ctx.Request.UserAgent(),
+				"error", err.Error(),
+			)
+		}
+	}
+	ah.clearSession(ctx)
+}
+
+// LogoutEverywhereHandler revokes every session of the user, it goes after
+// RequireAuthMiddleware.
+func (ah *authHandler) LogoutEverywhereHandler(ctx *gin.Context) {
+	id := ctx.GetString("id")
+	if err := ah.authService.LogoutEverywhere(ctx.Request.Context(), id); err != nil {
+		slog.Error("LogoutEverywhere: Failed to revoke the sessions",
+			"ip", ctx.ClientIP(),
+			"user_agent", ctx.Request.UserAgent(),
+			"error", err.Error(),
+			"user_id", id,
+		)
+		ctx.String(http.StatusInternalServerError, ErrUnknownStr)
+		return
+	}
+	ah.clearSession(ctx)
+	ctx.Status(http.StatusOK)
 }
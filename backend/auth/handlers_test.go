@@ -22,14 +22,29 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Signup(ctx context.Context, username, password string) (string, error) {
+func (m *MockAuthService) Signup(ctx context.Context, username, password string) (auth.Tokens, error) {
 	args := m.Called(ctx, username, password)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(auth.Tokens), args.Error(1)
 }
 
-func (m *MockAuthService) Login(ctx context.Context, username, password string) (string, error) {
+func (m *MockAuthService) Login(ctx context.Context, username, password string) (auth.Tokens, error) {
 	args := m.Called(ctx, username, password)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string) (auth.Tokens, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, refreshToken string) error {
+	args := m.Called(ctx, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) LogoutEverywhere(ctx context.Context, userId string) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
 }
 
 func (m *MockAuthService) VerifyToken(token string) (string, error) {
@@ -42,6 +57,97 @@ func (m *MockAuthService) GenerateToken(id string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockAuthService) Guest(ctx context.Context) (string, string, error) {
+	args := m.Called(ctx)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) Upgrade(ctx context.Context, guestId, username, password string) (auth.Tokens, error) {
+	args := m.Called(ctx, guestId, username, password)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userId string, proof auth.Proof, newPassword string) (auth.Tokens, error) {
+	args := m.Called(ctx, userId, proof, newPassword)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
+func (m *MockAuthService) Username(ctx context.Context, userId string) (string, error) {
+	args := m.Called(ctx, userId)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthService) ChangeUsername(ctx context.Context, userId, username string) error {
+	args := m.Called(ctx, userId, username)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) DeleteAccount(ctx context.Context, userId string, proof auth.Proof) error {
+	args := m.Called(ctx, userId, proof)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) CompleteMFA(ctx context.Context, mfaToken, code string) (auth.Tokens, error) {
+	args := m.Called(ctx, mfaToken, code)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
+func (m *MockAuthService) EnrollTOTP(ctx context.Context, userId string) (string, string, error) {
+	args := m.Called(ctx, userId)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) ConfirmTOTP(ctx context.Context, userId, code string) ([]string, error) {
+	args := m.Called(ctx, userId, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockAuthService) DisableTOTP(ctx context.Context, userId string, proof auth.Proof) error {
+	args := m.Called(ctx, userId, proof)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) Role(ctx context.Context, userId string) (domain.Role, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(domain.Role), args.Error(1)
+}
+
+func (m *MockAuthService) SetRole(ctx context.Context, username string, role domain.Role) error {
+	args := m.Called(ctx, username, role)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) OIDCProviders() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *MockAuthService) StartOIDC(ctx context.Context, provider string) (string, string, error) {
+	args := m.Called(ctx, provider)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) StartOIDCLink(ctx context.Context, provider, userId string, proof auth.Proof) (string, string, error) {
+	args := m.Called(ctx, provider, userId, proof)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) StartOIDCReauth(ctx context.Context, provider, userId string) (string, string, error) {
+	args := m.Called(ctx, provider, userId)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthService) FinishOIDC(ctx context.Context, provider, state, code string) (auth.OIDCResult, error) {
+	args := m.Called(ctx, provider, state, code)
+	return args.Get(0).(auth.OIDCResult), args.Error(1)
+}
+
+func (m *MockAuthService) CompleteOIDCSignup(ctx context.Context, signupToken, username string) (auth.Tokens, error) {
+	args := m.Called(ctx, signupToken, username)
+	return args.Get(0).(auth.Tokens), args.Error(1)
+}
+
 func TestSignupHandler(t *testing.T) {
 	t.Parallel()
 
@@ -64,7 +170,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "normal success",
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "pass1234").Return("tokenhaha", nil)
+				m.On("Signup", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{Access: "tokenhaha", Refresh: "refresh"}, nil)
 			},
 			expectedCode:  http.StatusCreated,
 			expectedBody:  "",
@@ -74,7 +180,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "username already exists",
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "pass1234").Return("", domain.ErrDuplicateUsername)
+				m.On("Signup", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{}, domain.ErrDuplicateUsername)
 			},
 			expectedCode:  http.StatusConflict,
 			expectedBody:  auth.ErrUsernameAlreadyExistsStr,
@@ -84,7 +190,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "weak password",
 			body:        `{"username":"oussama", "password":"123"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "123").Return("", auth.ErrWeakPassword)
+				m.On("Signup", mock.Anything, "oussama", "123").Return(auth.Tokens{}, auth.ErrWeakPassword)
 			},
 			expectedCode:  http.StatusBadRequest,
 			expectedBody:  auth.ErrWeakPasswordStr,
@@ -94,7 +200,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "password too long",
 			body:        `{"username":"oussama", "password":"longpass"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "longpass").Return("", auth.ErrPasswordTooLong)
+				m.On("Signup", mock.Anything, "oussama", "longpass").Return(auth.Tokens{}, auth.ErrPasswordTooLong)
 			},
 			expectedCode:  http.StatusBadRequest,
 			expectedBody:  auth.ErrPasswordTooLongStr,
@@ -104,7 +210,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "invalid username format",
 			body:        `{"username":"bad format", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "bad format", "pass1234").Return("", auth.ErrInvalidUsernameFormat)
+				m.On("Signup", mock.Anything, "bad format", "pass1234").Return(auth.Tokens{}, auth.ErrInvalidUsernameFormat)
 			},
 			expectedCode:  http.StatusBadRequest,
 			expectedBody:  auth.ErrInvalidUsernameFormatStr,
@@ -123,7 +229,7 @@ func TestSignupHandler(t *testing.T) {
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
 				m.On("Signup", mock.Anything, "oussama", "pass1234").
-					Return("", errors.Join(domain.UnexpectedDatabaseError, exErr))
+					Return(auth.Tokens{}, errors.Join(domain.UnexpectedDatabaseError, exErr))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  auth.ErrUnknownStr,
@@ -134,7 +240,7 @@ func TestSignupHandler(t *testing.T) {
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
 				m.On("Signup", mock.Anything, "oussama", "pass1234").
-					Return("", errors.Join(domain.UnexpectedPasswordHashingError, exErr))
+					Return(auth.Tokens{}, errors.Join(domain.UnexpectedPasswordHashingError, exErr))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  auth.ErrUnknownStr,
@@ -145,7 +251,7 @@ func TestSignupHandler(t *testing.T) {
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
 				m.On("Signup", mock.Anything, "oussama", "pass1234").
-					Return("", errors.Join(domain.UnexpectedTokenGenerationError, exErr))
+					Return(auth.Tokens{}, errors.Join(domain.UnexpectedTokenGenerationError, exErr))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  auth.ErrAccountCreatedButNoToken,
@@ -155,7 +261,7 @@ func TestSignupHandler(t *testing.T) {
 			description: "timeout error",
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "pass1234").Return("", context.DeadlineExceeded)
+				m.On("Signup", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{}, context.DeadlineExceeded)
 			},
 			expectedCode:  http.StatusGatewayTimeout,
 			expectedBody:  auth.ErrServerTimeoutStr,
@@ -165,12 +271,22 @@ func TestSignupHandler(t *testing.T) {
 			description: "client closed request",
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Signup", mock.Anything, "oussama", "pass1234").Return("", context.Canceled)
+				m.On("Signup", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{}, context.Canceled)
 			},
 			expectedCode:  499,
 			expectedBody:  "",
 			expectedToken: "",
 		},
+		{
+			description: "hashing queue full",
+			body:        `{"username":"oussama", "password":"pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("Signup", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{}, domain.ErrServerBusy)
+			},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedBody:  auth.ErrServerBusyStr,
+			expectedToken: "",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -233,7 +349,7 @@ func TestLoginHandler(t *testing.T) {
 			description: "successful login",
 			body:        `{"username":"oussama", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Login", mock.Anything, "oussama", "pass1234").Return("loginToken123", nil)
+				m.On("Login", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{Access: "loginToken123", Refresh: "refresh"}, nil)
 			},
 			expectedCode:  http.StatusOK,
 			expectedBody:  "",
@@ -243,7 +359,7 @@ func TestLoginHandler(t *testing.T) {
 			description: "user not found",
 			body:        `{"username":"ghost", "password":"pass1234"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Login", mock.Anything, "ghost", "pass1234").Return("", domain.ErrUserNotFound)
+				m.On("Login", mock.Anything, "ghost", "pass1234").Return(auth.Tokens{}, domain.ErrUserNotFound)
 			},
 			expectedCode:  http.StatusUnauthorized,
 			expectedBody:  auth.ErrInvalidCredentialsStr,
@@ -253,7 +369,7 @@ func TestLoginHandler(t *testing.T) {
 			description: "incorrect password",
 			body:        `{"username":"oussama", "password":"wrong"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Login", mock.Anything, "oussama", "wrong").Return("", auth.ErrIncorrectPassword)
+				m.On("Login", mock.Anything, "oussama", "wrong").Return(auth.Tokens{}, auth.ErrIncorrectPassword)
 			},
 			expectedCode:  http.StatusUnauthorized,
 			expectedBody:  auth.ErrInvalidCredentialsStr,
@@ -271,7 +387,7 @@ func TestLoginHandler(t *testing.T) {
 			description: "timeout error",
 			body:        `{"username":"oussama", "password":"pass"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Login", mock.Anything, "oussama", "pass").Return("", context.DeadlineExceeded)
+				m.On("Login", mock.Anything, "oussama", "pass").Return(auth.Tokens{}, context.DeadlineExceeded)
 			},
 			expectedCode:  http.StatusGatewayTimeout,
 			expectedBody:  auth.ErrServerTimeoutStr,
@@ -282,17 +398,28 @@ func TestLoginHandler(t *testing.T) {
 			body:        `{"username":"oussama", "password":"pass"}`,
 			setupMocks: func(m *MockAuthService) {
 				m.On("Login", mock.Anything, "oussama", "pass").
-					Return("", errors.Join(domain.UnexpectedDatabaseError, exErr))
+					Return(auth.Tokens{}, errors.Join(domain.UnexpectedDatabaseError, exErr))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  auth.ErrUnknownStr,
 			expectedToken: "",
 		},
+		{
+			description: "hashing queue full",
+			body:        `{"username":"oussama", "password":"pass"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("Login", mock.Anything, "oussama", "pass").
+					Return(auth.Tokens{}, domain.ErrServerBusy)
+			},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedBody:  auth.ErrServerBusyStr,
+			expectedToken: "",
+		},
 		{
 			description: "unknown error",
 			body:        `{"username":"oussama", "password":"pass"}`,
 			setupMocks: func(m *MockAuthService) {
-				m.On("Login", mock.Anything, "oussama", "pass").Return("", errors.New("random stuff"))
+				m.On("Login", mock.Anything, "oussama", "pass").Return(auth.Tokens{}, errors.New("random stuff"))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  auth.ErrUnknownStr,
@@ -337,6 +464,299 @@ func TestLoginHandler(t *testing.T) {
 	}
 }
 
+func TestLoginHandler_Throttles_Failures(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("Login", mock.Anything, "oussama", "wrong").Return(auth.Tokens{}, auth.ErrIncorrectPassword).Twice()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	config := auth.ThrottleConfig{FreeAttempts: 1, BaseDelay: 90 * time.Second, MaxDelay: time.Hour, Forget: time.Hour}
+	authHandler.SetLoginThrottle(auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), config, auth.DefaultIPThrottle()))
+	server := gin.New()
+	server.POST("/login", authHandler.LoginHandler)
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString(`{"username":"oussama", "password":"wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	res := login()
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	assert.Equal(t, auth.ErrTooManyAttemptsStr, res.Body.String())
+	assert.Equal(t, "90", res.Header().Get("Retry-After"))
+
+	// the throttled attempt never reached the password check
+	mockService.AssertExpectations(t)
+}
+
+func TestAccountHandlers_Throttle_The_Password_Like_Logins(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("Username", mock.Anything, "user-id-123").Return("oussama", nil)
+	mockService.On("ChangePassword", mock.Anything, "user-id-123", auth.Proof{Password: "wrong"}, "new_pass1").Return(auth.Tokens{}, auth.ErrIncorrectPassword).Once()
+	mockService.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{Password: "wrong"}).Return(auth.ErrIncorrectPassword).Once()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	config := auth.ThrottleConfig{FreeAttempts: 1, BaseDelay: 90 * time.Second, MaxDelay: time.Hour, Forget: time.Hour}
+	authHandler.SetLoginThrottle(auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), config, auth.DefaultIPThrottle()))
+	server := gin.New()
+	setId := func(ctx *gin.Context) { ctx.Set("id", "user-id-123") }
+	server.POST("/login", authHandler.LoginHandler)
+	server.POST("/password", setId, authHandler.ChangePasswordHandler)
+	server.POST("/delete-account", setId, authHandler.DeleteAccountHandler)
+	server.POST("/totp/disable", setId, authHandler.DisableTOTPHandler)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+
+	assert.Equal(t, http.StatusForbidden, post("/password", `{"current_password":"wrong", "new_password":"new_pass1"}`).Code)
+	assert.Equal(t, http.StatusForbidden, post("/delete-account", `{"password":"wrong"}`).Code)
+
+	res := post("/totp/disable", `{"password":"guess"}`)
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	assert.Equal(t, "90", res.Header().Get("Retry-After"))
+	res = post("/login", `{"username":"OUSSAMA", "password":"guess"}`)
+	assert.Equal(t, http.StatusTooManyRequests, res.Code, "one budget for logins and account changes")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestLoginHandler_With_MFA(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("Login", mock.Anything, "oussama", "pass1234").Return(auth.Tokens{MFA: "mfa-token"}, nil)
+	mockService.On("CompleteMFA", mock.Anything, "mfa-token", "000000").Return(auth.Tokens{}, auth.ErrIncorrectCode).Once()
+	mockService.On("CompleteMFA", mock.Anything, "mfa-token", "123456").
+		Return(auth.Tokens{Access: "access", Refresh: "refresh"}, nil).Once()
+	mockService.On("CompleteMFA", mock.Anything, "spent-token", "123456").Return(auth.Tokens{}, auth.ErrMFATokenInvalid).Once()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	server := gin.New()
+	server.POST("/login", authHandler.LoginHandler)
+	server.POST("/login/mfa", authHandler.LoginMFAHandler)
+
+	post := func(path, body, mfaToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if mfaToken != "" {
+			req.AddCookie(&http.Cookie{Name: auth.MFACookieName, Value: mfaToken})
+		}
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+
+	res := post("/login", `{"username":"oussama", "password":"pass1234"}`, "")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Equal(t, auth.ErrMFARequiredStr, res.Body.String())
+	cookies := res.Result().Cookies()
+	if assert.Len(t, cookies, 1, "no session before the code") {
+		assert.Equal(t, auth.MFACookieName, cookies[0].Name)
+		assert.Equal(t, "/auth", cookies[0].Path)
+		assert.Equal(t, "mfa-token", cookies[0].Value)
+	}
+
+	res = post("/login/mfa", `{"code":"123456"}`, "")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, auth.ErrMFATokenInvalidStr, res.Body.String())
+
+	res = post("/login/mfa", `{"code":"000000"}`, "mfa-token")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, auth.ErrIncorrectCodeStr, res.Body.String())
+
+	res = post("/login/mfa", `{"code":"123456"}`, "mfa-token")
+	assert.Equal(t, http.StatusOK, res.Code)
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 3) {
+		assert.Equal(t, auth.MFACookieName, cookies[0].Name)
+		assert.Less(t, cookies[0].MaxAge, 0)
+		assert.Equal(t, "access", cookies[1].Value)
+		assert.Equal(t, "refresh", cookies[2].Value)
+	}
+
+	res = post("/login/mfa", `{"code":"123456"}`, "spent-token")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, auth.ErrMFATokenInvalidStr, res.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestOIDCHandlers(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("StartOIDC", mock.Anything, "test").Return("https://provider.example.com/authorize?state=s1", "s1", nil)
+	mockService.On("StartOIDC", mock.Anything, "nope").Return("", "", auth.ErrUnknownProvider)
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "linked").
+		Return(auth.OIDCResult{Tokens: auth.Tokens{Access: "access", Refresh: "refresh"}}, nil).Once()
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "new").
+		Return(auth.OIDCResult{SignupToken: "signup-token", Username: "oussama"}, nil).Once()
+	mockService.On("CompleteOIDCSignup", mock.Anything, "signup-token", "taken").Return(auth.Tokens{}, domain.ErrDuplicateUsername).Once()
+	mockService.On("CompleteOIDCSignup", mock.Anything, "signup-token", "oussama").
+		Return(auth.Tokens{Access: "access", Refresh: "refresh"}, nil).Once()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	authHandler.SetFrontendURL("https://app.example.com/")
+	server := gin.New()
+	server.GET("/oidc/:provider", authHandler.StartOIDCHandler)
+	server.GET("/oidc/:provider/callback", authHandler.OIDCCallbackHandler)
+	server.POST("/oidc/signup", authHandler.OIDCSignupHandler)
+
+	send := func(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+	state := &http.Cookie{Name: auth.OIDCStateCookieName, Value: "s1"}
+
+	res := send(http.MethodGet, "/oidc/test", "", nil)
+	assert.Equal(t, http.StatusFound, res.Code)
+	assert.Equal(t, "https://provider.example.com/authorize?state=s1", res.Header().Get("Location"))
+	cookies := res.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, auth.OIDCStateCookieName, cookies[0].Name)
+		assert.Equal(t, "s1", cookies[0].Value)
+		assert.Equal(t, "/auth", cookies[0].Path)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	}
+
+	res = send(http.MethodGet, "/oidc/nope", "", nil)
+	assert.Equal(t, http.StatusFound, res.Code)
+	assert.Equal(t, "https://app.example.com/login?error="+auth.ErrUnknownProviderStr, res.Header().Get("Location"))
+
+	// a callback without the browser's state never reaches the service
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=linked", "", nil)
+	assert.Equal(t, "https://app.example.com/login?error="+auth.ErrOIDCStateInvalidStr, res.Header().Get("Location"))
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&error=access_denied", "", state)
+	assert.Equal(t, "https://app.example.com/login?error="+auth.ErrOIDCLoginRejectedStr, res.Header().Get("Location"))
+
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=linked", "", state)
+	assert.Equal(t, http.StatusFound, res.Code)
+	assert.Equal(t, "https://app.example.com/", res.Header().Get("Location"))
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 3) {
+		assert.Less(t, cookies[0].MaxAge, 0, "the state is spent")
+		assert.Equal(t, "access", cookies[1].Value)
+		assert.Equal(t, "refresh", cookies[2].Value)
+	}
+
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=new", "", state)
+	assert.Equal(t, "https://app.example.com/oidc-signup?username=oussama", res.Header().Get("Location"))
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 2, "no session before the username") {
+		assert.Equal(t, auth.OIDCSignupCookieName, cookies[1].Name)
+		assert.Equal(t, "signup-token", cookies[1].Value)
+	}
+
+	signup := &http.Cookie{Name: auth.OIDCSignupCookieName, Value: "signup-token"}
+	res = send(http.MethodPost, "/oidc/signup", `{"username":"oussama"}`, nil)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, auth.ErrOIDCSignupInvalidStr, res.Body.String())
+
+	res = send(http.MethodPost, "/oidc/signup", `{"username":"taken"}`, signup)
+	assert.Equal(t, http.StatusConflict, res.Code)
+	assert.Equal(t, auth.ErrUsernameAlreadyExistsStr, res.Body.String())
+
+	res = send(http.MethodPost, "/oidc/signup", `{"username":"oussama"}`, signup)
+	assert.Equal(t, http.StatusCreated, res.Code)
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 3) {
+		assert.Less(t, cookies[0].MaxAge, 0)
+		assert.Equal(t, "access", cookies[1].Value)
+		assert.Equal(t, "refresh", cookies[2].Value)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestOIDCHandlers_Link_Reauth_And_MFA(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("StartOIDCLink", mock.Anything, "test", "user-id-123", auth.Proof{Password: "wrong"}).Return("", "", auth.ErrIncorrectPassword).Once()
+	mockService.On("StartOIDCLink", mock.Anything, "test", "user-id-123", auth.Proof{Password: "pass1234"}).
+		Return("https://provider.example.com/authorize?state=s1", "s1", nil).Once()
+	mockService.On("StartOIDCReauth", mock.Anything, "test", "user-id-123").Return("https://provider.example.com/authorize?state=s1", "s1", nil).Once()
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "mfa").Return(auth.OIDCResult{Tokens: auth.Tokens{MFA: "mfa-token"}}, nil).Once()
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "link").Return(auth.OIDCResult{Linked: true}, nil).Once()
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "taken").Return(auth.OIDCResult{}, domain.ErrIdentityTaken).Once()
+	mockService.On("FinishOIDC", mock.Anything, "test", "s1", "reauth").Return(auth.OIDCResult{ReauthToken: "reauth-token"}, nil).Once()
+	mockService.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{ReauthToken: "reauth-token"}).Return(nil).Once()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	authHandler.SetFrontendURL("https://app.example.com")
+	server := gin.New()
+	setId := func(ctx *gin.Context) { ctx.Set("id", "user-id-123") }
+	server.POST("/oidc/:provider/link", setId, authHandler.StartOIDCLinkHandler)
+	server.POST("/oidc/:provider/reauth", setId, authHandler.StartOIDCReauthHandler)
+	server.GET("/oidc/:provider/callback", authHandler.OIDCCallbackHandler)
+	server.POST("/delete-account", setId, authHandler.DeleteAccountHandler)
+
+	send := func(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		return res
+	}
+	state := &http.Cookie{Name: auth.OIDCStateCookieName, Value: "s1"}
+
+	res := send(http.MethodPost, "/oidc/test/link", `{"password":"wrong"}`, nil)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Equal(t, auth.ErrIncorrectPasswordStr, res.Body.String())
+
+	res = send(http.MethodPost, "/oidc/test/link", `{"password":"pass1234"}`, nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"url":"https://provider.example.com/authorize?state=s1"}`, res.Body.String())
+	cookies := res.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, auth.OIDCStateCookieName, cookies[0].Name)
+		assert.Equal(t, http.SameSiteNoneMode, cookies[0].SameSite, "set by a fetch from the frontend")
+	}
+
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=mfa", "", state)
+	assert.Equal(t, "https://app.example.com/login?mfa=required", res.Header().Get("Location"))
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 2, "no session before the code") {
+		assert.Equal(t, auth.MFACookieName, cookies[1].Name)
+		assert.Equal(t, "mfa-token", cookies[1].Value)
+	}
+
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=link", "", state)
+	assert.Equal(t, "https://app.example.com/?linked=test", res.Header().Get("Location"))
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=taken", "", state)
+	assert.Equal(t, "https://app.example.com/login?error="+auth.ErrIdentityTakenStr, res.Header().Get("Location"))
+
+	res = send(http.MethodPost, "/oidc/test/reauth", "", nil)
+	assert.Equal(t, http.StatusOK, res.Code)
+	res = send(http.MethodGet, "/oidc/test/callback?state=s1&code=reauth", "", state)
+	assert.Equal(t, "https://app.example.com/?reauth=test", res.Header().Get("Location"))
+	cookies = res.Result().Cookies()
+	if assert.Len(t, cookies, 2) {
+		assert.Equal(t, auth.OIDCReauthCookieName, cookies[1].Name)
+		assert.Equal(t, "reauth-token", cookies[1].Value)
+	}
+
+	res = send(http.MethodPost, "/delete-account", `{}`, &http.Cookie{Name: auth.OIDCReauthCookieName, Value: "reauth-token"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	mockService.AssertExpectations(t)
+}
+
 func TestLogoutHandler(t *testing.T) {
 	t.Parallel()
 	mockService := new(MockAuthService)
@@ -355,6 +775,33 @@ func TestLogoutHandler(t *testing.T) {
 	assert.Less(t, cookies[0].MaxAge, 0, "token age must be negative so the cookie gets deleted")
 }
 
+func TestLogoutHandler_Revokes_The_Session(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	// revoking fails, the cookies still go
+	mockService.On("Logout", mock.Anything, "the-refresh").Return(domain.UnexpectedDatabaseError).Once()
+	mockService.On("LogoutEverywhere", mock.Anything, "user-id-123").Return(nil).Once()
+	authHandler := auth.NewAuthHandler(mockService, 4*time.Second)
+	server := gin.New()
+	server.POST("/logout", authHandler.LogoutHandler)
+	server.POST("/logout-all", func(ctx *gin.Context) { ctx.Set("id", "user-id-123") }, authHandler.LogoutEverywhereHandler)
+
+	for _, path := range []string{"/logout", "/logout-all"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.AddCookie(&http.Cookie{Name: auth.RefreshCookieName, Value: "the-refresh"})
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code, path)
+		cookies := res.Result().Cookies()
+		if assert.Len(t, cookies, 2, path) {
+			assert.Equal(t, auth.RefreshCookieName, cookies[1].Name)
+			assert.Less(t, cookies[1].MaxAge, 0)
+		}
+	}
+	mockService.AssertExpectations(t)
+}
+
 func TestRequireAuthMiddleware(t *testing.T) {
 	t.Parallel()
 
@@ -419,12 +866,14 @@ func TestRefreshSessionHandler(t *testing.T) {
 	type setupFn func(m *MockAuthService)
 
 	type testCase struct {
-		description   string
-		cookieValue   string
-		setupMocks    setupFn
-		expectedCode  int
-		expectedBody  string
-		expectedToken string
+		description    string
+		cookieValue    string
+		refreshCookie  string
+		setupMocks     setupFn
+		expectedCode   int
+		expectedBody   string
+		expectedToken  string
+		expectedClears bool
 	}
 
 	gin.SetMode(gin.TestMode)
@@ -439,6 +888,43 @@ func TestRefreshSessionHandler(t *testing.T) {
 			expectedBody:  "unauthenticated",
 			expectedToken: "",
 		},
+		{
+			description:   "Rotates the refresh token",
+			refreshCookie: "old-refresh",
+			setupMocks: func(m *MockAuthService) {
+				m.On("Refresh", mock.Anything, "old-refresh").Return(auth.Tokens{Access: "new-access", Refresh: "new-refresh"}, nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectedToken: "new-access",
+		},
+		{
+			description:   "Reused refresh token",
+			refreshCookie: "spent-refresh",
+			setupMocks: func(m *MockAuthService) {
+				m.On("Refresh", mock.Anything, "spent-refresh").Return(auth.Tokens{}, domain.ErrRefreshTokenReused)
+			},
+			expectedCode:   http.StatusUnauthorized,
+			expectedBody:   auth.ErrBadTokenStr,
+			expectedClears: true,
+		},
+		{
+			description:   "Expired refresh token",
+			refreshCookie: "old-refresh",
+			setupMocks: func(m *MockAuthService) {
+				m.On("Refresh", mock.Anything, "old-refresh").Return(auth.Tokens{}, domain.ErrExpiredToken)
+			},
+			expectedCode:   http.StatusUnauthorized,
+			expectedBody:   auth.ErrExpiredTokenStr,
+			expectedClears: true,
+		},
+		{
+			description:   "Database down",
+			refreshCookie: "old-refresh",
+			setupMocks: func(m *MockAuthService) {
+				m.On("Refresh", mock.Anything, "old-refresh").Return(auth.Tokens{}, errors.Join(domain.UnexpectedDatabaseError, exErr))
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
 		{
 			description: "Invalid or expired token",
 			cookieValue: "bad-token",
@@ -450,22 +936,31 @@ func TestRefreshSessionHandler(t *testing.T) {
 			expectedToken: "",
 		},
 		{
-			description: "Token generation failure",
+			description: "Account without a refresh token",
 			cookieValue: "valid-token",
 			setupMocks: func(m *MockAuthService) {
 				m.On("VerifyToken", "valid-token").Return("user-123", nil)
-				m.On("GenerateToken", "user-123").Return("", errors.Join(domain.UnexpectedTokenGenerationError, exErr))
+			},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "unauthenticated",
+		},
+		{
+			description: "Guest token generation failure",
+			cookieValue: "valid-token",
+			setupMocks: func(m *MockAuthService) {
+				m.On("VerifyToken", "valid-token").Return("guest:guest_0a1b2c3d", nil)
+				m.On("GenerateToken", "guest:guest_0a1b2c3d").Return("", errors.Join(domain.UnexpectedTokenGenerationError, exErr))
 			},
 			expectedCode:  http.StatusInternalServerError,
 			expectedBody:  "",
 			expectedToken: "",
 		},
 		{
-			description: "Successful refresh",
+			description: "Successful guest refresh",
 			cookieValue: "valid-token",
 			setupMocks: func(m *MockAuthService) {
-				m.On("VerifyToken", "valid-token").Return("user-123", nil)
-				m.On("GenerateToken", "user-123").Return("new-refreshed-token", nil)
+				m.On("VerifyToken", "valid-token").Return("guest:guest_0a1b2c3d", nil)
+				m.On("GenerateToken", "guest:guest_0a1b2c3d").Return("new-refreshed-token", nil)
 			},
 			expectedCode:  http.StatusOK,
 			expectedBody:  "",
@@ -489,6 +984,9 @@ func TestRefreshSessionHandler(t *testing.T) {
 			if tc.cookieValue != "" {
 				req.AddCookie(&http.Cookie{Name: "token", Value: tc.cookieValue})
 			}
+			if tc.refreshCookie != "" {
+				req.AddCookie(&http.Cookie{Name: auth.RefreshCookieName, Value: tc.refreshCookie})
+			}
 			res := httptest.NewRecorder()
 
 			server.ServeHTTP(res, req)
@@ -496,17 +994,433 @@ func TestRefreshSessionHandler(t *testing.T) {
 			assert.Equal(t, tc.expectedCode, res.Code)
 			assert.Equal(t, tc.expectedBody, res.Body.String())
 
-			if tc.expectedToken != "" {
-				cookies := res.Result().Cookies()
+			cookies := res.Result().Cookies()
+			switch {
+			case tc.expectedToken != "":
 				if assert.NotEmpty(t, cookies, "Expected a response cookie but got none") {
 					assert.Equal(t, "token", cookies[0].Name)
 					assert.Equal(t, tc.expectedToken, cookies[0].Value)
 				}
-			} else {
-				assert.Empty(t, res.Result().Cookies())
+				if tc.refreshCookie != "" && assert.Len(t, cookies, 2) {
+					assert.Equal(t, auth.RefreshCookieName, cookies[1].Name)
+					assert.Equal(t, "new-refresh", cookies[1].Value)
+					assert.Equal(t, "/auth", cookies[1].Path)
+				}
+			case tc.expectedClears:
+				if assert.Len(t, cookies, 2) {
+					assert.Less(t, cookies[0].MaxAge, 0)
+					assert.Less(t, cookies[1].MaxAge, 0)
+				}
+			default:
+				assert.Empty(t, cookies)
 			}
 
 			mockService.AssertExpectations(t)
 		})
 	}
 }
+
+func TestGuestHandler(t *testing.T) {
+	t.Parallel()
+	m := new(MockAuthService)
+	m.On("Guest", mock.Anything).Return("guest-token", "guest_0a1b2c3d", nil)
+	authHandler := auth.NewAuthHandler(m, 24*time.Hour)
+	server := gin.New()
+	server.POST("/guest", authHandler.GuestHandler)
+
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/guest", nil))
+
+	assert.Equal(t, http.StatusCreated, res.Code)
+	assert.JSONEq(t, `{"nickname": "guest_0a1b2c3d"}`, res.Body.String())
+	cookies := res.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "guest-token", cookies[0].Value)
+		assert.Equal(t, int(auth.GuestTokenAge.Seconds()), cookies[0].MaxAge, "the guest cookie is short lived")
+	}
+	m.AssertExpectations(t)
+}
+
+func TestUpgradeHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		description  string
+		id           string
+		body         string
+		setupMocks   func(m *MockAuthService)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			description: "keeps the nickname",
+			id:          "guest:guest_0a1b2c3d",
+			body:        `{"password": "pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("Upgrade", mock.Anything, "guest:guest_0a1b2c3d", "", "pass1234").Return(auth.Tokens{Access: "account-token", Refresh: "refresh"}, nil)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			description: "already an account",
+			id:          "user-id-123",
+			body:        `{"password": "pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("Upgrade", mock.Anything, "user-id-123", "", "pass1234").Return(auth.Tokens{}, auth.ErrNotGuest)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: auth.ErrNotGuestStr,
+		},
+		{
+			description: "another guest's nickname",
+			id:          "guest:guest_0a1b2c3d",
+			body:        `{"username": "guest_ffffffff", "password": "pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("Upgrade", mock.Anything, "guest:guest_0a1b2c3d", "guest_ffffffff", "pass1234").Return(auth.Tokens{}, auth.ErrReservedUsername)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: auth.ErrReservedUsernameStr,
+		},
+		{
+			description:  "bad body",
+			id:           "guest:guest_0a1b2c3d",
+			body:         `{`,
+			setupMocks:   func(m *MockAuthService) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: auth.ErrInvalidRequestFormatStr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			m := new(MockAuthService)
+			tc.setupMocks(m)
+			authHandler := auth.NewAuthHandler(m, 24*time.Hour)
+			server := gin.New()
+			server.POST("/upgrade", func(ctx *gin.Context) { ctx.Set("id", tc.id) }, authHandler.UpgradeHandler)
+
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/upgrade", bytes.NewBufferString(tc.body)))
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Equal(t, tc.expectedBody, res.Body.String())
+			if tc.expectedCode == http.StatusCreated {
+				cookies := res.Result().Cookies()
+				if assert.Len(t, cookies, 2) {
+					assert.Equal(t, "account-token", cookies[0].Value)
+					assert.Equal(t, int((24 * time.Hour).Seconds()), cookies[0].MaxAge)
+					assert.Equal(t, "refresh", cookies[1].Value)
+				}
+			}
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequireAccountMiddleware(t *testing.T) {
+	t.Parallel()
+	authHandler := auth.NewAuthHandler(new(MockAuthService), time.Hour)
+
+	for id, code := range map[string]int{
+		"user-id-123":          http.StatusOK,
+		"guest:guest_0a1b2c3d": http.StatusForbidden,
+	} {
+		server := gin.New()
+		server.GET("/webhooks", func(ctx *gin.Context) { ctx.Set("id", id) }, authHandler.RequireAccountMiddleware(), func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+		assert.Equal(t, code, res.Code, id)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("Role", mock.Anything, "admin-id").Return(domain.RoleAdmin, nil)
+	mockService.On("Role", mock.Anything, "moderator-id").Return(domain.RoleModerator, nil)
+	mockService.On("Role", mock.Anything, "player-id").Return(domain.RolePlayer, nil)
+	mockService.On("Role", mock.Anything, "deleted-id").Return(domain.Role(""), domain.ErrUserNotFound)
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+
+	tests := []struct {
+		id          string
+		permissions []domain.Permission
+		code        int
+	}{
+		{"admin-id", []domain.Permission{domain.PermManageWords, domain.PermManageRoles}, http.StatusOK},
+		{"moderator-id", []domain.Permission{domain.PermModerateDrawings}, http.StatusOK},
+		{"moderator-id", []domain.Permission{domain.PermModerateDrawings, domain.PermManageWords}, http.StatusForbidden},
+		{"player-id", []domain.Permission{domain.PermModerateDrawings}, http.StatusForbidden},
+		{"deleted-id", []domain.Permission{domain.PermManageWords}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		server := gin.New()
+		server.GET("/admin", func(ctx *gin.Context) { ctx.Set("id", tt.id) }, authHandler.RequirePermission(tt.permissions...), func(ctx *gin.Context) {
+			role, _ := ctx.Get("role")
+			assert.IsType(t, domain.Role(""), role)
+			ctx.Status(http.StatusOK)
+		})
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		assert.Equal(t, tt.code, res.Code, tt.id)
+		if tt.code == http.StatusForbidden {
+			assert.Equal(t, auth.ErrMissingPermissionStr, res.Body.String())
+		}
+	}
+}
+
+func TestSetRoleHandler(t *testing.T) {
+	t.Parallel()
+	mockService := new(MockAuthService)
+	mockService.On("SetRole", mock.Anything, "oussama", domain.RoleModerator).Return(nil).Once()
+	mockService.On("SetRole", mock.Anything, "oussama", domain.Role("emperor")).Return(domain.ErrInvalidRole).Once()
+	mockService.On("SetRole", mock.Anything, "nobody", domain.RoleAdmin).Return(domain.ErrUserNotFound).Once()
+
+	authHandler := auth.NewAuthHandler(mockService, time.Hour)
+	server := gin.New()
+	server.POST("/admin/users/:username/role", authHandler.SetRoleHandler)
+
+	for _, tt := range []struct {
+		username string
+		body     string
+		code     int
+	}{
+		{"oussama", `{"role":"moderator"}`, http.StatusOK},
+		{"nobody", `{"role":"admin"}`, http.StatusNotFound},
+		{"oussama", `{"role":"emperor"}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/"+tt.username+"/role", bytes.NewBufferString(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		assert.Equal(t, tt.code, res.Code, tt.body)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestAccountHandlers(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		description  string
+		path         string
+		body         string
+		setupMocks   func(m *MockAuthService)
+		expectedCode int
+		expectedBody string
+		// cookies set, the session ones or the cleared ones
+		expectedCookies int
+	}
+
+	testCases := []testCase{
+		{
+			description: "change password",
+			path:        "/password",
+			body:        `{"current_password":"old_pass1", "new_password":"new_pass1"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangePassword", mock.Anything, "user-id-123", auth.Proof{Password: "old_pass1"}, "new_pass1").
+					Return(auth.Tokens{Access: "access", Refresh: "refresh"}, nil)
+			},
+			expectedCode:    http.StatusOK,
+			expectedCookies: 2,
+		},
+		{
+			description: "change password with a wrong current one",
+			path:        "/password",
+			body:        `{"current_password":"wrong", "new_password":"new_pass1"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangePassword", mock.Anything, "user-id-123", auth.Proof{Password: "wrong"}, "new_pass1").
+					Return(auth.Tokens{}, auth.ErrIncorrectPassword)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: auth.ErrIncorrectPasswordStr,
+		},
+		{
+			description: "change password to a weak one",
+			path:        "/password",
+			body:        `{"current_password":"old_pass1", "new_password":"short"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangePassword", mock.Anything, "user-id-123", auth.Proof{Password: "old_pass1"}, "short").
+					Return(auth.Tokens{}, auth.ErrWeakPassword)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: auth.ErrWeakPasswordStr,
+		},
+		{
+			description:  "change password bad request",
+			path:         "/password",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: auth.ErrInvalidRequestFormatStr,
+		},
+		{
+			description: "change username",
+			path:        "/username",
+			body:        `{"username":"new_name"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangeUsername", mock.Anything, "user-id-123", "new_name").Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "change username too soon",
+			path:        "/username",
+			body:        `{"username":"new_name"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangeUsername", mock.Anything, "user-id-123", "new_name").Return(domain.ErrUsernameChangeTooSoon)
+			},
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: auth.ErrUsernameChangeTooSoonStr,
+		},
+		{
+			description: "change username to a taken one",
+			path:        "/username",
+			body:        `{"username":"taken"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangeUsername", mock.Anything, "user-id-123", "taken").Return(domain.ErrDuplicateUsername)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: auth.ErrUsernameAlreadyExistsStr,
+		},
+		{
+			description: "change username to a guest one",
+			path:        "/username",
+			body:        `{"username":"guest_0a1b2c3d"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ChangeUsername", mock.Anything, "user-id-123", "guest_0a1b2c3d").Return(auth.ErrReservedUsername)
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: auth.ErrReservedUsernameStr,
+		},
+		{
+			description: "delete account",
+			path:        "/delete-account",
+			body:        `{"password":"pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{Password: "pass1234"}).Return(nil)
+			},
+			expectedCode:    http.StatusOK,
+			expectedCookies: 2,
+		},
+		{
+			description: "delete account with a wrong password",
+			path:        "/delete-account",
+			body:        `{"password":"wrong"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{Password: "wrong"}).Return(auth.ErrIncorrectPassword)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: auth.ErrIncorrectPasswordStr,
+		},
+		{
+			description: "delete account already gone",
+			path:        "/delete-account",
+			body:        `{"password":"pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{Password: "pass1234"}).Return(domain.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: auth.ErrUserNotFoundStr,
+		},
+		{
+			description: "enroll totp",
+			path:        "/totp/enroll",
+			setupMocks: func(m *MockAuthService) {
+				m.On("EnrollTOTP", mock.Anything, "user-id-123").Return("SECRET", "otpauth://totp/x", nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"secret":"SECRET","uri":"otpauth://totp/x"}`,
+		},
+		{
+			description: "enroll totp already enabled",
+			path:        "/totp/enroll",
+			setupMocks: func(m *MockAuthService) {
+				m.On("EnrollTOTP", mock.Anything, "user-id-123").Return("", "", domain.ErrTOTPAlreadyEnabled)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: auth.ErrTOTPAlreadyEnabledStr,
+		},
+		{
+			description: "confirm totp",
+			path:        "/totp/confirm",
+			body:        `{"code":"123456"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ConfirmTOTP", mock.Anything, "user-id-123", "123456").Return([]string{"abcde-fghij"}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"recovery_codes":["abcde-fghij"]}`,
+		},
+		{
+			description: "confirm totp with a wrong code",
+			path:        "/totp/confirm",
+			body:        `{"code":"000000"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ConfirmTOTP", mock.Anything, "user-id-123", "000000").Return(nil, auth.ErrIncorrectCode)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: auth.ErrIncorrectCodeStr,
+		},
+		{
+			description: "confirm totp not enrolled",
+			path:        "/totp/confirm",
+			body:        `{"code":"123456"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("ConfirmTOTP", mock.Anything, "user-id-123", "123456").Return(nil, domain.ErrTOTPNotEnrolled)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: auth.ErrTOTPNotEnrolledStr,
+		},
+		{
+			description: "disable totp",
+			path:        "/totp/disable",
+			body:        `{"password":"pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("DisableTOTP", mock.Anything, "user-id-123", auth.Proof{Password: "pass1234"}).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			description: "delete account database failure",
+			path:        "/delete-account",
+			body:        `{"password":"pass1234"}`,
+			setupMocks: func(m *MockAuthService) {
+				m.On("DeleteAccount", mock.Anything, "user-id-123", auth.Proof{Password: "pass1234"}).Return(domain.UnexpectedDatabaseError)
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: auth.ErrUnknownStr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			mockService := new(MockAuthService)
+			if tc.setupMocks != nil {
+				tc.setupMocks(mockService)
+			}
+
+			authHandler := auth.NewAuthHandler(mockService, time.Hour)
+			server := gin.New()
+			setId := func(ctx *gin.Context) { ctx.Set("id", "user-id-123") }
+			server.POST("/password", setId, authHandler.ChangePasswordHandler)
+			server.POST("/username", setId, authHandler.ChangeUsernameHandler)
+			server.POST("/delete-account", setId, authHandler.DeleteAccountHandler)
+			server.POST("/totp/enroll", setId, authHandler.EnrollTOTPHandler)
+			server.POST("/totp/confirm", setId, authHandler.ConfirmTOTPHandler)
+			server.POST("/totp/disable", setId, authHandler.DisableTOTPHandler)
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Equal(t, tc.expectedBody, res.Body.String())
+			assert.Len(t, res.Result().Cookies(), tc.expectedCookies)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
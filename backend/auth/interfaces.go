@@ -7,21 +7,133 @@ import (
 )
 
 type AuthService interface {
-	Signup(ctx context.Context, username, password string) (string, error)
-	Login(ctx context.Context, username, password string) (string, error)
+	Signup(ctx context.Context, username, password string) (Tokens, error)
+	Login(ctx context.Context, username, password string) (Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutEverywhere(ctx context.Context, userId string) error
 	VerifyToken(token string) (string, error)
 	GenerateToken(id string) (string, error)
+	// Guest returns a guest token and the nickname it was issued for.
+	Guest(ctx context.Context) (string, string, error)
+	Upgrade(ctx context.Context, guestId, username, password string) (Tokens, error)
+	// ChangePassword returns the tokens of a new session, the other ones are
+	// revoked.
+	ChangePassword(ctx context.Context, userId string, proof Proof, newPassword string) (Tokens, error)
+	Username(ctx context.Context, userId string) (string, error)
+	ChangeUsername(ctx context.Context, userId, username string) error
+	DeleteAccount(ctx context.Context, userId string, proof Proof) error
+	// CompleteMFA exchanges the MFA token of a login and a TOTP or recovery
+	// code for the session.
+	CompleteMFA(ctx context.Context, mfaToken, code string) (Tokens, error)
+	// EnrollTOTP returns the secret, base32 encoded, and its otpauth URI.
+	EnrollTOTP(ctx context.Context, userId string) (string, string, error)
+	// ConfirmTOTP enables the enrollment and returns the recovery codes, they
+	// are shown this once.
+	ConfirmTOTP(ctx context.Context, userId, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userId string, proof Proof) error
+	OIDCProviders() []string
+	// StartOIDC returns the provider URL to send the player to and the state
+	// the callback comes back with.
+	StartOIDC(ctx context.Context, provider string) (string, string, error)
+	// StartOIDCLink starts a flow linking the provider to the user, once the
+	// proof checks out.
+	StartOIDCLink(ctx context.Context, provider, userId string, proof Proof) (string, string, error)
+	// StartOIDCReauth starts a flow whose result is a reauth token of the
+	// user, the proof of an account without a password.
+	StartOIDCReauth(ctx context.Context, provider, userId string) (string, string, error)
+	FinishOIDC(ctx context.Context, provider, state, code string) (OIDCResult, error)
+	CompleteOIDCSignup(ctx context.Context, signupToken, username string) (Tokens, error)
+	Role(ctx context.Context, userId string) (domain.Role, error)
+	SetRole(ctx context.Context, username string, role domain.Role) error
 }
 
 type UserRepo interface {
 	CreateUser(ctx context.Context, username string, passwordHash string) (string, error)
 	GetUserByUsername(ctx context.Context, username string) (domain.User, error)
 	GetUserById(ctx context.Context, id string) (domain.User, error)
+	// UpdatePasswordHash replaces oldHash only, a hash changed meanwhile
+	// fails with domain.ErrPasswordChanged.
+	UpdatePasswordHash(ctx context.Context, id string, oldHash, passwordHash string) error
+	// UpdateUsername fails with domain.ErrUsernameChangeTooSoon when the
+	// username was changed after changedBefore.
+	UpdateUsername(ctx context.Context, id string, username string, changedBefore, now time.Time) error
+	DeleteUser(ctx context.Context, id string) error
+	GetUserRole(ctx context.Context, id string) (domain.Role, error)
+	// SetUserRole returns the id of the user it gave the role to.
+	SetUserRole(ctx context.Context, username string, role domain.Role) (string, error)
 }
 
+// SessionRepo keeps the hashes of refresh tokens, grouped in families: the
+// tokens a login led to through rotations.
+type SessionRepo interface {
+	// CreateRefreshToken starts a new family when the token has no family id.
+	CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error
+	// RotateRefreshToken spends the token of the hash and stores next in its
+	// family, returning the spent one. A token already spent revokes its
+	// family and fails with domain.ErrRefreshTokenReused, unless it was spent
+	// within reuseGrace and the family did not rotate on since: next is then
+	// stored as well.
+	RotateRefreshToken(ctx context.Context, hash []byte, next domain.RefreshToken, now time.Time, reuseGrace time.Duration) (domain.RefreshToken, error)
+	RevokeRefreshTokenFamily(ctx context.Context, hash []byte) error
+	RevokeUserRefreshTokens(ctx context.Context, userId string) error
+	// DeleteRefreshTokensBefore deletes the tokens that expired or were
+	// revoked before cutoff.
+	DeleteRefreshTokensBefore(ctx context.Context, cutoff time.Time) error
+}
+
+// TOTPRepo keeps the second factor of users: the TOTP secret, the step of
+// the last code used and the hashes of the recovery codes.
+type TOTPRepo interface {
+	// SetTOTPSecret starts an enrollment, replacing one not confirmed yet.
+	SetTOTPSecret(ctx context.Context, userId string, secret []byte) error
+	// GetTOTP fails with domain.ErrTOTPNotEnrolled when the user has none.
+	GetTOTP(ctx context.Context, userId string) (domain.TOTP, error)
+	// EnableTOTP confirms the enrollment with the code of the step and
+	// replaces the recovery codes.
+	EnableTOTP(ctx context.Context, userId string, recoveryCodeHashes []string, step int64) error
+	DisableTOTP(ctx context.Context, userId string) error
+	// UseTOTPStep fails with domain.ErrTOTPCodeReused when a code of the step
+	// or a later one was used.
+	UseTOTPStep(ctx context.Context, userId string, step int64) error
+	GetRecoveryCodes(ctx context.Context, userId string) ([]domain.RecoveryCode, error)
+	// UseRecoveryCode fails with domain.ErrRecoveryCodeUsed when the code was
+	// already used.
+	UseRecoveryCode(ctx context.Context, id string, now time.Time) error
+}
+
+// IdentityRepo links the identities of OpenID providers to users.
+type IdentityRepo interface {
+	// GetUserIdByIdentity fails with domain.ErrIdentityNotFound when no user
+	// is linked to the identity.
+	GetUserIdByIdentity(ctx context.Context, provider, subject string) (string, error)
+	// CreateUserWithIdentity creates a user without a password, linked to the
+	// identity.
+	CreateUserWithIdentity(ctx context.Context, username, provider, subject string) (string, error)
+	// LinkIdentity links the identity to the user, it fails with
+	// domain.ErrIdentityTaken when another user has it.
+	LinkIdentity(ctx context.Context, userId, provider, subject string) error
+}
+
+// IDTokenVerifier checks the signature, issuer, audience and expiry of the
+// ID tokens of a provider, with the keys at jwksURI.
+type IDTokenVerifier interface {
+	VerifyIDToken(ctx context.Context, raw, jwksURI, issuer, audience string, now time.Time) (map[string]any, error)
+}
+
+// KeyPublisher hands out the public keys tokens are verified with, as a JWK
+// set.
+type KeyPublisher interface {
+	PublicJWKS() ([]byte, error)
+}
+
+// PasswordHasher may queue hashes for memory, they give up when ctx is done.
 type PasswordHasher interface {
-	Hash(password string) (string, error)
-	Compare(hash, password string) (bool, error)
+	Hash(ctx context.Context, password string) (string, error)
+	Compare(ctx context.Context, hash, password string) (bool, error)
+	// NeedsRehash reports whether the hash was made with weaker params than
+	// the current ones.
+	NeedsRehash(hash string) bool
 }
 
 type TokenManager interface {
@@ -0,0 +1,488 @@
+package auth
+
+import (
+	"api/domain"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OIDCFlowAge is how long a player has at the provider before the
+	// callback.
+	OIDCFlowAge = 10 * time.Minute
+	// OIDCSignupAge is how long a first login has to pick its username.
+	OIDCSignupAge = 15 * time.Minute
+	// OIDCReauthAge is how long a fresh login with a provider confirms a
+	// change to an account without a password.
+	OIDCReauthAge = 5 * time.Minute
+
+	maxOIDCResponseBytes = 1 << 20
+)
+
+// OIDCProviderConfig is a provider players sign in with, its endpoints are
+// discovered from the issuer.
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// RedirectURL is the callback route of the provider, registered with it.
+	RedirectURL string
+}
+
+// OIDCResult is how a flow through a provider ended: a session, or its MFA
+// token with 2FA on, a first login that has to pick a username with the
+// signup token, a provider linked, or a reauth token.
+type OIDCResult struct {
+	Tokens      Tokens
+	SignupToken string
+	// Username is a suggestion from the provider's claims, maybe empty.
+	Username    string
+	Linked      bool
+	ReauthToken string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	config OIDCProviderConfig
+
+	mu sync.Mutex
+	// discovered on first use, a provider down at boot mustn't stop the server
+	discovery *oidcDiscovery
+}
+
+// oidcPurpose is what a flow signs in with the provider for.
+type oidcPurpose int
+
+const (
+	forLogin oidcPurpose = iota
+	forLink
+	forReauth
+)
+
+// oidcFlow is a login gone to the provider, by its state.
+type oidcFlow struct {
+	provider     string
+	codeVerifier string
+	nonce        string
+	expiresAt    time.Time
+	purpose      oidcPurpose
+	// the account a link or reauth is for
+	userId string
+}
+
+// oidcSignup is a first login waiting for its username, by token hash.
+type oidcSignup struct {
+	provider  string
+	subject   string
+	expiresAt time.Time
+}
+
+// oidcReauth is a fresh login of an account, by token hash.
+type oidcReauth struct {
+	userId    string
+	expiresAt time.Time
+}
+
+// oidcRelyingParty signs players in with OpenID providers, the
+// authorization code flow with PKCE.
+type oidcRelyingParty struct {
+	identities IdentityRepo
+	verifier   IDTokenVerifier
+	client     *http.Client
+	providers  map[string]*oidcProvider
+
+	mu      sync.Mutex
+	flows   map[string]*oidcFlow
+	signups map[string]*oidcSignup
+	reauths map[string]*oidcReauth
+}
+
+// SetOIDC lets players sign in with the providers, none without it.
+func (as *authService) SetOIDC(identities IdentityRepo, verifier IDTokenVerifier, client *http.Client, providers ...OIDCProviderConfig) {
+	rp := &oidcRelyingParty{
+		identities: identities,
+		verifier:   verifier,
+		client:     client,
+		providers:  make(map[string]*oidcProvider, len(providers)),
+		flows:      make(map[string]*oidcFlow),
+		signups:    make(map[string]*oidcSignup),
+		reauths:    make(map[string]*oidcReauth),
+	}
+	for _, config := range providers {
+		rp.providers[config.Name] = &oidcProvider{config: config}
+	}
+	as.oidc = rp
+}
+
+// OIDCProviders names the providers players can sign in with.
+func (as *authService) OIDCProviders() []string {
+	if as.oidc == nil {
+		return []string{}
+	}
+	names := make([]string, 0, len(as.oidc.providers))
+	for name := range as.oidc.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (as *authService) oidcProvider(name string) (*oidcProvider, error) {
+	if as.oidc == nil {
+		return nil, ErrUnknownProvider
+	}
+	provider, ok := as.oidc.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// StartOIDC returns the URL of the provider to send the player to and the
+// state the callback must come back with.
+func (as *authService) StartOIDC(ctx context.Context, providerName string) (string, string, error) {
+	return as.startOIDC(ctx, providerName, forLogin, "")
+}
+
+// StartOIDCLink is StartOIDC for a signed in user adding a provider to sign
+// in with, the proof keeps a stolen session from adding one of its own.
+func (as *authService) StartOIDCLink(ctx context.Context, providerName, userId string, proof Proof) (string, string, error) {
+	if _, err := as.oidcProvider(providerName); err != nil {
+		return "", "", err
+	}
+	if _, err := as.checkProof(ctx, userId, proof); err != nil {
+		return "", "", err
+	}
+	return as.startOIDC(ctx, providerName, forLink, userId)
+}
+
+// StartOIDCReauth is StartOIDC for a signed in user proving it is them, the
+// identity must be one linked to the user.
+func (as *authService) StartOIDCReauth(ctx context.Context, providerName, userId string) (string, string, error) {
+	return as.startOIDC(ctx, providerName, forReauth, userId)
+}
+
+func (as *authService) startOIDC(ctx context.Context, providerName string, purpose oidcPurpose, userId string) (string, string, error) {
+	provider, err := as.oidcProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+	discovery, err := as.oidc.discover(ctx, provider)
+	if err != nil {
+		return "", "", err
+	}
+
+	// the verifier never leaves the server, the provider gets its hash
+	flow := &oidcFlow{
+		provider:     providerName,
+		codeVerifier: newRefreshToken(),
+		nonce:        newRefreshToken(),
+		expiresAt:    as.now().Add(OIDCFlowAge),
+		purpose:      purpose,
+		userId:       userId,
+	}
+	state := newRefreshToken()
+	challenge := sha256.Sum256([]byte(flow.codeVerifier))
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientId)
+	query.Set("redirect_uri", provider.config.RedirectURL)
+	query.Set("scope", "openid profile email")
+	query.Set("state", state)
+	query.Set("nonce", flow.nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	now := as.now()
+	as.oidc.mu.Lock()
+	defer as.oidc.mu.Unlock()
+	for key, pending := range as.oidc.flows {
+		if now.After(pending.expiresAt) {
+			delete(as.oidc.flows, key)
+		}
+	}
+	as.oidc.flows[state] = flow
+	return authURL.String(), state, nil
+}
+
+// FinishOIDC exchanges the code of the callback. An identity already linked
+// gets its session, or its MFA token when the user has 2FA on, a new one a
+// signup token. Link and reauth flows end with the identity linked to their
+// user, or the reauth token of the user.
+func (as *authService) FinishOIDC(ctx context.Context, providerName, state, code string) (OIDCResult, error) {
+	provider, err := as.oidcProvider(providerName)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+
+	as.oidc.mu.Lock()
+	flow, ok := as.oidc.flows[state]
+	// a state works once
+	delete(as.oidc.flows, state)
+	as.oidc.mu.Unlock()
+	if !ok || flow.provider != providerName || as.now().After(flow.expiresAt) {
+		return OIDCResult{}, ErrOIDCStateInvalid
+	}
+
+	discovery, err := as.oidc.discover(ctx, provider)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+	rawIDToken, err := as.oidc.exchange(ctx, provider, discovery, code, flow.codeVerifier)
+	if err != nil {
+		return OIDCResult{}, err
+	}
+
+	claims, err := as.oidc.verifier.VerifyIDToken(ctx, rawIDToken, discovery.JWKSURI, discovery.Issuer, provider.config.ClientId, as.now())
+	if err != nil {
+		return OIDCResult{}, fmt.Errorf("%w: %w", ErrOIDCLoginRejected, err)
+	}
+	nonce, _ := claims["nonce"].(string)
+	subject, _ := claims["sub"].(string)
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(flow.nonce)) != 1 || subject == "" {
+		return OIDCResult{}, ErrOIDCLoginRejected
+	}
+
+	switch flow.purpose {
+	case forLink:
+		if err := as.oidc.identities.LinkIdentity(ctx, flow.userId, providerName, subject); err != nil {
+			return OIDCResult{}, err
+		}
+		return OIDCResult{Linked: true}, nil
+	case forReauth:
+		userId, err := as.oidc.identities.GetUserIdByIdentity(ctx, providerName, subject)
+		if errors.Is(err, domain.ErrIdentityNotFound) || (err == nil && userId != flow.userId) {
+			return OIDCResult{}, fmt.Errorf("%w: not an identity of the user", ErrOIDCLoginRejected)
+		}
+		if err != nil {
+			return OIDCResult{}, err
+		}
+		return OIDCResult{ReauthToken: as.startReauth(userId)}, nil
+	}
+
+	userId, err := as.oidc.identities.GetUserIdByIdentity(ctx, providerName, subject)
+	switch {
+	case err == nil:
+		// the provider stands for the password, not for the second factor
+		user, err := as.UserRepo.GetUserById(ctx, userId)
+		if err != nil {
+			return OIDCResult{}, err
+		}
+		if user.TOTPEnabled {
+			return OIDCResult{Tokens: Tokens{MFA: as.startMFA(userId)}}, nil
+		}
+		tokens, err := as.startSession(ctx, userId)
+		return OIDCResult{Tokens: tokens}, err
+	case !errors.Is(err, domain.ErrIdentityNotFound):
+		return OIDCResult{}, err
+	}
+
+	signupToken := newRefreshToken()
+	now := as.now()
+	as.oidc.mu.Lock()
+	for key, pending := range as.oidc.signups {
+		if now.After(pending.expiresAt) {
+			delete(as.oidc.signups, key)
+		}
+	}
+	as.oidc.signups[string(hashRefreshToken(signupToken))] = &oidcSignup{
+		provider:  providerName,
+		subject:   subject,
+		expiresAt: now.Add(OIDCSignupAge),
+	}
+	as.oidc.mu.Unlock()
+	return OIDCResult{SignupToken: signupToken, Username: suggestUsername(claims)}, nil
+}
+
+// CompleteOIDCSignup creates the account of a first login under the username
+// the player picked, linked to the identity.
+func (as *authService) CompleteOIDCSignup(ctx context.Context, signupToken, username string) (Tokens, error) {
+	if as.oidc == nil {
+		return Tokens{}, ErrOIDCSignupInvalid
+	}
+	if !validateUsernameFormat(username) {
+		return Tokens{}, ErrInvalidUsernameFormat
+	}
+	if strings.HasPrefix(username, guestUsernamePrefix) {
+		return Tokens{}, ErrReservedUsername
+	}
+
+	key := string(hashRefreshToken(signupToken))
+	as.oidc.mu.Lock()
+	signup, ok := as.oidc.signups[key]
+	as.oidc.mu.Unlock()
+	if !ok || as.now().After(signup.expiresAt) {
+		return Tokens{}, ErrOIDCSignupInvalid
+	}
+
+	userId, err := as.oidc.identities.CreateUserWithIdentity(ctx, username, signup.provider, signup.subject)
+	if err != nil {
+		// a taken username can be picked again
+		return Tokens{}, err
+	}
+
+	as.oidc.mu.Lock()
+	delete(as.oidc.signups, key)
+	as.oidc.mu.Unlock()
+	return as.startSession(ctx, userId)
+}
+
+// startReauth hands out the reauth token of a fresh login of the user.
+func (as *authService) startReauth(userId string) string {
+	token := newRefreshToken()
+	now := as.now()
+
+	as.oidc.mu.Lock()
+	defer as.oidc.mu.Unlock()
+	for key, pending := range as.oidc.reauths {
+		if now.After(pending.expiresAt) {
+			delete(as.oidc.reauths, key)
+		}
+	}
+	as.oidc.reauths[string(hashRefreshToken(token))] = &oidcReauth{userId: userId, expiresAt: now.Add(OIDCReauthAge)}
+	return token
+}
+
+// takeReauth spends the reauth token, it tells whether it was a fresh one of
+// the user.
+func (as *authService) takeReauth(token, userId string) bool {
+	if as.oidc == nil {
+		return false
+	}
+	key := string(hashRefreshToken(token))
+
+	as.oidc.mu.Lock()
+	defer as.oidc.mu.Unlock()
+	reauth, ok := as.oidc.reauths[key]
+	if !ok || reauth.userId != userId {
+		return false
+	}
+	delete(as.oidc.reauths, key)
+	return !as.now().After(reauth.expiresAt)
+}
+
+func (rp *oidcRelyingParty) discover(ctx context.Context, provider *oidcProvider) (*oidcDiscovery, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.discovery != nil {
+		return provider.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(provider.config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	var discovery oidcDiscovery
+	if err := rp.doJSON(req, &discovery); err != nil {
+		return nil, err
+	}
+	// the issuer must be the one configured, OpenID Connect Discovery 4.3
+	if discovery.Issuer != provider.config.Issuer || discovery.AuthorizationEndpoint == "" ||
+		discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: bad discovery document of %s", ErrProviderUnavailable, provider.config.Name)
+	}
+	provider.discovery = &discovery
+	return provider.discovery, nil
+}
+
+// exchange trades the code for the ID token at the token endpoint.
+func (rp *oidcRelyingParty) exchange(ctx context.Context, provider *oidcProvider, discovery *oidcDiscovery, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {provider.config.ClientId},
+		"client_secret": {provider.config.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var response struct {
+		IdToken string `json:"id_token"`
+	}
+	if err := rp.doJSON(req, &response); err != nil {
+		return "", err
+	}
+	if response.IdToken == "" {
+		return "", fmt.Errorf("%w: no id_token", ErrOIDCLoginRejected)
+	}
+	return response.IdToken, nil
+}
+
+func (rp *oidcRelyingParty) doJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxOIDCResponseBytes))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	switch {
+	// a refused code is the player's, a broken provider is ours
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return fmt.Errorf("%w: %s answered %d", ErrOIDCLoginRejected, req.URL.Host, res.StatusCode)
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: %s answered %d", ErrProviderUnavailable, req.URL.Host, res.StatusCode)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrProviderUnavailable, err)
+	}
+	return nil
+}
+
+var notUsernameChars = regexp.MustCompile("[^a-z0-9_]+")
+
+// suggestUsername makes a username of the provider's, or of the email, for
+// the player to keep or change.
+func suggestUsername(claims map[string]any) string {
+	name, _ := claims["preferred_username"].(string)
+	if name == "" {
+		email, _ := claims["email"].(string)
+		name, _, _ = strings.Cut(email, "@")
+	}
+	name = notUsernameChars.ReplaceAllString(strings.ToLower(name), "_")
+	name = strings.TrimPrefix(name, guestUsernamePrefix)
+	// the column is VARCHAR(15)
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	if !validateUsernameFormat(name) {
+		return ""
+	}
+	return name
+}
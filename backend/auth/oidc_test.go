@@ -0,0 +1,251 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"api/auth"
+	"api/auth/oidctest"
+	"api/crypto"
+	"api/domain"
+)
+
+const callbackURL = "https://api.example.com/auth/oidc/test/callback"
+
+// newOIDCService signs in with a stand-in provider named "test", at the time
+// now tells.
+func newOIDCService(t *testing.T, now func() time.Time) (*oidctest.Server, *MockUserRepo, *MockTokenManager, auth.AuthService) {
+	provider := oidctest.NewServer()
+	t.Cleanup(provider.Close)
+
+	r, tm := new(MockUserRepo), new(MockTokenManager)
+	authService := auth.NewService(r, r, r, new(MockPasswordHasher), tm)
+	authService.SetClock(now)
+	authService.SetOIDC(r, crypto.NewIDTokenVerifier(provider.Client()), provider.Client(), auth.OIDCProviderConfig{
+		Name:         "test",
+		Issuer:       provider.Issuer(),
+		ClientId:     oidctest.ClientId,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  callbackURL,
+	})
+	return provider, r, tm, authService
+}
+
+// authorize follows the player to the provider and back, the query of the
+// callback it would be sent to.
+func authorize(t *testing.T, provider *oidctest.Server, authURL string) url.Values {
+	client := provider.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	res, err := client.Get(authURL)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusFound, res.StatusCode)
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, callbackURL, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func TestOIDC(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("a first login picks a username and is linked", func(t *testing.T) {
+		t.Parallel()
+		provider, r, tm, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-1", PreferredUsername: "Oussama.B"})
+
+		authURL, state, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+		assert.Equal(t, state, callback.Get("state"))
+
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-1").Return("", domain.ErrIdentityNotFound).Once()
+		result, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.SignupToken)
+		assert.Empty(t, result.Tokens.Access)
+		assert.Equal(t, "oussama_b", result.Username)
+
+		r.On("CreateUserWithIdentity", mock.Anything, "taken", "test", "sub-1").Return("", domain.ErrDuplicateUsername).Once()
+		_, err = authService.CompleteOIDCSignup(ctx, result.SignupToken, "taken")
+		assert.ErrorIs(t, err, domain.ErrDuplicateUsername)
+
+		r.On("CreateUserWithIdentity", mock.Anything, "oussama", "test", "sub-1").Return("111", nil).Once()
+		tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil).Once()
+		r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil).Once()
+		tokens, err := authService.CompleteOIDCSignup(ctx, result.SignupToken, "oussama")
+		require.NoError(t, err)
+		assert.Equal(t, "111.tokkken", tokens.Access)
+		assert.NotEmpty(t, tokens.Refresh)
+
+		_, err = authService.CompleteOIDCSignup(ctx, result.SignupToken, "oussama")
+		assert.ErrorIs(t, err, auth.ErrOIDCSignupInvalid)
+		r.AssertExpectations(t)
+		tm.AssertExpectations(t)
+	})
+
+	t.Run("a linked identity gets its session", func(t *testing.T) {
+		t.Parallel()
+		provider, r, tm, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-2"})
+
+		authURL, _, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-2").Return("222", nil).Once()
+		r.On("GetUserById", mock.Anything, "222").Return(domain.User{Id: "222"}, nil).Once()
+		tm.On("Generate", "222", mock.Anything).Return("222.tokkken", nil).Once()
+		r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("222"))).Return(nil).Once()
+		result, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+		assert.Equal(t, "222.tokkken", result.Tokens.Access)
+		assert.Empty(t, result.SignupToken)
+
+		// a state works once
+		_, err = authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		assert.ErrorIs(t, err, auth.ErrOIDCStateInvalid)
+		r.AssertExpectations(t)
+	})
+
+	t.Run("a linked identity with 2FA on still needs its code", func(t *testing.T) {
+		t.Parallel()
+		provider, r, _, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-6"})
+
+		authURL, _, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-6").Return("666", nil).Once()
+		r.On("GetUserById", mock.Anything, "666").Return(domain.User{Id: "666", TOTPEnabled: true}, nil).Once()
+		result, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.Tokens.MFA)
+		assert.Empty(t, result.Tokens.Access)
+		assert.Empty(t, result.Tokens.Refresh)
+		r.AssertExpectations(t)
+	})
+
+	t.Run("an account without a password signs in again to link another identity", func(t *testing.T) {
+		t.Parallel()
+		provider, r, _, authService := newOIDCService(t, time.Now)
+		r.On("GetUserById", mock.Anything, "777").Return(domain.User{Id: "777", Username: "oidc_only"}, nil)
+
+		_, _, err := authService.StartOIDCLink(ctx, "test", "777", auth.Proof{Password: ""})
+		assert.ErrorIs(t, err, auth.ErrReauthRequired)
+
+		provider.LogIn(oidctest.User{Subject: "sub-7"})
+		authURL, _, err := authService.StartOIDCReauth(ctx, "test", "777")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-7").Return("777", nil).Once()
+		reauth, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+		require.NotEmpty(t, reauth.ReauthToken)
+		assert.Empty(t, reauth.Tokens.Access)
+
+		provider.LogIn(oidctest.User{Subject: "sub-8"})
+		authURL, _, err = authService.StartOIDCLink(ctx, "test", "777", auth.Proof{ReauthToken: reauth.ReauthToken})
+		require.NoError(t, err)
+		callback = authorize(t, provider, authURL)
+		r.On("LinkIdentity", mock.Anything, "777", "test", "sub-8").Return(nil).Once()
+		linked, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+		assert.True(t, linked.Linked)
+
+		_, _, err = authService.StartOIDCLink(ctx, "test", "777", auth.Proof{ReauthToken: reauth.ReauthToken})
+		assert.ErrorIs(t, err, auth.ErrReauthRequired, "a reauth token works once")
+		r.AssertExpectations(t)
+	})
+
+	t.Run("a reauth with the identity of another user is refused", func(t *testing.T) {
+		t.Parallel()
+		provider, r, _, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-9"})
+
+		authURL, _, err := authService.StartOIDCReauth(ctx, "test", "777")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-9").Return("999", nil).Once()
+		_, err = authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		assert.ErrorIs(t, err, auth.ErrOIDCLoginRejected)
+	})
+
+	t.Run("an unknown state is refused", func(t *testing.T) {
+		t.Parallel()
+		provider, _, _, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-3"})
+
+		authURL, _, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+		_, err = authService.FinishOIDC(ctx, "test", "forged", callback.Get("code"))
+		assert.ErrorIs(t, err, auth.ErrOIDCStateInvalid)
+	})
+
+	t.Run("a code of another flow fails PKCE", func(t *testing.T) {
+		t.Parallel()
+		provider, _, _, authService := newOIDCService(t, time.Now)
+		provider.LogIn(oidctest.User{Subject: "sub-4"})
+
+		authURL, _, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		stolen := authorize(t, provider, authURL)
+		_, state, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+
+		_, err = authService.FinishOIDC(ctx, "test", state, stolen.Get("code"))
+		assert.ErrorIs(t, err, auth.ErrOIDCLoginRejected)
+	})
+
+	t.Run("an unknown provider", func(t *testing.T) {
+		t.Parallel()
+		_, _, _, authService := newOIDCService(t, time.Now)
+		_, _, err := authService.StartOIDC(ctx, "nope")
+		assert.ErrorIs(t, err, auth.ErrUnknownProvider)
+		assert.Equal(t, []string{"test"}, authService.OIDCProviders())
+	})
+
+	t.Run("a provider down", func(t *testing.T) {
+		t.Parallel()
+		provider, _, _, authService := newOIDCService(t, time.Now)
+		provider.Close()
+		_, _, err := authService.StartOIDC(ctx, "test")
+		assert.ErrorIs(t, err, auth.ErrProviderUnavailable)
+	})
+
+	t.Run("a signup expires", func(t *testing.T) {
+		t.Parallel()
+		clock := time.Now()
+		provider, r, _, authService := newOIDCService(t, func() time.Time { return clock })
+		provider.LogIn(oidctest.User{Subject: "sub-5"})
+
+		authURL, _, err := authService.StartOIDC(ctx, "test")
+		require.NoError(t, err)
+		callback := authorize(t, provider, authURL)
+		r.On("GetUserIdByIdentity", mock.Anything, "test", "sub-5").Return("", domain.ErrIdentityNotFound).Once()
+		result, err := authService.FinishOIDC(ctx, "test", callback.Get("state"), callback.Get("code"))
+		require.NoError(t, err)
+
+		clock = clock.Add(auth.OIDCSignupAge + time.Second)
+		_, err = authService.CompleteOIDCSignup(ctx, result.SignupToken, "oussama")
+		assert.ErrorIs(t, err, auth.ErrOIDCSignupInvalid)
+	})
+
+	t.Run("a reserved username is refused", func(t *testing.T) {
+		t.Parallel()
+		_, _, _, authService := newOIDCService(t, time.Now)
+		_, err := authService.CompleteOIDCSignup(ctx, "whatever", "guest_123")
+		assert.ErrorIs(t, err, auth.ErrReservedUsername)
+	})
+}
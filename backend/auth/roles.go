@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"api/domain"
+	"context"
+	"time"
+)
+
+// RoleCacheAge is how long a role is trusted before it's looked up again, a
+// role taken away in another instance lasts that long there.
+const RoleCacheAge = 30 * time.Second
+
+type cachedRole struct {
+	role      domain.Role
+	expiresAt time.Time
+}
+
+// Role returns the role of the user, guests are players.
+func (as *authService) Role(ctx context.Context, userId string) (domain.Role, error) {
+	if domain.IsGuestId(userId) {
+		return domain.RolePlayer, nil
+	}
+
+	now := as.now()
+	as.mu.Lock()
+	cached, ok := as.roles[userId]
+	as.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.role, nil
+	}
+
+	role, err := as.UserRepo.GetUserRole(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for id, entry := range as.roles {
+		if !now.Before(entry.expiresAt) {
+			delete(as.roles, id)
+		}
+	}
+	as.roles[userId] = cachedRole{role: role, expiresAt: now.Add(RoleCacheAge)}
+	return role, nil
+}
+
+// SetRole gives the user of the username the role, this instance sees it
+// right away.
+func (as *authService) SetRole(ctx context.Context, username string, role domain.Role) error {
+	if !role.Valid() {
+		return domain.ErrInvalidRole
+	}
+	userId, err := as.UserRepo.SetUserRole(ctx, username, role)
+	if err != nil {
+		return err
+	}
+	as.forgetRole(userId)
+	return nil
+}
+
+func (as *authService) forgetRole(userId string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	delete(as.roles, userId)
+}
@@ -1,19 +1,100 @@
 package auth
 
 import (
+	"api/domain"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 )
 
+// GuestTokenAge is how long guest tokens and the guest cookie live, the
+// token manager must be set up with it too.
+const GuestTokenAge = 2 * time.Hour
+
+// RefreshTokenAge is how long a session lasts without being refreshed.
+const RefreshTokenAge = 7 * 24 * time.Hour
+
+// RefreshReuseGrace is how long a spent refresh token still rotates, so that
+// two tabs refreshing at once don't end the session.
+const RefreshReuseGrace = 10 * time.Second
+
+// Tokens are what a session is made of: the short lived access token every
+// request carries, and the refresh token that gets a new pair once.
+type Tokens struct {
+	Access  string
+	Refresh string
+	// MFA is set instead when the login waits for a second factor, it is
+	// exchanged with a code for the session.
+	MFA string
+}
+
+// MFAPendingAge is how long a login that passed the password has to send its
+// code.
+const MFAPendingAge = 5 * time.Minute
+
+// a pending login takes this many codes, then the password is asked again
+const maxMFAAttempts = 5
+
+// UsernameChangeCooldown is how long after a username change the next one
+// is allowed.
+const UsernameChangeCooldown = 30 * 24 * time.Hour
+
+const (
+	// guest nicknames are this prefix and random hex, signups can't take it
+	guestUsernamePrefix = "guest_"
+	// a nickname taken this many times in a row means something is off
+	maxGuestNicknameAttempts = 8
+)
+
 type authService struct {
 	UserRepo       UserRepo
+	sessionRepo    SessionRepo
+	totpRepo       TOTPRepo
 	passwordHasher PasswordHasher
 	tokenManager   TokenManager
+	now            func() time.Time
+
+	mu sync.Mutex
+	// nicknames handed out to guests, until their tokens expire
+	guests map[string]time.Time
+	// logins waiting for their second factor, by token hash
+	mfaPending map[string]*mfaLogin
+	// roles looked up lately, by user id
+	roles map[string]cachedRole
+	// nil until SetOIDC
+	oidc *oidcRelyingParty
 }
 
-func NewService(userRepo UserRepo, passwordHasher PasswordHasher, tokenManager TokenManager) *authService {
-	return &authService{userRepo, passwordHasher, tokenManager}
+type mfaLogin struct {
+	userId    string
+	expiresAt time.Time
+	attempts  int
+}
+
+func NewService(userRepo UserRepo, sessionRepo SessionRepo, totpRepo TOTPRepo, passwordHasher PasswordHasher, tokenManager TokenManager) *authService {
+	return &authService{
+		UserRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		totpRepo:       totpRepo,
+		passwordHasher: passwordHasher,
+		tokenManager:   tokenManager,
+		now:            time.Now,
+		guests:         make(map[string]time.Time),
+		mfaPending:     make(map[string]*mfaLogin),
+		roles:          make(map[string]cachedRole),
+	}
+}
+
+// SetClock replaces time.Now, for tests to move through TOTP steps.
+func (as *authService) SetClock(now func() time.Time) {
+	as.now = now
 }
 
 var usernameRegex = regexp.MustCompile("^[a-z0-9_]{3,20}$")
@@ -23,58 +104,288 @@ func validateUsernameFormat(username string) bool {
 	return match
 }
 
-func (as *authService) Signup(ctx context.Context, username, password string) (string, error) {
+func (as *authService) Signup(ctx context.Context, username, password string) (Tokens, error) {
 	if !validateUsernameFormat(username) {
-		return "", ErrInvalidUsernameFormat
+		return Tokens{}, ErrInvalidUsernameFormat
 	}
 
+	if strings.HasPrefix(username, guestUsernamePrefix) {
+		return Tokens{}, ErrReservedUsername
+	}
+
+	return as.createAccount(ctx, username, password)
+}
+
+func validatePassword(password string) error {
 	if len(password) < 8 {
-		return "", ErrWeakPassword
+		return ErrWeakPassword
 	}
 
 	if len(password) > 100 {
-		return "", ErrPasswordTooLong
+		return ErrPasswordTooLong
 	}
+	return nil
+}
 
-	passwordHash, err := as.passwordHasher.Hash(password)
+// createAccount is the part of a signup after the username checks.
+func (as *authService) createAccount(ctx context.Context, username, password string) (Tokens, error) {
+	if err := validatePassword(password); err != nil {
+		return Tokens{}, err
+	}
+
+	passwordHash, err := as.passwordHasher.Hash(ctx, password)
 	if err != nil {
-		return "", err
+		return Tokens{}, err
 	}
 
 	id, err := as.UserRepo.CreateUser(ctx, username, passwordHash)
 	if err != nil {
-		return "", err
+		return Tokens{}, err
 	}
 
-	token, err := as.tokenManager.Generate(id, time.Now())
+	return as.startSession(ctx, id)
+}
+
+func (as *authService) Login(ctx context.Context, username, password string) (Tokens, error) {
+	player, err := as.UserRepo.GetUserByUsername(ctx, username)
+
 	if err != nil {
-		return "", err
+		return Tokens{}, err
+	}
+	// accounts made through an OpenID provider have no password
+	if player.PasswordHash == "" {
+		return Tokens{}, ErrIncorrectPassword
 	}
 
-	return token, nil
+	match, err := as.passwordHasher.Compare(ctx, player.PasswordHash, password)
+
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if !match {
+		return Tokens{}, ErrIncorrectPassword
+	}
+	if as.passwordHasher.NeedsRehash(player.PasswordHash) {
+		as.rehashPassword(ctx, player, password)
+	}
+	if player.TOTPEnabled {
+		return Tokens{MFA: as.startMFA(player.Id)}, nil
+	}
+	return as.startSession(ctx, player.Id)
 }
 
-func (as *authService) Login(ctx context.Context, username, password string) (string, error) {
-	player, err := as.UserRepo.GetUserByUsername(ctx, username)
+// rehashPassword moves the user to the current hashing params. It only runs
+// with the right password at hand, a failure keeps the old hash and the
+// login goes on.
+func (as *authService) rehashPassword(ctx context.Context, player domain.User, password string) {
+	passwordHash, err := as.passwordHasher.Hash(ctx, password)
+	if err == nil {
+		err = as.UserRepo.UpdatePasswordHash(ctx, player.Id, player.PasswordHash, passwordHash)
+	}
+	if err != nil {
+		slog.Warn("Login: password rehash failed", "user_id", player.Id, "error", err.Error())
+	}
+}
+
+// startSession issues the tokens of a new session, its refresh token starts
+// a new family.
+func (as *authService) startSession(ctx context.Context, userId string) (Tokens, error) {
+	return as.issueTokens(ctx, userId, "")
+}
 
+// issueTokens signs an access token and stores a refresh token in the
+// family, a new one when familyId is empty.
+func (as *authService) issueTokens(ctx context.Context, userId, familyId string) (Tokens, error) {
+	now := as.now()
+	access, err := as.tokenManager.Generate(userId, now)
 	if err != nil {
-		return "", err
+		return Tokens{}, err
 	}
 
-	match, err := as.passwordHasher.Compare(player.PasswordHash, password)
+	refresh := newRefreshToken()
+	err = as.sessionRepo.CreateRefreshToken(ctx, domain.RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		FamilyId:  familyId,
+		UserId:    userId,
+		ExpiresAt: now.Add(RefreshTokenAge),
+	})
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{Access: access, Refresh: refresh}, nil
+}
 
+// Refresh trades a refresh token for a new pair, the old one is spent. A
+// spent token coming back after RefreshReuseGrace means it leaked, the repo
+// then revokes its whole family and ErrRefreshTokenReused is returned.
+func (as *authService) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	now := as.now()
+	refresh := newRefreshToken()
+	spent, err := as.sessionRepo.RotateRefreshToken(ctx, hashRefreshToken(refreshToken), domain.RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		ExpiresAt: now.Add(RefreshTokenAge),
+	}, now, RefreshReuseGrace)
 	if err != nil {
-		return "", err
+		return Tokens{}, err
+	}
+
+	access, err := as.tokenManager.Generate(spent.UserId, now)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{Access: access, Refresh: refresh}, nil
+}
+
+// Logout revokes the session of the refresh token, the access tokens already
+// out stay valid until they expire.
+func (as *authService) Logout(ctx context.Context, refreshToken string) error {
+	return as.sessionRepo.RevokeRefreshTokenFamily(ctx, hashRefreshToken(refreshToken))
+}
+
+// PruneSessions deletes the refresh tokens of sessions that are over every
+// interval until ctx is done.
+func (as *authService) PruneSessions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := as.sessionRepo.DeleteRefreshTokensBefore(ctx, as.now()); err != nil && ctx.Err() == nil {
+				slog.Error("PruneSessions: failed to delete refresh tokens", "error", err.Error())
+			}
+		}
+	}
+}
+
+// LogoutEverywhere revokes every session of the user.
+func (as *authService) LogoutEverywhere(ctx context.Context, userId string) error {
+	return as.sessionRepo.RevokeUserRefreshTokens(ctx, userId)
+}
+
+// Proof confirms a change to an account: its password, or for an account
+// signing in through providers only, a code of its authenticator or the
+// reauth token of a fresh login with one of them.
+type Proof struct {
+	Password    string
+	Code        string
+	ReauthToken string
+}
+
+// checkProof loads the user and makes sure the proof is theirs.
+func (as *authService) checkProof(ctx context.Context, userId string, proof Proof) (domain.User, error) {
+	user, err := as.UserRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return domain.User{}, err
 	}
 
+	if user.PasswordHash == "" {
+		switch {
+		case proof.ReauthToken != "" && as.takeReauth(proof.ReauthToken, userId):
+			return user, nil
+		case proof.Code != "" && user.TOTPEnabled:
+			if err := as.checkSecondFactor(ctx, userId, proof.Code); err != nil {
+				return domain.User{}, err
+			}
+			return user, nil
+		}
+		return domain.User{}, ErrReauthRequired
+	}
+
+	match, err := as.passwordHasher.Compare(ctx, user.PasswordHash, proof.Password)
+	if err != nil {
+		return domain.User{}, err
+	}
 	if !match {
-		return "", ErrIncorrectPassword
+		return domain.User{}, ErrIncorrectPassword
 	}
-	token, err := as.tokenManager.Generate(player.Id, time.Now())
+	return user, nil
+}
+
+// ChangePassword revokes every session of the user, a stolen one must not
+// outlive the change, then starts a new one for the caller. Access tokens
+// already out stay valid until they expire. An account without a password
+// gets its first one.
+func (as *authService) ChangePassword(ctx context.Context, userId string, proof Proof, newPassword string) (Tokens, error) {
+	if err := validatePassword(newPassword); err != nil {
+		return Tokens{}, err
+	}
+
+	user, err := as.checkProof(ctx, userId, proof)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	passwordHash, err := as.passwordHasher.Hash(ctx, newPassword)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	// a concurrent change won, the current password checked is no longer it
+	err = as.UserRepo.UpdatePasswordHash(ctx, userId, user.PasswordHash, passwordHash)
+	if errors.Is(err, domain.ErrPasswordChanged) {
+		return Tokens{}, ErrIncorrectPassword
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := as.sessionRepo.RevokeUserRefreshTokens(ctx, userId); err != nil {
+		return Tokens{}, err
+	}
+	return as.startSession(ctx, userId)
+}
+
+// Username is the current username of the user.
+func (as *authService) Username(ctx context.Context, userId string) (string, error) {
+	user, err := as.UserRepo.GetUserById(ctx, userId)
 	if err != nil {
 		return "", err
 	}
-	return token, nil
+	return user.Username, nil
+}
+
+// ChangeUsername renames the user at most once per UsernameChangeCooldown.
+// Guessers of drawings and replays keep the name played under.
+func (as *authService) ChangeUsername(ctx context.Context, userId, username string) error {
+	if !validateUsernameFormat(username) {
+		return ErrInvalidUsernameFormat
+	}
+
+	if strings.HasPrefix(username, guestUsernamePrefix) {
+		return ErrReservedUsername
+	}
+
+	now := as.now()
+	return as.UserRepo.UpdateUsername(ctx, userId, username, now.Add(-UsernameChangeCooldown), now)
+}
+
+// DeleteAccount removes the user with everything it owns: sessions,
+// webhooks and gallery drawings. The name stays among the guessers of other
+// drawings and in the replays of its rooms, as it was played under.
+func (as *authService) DeleteAccount(ctx context.Context, userId string, proof Proof) error {
+	if _, err := as.checkProof(ctx, userId, proof); err != nil {
+		return err
+	}
+	if err := as.UserRepo.DeleteUser(ctx, userId); err != nil {
+		return err
+	}
+	as.forgetRole(userId)
+	return nil
+}
+
+func newRefreshToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// only the hash is stored, a leaked table hands out no sessions
+func hashRefreshToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
 }
 
 // VerifyToken returns the id if the token is valid, else, it returns an error
@@ -82,6 +393,229 @@ func (as *authService) VerifyToken(token string) (string, error) {
 	return as.tokenManager.Verify(token)
 }
 
+// GenerateToken re-signs the access token of a guest, guests have no row to
+// keep refresh tokens for.
 func (as *authService) GenerateToken(id string) (string, error) {
-	return as.tokenManager.Generate(id, time.Now())
+	if nickname, ok := domain.GuestNickname(id); ok {
+		as.holdGuestNickname(nickname, as.now())
+	}
+	return as.tokenManager.Generate(id, as.now())
+}
+
+// Guest starts a guest session under a generated nickname, no user row is
+// created for it.
+func (as *authService) Guest(ctx context.Context) (string, string, error) {
+	nickname, err := as.newGuestNickname(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := as.tokenManager.Generate(domain.GuestId(nickname), as.now())
+	if err != nil {
+		as.releaseGuestNickname(nickname)
+		return "", "", err
+	}
+	return token, nickname, nil
+}
+
+// Upgrade turns a guest into an account. An empty username keeps the guest's
+// nickname, so rooms the guest is in take the account for the same player.
+func (as *authService) Upgrade(ctx context.Context, guestId, username, password string) (Tokens, error) {
+	nickname, ok := domain.GuestNickname(guestId)
+	if !ok {
+		return Tokens{}, ErrNotGuest
+	}
+	if username == "" {
+		username = nickname
+	}
+	if username != nickname {
+		return as.Signup(ctx, username, password)
+	}
+
+	tokens, err := as.createAccount(ctx, username, password)
+	if err != nil {
+		return Tokens{}, err
+	}
+	// the row holds the nickname from now on
+	as.releaseGuestNickname(nickname)
+	return tokens, nil
+}
+
+// newGuestNickname picks a nickname no live guest and no account has.
+func (as *authService) newGuestNickname(ctx context.Context) (string, error) {
+	for range maxGuestNicknameAttempts {
+		b := make([]byte, 4)
+		rand.Read(b)
+		nickname := guestUsernamePrefix + hex.EncodeToString(b)
+		if !as.holdGuestNickname(nickname, as.now()) {
+			continue
+		}
+		_, err := as.UserRepo.GetUserByUsername(ctx, nickname)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nickname, nil
+		}
+		as.releaseGuestNickname(nickname)
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", ErrNoGuestNickname
+}
+
+// holdGuestNickname keeps the nickname for as long as a token issued now
+// lives. It returns false when a new guest asks for one still held, refreshes
+// of the guest holding it always get it.
+func (as *authService) holdGuestNickname(nickname string, now time.Time) bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for held, until := range as.guests {
+		if now.After(until) {
+			delete(as.guests, held)
+		}
+	}
+	_, taken := as.guests[nickname]
+	as.guests[nickname] = now.Add(GuestTokenAge)
+	return !taken
+}
+
+func (as *authService) releaseGuestNickname(nickname string) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	delete(as.guests, nickname)
+}
+
+// startMFA parks a login that passed the password until its code comes.
+func (as *authService) startMFA(userId string) string {
+	token := newRefreshToken()
+	now := as.now()
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for key, login := range as.mfaPending {
+		if now.After(login.expiresAt) {
+			delete(as.mfaPending, key)
+		}
+	}
+	as.mfaPending[string(hashRefreshToken(token))] = &mfaLogin{userId: userId, expiresAt: now.Add(MFAPendingAge)}
+	return token
+}
+
+// CompleteMFA lets each pending login try a few codes, guessing one of a
+// million takes the password again every maxMFAAttempts.
+func (as *authService) CompleteMFA(ctx context.Context, mfaToken, code string) (Tokens, error) {
+	key := string(hashRefreshToken(mfaToken))
+
+	as.mu.Lock()
+	login, ok := as.mfaPending[key]
+	if ok && (as.now().After(login.expiresAt) || login.attempts >= maxMFAAttempts) {
+		delete(as.mfaPending, key)
+		ok = false
+	}
+	if ok {
+		login.attempts++
+	}
+	as.mu.Unlock()
+
+	if !ok {
+		return Tokens{}, ErrMFATokenInvalid
+	}
+
+	if err := as.checkSecondFactor(ctx, login.userId, code); err != nil {
+		return Tokens{}, err
+	}
+
+	as.mu.Lock()
+	delete(as.mfaPending, key)
+	as.mu.Unlock()
+	return as.startSession(ctx, login.userId)
+}
+
+// checkSecondFactor takes a TOTP code, or a recovery code when the
+// authenticator is lost. Each works once.
+func (as *authService) checkSecondFactor(ctx context.Context, userId, code string) error {
+	code = normalizeCode(code)
+
+	if len(code) == totpDigits {
+		totp, err := as.totpRepo.GetTOTP(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if !totp.Enabled {
+			return domain.ErrTOTPNotEnrolled
+		}
+		step, ok := matchTOTP(totp.Secret, code, as.now())
+		if !ok {
+			return ErrIncorrectCode
+		}
+		return as.totpRepo.UseTOTPStep(ctx, userId, step)
+	}
+
+	recoveryCodes, err := as.totpRepo.GetRecoveryCodes(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, recoveryCode := range recoveryCodes {
+		match, err := as.passwordHasher.Compare(ctx, recoveryCode.Hash, code)
+		if err != nil {
+			return err
+		}
+		if match {
+			return as.totpRepo.UseRecoveryCode(ctx, recoveryCode.Id, as.now())
+		}
+	}
+	return ErrIncorrectCode
+}
+
+// EnrollTOTP starts a new enrollment, the user confirms it with a first code
+// from the app.
+func (as *authService) EnrollTOTP(ctx context.Context, userId string) (string, string, error) {
+	user, err := as.UserRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", domain.ErrTOTPAlreadyEnabled
+	}
+
+	secret := newTOTPSecret()
+	if err := as.totpRepo.SetTOTPSecret(ctx, userId, secret); err != nil {
+		return "", "", err
+	}
+	return base32NoPadding.EncodeToString(secret), totpURI(user.Username, secret), nil
+}
+
+func (as *authService) ConfirmTOTP(ctx context.Context, userId, code string) ([]string, error) {
+	totp, err := as.totpRepo.GetTOTP(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, domain.ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := matchTOTP(totp.Secret, normalizeCode(code), as.now())
+	if !ok {
+		return nil, ErrIncorrectCode
+	}
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCodes[i] = newRecoveryCode()
+		hashes[i], err = as.passwordHasher.Hash(ctx, normalizeCode(recoveryCodes[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := as.totpRepo.EnableTOTP(ctx, userId, hashes, step); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (as *authService) DisableTOTP(ctx context.Context, userId string, proof Proof) error {
+	if _, err := as.checkProof(ctx, userId, proof); err != nil {
+		return err
+	}
+	return as.totpRepo.DisableTOTP(ctx, userId)
 }
@@ -2,12 +2,16 @@ package auth_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base32"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"api/auth"
 	"api/domain"
@@ -32,20 +36,134 @@ func (m *MockUserRepo) GetUserById(ctx context.Context, id string) (domain.User,
 	return args.Get(0).(domain.User), args.Error(1)
 }
 
+func (m *MockUserRepo) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdatePasswordHash(ctx context.Context, id string, oldHash, passwordHash string) error {
+	args := m.Called(ctx, id, oldHash, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UpdateUsername(ctx context.Context, id string, username string, changedBefore, now time.Time) error {
+	args := m.Called(ctx, id, username, changedBefore, now)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) DeleteUser(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetUserRole(ctx context.Context, id string) (domain.Role, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(domain.Role), args.Error(1)
+}
+
+func (m *MockUserRepo) SetUserRole(ctx context.Context, username string, role domain.Role) (string, error) {
+	args := m.Called(ctx, username, role)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepo) SetTOTPSecret(ctx context.Context, userId string, secret []byte) error {
+	args := m.Called(ctx, userId, secret)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetTOTP(ctx context.Context, userId string) (domain.TOTP, error) {
+	args := m.Called(ctx, userId)
+	return args.Get(0).(domain.TOTP), args.Error(1)
+}
+
+func (m *MockUserRepo) EnableTOTP(ctx context.Context, userId string, recoveryCodeHashes []string, step int64) error {
+	args := m.Called(ctx, userId, recoveryCodeHashes, step)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) DisableTOTP(ctx context.Context, userId string) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) UseTOTPStep(ctx context.Context, userId string, step int64) error {
+	args := m.Called(ctx, userId, step)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetRecoveryCodes(ctx context.Context, userId string) ([]domain.RecoveryCode, error) {
+	args := m.Called(ctx, userId)
+	codes, _ := args.Get(0).([]domain.RecoveryCode)
+	return codes, args.Error(1)
+}
+
+func (m *MockUserRepo) UseRecoveryCode(ctx context.Context, id string, now time.Time) error {
+	args := m.Called(ctx, id, now)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) GetUserIdByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	args := m.Called(ctx, provider, subject)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepo) CreateUserWithIdentity(ctx context.Context, username, provider, subject string) (string, error) {
+	args := m.Called(ctx, username, provider, subject)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUserRepo) LinkIdentity(ctx context.Context, userId, provider, subject string) error {
+	args := m.Called(ctx, userId, provider, subject)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) RotateRefreshToken(ctx context.Context, hash []byte, next domain.RefreshToken, now time.Time, reuseGrace time.Duration) (domain.RefreshToken, error) {
+	args := m.Called(ctx, hash, next, now, reuseGrace)
+	return args.Get(0).(domain.RefreshToken), args.Error(1)
+}
+
+func (m *MockUserRepo) DeleteRefreshTokensBefore(ctx context.Context, cutoff time.Time) error {
+	args := m.Called(ctx, cutoff)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) RevokeRefreshTokenFamily(ctx context.Context, hash []byte) error {
+	args := m.Called(ctx, hash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
+// newFamilyOf matches the first refresh token of a session of the user.
+func newFamilyOf(userId string) func(domain.RefreshToken) bool {
+	return func(token domain.RefreshToken) bool {
+		return token.UserId == userId && token.FamilyId == "" && len(token.Hash) == sha256.Size &&
+			time.Until(token.ExpiresAt) > auth.RefreshTokenAge-time.Minute
+	}
+}
+
 type MockPasswordHasher struct {
 	mock.Mock
 }
 
-func (m *MockPasswordHasher) Hash(password string) (string, error) {
-	args := m.Called(password)
+func (m *MockPasswordHasher) Hash(ctx context.Context, password string) (string, error) {
+	args := m.Called(ctx, password)
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockPasswordHasher) Compare(hash, password string) (bool, error) {
-	args := m.Called(hash, password)
+func (m *MockPasswordHasher) Compare(ctx context.Context, hash, password string) (bool, error) {
+	args := m.Called(ctx, hash, password)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockPasswordHasher) NeedsRehash(hash string) bool {
+	args := m.Called(hash)
+	return args.Bool(0)
+}
+
 type MockTokenManager struct {
 	mock.Mock
 }
@@ -82,9 +200,10 @@ func TestSignup(t *testing.T) {
 			username:    "oussama",
 			password:    "12345678",
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
-				h.On("Hash", "12345678").Return("hashed_secret", nil)
+				h.On("Hash", mock.Anything, "12345678").Return("hashed_secret", nil)
 				r.On("CreateUser", mock.Anything, "oussama", "hashed_secret").Return("111-111", nil)
 				tm.On("Generate", "111-111", mock.AnythingOfType("time.Time")).Return("111-111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111-111"))).Return(nil)
 			},
 			expectedToken: "111-111.tokkken",
 			expectedError: nil,
@@ -94,7 +213,7 @@ func TestSignup(t *testing.T) {
 			username:    "oussama",
 			password:    "12345678",
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
-				h.On("Hash", "12345678").Return("", errors.Join(domain.UnexpectedPasswordHashingError, exampleErr))
+				h.On("Hash", mock.Anything, "12345678").Return("", errors.Join(domain.UnexpectedPasswordHashingError, exampleErr))
 			},
 			expectedToken: "",
 			expectedError: domain.UnexpectedPasswordHashingError,
@@ -104,7 +223,7 @@ func TestSignup(t *testing.T) {
 			username:    "oussama",
 			password:    "12345678",
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
-				h.On("Hash", "12345678").Return("hashed_secret", nil)
+				h.On("Hash", mock.Anything, "12345678").Return("hashed_secret", nil)
 				r.On("CreateUser", mock.Anything, "oussama", "hashed_secret").Return("111-111", nil)
 
 				tm.On("Generate", "111-111", mock.Anything).Return("", errors.Join(domain.UnexpectedTokenGenerationError, exampleErr))
@@ -124,11 +243,18 @@ func TestSignup(t *testing.T) {
 			username:    "oussama145",
 			password:    "12345678",
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
-				h.On("Hash", "12345678").Return("hashed_secret", nil)
+				h.On("Hash", mock.Anything, "12345678").Return("hashed_secret", nil)
 				r.On("CreateUser", mock.Anything, "oussama145", "hashed_secret").Return("", domain.ErrDuplicateUsername)
 			},
 			expectedError: domain.ErrDuplicateUsername,
 		},
+		{
+			description:   "username taken from guests",
+			username:      "guest_0a1b2c3d",
+			password:      "12345678",
+			setupMocks:    func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {},
+			expectedError: auth.ErrReservedUsername,
+		},
 		{
 			description:   "short password",
 			username:      "oussama",
@@ -213,8 +339,8 @@ func TestSignup(t *testing.T) {
 				tc.setupMocks(mockRepo, mockHasher, mockToken)
 			}
 
-			authService := auth.NewService(mockRepo, mockHasher, mockToken)
-			token, err := authService.Signup(context.Background(), tc.username, tc.password)
+			authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, mockToken)
+			tokens, err := authService.Signup(context.Background(), tc.username, tc.password)
 
 			if tc.expectedError != nil {
 				assert.ErrorIs(t, err, tc.expectedError)
@@ -222,7 +348,7 @@ func TestSignup(t *testing.T) {
 				assert.NoError(t, err)
 			}
 
-			assert.Equal(t, tc.expectedToken, token)
+			assert.Equal(t, tc.expectedToken, tokens.Access)
 
 			// 5. Verify that expected calls (and ONLY expected calls) happened
 			mockRepo.AssertExpectations(t)
@@ -256,9 +382,62 @@ func TestLogin(t *testing.T) {
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
 				r.On("GetUserByUsername", mock.Anything, "oussama").
 					Return(domain.User{Id: "111", Username: "oussama", PasswordHash: "hashed_secret"}, nil)
-				h.On("Compare", "hashed_secret", "12345678").Return(true, nil)
+				h.On("Compare", mock.Anything, "hashed_secret", "12345678").Return(true, nil)
+				h.On("NeedsRehash", "hashed_secret").Return(false)
 				tm.On("Generate", "111", mock.AnythingOfType("time.Time")).
 					Return("111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil)
+			},
+			expectedToken: "111.tokkken",
+			expectedError: nil,
+		},
+		{
+			description: "weak hash gets upgraded",
+			username:    "oussama",
+			password:    "12345678",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserByUsername", mock.Anything, "oussama").
+					Return(domain.User{Id: "111", Username: "oussama", PasswordHash: "weak_hash"}, nil)
+				h.On("Compare", mock.Anything, "weak_hash", "12345678").Return(true, nil)
+				h.On("NeedsRehash", "weak_hash").Return(true)
+				h.On("Hash", mock.Anything, "12345678").Return("strong_hash", nil)
+				r.On("UpdatePasswordHash", mock.Anything, "111", "weak_hash", "strong_hash").Return(nil)
+				tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil)
+			},
+			expectedToken: "111.tokkken",
+			expectedError: nil,
+		},
+		{
+			description: "failed upgrade still logs in",
+			username:    "oussama",
+			password:    "12345678",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserByUsername", mock.Anything, "oussama").
+					Return(domain.User{Id: "111", Username: "oussama", PasswordHash: "weak_hash"}, nil)
+				h.On("Compare", mock.Anything, "weak_hash", "12345678").Return(true, nil)
+				h.On("NeedsRehash", "weak_hash").Return(true)
+				h.On("Hash", mock.Anything, "12345678").Return("strong_hash", nil)
+				r.On("UpdatePasswordHash", mock.Anything, "111", "weak_hash", "strong_hash").
+					Return(errors.Join(domain.UnexpectedDatabaseError, exampleErr))
+				tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil)
+			},
+			expectedToken: "111.tokkken",
+			expectedError: nil,
+		},
+		{
+			description: "hashing failure on upgrade still logs in",
+			username:    "oussama",
+			password:    "12345678",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserByUsername", mock.Anything, "oussama").
+					Return(domain.User{Id: "111", Username: "oussama", PasswordHash: "weak_hash"}, nil)
+				h.On("Compare", mock.Anything, "weak_hash", "12345678").Return(true, nil)
+				h.On("NeedsRehash", "weak_hash").Return(true)
+				h.On("Hash", mock.Anything, "12345678").Return("", errors.Join(domain.UnexpectedPasswordHashingError, exampleErr))
+				tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil)
 			},
 			expectedToken: "111.tokkken",
 			expectedError: nil,
@@ -281,7 +460,7 @@ func TestLogin(t *testing.T) {
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
 				r.On("GetUserByUsername", mock.Anything, "oussama").
 					Return(domain.User{Id: "111", PasswordHash: "hashed_secret"}, nil)
-				h.On("Compare", "hashed_secret", "wrong_pass").Return(false, nil)
+				h.On("Compare", mock.Anything, "hashed_secret", "wrong_pass").Return(false, nil)
 			},
 			expectedToken: "",
 			expectedError: auth.ErrIncorrectPassword,
@@ -293,7 +472,7 @@ func TestLogin(t *testing.T) {
 			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
 				r.On("GetUserByUsername", mock.Anything, "oussama").
 					Return(domain.User{Id: "111", PasswordHash: "hashed_secret"}, nil)
-				h.On("Compare", "hashed_secret", "12345678").
+				h.On("Compare", mock.Anything, "hashed_secret", "12345678").
 					Return(false, errors.Join(domain.UnexpectedPasswordHashingError, exampleErr))
 			},
 			expectedToken: "",
@@ -307,7 +486,8 @@ func TestLogin(t *testing.T) {
 				r.On("GetUserByUsername", mock.Anything, "oussama_yaqdane").
 					Return(domain.User{Id: "111", PasswordHash: "hashed_secret"}, nil)
 
-				h.On("Compare", "hashed_secret", "12345678").Return(true, nil)
+				h.On("Compare", mock.Anything, "hashed_secret", "12345678").Return(true, nil)
+				h.On("NeedsRehash", "hashed_secret").Return(false)
 				tm.On("Generate", "111", mock.Anything).
 					Return("", errors.Join(domain.UnexpectedTokenGenerationError, exampleErr))
 			},
@@ -327,16 +507,301 @@ func TestLogin(t *testing.T) {
 				tc.setupMocks(mockRepo, mockHasher, mockToken)
 			}
 
-			authService := auth.NewService(mockRepo, mockHasher, mockToken)
+			authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, mockToken)
+
+			tokens, err := authService.Login(context.Background(), tc.username, tc.password)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedToken, tokens.Access)
+
+			mockRepo.AssertExpectations(t)
+			mockHasher.AssertExpectations(t)
+			mockToken.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGuest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("generates unused nicknames", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := new(MockUserRepo)
+		mockToken := new(MockTokenManager)
+		// the first nickname drawn belongs to an upgraded guest
+		mockRepo.On("GetUserByUsername", mock.Anything, mock.Anything).Return(domain.User{Id: "111"}, nil).Once()
+		mockRepo.On("GetUserByUsername", mock.Anything, mock.Anything).Return(domain.User{}, domain.ErrUserNotFound)
+		mockToken.On("Generate", mock.MatchedBy(domain.IsGuestId), mock.Anything).Return("guest.tokkken", nil)
+
+		authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), mockToken)
+		token, nickname, err := authService.Guest(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "guest.tokkken", token)
+		assert.Regexp(t, "^guest_[0-9a-f]{8}$", nickname)
+		mockToken.AssertCalled(t, "Generate", domain.GuestId(nickname), mock.Anything)
+		mockRepo.AssertNumberOfCalls(t, "GetUserByUsername", 2)
+
+		_, other, err := authService.Guest(context.Background())
+		assert.NoError(t, err)
+		assert.NotEqual(t, nickname, other)
+	})
+
+	t.Run("database down", func(t *testing.T) {
+		t.Parallel()
+		mockRepo := new(MockUserRepo)
+		mockRepo.On("GetUserByUsername", mock.Anything, mock.Anything).Return(domain.User{}, domain.UnexpectedDatabaseError)
+
+		authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+		_, _, err := authService.Guest(context.Background())
+		assert.ErrorIs(t, err, domain.UnexpectedDatabaseError)
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Parallel()
+
+	type setupFn func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager)
+
+	testCases := []struct {
+		description   string
+		id            string
+		username      string
+		setupMocks    setupFn
+		expectedToken string
+		expectedError error
+	}{
+		{
+			description: "keeps the nickname",
+			id:          "guest:guest_0a1b2c3d",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				h.On("Hash", mock.Anything, "12345678").Return("hashed_secret", nil)
+				r.On("CreateUser", mock.Anything, "guest_0a1b2c3d", "hashed_secret").Return("111-111", nil)
+				tm.On("Generate", "111-111", mock.Anything).Return("111-111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111-111"))).Return(nil)
+			},
+			expectedToken: "111-111.tokkken",
+		},
+		{
+			description: "picks a username",
+			id:          "guest:guest_0a1b2c3d",
+			username:    "oussama",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				h.On("Hash", mock.Anything, "12345678").Return("hashed_secret", nil)
+				r.On("CreateUser", mock.Anything, "oussama", "hashed_secret").Return("111-111", nil)
+				tm.On("Generate", "111-111", mock.Anything).Return("111-111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111-111"))).Return(nil)
+			},
+			expectedToken: "111-111.tokkken",
+		},
+		{
+			description:   "another guest's nickname",
+			id:            "guest:guest_0a1b2c3d",
+			username:      "guest_ffffffff",
+			setupMocks:    func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {},
+			expectedError: auth.ErrReservedUsername,
+		},
+		{
+			description:   "already an account",
+			id:            "111-111",
+			setupMocks:    func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {},
+			expectedError: auth.ErrNotGuest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := new(MockUserRepo)
+			mockHasher := new(MockPasswordHasher)
+			mockToken := new(MockTokenManager)
+			tc.setupMocks(mockRepo, mockHasher, mockToken)
+
+			authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, mockToken)
+			tokens, err := authService.Upgrade(context.Background(), tc.id, tc.username, "12345678")
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedToken, tokens.Access)
+
+			mockRepo.AssertExpectations(t)
+			mockHasher.AssertExpectations(t)
+			mockToken.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		description    string
+		setupMocks     func(r *MockUserRepo, tm *MockTokenManager)
+		expectedAccess string
+		expectedError  error
+	}{
+		{
+			description: "rotates",
+			setupMocks: func(r *MockUserRepo, tm *MockTokenManager) {
+				r.On("RotateRefreshToken", mock.Anything, mock.Anything, mock.MatchedBy(func(next domain.RefreshToken) bool {
+					return len(next.Hash) == sha256.Size && next.FamilyId == ""
+				}), mock.Anything, auth.RefreshReuseGrace).Return(domain.RefreshToken{FamilyId: "fam", UserId: "111"}, nil)
+				tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil)
+			},
+			expectedAccess: "111.tokkken",
+		},
+		{
+			description: "reused",
+			setupMocks: func(r *MockUserRepo, tm *MockTokenManager) {
+				r.On("RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+					Return(domain.RefreshToken{FamilyId: "fam", UserId: "111"}, domain.ErrRefreshTokenReused)
+			},
+			expectedError: domain.ErrRefreshTokenReused,
+		},
+		{
+			description: "unknown",
+			setupMocks: func(r *MockUserRepo, tm *MockTokenManager) {
+				r.On("RotateRefreshToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+					Return(domain.RefreshToken{}, domain.ErrRefreshTokenNotFound)
+			},
+			expectedError: domain.ErrRefreshTokenNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := new(MockUserRepo)
+			mockToken := new(MockTokenManager)
+			tc.setupMocks(mockRepo, mockToken)
+
+			authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), mockToken)
+			tokens, err := authService.Refresh(context.Background(), "the-refresh-token")
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				assert.Empty(t, tokens.Refresh)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, tokens.Refresh, 64)
+				sum := sha256.Sum256([]byte("the-refresh-token"))
+				mockRepo.AssertCalled(t, "RotateRefreshToken", mock.Anything, sum[:], mock.Anything, mock.Anything, mock.Anything)
+			}
+			assert.Equal(t, tc.expectedAccess, tokens.Access)
+			mockRepo.AssertExpectations(t)
+			mockToken.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout_Revokes_By_Hash(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(MockUserRepo)
+	sum := sha256.Sum256([]byte("the-refresh-token"))
+	mockRepo.On("RevokeRefreshTokenFamily", mock.Anything, sum[:]).Return(nil)
+	mockRepo.On("RevokeUserRefreshTokens", mock.Anything, "111").Return(nil)
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+	assert.NoError(t, authService.Logout(context.Background(), "the-refresh-token"))
+	assert.NoError(t, authService.LogoutEverywhere(context.Background(), "111"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	type setupFn func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager)
+
+	testCases := []struct {
+		description   string
+		current       string
+		next          string
+		setupMocks    setupFn
+		expectedToken string
+		expectedError error
+	}{
+		{
+			description: "revokes the sessions and starts a new one",
+			current:     "old_pass1",
+			next:        "new_pass1",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", PasswordHash: "old_hash"}, nil)
+				h.On("Compare", mock.Anything, "old_hash", "old_pass1").Return(true, nil)
+				h.On("Hash", mock.Anything, "new_pass1").Return("new_hash", nil)
+				r.On("UpdatePasswordHash", mock.Anything, "111", "old_hash", "new_hash").Return(nil)
+				r.On("RevokeUserRefreshTokens", mock.Anything, "111").Return(nil)
+				tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil)
+				r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil)
+			},
+			expectedToken: "111.tokkken",
+		},
+		{
+			description: "wrong current password",
+			current:     "wrong_pass",
+			next:        "new_pass1",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", PasswordHash: "old_hash"}, nil)
+				h.On("Compare", mock.Anything, "old_hash", "wrong_pass").Return(false, nil)
+			},
+			expectedError: auth.ErrIncorrectPassword,
+		},
+		{
+			description: "password changed meanwhile",
+			current:     "old_pass1",
+			next:        "new_pass1",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", PasswordHash: "old_hash"}, nil)
+				h.On("Compare", mock.Anything, "old_hash", "old_pass1").Return(true, nil)
+				h.On("Hash", mock.Anything, "new_pass1").Return("new_hash", nil)
+				r.On("UpdatePasswordHash", mock.Anything, "111", "old_hash", "new_hash").Return(domain.ErrPasswordChanged)
+			},
+			expectedError: auth.ErrIncorrectPassword,
+		},
+		{
+			description:   "weak new password",
+			current:       "old_pass1",
+			next:          "short",
+			setupMocks:    func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {},
+			expectedError: auth.ErrWeakPassword,
+		},
+		{
+			description: "revoking fails",
+			current:     "old_pass1",
+			next:        "new_pass1",
+			setupMocks: func(r *MockUserRepo, h *MockPasswordHasher, tm *MockTokenManager) {
+				r.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", PasswordHash: "old_hash"}, nil)
+				h.On("Compare", mock.Anything, "old_hash", "old_pass1").Return(true, nil)
+				h.On("Hash", mock.Anything, "new_pass1").Return("new_hash", nil)
+				r.On("UpdatePasswordHash", mock.Anything, "111", "old_hash", "new_hash").Return(nil)
+				r.On("RevokeUserRefreshTokens", mock.Anything, "111").Return(domain.UnexpectedDatabaseError)
+			},
+			expectedError: domain.UnexpectedDatabaseError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := new(MockUserRepo)
+			mockHasher := new(MockPasswordHasher)
+			mockToken := new(MockTokenManager)
+			tc.setupMocks(mockRepo, mockHasher, mockToken)
 
-			token, err := authService.Login(context.Background(), tc.username, tc.password)
+			authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, mockToken)
+			tokens, err := authService.ChangePassword(context.Background(), "111", auth.Proof{Password: tc.current}, tc.next)
 
 			if tc.expectedError != nil {
 				assert.ErrorIs(t, err, tc.expectedError)
 			} else {
 				assert.NoError(t, err)
 			}
-			assert.Equal(t, tc.expectedToken, token)
+			assert.Equal(t, tc.expectedToken, tokens.Access)
 
 			mockRepo.AssertExpectations(t)
 			mockHasher.AssertExpectations(t)
@@ -344,3 +809,246 @@ func TestLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestChangeUsername(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(MockUserRepo)
+	cooledDown := mock.MatchedBy(func(changedBefore time.Time) bool {
+		return time.Since(changedBefore) >= auth.UsernameChangeCooldown
+	})
+	mockRepo.On("UpdateUsername", mock.Anything, "111", "new_name", cooledDown, mock.Anything).Return(nil).Once()
+	mockRepo.On("UpdateUsername", mock.Anything, "111", "newer_name", cooledDown, mock.Anything).
+		Return(domain.ErrUsernameChangeTooSoon).Once()
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+	ctx := context.Background()
+	assert.NoError(t, authService.ChangeUsername(ctx, "111", "new_name"))
+	assert.ErrorIs(t, authService.ChangeUsername(ctx, "111", "newer_name"), domain.ErrUsernameChangeTooSoon)
+	assert.ErrorIs(t, authService.ChangeUsername(ctx, "111", "Bad Name"), auth.ErrInvalidUsernameFormat)
+	assert.ErrorIs(t, authService.ChangeUsername(ctx, "111", "guest_0a1b2c3d"), auth.ErrReservedUsername)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRole(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(MockUserRepo)
+	mockRepo.On("GetUserRole", mock.Anything, "111").Return(domain.RolePlayer, nil).Once()
+	mockRepo.On("SetUserRole", mock.Anything, "oussama", domain.RoleAdmin).Return("111", nil).Once()
+	mockRepo.On("GetUserRole", mock.Anything, "111").Return(domain.RoleAdmin, nil).Once()
+
+	clock := time.Now()
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+	authService.SetClock(func() time.Time { return clock })
+	ctx := context.Background()
+
+	role, err := authService.Role(ctx, "guest:guest_0a1b2c3d")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RolePlayer, role, "guests have no row to look up")
+
+	role, err = authService.Role(ctx, "111")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RolePlayer, role)
+	clock = clock.Add(auth.RoleCacheAge / 2)
+	role, err = authService.Role(ctx, "111")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RolePlayer, role, "cached")
+
+	assert.ErrorIs(t, authService.SetRole(ctx, "oussama", "emperor"), domain.ErrInvalidRole)
+	require.NoError(t, authService.SetRole(ctx, "oussama", domain.RoleAdmin))
+	role, err = authService.Role(ctx, "111")
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, role, "a role given here is seen right away")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(MockUserRepo)
+	mockHasher := new(MockPasswordHasher)
+	mockRepo.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", PasswordHash: "hash"}, nil)
+	mockHasher.On("Compare", mock.Anything, "hash", "wrong_pass").Return(false, nil).Once()
+	mockHasher.On("Compare", mock.Anything, "hash", "12345678").Return(true, nil).Once()
+	mockRepo.On("DeleteUser", mock.Anything, "111").Return(nil).Once()
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, new(MockTokenManager))
+	ctx := context.Background()
+	assert.ErrorIs(t, authService.DeleteAccount(ctx, "111", auth.Proof{Password: "wrong_pass"}), auth.ErrIncorrectPassword)
+	assert.NoError(t, authService.DeleteAccount(ctx, "111", auth.Proof{Password: "12345678"}))
+	mockRepo.AssertExpectations(t)
+	mockHasher.AssertExpectations(t)
+}
+
+func TestDeleteAccount_Without_Password(t *testing.T) {
+	t.Parallel()
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1_800_000_000, 0)
+	mockRepo := new(MockUserRepo)
+	mockRepo.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", TOTPEnabled: true}, nil)
+	mockRepo.On("GetTOTP", mock.Anything, "111").Return(domain.TOTP{Secret: secret, Enabled: true}, nil)
+	mockRepo.On("UseTOTPStep", mock.Anything, "111", now.Unix()/30).Return(nil).Once()
+	mockRepo.On("DeleteUser", mock.Anything, "111").Return(nil).Once()
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+	authService.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+	assert.ErrorIs(t, authService.DeleteAccount(ctx, "111", auth.Proof{Password: ""}), auth.ErrReauthRequired, "an empty password isn't its password")
+	assert.ErrorIs(t, authService.DeleteAccount(ctx, "111", auth.Proof{Code: "000000"}), auth.ErrIncorrectCode)
+	assert.NoError(t, authService.DeleteAccount(ctx, "111", auth.Proof{Code: auth.TOTPCode(secret, now.Unix()/30)}))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_With_TOTP(t *testing.T) {
+	t.Parallel()
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1_800_000_000, 0)
+	step := now.Unix() / 30
+
+	// the password passes, the user has 2FA on
+	passwordStep := func(r *MockUserRepo, h *MockPasswordHasher) {
+		r.On("GetUserByUsername", mock.Anything, "oussama").
+			Return(domain.User{Id: "111", PasswordHash: "hash", TOTPEnabled: true}, nil)
+		h.On("Compare", mock.Anything, "hash", "12345678").Return(true, nil)
+		h.On("NeedsRehash", "hash").Return(false)
+		r.On("GetTOTP", mock.Anything, "111").Return(domain.TOTP{Secret: secret, Enabled: true}, nil).Maybe()
+	}
+
+	t.Run("the code completes the login", func(t *testing.T) {
+		t.Parallel()
+		r, h, tm := new(MockUserRepo), new(MockPasswordHasher), new(MockTokenManager)
+		passwordStep(r, h)
+		r.On("UseTOTPStep", mock.Anything, "111", step-1).Return(nil).Once()
+		tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil).Once()
+		r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil).Once()
+
+		authService := auth.NewService(r, r, r, h, tm)
+		authService.SetClock(func() time.Time { return now })
+		ctx := context.Background()
+
+		tokens, err := authService.Login(ctx, "oussama", "12345678")
+		require.NoError(t, err)
+		assert.Empty(t, tokens.Access, "no session before the code")
+		require.NotEmpty(t, tokens.MFA)
+
+		_, err = authService.CompleteMFA(ctx, tokens.MFA, "000000")
+		assert.ErrorIs(t, err, auth.ErrIncorrectCode)
+
+		// the phone is a step behind
+		session, err := authService.CompleteMFA(ctx, tokens.MFA, auth.TOTPCode(secret, step-1))
+		assert.NoError(t, err)
+		assert.Equal(t, "111.tokkken", session.Access)
+
+		_, err = authService.CompleteMFA(ctx, tokens.MFA, auth.TOTPCode(secret, step))
+		assert.ErrorIs(t, err, auth.ErrMFATokenInvalid, "the token is spent")
+		r.AssertExpectations(t)
+		tm.AssertExpectations(t)
+	})
+
+	t.Run("a pending login takes a few codes", func(t *testing.T) {
+		t.Parallel()
+		r, h, tm := new(MockUserRepo), new(MockPasswordHasher), new(MockTokenManager)
+		passwordStep(r, h)
+
+		authService := auth.NewService(r, r, r, h, tm)
+		authService.SetClock(func() time.Time { return now })
+		ctx := context.Background()
+
+		tokens, err := authService.Login(ctx, "oussama", "12345678")
+		require.NoError(t, err)
+		for range 5 {
+			_, err = authService.CompleteMFA(ctx, tokens.MFA, "000000")
+			assert.ErrorIs(t, err, auth.ErrIncorrectCode)
+		}
+		_, err = authService.CompleteMFA(ctx, tokens.MFA, auth.TOTPCode(secret, step))
+		assert.ErrorIs(t, err, auth.ErrMFATokenInvalid)
+	})
+
+	t.Run("a pending login expires", func(t *testing.T) {
+		t.Parallel()
+		r, h, tm := new(MockUserRepo), new(MockPasswordHasher), new(MockTokenManager)
+		passwordStep(r, h)
+
+		clock := now
+		authService := auth.NewService(r, r, r, h, tm)
+		authService.SetClock(func() time.Time { return clock })
+		ctx := context.Background()
+
+		tokens, err := authService.Login(ctx, "oussama", "12345678")
+		require.NoError(t, err)
+		clock = clock.Add(auth.MFAPendingAge + time.Second)
+		_, err = authService.CompleteMFA(ctx, tokens.MFA, auth.TOTPCode(secret, clock.Unix()/30))
+		assert.ErrorIs(t, err, auth.ErrMFATokenInvalid)
+	})
+
+	t.Run("a recovery code stands in for the code", func(t *testing.T) {
+		t.Parallel()
+		r, h, tm := new(MockUserRepo), new(MockPasswordHasher), new(MockTokenManager)
+		passwordStep(r, h)
+		r.On("GetRecoveryCodes", mock.Anything, "111").
+			Return([]domain.RecoveryCode{{Id: "rc-1", Hash: "rc-hash-1"}, {Id: "rc-2", Hash: "rc-hash-2"}}, nil)
+		h.On("Compare", mock.Anything, "rc-hash-1", "abcde23456").Return(false, nil)
+		h.On("Compare", mock.Anything, "rc-hash-2", "abcde23456").Return(true, nil)
+		r.On("UseRecoveryCode", mock.Anything, "rc-2", now).Return(nil).Once()
+		tm.On("Generate", "111", mock.Anything).Return("111.tokkken", nil).Once()
+		r.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(newFamilyOf("111"))).Return(nil).Once()
+
+		authService := auth.NewService(r, r, r, h, tm)
+		authService.SetClock(func() time.Time { return now })
+		ctx := context.Background()
+
+		tokens, err := authService.Login(ctx, "oussama", "12345678")
+		require.NoError(t, err)
+		session, err := authService.CompleteMFA(ctx, tokens.MFA, "ABCDE-23456")
+		assert.NoError(t, err)
+		assert.Equal(t, "111.tokkken", session.Access)
+		r.AssertExpectations(t)
+	})
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_800_000_000, 0)
+	mockRepo := new(MockUserRepo)
+	mockHasher := new(MockPasswordHasher)
+
+	var secret []byte
+	mockRepo.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", Username: "oussama"}, nil)
+	mockRepo.On("SetTOTPSecret", mock.Anything, "111", mock.Anything).
+		Run(func(args mock.Arguments) { secret = args.Get(2).([]byte) }).Return(nil).Once()
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, mockHasher, new(MockTokenManager))
+	authService.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	encoded, uri, err := authService.EnrollTOTP(ctx, "111")
+	require.NoError(t, err)
+	assert.Len(t, secret, 20)
+	assert.Equal(t, base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), encoded)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/GuessTheObject:oussama?"), uri)
+	assert.Contains(t, uri, "secret="+encoded)
+
+	mockRepo.On("GetTOTP", mock.Anything, "111").Return(domain.TOTP{Secret: secret}, nil)
+	mockHasher.On("Hash", mock.Anything, mock.Anything).Return("rc-hash", nil).Times(10)
+	mockRepo.On("EnableTOTP", mock.Anything, "111", mock.MatchedBy(func(hashes []string) bool {
+		return len(hashes) == 10
+	}), now.Unix()/30).Return(nil).Once()
+
+	_, err = authService.ConfirmTOTP(ctx, "111", "000000")
+	assert.ErrorIs(t, err, auth.ErrIncorrectCode)
+
+	recoveryCodes, err := authService.ConfirmTOTP(ctx, "111", auth.TOTPCode(secret, now.Unix()/30))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10)
+	assert.Regexp(t, "^[a-z2-7]{5}-[a-z2-7]{5}$", recoveryCodes[0])
+	mockRepo.AssertExpectations(t)
+	mockHasher.AssertExpectations(t)
+}
+
+func TestEnrollTOTP_Already_Enabled(t *testing.T) {
+	t.Parallel()
+	mockRepo := new(MockUserRepo)
+	mockRepo.On("GetUserById", mock.Anything, "111").Return(domain.User{Id: "111", TOTPEnabled: true}, nil)
+
+	authService := auth.NewService(mockRepo, mockRepo, mockRepo, new(MockPasswordHasher), new(MockTokenManager))
+	_, _, err := authService.EnrollTOTP(context.Background(), "111")
+	assert.ErrorIs(t, err, domain.ErrTOTPAlreadyEnabled)
+}
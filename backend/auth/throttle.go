@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ThrottleConfig is the backoff of one kind of key: the failures allowed
+// before any wait, then a wait doubling with each failure up to MaxDelay,
+// the lockout.
+type ThrottleConfig struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// failures are forgotten after this long without a new one
+	Forget time.Duration
+}
+
+// DefaultUsernameThrottle lets an account fail 5 times, then waits 30
+// seconds doubling with each failure, up to a 15 minute lockout from the 11th.
+func DefaultUsernameThrottle() ThrottleConfig {
+	return ThrottleConfig{FreeAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, Forget: 24 * time.Hour}
+}
+
+// DefaultIPThrottle is looser, players behind the same NAT share an IP.
+func DefaultIPThrottle() ThrottleConfig {
+	return ThrottleConfig{FreeAttempts: 20, BaseDelay: 10 * time.Second, MaxDelay: 15 * time.Minute, Forget: time.Hour}
+}
+
+// delay is how long after the last failure the key must wait.
+func (c ThrottleConfig) delay(failures int) time.Duration {
+	over := failures - c.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := c.BaseDelay
+	for range over - 1 {
+		delay *= 2
+		if delay >= c.MaxDelay {
+			return c.MaxDelay
+		}
+	}
+	return min(delay, c.MaxDelay)
+}
+
+// justLocked tells whether the failure is the one that reached the lockout.
+func (c ThrottleConfig) justLocked(failures int) bool {
+	return c.delay(failures) == c.MaxDelay && c.delay(failures-1) < c.MaxDelay
+}
+
+type AttemptRecord struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// AttemptStore keeps the failed attempts of keys. The memory store fits a
+// single instance, replicas need a shared one to agree.
+type AttemptStore interface {
+	Get(ctx context.Context, key string, now time.Time) AttemptRecord
+	// Attempt counts a failure ahead of the attempt unless the key must still
+	// wait, then it returns the wait and counts nothing. Checking and counting
+	// at once keeps concurrent attempts from all passing the check before the
+	// first of them failed. Records are forgotten after config.Forget without
+	// a new failure.
+	Attempt(ctx context.Context, key string, now time.Time, config ThrottleConfig) time.Duration
+	// Release takes back the failure counted by an attempt that didn't fail.
+	Release(ctx context.Context, key string)
+	Reset(ctx context.Context, key string)
+}
+
+// LoginThrottle slows down guessing, per username against targeted attacks
+// and per IP against one client spraying many usernames.
+type LoginThrottle struct {
+	store    AttemptStore
+	username ThrottleConfig
+	ip       ThrottleConfig
+}
+
+func NewLoginThrottle(store AttemptStore, username, ip ThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{store: store, username: username, ip: ip}
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// retryAfter is the wait left for the key, zero when it may try now.
+func retryAfter(record AttemptRecord, config ThrottleConfig, now time.Time) time.Duration {
+	return max(record.LastFailure.Add(config.delay(record.Failures)).Sub(now), 0)
+}
+
+// Attempt returns how long the attempt must wait, zero when it may go on. An
+// attempt that goes on is counted as failed until Succeed or Cancel says
+// otherwise. An empty username counts against the IP alone.
+func (lt *LoginThrottle) Attempt(ctx context.Context, username, ip string, now time.Time) time.Duration {
+	if wait := lt.store.Attempt(ctx, ipKey(ip), now, lt.ip); wait > 0 {
+		return wait
+	}
+	if username != "" {
+		if wait := lt.store.Attempt(ctx, usernameKey(username), now, lt.username); wait > 0 {
+			lt.store.Release(ctx, ipKey(ip))
+			return wait
+		}
+	}
+	return 0
+}
+
+// Fail keeps the failure the attempt counted and tells which keys it just
+// locked out.
+func (lt *LoginThrottle) Fail(ctx context.Context, username, ip string, now time.Time) (usernameLocked, ipLocked bool) {
+	if username != "" {
+		usernameLocked = lt.username.justLocked(lt.store.Get(ctx, usernameKey(username), now).Failures)
+	}
+	return usernameLocked, lt.ip.justLocked(lt.store.Get(ctx, ipKey(ip), now).Failures)
+}
+
+// Cancel takes back the failure of an attempt that ended neither way, a
+// server error or a login still waiting for its second factor.
+func (lt *LoginThrottle) Cancel(ctx context.Context, username, ip string) {
+	if username != "" {
+		lt.store.Release(ctx, usernameKey(username))
+	}
+	lt.store.Release(ctx, ipKey(ip))
+}
+
+// Succeed clears the failures of the username. The IP only gets back the
+// failure of this attempt, one valid account must not let a client reset its
+// guessing budget.
+func (lt *LoginThrottle) Succeed(ctx context.Context, username, ip string) {
+	if username != "" {
+		lt.store.Reset(ctx, usernameKey(username))
+	}
+	lt.store.Release(ctx, ipKey(ip))
+}
+
+const (
+	// past this many keys the one that failed least recently is forgotten
+	defaultMaxAttemptRecords = 100_000
+	attemptSweepInterval     = time.Minute
+)
+
+type memoryAttempt struct {
+	AttemptRecord
+	key      string
+	forgetAt time.Time
+}
+
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	records map[string]*list.Element
+	// by last failure, the least recent first
+	order      *list.List
+	maxRecords int
+	nextSweep  time.Time
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{records: make(map[string]*list.Element), order: list.New(), maxRecords: defaultMaxAttemptRecords}
+}
+
+// SetMaxRecords bounds the keys kept, defaultMaxAttemptRecords otherwise.
+func (s *MemoryAttemptStore) SetMaxRecords(maxRecords int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxRecords = max(maxRecords, 1)
+}
+
+func (s *MemoryAttemptStore) Get(ctx context.Context, key string, now time.Time) AttemptRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.records[key]
+	if !ok {
+		return AttemptRecord{}
+	}
+	record := elem.Value.(*memoryAttempt)
+	if now.After(record.forgetAt) {
+		return AttemptRecord{}
+	}
+	return record.AttemptRecord
+}
+
+func (s *MemoryAttemptStore) Attempt(ctx context.Context, key string, now time.Time, config ThrottleConfig) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	elem, ok := s.records[key]
+	if !ok {
+		for len(s.records) >= s.maxRecords {
+			s.remove(s.order.Front())
+		}
+		elem = s.order.PushBack(&memoryAttempt{key: key})
+		s.records[key] = elem
+	}
+	record := elem.Value.(*memoryAttempt)
+	if now.After(record.forgetAt) {
+		record.AttemptRecord = AttemptRecord{}
+	}
+	if wait := retryAfter(record.AttemptRecord, config, now); wait > 0 {
+		return wait
+	}
+	record.Failures++
+	record.LastFailure = now
+	record.forgetAt = now.Add(config.Forget)
+	s.order.MoveToBack(elem)
+	return 0
+}
+
+func (s *MemoryAttemptStore) Release(ctx context.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.records[key]
+	if !ok {
+		return
+	}
+	record := elem.Value.(*memoryAttempt)
+	record.Failures--
+	if record.Failures <= 0 {
+		s.remove(elem)
+	}
+}
+
+func (s *MemoryAttemptStore) Reset(ctx context.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.records[key]; ok {
+		s.remove(elem)
+	}
+}
+
+func (s *MemoryAttemptStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.records, elem.Value.(*memoryAttempt).key)
+}
+
+// sweep drops the forgotten records, at most once a minute.
+func (s *MemoryAttemptStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(attemptSweepInterval)
+	for _, elem := range s.records {
+		if now.After(elem.Value.(*memoryAttempt).forgetAt) {
+			s.remove(elem)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package auth_test
+
+import (
+	"api/auth"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottle_Backs_Off_Then_Locks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	config := auth.ThrottleConfig{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Forget: time.Hour}
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), config, auth.DefaultIPThrottle())
+	now := time.Unix(1_000_000, 0)
+
+	fail := func(username, ip string, at time.Time) (bool, bool) {
+		assert.Zero(t, throttle.Attempt(ctx, username, ip, at))
+		return throttle.Fail(ctx, username, ip, at)
+	}
+
+	for range 3 {
+		fail("oussama", "1.2.3.4", now)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	at := now
+	for _, wait := range expected {
+		usernameLocked, _ := fail("oussama", "1.2.3.4", at)
+		assert.False(t, usernameLocked)
+		assert.Equal(t, wait, throttle.Attempt(ctx, "oussama", "1.2.3.4", at))
+		at = at.Add(wait)
+	}
+
+	usernameLocked, ipLocked := fail("OUSSAMA", "1.2.3.4", at)
+	assert.True(t, usernameLocked, "the capped delay is the lockout")
+	assert.False(t, ipLocked)
+	assert.Equal(t, 10*time.Second, throttle.Attempt(ctx, "oussama", "5.6.7.8", at), "the username is locked from any IP")
+	assert.Equal(t, 4*time.Second, throttle.Attempt(ctx, "oussama", "1.2.3.4", at.Add(6*time.Second)))
+
+	at = at.Add(10 * time.Second)
+	usernameLocked, _ = fail("oussama", "1.2.3.4", at)
+	assert.False(t, usernameLocked, "already locked out, no second event")
+
+	at = at.Add(10 * time.Second)
+	assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", at))
+	throttle.Succeed(ctx, "oussama", "1.2.3.4")
+	assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", at))
+}
+
+func TestLoginThrottle_Limits_An_IP_Across_Usernames(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	config := auth.ThrottleConfig{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Minute, Forget: time.Hour}
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), auth.DefaultUsernameThrottle(), config)
+	now := time.Unix(1_000_000, 0)
+
+	var ipLocked bool
+	for i := range 3 {
+		username := fmt.Sprintf("user%d", i)
+		assert.Zero(t, throttle.Attempt(ctx, username, "1.2.3.4", now))
+		_, ipLocked = throttle.Fail(ctx, username, "1.2.3.4", now)
+	}
+	assert.True(t, ipLocked)
+	assert.Equal(t, time.Minute, throttle.Attempt(ctx, "someone", "1.2.3.4", now))
+	assert.Zero(t, throttle.Attempt(ctx, "someone", "5.6.7.8", now))
+
+	throttle.Succeed(ctx, "someone", "5.6.7.8")
+	assert.Equal(t, time.Minute, throttle.Attempt(ctx, "someone", "1.2.3.4", now), "a success elsewhere doesn't reset the IP")
+}
+
+func TestLoginThrottle_Counts_Concurrent_Attempts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	config := auth.ThrottleConfig{FreeAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Forget: time.Hour}
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), config, auth.DefaultIPThrottle())
+	now := time.Unix(1_000_000, 0)
+
+	var wg sync.WaitGroup
+	var passed atomic.Int32
+	for i := range 50 {
+		wg.Go(func() {
+			if throttle.Attempt(ctx, "oussama", fmt.Sprintf("10.0.0.%d", i), now) == 0 {
+				passed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	assert.EqualValues(t, 4, passed.Load(), "the attempts allowed before the first wait, however many race")
+}
+
+func TestLoginThrottle_Gives_Back_Attempts_That_Did_Not_Fail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	config := auth.ThrottleConfig{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, Forget: time.Hour}
+	throttle := auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), config, config)
+	now := time.Unix(1_000_000, 0)
+
+	for range 5 {
+		assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", now))
+		throttle.Cancel(ctx, "oussama", "1.2.3.4")
+	}
+	assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", now))
+	throttle.Fail(ctx, "oussama", "1.2.3.4", now)
+	assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", now))
+	throttle.Succeed(ctx, "oussama", "1.2.3.4")
+
+	assert.Zero(t, throttle.Attempt(ctx, "oussama", "1.2.3.4", now), "only the failure is left on the IP")
+	assert.Equal(t, time.Minute, throttle.Attempt(ctx, "someone", "1.2.3.4", now))
+}
+
+func TestMemoryAttemptStore_Forgets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := auth.NewMemoryAttemptStore()
+	config := auth.ThrottleConfig{FreeAttempts: 10, Forget: time.Minute}
+	now := time.Unix(1_000_000, 0)
+
+	store.Attempt(ctx, "ip:1.2.3.4", now, config)
+	store.Attempt(ctx, "ip:1.2.3.4", now.Add(time.Second), config)
+	record := store.Get(ctx, "ip:1.2.3.4", now.Add(time.Second))
+	assert.Equal(t, 2, record.Failures)
+	assert.Equal(t, now.Add(time.Second), record.LastFailure)
+
+	assert.Zero(t, store.Get(ctx, "ip:1.2.3.4", now.Add(2*time.Minute)).Failures)
+	store.Attempt(ctx, "ip:1.2.3.4", now.Add(2*time.Minute), config)
+	assert.Equal(t, 1, store.Get(ctx, "ip:1.2.3.4", now.Add(2*time.Minute)).Failures, "a forgotten record starts over")
+}
+
+func TestMemoryAttemptStore_Evicts_The_Least_Recent_When_Full(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := auth.NewMemoryAttemptStore()
+	store.SetMaxRecords(2)
+	config := auth.ThrottleConfig{FreeAttempts: 10, Forget: time.Hour}
+	now := time.Unix(1_000_000, 0)
+
+	store.Attempt(ctx, "ip:a", now, config)
+	store.Attempt(ctx, "ip:b", now.Add(time.Second), config)
+	store.Attempt(ctx, "ip:a", now.Add(2*time.Second), config)
+	store.Attempt(ctx, "ip:c", now.Add(3*time.Second), config)
+
+	assert.Equal(t, 2, store.Get(ctx, "ip:a", now.Add(3*time.Second)).Failures)
+	assert.Zero(t, store.Get(ctx, "ip:b", now.Add(3*time.Second)).Failures)
+	assert.Equal(t, 1, store.Get(ctx, "ip:c", now.Add(3*time.Second)).Failures, "a new key is still counted")
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// SHA1, 6 digits and 30 seconds are what authenticator apps assume
+	totpPeriod = 30
+	totpDigits = 6
+	// codes of the steps next to the current one pass too, phones drift
+	totpSkew       = 1
+	totpSecretSize = 20
+
+	// TOTPIssuer names the account in authenticator apps.
+	TOTPIssuer = "GuessTheObject"
+
+	recoveryCodeCount = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TOTPCode is the RFC 6238 code of the secret at the time step.
+func TOTPCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1_000_000)
+}
+
+// matchTOTP returns the time step the code is of, false when it is of no
+// step around now.
+func matchTOTP(secret []byte, code string, now time.Time) (int64, bool) {
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(TOTPCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func newTOTPSecret() []byte {
+	secret := make([]byte, totpSecretSize)
+	rand.Read(secret)
+	return secret
+}
+
+// totpURI is the otpauth URI authenticator apps scan, shown as a QR code.
+func totpURI(username string, secret []byte) string {
+	query := url.Values{
+		"secret":    {base32NoPadding.EncodeToString(secret)},
+		"issuer":    {TOTPIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return "otpauth://totp/" + url.PathEscape(TOTPIssuer+":"+username) + "?" + query.Encode()
+}
+
+// newRecoveryCode is 10 base32 characters split in two, 50 random bits.
+func newRecoveryCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	code := strings.ToLower(base32NoPadding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:]
+}
+
+// normalizeCode lets codes be typed with spaces, dashes or in capitals.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
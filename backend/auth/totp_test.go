@@ -0,0 +1,25 @@
+package auth_test
+
+import (
+	"api/auth"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the SHA1 vectors of RFC 6238 appendix B, cut to 6 digits
+func TestTOTPCode_RFC6238(t *testing.T) {
+	t.Parallel()
+	secret := []byte("12345678901234567890")
+
+	for unix, code := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		assert.Equal(t, code, auth.TOTPCode(secret, unix/30), unix)
+	}
+}
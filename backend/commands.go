@@ -0,0 +1,62 @@
+package main
+
+import (
+	"api/domain"
+	"api/migrations"
+	"api/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+const commandsUsage = `usage: server [command]
+
+commands:
+  bootstrap-admin <username>  make the account the first admin, once`
+
+var errUsage = errors.New(commandsUsage)
+
+type adminBootstrapper interface {
+	BootstrapAdmin(ctx context.Context, username string) error
+}
+
+// runCommand runs the maintenance command of args against the database of
+// POSTGRES_URL.
+func runCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "bootstrap-admin":
+		if len(args) != 2 {
+			return errUsage
+		}
+		POSTGRES_URL, exists := os.LookupEnv("POSTGRES_URL")
+		if !exists {
+			return errors.New("missing POSTGRES_URL")
+		}
+		migrations.Migrate(POSTGRES_URL)
+		pgRepo, err := storage.NewPostgresRepo(ctx, POSTGRES_URL)
+		if err != nil {
+			return err
+		}
+		return bootstrapAdmin(ctx, pgRepo, args[1])
+	default:
+		return errUsage
+	}
+}
+
+// bootstrapAdmin gives the admin role to the account when nobody has it,
+// admins give roles through the API afterwards.
+func bootstrapAdmin(ctx context.Context, repo adminBootstrapper, username string) error {
+	err := repo.BootstrapAdmin(ctx, username)
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return fmt.Errorf("no account is named %q, sign up first", username)
+	case errors.Is(err, domain.ErrAdminExists):
+		return errors.New("an admin exists already, ask them for the role")
+	case err != nil:
+		return err
+	}
+	slog.Info("bootstrap-admin: admin role given", "username", username)
+	return nil
+}
@@ -42,3 +42,17 @@ func (h *Argon2idHasher) Compare(hash, password string) (bool, error) {
 	}
 	return match, nil
 }
+
+// NeedsRehash reports whether the hash was made with weaker params than the
+// hasher's, so raising them reaches old hashes on the next login.
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, _, _, err := argon2id.DecodeHash(hash)
+	if err != nil {
+		return false
+	}
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.Parallelism < h.params.Parallelism ||
+		params.SaltLength < h.params.SaltLength ||
+		params.KeyLength < h.params.KeyLength
+}
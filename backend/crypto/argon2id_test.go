@@ -75,3 +75,19 @@ func TestHasherParams(t *testing.T) {
 	assert.NoError(t, err, "Key should be valid base64")
 	assert.Len(t, key, int(keyLen), "Key length should match config")
 }
+
+func TestNeedsRehash(t *testing.T) {
+	weak := crypto.NewArgon2idHasher(1, 15*1024, 32, 16, 1)
+	strong := crypto.NewArgon2idHasher(2, 19*1024, 32, 16, 1)
+
+	hash, err := weak.Hash("my_password_123")
+	assert.NoError(t, err)
+
+	assert.False(t, weak.NeedsRehash(hash), "same params")
+	assert.True(t, strong.NeedsRehash(hash), "stronger params")
+
+	hash, err = strong.Hash("my_password_123")
+	assert.NoError(t, err)
+	assert.False(t, weak.NeedsRehash(hash), "weaker params never downgrade")
+	assert.False(t, strong.NeedsRehash("not-a-hash"))
+}
@@ -0,0 +1,9 @@
+package crypto
+
+import "context"
+
+// Hold takes memory from the hashing budget as a hash would.
+// This is used by tests to fill the queue.
+func (h *LimitedHasher) Hold(weightKB int64) (func(), error) {
+	return h.acquire(context.Background(), weightKB)
+}
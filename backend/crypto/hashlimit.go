@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"api/domain"
+	"context"
+	"expvar"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"golang.org/x/sync/semaphore"
+)
+
+// hashMetrics tells how long hashes queued for memory, served with the
+// other expvars.
+var hashMetrics = expvar.NewMap("password_hashing")
+
+// LimitedHasher bounds the memory Argon2id hashes take together, each one
+// holds its params' memory. A hash waiting longer than the queue timeout
+// fails with domain.ErrServerBusy, one whose caller gave up leaves the queue
+// with the caller's ctx error.
+type LimitedHasher struct {
+	hasher       *Argon2idHasher
+	sem          *semaphore.Weighted
+	budgetKB     int64
+	queueTimeout time.Duration
+}
+
+// NewLimitedHasher lets hashes take up to budgetKB of memory at once, never
+// less than one hash.
+func NewLimitedHasher(hasher *Argon2idHasher, budgetKB int64, queueTimeout time.Duration) *LimitedHasher {
+	budgetKB = max(budgetKB, int64(hasher.params.Memory))
+	return &LimitedHasher{
+		hasher:       hasher,
+		sem:          semaphore.NewWeighted(budgetKB),
+		budgetKB:     budgetKB,
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (h *LimitedHasher) Hash(ctx context.Context, password string) (string, error) {
+	release, err := h.acquire(ctx, int64(h.hasher.params.Memory))
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return h.hasher.Hash(password)
+}
+
+// Compare holds the memory of the params the hash was made with.
+func (h *LimitedHasher) Compare(ctx context.Context, hash, password string) (bool, error) {
+	weight := int64(h.hasher.params.Memory)
+	if params, _, _, err := argon2id.DecodeHash(hash); err == nil {
+		weight = int64(params.Memory)
+	}
+	release, err := h.acquire(ctx, weight)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return h.hasher.Compare(hash, password)
+}
+
+func (h *LimitedHasher) NeedsRehash(hash string) bool {
+	return h.hasher.NeedsRehash(hash)
+}
+
+func (h *LimitedHasher) acquire(ctx context.Context, weight int64) (func(), error) {
+	weight = min(weight, h.budgetKB)
+	queueCtx, cancel := context.WithTimeout(ctx, h.queueTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.sem.Acquire(queueCtx, weight)
+	observeHashWait(time.Since(start))
+	if err != nil {
+		if ctx.Err() != nil {
+			hashMetrics.Add("abandoned", 1)
+			return nil, ctx.Err()
+		}
+		// not joined with the deadline, it isn't the request's timeout
+		hashMetrics.Add("timeouts", 1)
+		return nil, domain.ErrServerBusy
+	}
+	return func() { h.sem.Release(weight) }, nil
+}
+
+// observeHashWait counts the wait in a bucket of its latency.
+func observeHashWait(wait time.Duration) {
+	hashMetrics.Add("waits", 1)
+	hashMetrics.Add("wait_ms_total", wait.Milliseconds())
+	switch {
+	case wait < 10*time.Millisecond:
+		hashMetrics.Add("wait_under_10ms", 1)
+	case wait < 100*time.Millisecond:
+		hashMetrics.Add("wait_under_100ms", 1)
+	case wait < time.Second:
+		hashMetrics.Add("wait_under_1s", 1)
+	default:
+		hashMetrics.Add("wait_over_1s", 1)
+	}
+}
+
+// AvailableMemory is the memory the process may use in bytes: GOMEMLIMIT,
+// else the cgroup limit of the container, false when neither is set.
+func AvailableMemory() (int64, bool) {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit, true
+	}
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// "max" on cgroup v2, a huge number on v1 when unlimited
+		limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err == nil && limit > 0 && limit < 1<<50 {
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
+// HashMemoryBudgetKB is the memory hashes may take together: half of the
+// available memory, else a hash per CPU.
+func HashMemoryBudgetKB(hasher *Argon2idHasher) int64 {
+	if available, ok := AvailableMemory(); ok {
+		return available / 1024 / 2
+	}
+	return int64(hasher.params.Memory) * int64(runtime.NumCPU())
+}
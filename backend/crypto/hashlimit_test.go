@@ -0,0 +1,88 @@
+package crypto_test
+
+import (
+	"api/crypto"
+	"api/domain"
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashMetric(name string) int64 {
+	if v, ok := expvar.Get("password_hashing").(*expvar.Map).Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestLimitedHasher_Times_Out_When_Full(t *testing.T) {
+	ctx := context.Background()
+	hasher := crypto.NewLimitedHasher(crypto.NewArgon2idHasher(1, 15*1024, 32, 16, 1), 15*1024, 20*time.Millisecond)
+
+	hash, err := hasher.Hash(ctx, "my_password_123")
+	require.NoError(t, err)
+
+	release, err := hasher.Hold(15 * 1024)
+	require.NoError(t, err)
+	timeouts := hashMetric("timeouts")
+
+	_, err = hasher.Hash(ctx, "my_password_123")
+	assert.ErrorIs(t, err, domain.ErrServerBusy)
+	_, err = hasher.Compare(ctx, hash, "my_password_123")
+	assert.ErrorIs(t, err, domain.ErrServerBusy)
+	assert.Equal(t, timeouts+2, hashMetric("timeouts"))
+
+	release()
+	match, err := hasher.Compare(ctx, hash, "my_password_123")
+	assert.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestLimitedHasher_Waits_For_Room(t *testing.T) {
+	ctx := context.Background()
+	hasher := crypto.NewLimitedHasher(crypto.NewArgon2idHasher(1, 15*1024, 32, 16, 1), 15*1024, time.Second)
+	release, err := hasher.Hold(15 * 1024)
+	require.NoError(t, err)
+	waits := hashMetric("waits")
+
+	time.AfterFunc(20*time.Millisecond, release)
+	_, err = hasher.Hash(ctx, "my_password_123")
+	assert.NoError(t, err)
+	assert.Greater(t, hashMetric("waits"), waits)
+	assert.Positive(t, hashMetric("wait_ms_total"))
+}
+
+// a hash made with more memory than the budget must still be compared
+func TestLimitedHasher_Caps_Weight_To_Budget(t *testing.T) {
+	ctx := context.Background()
+	hash, err := crypto.NewArgon2idHasher(1, 16*1024, 32, 16, 1).Hash("my_password_123")
+	require.NoError(t, err)
+
+	hasher := crypto.NewLimitedHasher(crypto.NewArgon2idHasher(1, 8*1024, 32, 16, 1), 8*1024, 50*time.Millisecond)
+	match, err := hasher.Compare(ctx, hash, "my_password_123")
+	assert.NoError(t, err)
+	assert.True(t, match)
+}
+
+// a caller that gave up, say a client that disconnected, must not keep its
+// place in the queue until the timeout
+func TestLimitedHasher_Leaves_Queue_With_Caller(t *testing.T) {
+	hasher := crypto.NewLimitedHasher(crypto.NewArgon2idHasher(1, 15*1024, 32, 16, 1), 15*1024, time.Minute)
+	release, err := hasher.Hold(15 * 1024)
+	require.NoError(t, err)
+	defer release()
+	timeouts := hashMetric("timeouts")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = hasher.Hash(ctx, "my_password_123")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, timeouts, hashMetric("timeouts"))
+	assert.Positive(t, hashMetric("abandoned"))
+}
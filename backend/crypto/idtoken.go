@@ -0,0 +1,211 @@
+package crypto
+
+import (
+	"api/domain"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrKeySetUnavailable = errors.New("key-set-unavailable")
+
+const (
+	// a provider's keys are fetched again at most this often, an unknown kid
+	// would otherwise hammer it
+	jwksRefetchInterval = time.Minute
+	maxJWKSBytes        = 1 << 20
+)
+
+// IDTokenVerifier checks ID tokens of OpenID providers with the keys they
+// publish. The sets are fetched on first use and again when a token names a
+// kid they don't have, providers rotate keys that way.
+type IDTokenVerifier struct {
+	client *http.Client
+
+	mu   sync.Mutex
+	sets map[string]*remoteKeySet
+}
+
+type remoteKeySet struct {
+	keys      map[string]*SigningKey
+	fetchedAt time.Time
+}
+
+func NewIDTokenVerifier(client *http.Client) *IDTokenVerifier {
+	return &IDTokenVerifier{client: client, sets: make(map[string]*remoteKeySet)}
+}
+
+// VerifyIDToken returns the claims of the token once its signature, issuer,
+// audience and expiry check out at now. The nonce is the caller's to check.
+func (v *IDTokenVerifier) VerifyIDToken(ctx context.Context, raw, jwksURI, issuer, audience string, now time.Time) (map[string]any, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := v.key(ctx, jwksURI, kid, now)
+		if err != nil {
+			return nil, err
+		}
+		// the key decides the algorithm, never the token
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, domain.ErrInvalidSigningAlg
+		}
+		return key.public, nil
+	},
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidSigningAlg), errors.Is(err, domain.ErrUnknownSigningKey), errors.Is(err, ErrKeySetUnavailable):
+			return nil, err
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, domain.ErrExpiredToken
+		case errors.Is(err, jwt.ErrSignatureInvalid):
+			return nil, domain.ErrInvalidTokenSignature
+		default:
+			return nil, fmt.Errorf("%w: %w", domain.ErrCorruptedToken, err)
+		}
+	}
+	return claims, nil
+}
+
+func (v *IDTokenVerifier) key(ctx context.Context, jwksURI, kid string, now time.Time) (*SigningKey, error) {
+	v.mu.Lock()
+	set, ok := v.sets[jwksURI]
+	v.mu.Unlock()
+	if ok {
+		if key, ok := set.keys[kid]; ok {
+			return key, nil
+		}
+		if now.Sub(set.fetchedAt) < jwksRefetchInterval {
+			return nil, domain.ErrUnknownSigningKey
+		}
+	}
+
+	keys, err := v.fetch(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	v.sets[jwksURI] = &remoteKeySet{keys: keys, fetchedAt: now}
+	v.mu.Unlock()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, domain.ErrUnknownSigningKey
+	}
+	return key, nil
+}
+
+func (v *IDTokenVerifier) fetch(ctx context.Context, jwksURI string) (map[string]*SigningKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered %d", ErrKeySetUnavailable, jwksURI, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+	return ParseJWKS(body)
+}
+
+// ParseJWKS reads the signing keys of a JWK set: RSA, P-256 and Ed25519.
+// Keys of other kinds or for encryption are skipped.
+func ParseJWKS(data []byte) (map[string]*SigningKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeySetUnavailable, err)
+	}
+
+	keys := make(map[string]*SigningKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func parseJWK(k jwk) (*SigningKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	key := &SigningKey{Kid: k.Kid}
+
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == "RS256"):
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, ErrUnsupportedKey
+		}
+		key.method, key.public = jwt.SigningMethodRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		// uncompressed point: 0x04 then 32 bytes of x and of y
+		public, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		key.method, key.public = jwt.SigningMethodES256, public
+
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		key.method, key.public = jwt.SigningMethodEdDSA, ed25519.PublicKey(x)
+
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	return key, nil
+}
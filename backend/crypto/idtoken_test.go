@@ -0,0 +1,85 @@
+package crypto_test
+
+import (
+	"api/auth/oidctest"
+	"api/crypto"
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyIDToken(t *testing.T) {
+	provider := oidctest.NewServer()
+	defer provider.Close()
+	verifier := crypto.NewIDTokenVerifier(provider.Client())
+	jwksURI := provider.URL + "/jwks"
+	ctx := context.Background()
+	now := time.Now()
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   provider.Issuer(),
+			"aud":   oidctest.ClientId,
+			"sub":   "sub-1",
+			"exp":   now.Add(time.Minute).Unix(),
+			"nonce": "n",
+		}
+	}
+
+	t.Run("a token of the provider", func(t *testing.T) {
+		got, err := verifier.VerifyIDToken(ctx, provider.SignIDToken(claims()), jwksURI, provider.Issuer(), oidctest.ClientId, now)
+		require.NoError(t, err)
+		assert.Equal(t, "sub-1", got["sub"])
+		assert.Equal(t, "n", got["nonce"])
+	})
+
+	tests := []struct {
+		name  string
+		edit  func(jwt.MapClaims)
+		token func(jwt.MapClaims) string
+		err   error
+	}{
+		{"expired", func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }, nil, domain.ErrExpiredToken},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }, nil, domain.ErrCorruptedToken},
+		{"another issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, nil, domain.ErrCorruptedToken},
+		{"another audience", func(c jwt.MapClaims) { c["aud"] = "other-client" }, nil, domain.ErrCorruptedToken},
+		{"signed by us, not the provider", nil, func(c jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+			token.Header["kid"] = "test-key"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}, domain.ErrInvalidSigningAlg},
+		{"an unknown kid", nil, func(c jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+			token.Header["kid"] = "rotated-away"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}, domain.ErrUnknownSigningKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := claims()
+			if tt.edit != nil {
+				tt.edit(c)
+			}
+			raw := provider.SignIDToken(c)
+			if tt.token != nil {
+				raw = tt.token(c)
+			}
+			_, err := verifier.VerifyIDToken(ctx, raw, jwksURI, provider.Issuer(), oidctest.ClientId, now)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("a provider down", func(t *testing.T) {
+		down := oidctest.NewServer()
+		down.Close()
+		_, err := crypto.NewIDTokenVerifier(down.Client()).VerifyIDToken(ctx, down.SignIDToken(claims()), down.URL+"/jwks", provider.Issuer(), oidctest.ClientId, now)
+		assert.ErrorIs(t, err, crypto.ErrKeySetUnavailable)
+	})
+}
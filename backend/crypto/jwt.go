@@ -12,32 +12,66 @@ import (
 // jwtCustomClaims is an unexported struct used for claims.
 // Fields must be exported for JSON serialization.
 type jwtCustomClaims struct {
-	Id string `json:"id"`
+	Id    string `json:"id"`
+	Guest bool   `json:"guest,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// guests get short lived tokens unless told otherwise
+const defaultGuestMaxAge = 2 * time.Hour
+
 type JWTManager struct {
-	secretKey []byte
-	maxAge    time.Duration
+	keys        *KeySet
+	maxAge      time.Duration
+	guestMaxAge time.Duration
 }
 
+// NewJWTManager signs and verifies with a single HS256 secret, the tokens
+// carry no kid.
 func NewJWTManager(secretKey string, maxAge time.Duration) *JWTManager {
+	keys, _ := NewKeySet("", NewHMACKey("", []byte(secretKey)))
+	return NewJWTManagerWithKeys(keys, maxAge)
+}
+
+func NewJWTManagerWithKeys(keys *KeySet, maxAge time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey: []byte(secretKey),
-		maxAge:    maxAge,
+		keys:        keys,
+		maxAge:      maxAge,
+		guestMaxAge: defaultGuestMaxAge,
 	}
 }
 
+// PublicJWKS is the JWK set other services verify our tokens with.
+func (m *JWTManager) PublicJWKS() ([]byte, error) {
+	return m.keys.PublicJWKS()
+}
+
+func (m *JWTManager) SetGuestMaxAge(maxAge time.Duration) {
+	m.guestMaxAge = maxAge
+}
+
+// Generate signs a token for the id, guest ids get the guest claim and the
+// guest max age.
 func (m *JWTManager) Generate(id string, now time.Time) (string, error) {
+	guest := domain.IsGuestId(id)
+	maxAge := m.maxAge
+	if guest {
+		maxAge = m.guestMaxAge
+	}
 	claims := jwtCustomClaims{
-		Id: id,
+		Id:    id,
+		Guest: guest,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(m.maxAge)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(maxAge)),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(m.secretKey)
+	active := m.keys.active
+	token := jwt.NewWithClaims(active.method, claims)
+	if active.Kid != "" {
+		token.Header["kid"] = active.Kid
+	}
+	signedToken, err := token.SignedString(active.private)
 
 	if err != nil {
 		return "", fmt.Errorf("%w: %w", domain.UnexpectedTokenGenerationError, err)
@@ -48,16 +82,21 @@ func (m *JWTManager) Generate(id string, now time.Time) (string, error) {
 
 func (m *JWTManager) Verify(tokenString string) (string, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwtCustomClaims{}, func(token *jwt.Token) (any, error) {
-		// Validate the signing method is what we expect (HMAC)
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys.keys[kid]
+		if !ok {
+			return nil, domain.ErrUnknownSigningKey
+		}
+		// the key decides the algorithm, never the token
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, domain.ErrInvalidSigningAlg
 		}
-		return m.secretKey, nil
+		return key.public, nil
 	})
 
 	if err != nil {
 		switch {
-		case errors.Is(err, domain.ErrInvalidSigningAlg):
+		case errors.Is(err, domain.ErrInvalidSigningAlg), errors.Is(err, domain.ErrUnknownSigningKey):
 			return "", err
 		case errors.Is(err, jwt.ErrTokenExpired):
 			return "", domain.ErrExpiredToken
@@ -70,7 +109,9 @@ func (m *JWTManager) Verify(tokenString string) (string, error) {
 		}
 	}
 
-	if claims, ok := token.Claims.(*jwtCustomClaims); ok && token.Valid {
+	// the claim and the id must agree, an account id never passes for a guest
+	// and the other way around
+	if claims, ok := token.Claims.(*jwtCustomClaims); ok && token.Valid && claims.Guest == domain.IsGuestId(claims.Id) {
 		return claims.Id, nil
 	}
 
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,3 +67,29 @@ func TestVerify(t *testing.T) {
 	assert.ErrorIs(t, err, domain.ErrCorruptedToken)
 
 }
+
+func TestGuestTokens(t *testing.T) {
+	secret := "supersupersecretkey don't share it with anyone i tell you bruh"
+	JWTManager := crypto.NewJWTManager(secret, 24*time.Hour)
+	JWTManager.SetGuestMaxAge(time.Hour)
+	now := time.Now()
+
+	token, err := JWTManager.Generate("guest:guest_0a1b2c3d", now)
+	assert.NoError(t, err)
+	jwtBody, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	assert.JSONEq(t, fmt.Sprintf(`{"id": "guest:guest_0a1b2c3d","guest": true,"exp": %d }`, now.Add(time.Hour).Unix()), string(jwtBody))
+
+	id, err := JWTManager.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "guest:guest_0a1b2c3d", id)
+
+	// the claim and the id disagreeing means the token was not ours
+	for _, claims := range []jwt.MapClaims{
+		{"id": "guest:guest_0a1b2c3d", "exp": now.Add(time.Hour).Unix()},
+		{"id": "123-456-789", "guest": true, "exp": now.Add(time.Hour).Unix()},
+	} {
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		_, err = JWTManager.Verify(forged)
+		assert.ErrorIs(t, err, domain.ErrCorruptedToken)
+	}
+}
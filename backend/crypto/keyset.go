@@ -0,0 +1,199 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported-key")
+	ErrBadKeySpec     = errors.New("bad-key-spec")
+	ErrNoSigningKey   = errors.New("no-signing-key")
+)
+
+// SigningKey is a key of a keyset. Keys without their private half only
+// verify.
+type SigningKey struct {
+	Kid     string
+	method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// KeySet verifies tokens with whichever of its keys the kid header names and
+// signs with the active one. Tokens without a kid are for the key without
+// one, the single HS256 secret tokens used to be signed with.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKey is an HS256 key, the secret both signs and verifies.
+func NewHMACKey(kid string, secret []byte) *SigningKey {
+	return &SigningKey{Kid: kid, method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+// ParseKey reads a PEM key, Ed25519 or P-256 ECDSA, private or public, or an
+// HS256 secret prefixed with hs256:. Anything else is refused rather than
+// guessed, a broken PEM must not turn into a guessable HMAC secret.
+func ParseKey(kid string, data []byte) (*SigningKey, error) {
+	if secret, ok := bytes.CutPrefix(bytes.TrimSpace(data), []byte("hs256:")); ok {
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("%w: %s has an empty hs256 secret", ErrUnsupportedKey, kid)
+		}
+		return NewHMACKey(kid, secret), nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s is neither PEM nor an hs256: secret", ErrUnsupportedKey, kid)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: %s %q", ErrUnsupportedKey, kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrUnsupportedKey, kid, err)
+	}
+
+	key := &SigningKey{Kid: kid}
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	case *ecdsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodES256, k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodES256, k
+	default:
+		return nil, fmt.Errorf("%w: %s %T", ErrUnsupportedKey, kid, parsed)
+	}
+	if ec, ok := key.public.(*ecdsa.PublicKey); ok && ec.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: %s is not P-256", ErrUnsupportedKey, kid)
+	}
+	return key, nil
+}
+
+// LoadKeys reads a comma separated list of kid=file:path and kid=env:NAME
+// entries.
+func LoadKeys(spec string) ([]*SigningKey, error) {
+	keys := []*SigningKey{}
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, source, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("%w: %q", ErrBadKeySpec, entry)
+		}
+
+		var data []byte
+		switch {
+		case strings.HasPrefix(source, "file:"):
+			var err error
+			data, err = os.ReadFile(strings.TrimPrefix(source, "file:"))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrBadKeySpec, kid, err)
+			}
+		case strings.HasPrefix(source, "env:"):
+			data = []byte(os.Getenv(strings.TrimPrefix(source, "env:")))
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrBadKeySpec, entry)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, fmt.Errorf("%w: %s is empty", ErrBadKeySpec, kid)
+		}
+
+		key, err := ParseKey(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// NewKeySet signs with the key of activeKid. The others only verify, until
+// they are dropped from the set.
+func NewKeySet(activeKid string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, dup := ks.keys[key.Kid]; dup {
+			return nil, fmt.Errorf("%w: duplicate kid %q", ErrBadKeySpec, key.Kid)
+		}
+		ks.keys[key.Kid] = key
+	}
+	active, ok := ks.keys[activeKid]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNoSigningKey, activeKid)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// jwk is the public half of a key as RFC 7517 has it.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	// RSA keys, only read from the sets of OpenID providers
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// PublicJWKS is the JWK set of the asymmetric keys, HMAC secrets are never
+// published.
+func (ks *KeySet) PublicJWKS() ([]byte, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: []jwk{}}
+	for _, key := range ks.keys {
+		k := jwk{Kid: key.Kid, Alg: key.method.Alg(), Use: "sig"}
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			k.Kty, k.Crv = "OKP", "Ed25519"
+			k.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *ecdsa.PublicKey:
+			raw, err := pub.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			// uncompressed point: 0x04 then 32 bytes of x and of y
+			k.Kty, k.Crv = "EC", "P-256"
+			k.X = base64.RawURLEncoding.EncodeToString(raw[1:33])
+			k.Y = base64.RawURLEncoding.EncodeToString(raw[33:])
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, k)
+	}
+	slices.SortFunc(set.Keys, func(a, b jwk) int { return strings.Compare(a.Kid, b.Kid) })
+	return json.Marshal(set)
+}
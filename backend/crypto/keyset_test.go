@@ -0,0 +1,198 @@
+package crypto_test
+
+import (
+	"api/crypto"
+	"api/domain"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pemPrivate(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func pemPublic(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func header(t *testing.T, token string) map[string]any {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	require.NoError(t, err)
+	h := map[string]any{}
+	require.NoError(t, json.Unmarshal(raw, &h))
+	return h
+}
+
+func TestKeySet_Algorithms(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	testCases := []struct {
+		alg  string
+		data []byte
+	}{
+		{"HS256", []byte("hs256:a secret long enough for hmac\n")},
+		{"EdDSA", pemPrivate(t, edPriv)},
+		{"ES256", pemPrivate(t, ecPriv)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.alg, func(t *testing.T) {
+			key, err := crypto.ParseKey("k1", tc.data)
+			require.NoError(t, err)
+			keys, err := crypto.NewKeySet("k1", key)
+			require.NoError(t, err)
+			m := crypto.NewJWTManagerWithKeys(keys, time.Hour)
+
+			token, err := m.Generate("idid", time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"alg": tc.alg, "kid": "k1", "typ": "JWT"}, header(t, token))
+
+			id, err := m.Verify(token)
+			assert.NoError(t, err)
+			assert.Equal(t, "idid", id)
+		})
+	}
+
+	t.Run("public keys only verify", func(t *testing.T) {
+		key, err := crypto.ParseKey("pub", pemPublic(t, edPub))
+		require.NoError(t, err)
+		_, err = crypto.NewKeySet("pub", key)
+		assert.ErrorIs(t, err, crypto.ErrNoSigningKey)
+	})
+
+	t.Run("unprefixed secrets and broken PEM", func(t *testing.T) {
+		truncated := pemPrivate(t, edPriv)
+		for _, data := range [][]byte{
+			[]byte("a secret without its algorithm"),
+			truncated[:len(truncated)/2],
+			[]byte("hs256:"),
+		} {
+			_, err := crypto.ParseKey("k1", data)
+			assert.ErrorIs(t, err, crypto.ErrUnsupportedKey, string(data))
+		}
+	})
+
+	t.Run("other curves", func(t *testing.T) {
+		p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		_, err := crypto.ParseKey("p384", pemPrivate(t, p384))
+		assert.ErrorIs(t, err, crypto.ErrUnsupportedKey)
+	})
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	_, oldPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, newPriv, _ := ed25519.GenerateKey(rand.Reader)
+	oldKey, err := crypto.ParseKey("2026-09", pemPrivate(t, oldPriv))
+	require.NoError(t, err)
+	newKey, err := crypto.ParseKey("2026-10", pemPrivate(t, newPriv))
+	require.NoError(t, err)
+	legacy := crypto.NewHMACKey("", []byte("the old JWT_KEY"))
+
+	before, _ := crypto.NewKeySet("2026-09", oldKey, legacy)
+	oldToken, err := crypto.NewJWTManagerWithKeys(before, time.Hour).Generate("idid", time.Now())
+	require.NoError(t, err)
+	legacyToken, err := crypto.NewJWTManager("the old JWT_KEY", time.Hour).Generate("idid", time.Now())
+	require.NoError(t, err)
+
+	after, _ := crypto.NewKeySet("2026-10", newKey, oldKey, legacy)
+	m := crypto.NewJWTManagerWithKeys(after, time.Hour)
+	newToken, _ := m.Generate("idid", time.Now())
+	assert.Equal(t, "2026-10", header(t, newToken)["kid"])
+	for _, token := range []string{oldToken, legacyToken, newToken} {
+		id, err := m.Verify(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "idid", id)
+	}
+
+	retired, _ := crypto.NewKeySet("2026-10", newKey)
+	_, err = crypto.NewJWTManagerWithKeys(retired, time.Hour).Verify(oldToken)
+	assert.ErrorIs(t, err, domain.ErrUnknownSigningKey, "retired keys verify nothing")
+	_, err = crypto.NewJWTManagerWithKeys(retired, time.Hour).Verify(legacyToken)
+	assert.ErrorIs(t, err, domain.ErrUnknownSigningKey)
+}
+
+// an HS256 token keyed with the public key of an asymmetric kid must not pass
+func TestKeySet_Rejects_Algorithm_Confusion(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	key, err := crypto.ParseKey("ed", pemPrivate(t, edPriv))
+	require.NoError(t, err)
+	keys, _ := crypto.NewKeySet("ed", key)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+	forged.Header["kid"] = "ed"
+	signed, err := forged.SignedString([]byte(edPub))
+	require.NoError(t, err)
+
+	_, err = crypto.NewJWTManagerWithKeys(keys, time.Hour).Verify(signed)
+	assert.ErrorIs(t, err, domain.ErrInvalidSigningAlg)
+}
+
+func TestLoadKeys(t *testing.T) {
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	path := filepath.Join(t.TempDir(), "ed.pem")
+	require.NoError(t, os.WriteFile(path, pemPrivate(t, edPriv), 0o600))
+	t.Setenv("TEST_JWT_SECRET", "hs256:from the environment")
+
+	keys, err := crypto.LoadKeys("ed=file:" + path + ", hs=env:TEST_JWT_SECRET")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "ed", keys[0].Kid)
+	assert.Equal(t, "hs", keys[1].Kid)
+
+	for _, spec := range []string{
+		"nokid",
+		"=env:TEST_JWT_SECRET",
+		"k=vault:secret",
+		"k=env:TEST_JWT_UNSET",
+		"k=file:" + filepath.Join(t.TempDir(), "missing.pem"),
+	} {
+		_, err := crypto.LoadKeys(spec)
+		assert.ErrorIs(t, err, crypto.ErrBadKeySpec, spec)
+	}
+}
+
+func TestKeySet_PublicJWKS(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	ecPriv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edKey, _ := crypto.ParseKey("ed", pemPrivate(t, edPriv))
+	ecKey, _ := crypto.ParseKey("ec", pemPublic(t, &ecPriv.PublicKey))
+	keys, err := crypto.NewKeySet("ed", edKey, ecKey, crypto.NewHMACKey("hs", []byte("secret")))
+	require.NoError(t, err)
+
+	raw, err := keys.PublicJWKS()
+	require.NoError(t, err)
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &set))
+	require.Len(t, set.Keys, 2, "HMAC secrets are never published")
+
+	ec, ed := set.Keys[0], set.Keys[1]
+	assert.Equal(t, map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "alg": "EdDSA", "use": "sig",
+		"x": base64.RawURLEncoding.EncodeToString(edPub)}, ed)
+	assert.Equal(t, "ES256", ec["alg"])
+	x, _ := base64.RawURLEncoding.DecodeString(ec["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(ec["y"])
+	assert.Equal(t, ecPriv.X.FillBytes(make([]byte, 32)), x)
+	assert.Equal(t, ecPriv.Y.FillBytes(make([]byte, 32)), y)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Drawing is the final picture of a turn, kept as the DrawingData chunks the
+// drawer sent.
+type Drawing struct {
+	Id       string
+	DrawerId string
+	Drawer   string
+	RoomId   string
+	Word     string
+	Guessers []string // the names played under, renames and deleted accounts keep them
+	Strokes  [][]byte // only filled by GetDrawing and when saving
+	// Strokes hold typed drawing ops only, dende parts can not be rendered
+	DrawingOps bool
+	Size       int
+	CreatedAt  time.Time
+
+	// relative to the user viewing the drawing
+	Likes      int
+	Liked      bool
+	Favourited bool
+}
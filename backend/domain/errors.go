@@ -8,15 +8,61 @@ var UnexpectedPasswordHashComparisonError = errors.New("unexpected-password-hash
 var UnexpectedTokenGenerationError = errors.New("unexpected-token-generation-error")
 var UnexpectedTokenVerificationError = errors.New("unexpected-token-verification-error")
 
+// ErrServerBusy is a request that waited too long for a scarce resource.
+var ErrServerBusy = errors.New("server-busy")
+
 var (
 	ErrDuplicateUsername = errors.New("duplicate-username")
 	ErrUserNotFound      = errors.New("user-not-found")
 	ErrIdNotFound        = errors.New("id-not-found")
+	// the username was changed too recently to change again
+	ErrUsernameChangeTooSoon = errors.New("username-change-too-soon")
+	// the password hash is no longer the one the change was based on
+	ErrPasswordChanged = errors.New("password-changed")
+	ErrInvalidRole     = errors.New("invalid-role")
+	// the first admin is bootstrapped once, the next ones are given the role
+	ErrAdminExists = errors.New("admin-exists")
 )
 
 var (
 	ErrInvalidSigningAlg     = errors.New("invalid-signing-algorithm")
+	ErrUnknownSigningKey     = errors.New("unknown-signing-key")
 	ErrExpiredToken          = errors.New("expired-token")
 	ErrInvalidTokenSignature = errors.New("invalid-token-signature")
 	ErrCorruptedToken        = errors.New("corrupted-token")
 )
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh-token-not-found")
+	ErrRefreshTokenReused   = errors.New("refresh-token-reused")
+)
+
+var (
+	ErrTOTPNotEnrolled    = errors.New("totp-not-enrolled")
+	ErrTOTPAlreadyEnabled = errors.New("totp-already-enabled")
+	ErrTOTPCodeReused     = errors.New("totp-code-reused")
+	ErrRecoveryCodeUsed   = errors.New("recovery-code-used")
+)
+
+var (
+	ErrIdentityNotFound = errors.New("identity-not-found")
+	// the identity is linked to another user
+	ErrIdentityTaken = errors.New("identity-taken")
+)
+
+var (
+	ErrWordNotFound  = errors.New("word-not-found")
+	ErrDuplicateWord = errors.New("duplicate-word")
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook-not-found")
+)
+
+var (
+	ErrReplayNotFound = errors.New("replay-not-found")
+)
+
+var (
+	ErrDrawingNotFound = errors.New("drawing-not-found")
+)
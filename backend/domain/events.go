@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+type GameEventType string
+
+const (
+	GameEventRoomCreated GameEventType = "room-created"
+	GameEventGameStarted GameEventType = "game-started"
+	GameEventGameEnded   GameEventType = "game-ended"
+)
+
+type GameEventPlayer struct {
+	Username string
+	Score    int
+	// Guest scores are not ranked, consumers keeping ratings skip them.
+	Guest bool
+}
+
+// GameEvent describes a room lifecycle change. HostId is the user id of the
+// room creator and is used to look up their personal webhooks.
+type GameEvent struct {
+	Type       GameEventType
+	RoomId     string
+	Private    bool
+	Host       string
+	HostId     string
+	Round      int
+	Players    []GameEventPlayer
+	OccurredAt time.Time
+}
@@ -2,8 +2,59 @@ package protobuf // or whatever package you are using these in
 
 import (
 	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// batchFieldNumber is ServerPacket.batch.
+const batchFieldNumber protowire.Number = 18
+
+// seqFieldNumber and firstSeqFieldNumber are ServerPacket.seq and first_seq.
+const (
+	seqFieldNumber      protowire.Number = 21
+	firstSeqFieldNumber protowire.Number = 22
 )
 
+// AppendToBatch adds an already marshalled ServerPacket to a batch frame,
+// which decodes as a ServerPacket holding them all in Batch.
+func AppendToBatch(frame []byte, packet []byte) []byte {
+	frame = protowire.AppendTag(frame, batchFieldNumber, protowire.BytesType)
+	return protowire.AppendBytes(frame, packet)
+}
+
+// PacketSeq reads ServerPacket.seq out of a marshalled packet without
+// unmarshalling the rest, zero when it has none.
+func PacketSeq(packet []byte) uint64 {
+	var seq uint64
+	for len(packet) > 0 {
+		num, typ, n := protowire.ConsumeTag(packet)
+		if n < 0 {
+			return 0
+		}
+		packet = packet[n:]
+		if num == seqFieldNumber && typ == protowire.VarintType {
+			v, m := protowire.ConsumeVarint(packet)
+			if m < 0 {
+				return 0
+			}
+			seq, packet = v, packet[m:]
+			continue
+		}
+		m := protowire.ConsumeFieldValue(num, typ, packet)
+		if m < 0 {
+			return 0
+		}
+		packet = packet[m:]
+	}
+	return seq
+}
+
+// AppendFirstSeq sets ServerPacket.first_seq on a marshalled packet.
+func AppendFirstSeq(packet []byte, seq uint64) []byte {
+	packet = protowire.AppendTag(packet, firstSeqFieldNumber, protowire.VarintType)
+	return protowire.AppendVarint(packet, seq)
+}
+
 // Helper to get current time (boilerplate reduction)
 func now() int64 {
 	return time.Now().UnixMilli()
@@ -128,6 +179,46 @@ func MakePacketPlayerMessage(from, msg string) *ServerPacket {
 	}
 }
 
+func MakePacketWelcome(version uint32, features []string) *ServerPacket {
+	return &ServerPacket{
+		Payload: &ServerPacket_Welcome_{
+			Welcome: &ServerPacket_Welcome{
+				ProtocolVersion: version,
+				Features:        features,
+			},
+		},
+		ServerTimestamp: now(),
+	}
+}
+
+// MakePacketTimeSync answers a time sync sample, receivedAt being when the
+// server read it.
+func MakePacketTimeSync(clientTime, receivedAt int64) *ServerPacket {
+	sentAt := now()
+	return &ServerPacket{
+		Payload: &ServerPacket_TimeSync_{
+			TimeSync: &ServerPacket_TimeSync{
+				ClientTime:        clientTime,
+				ServerReceiveTime: receivedAt,
+				ServerSendTime:    sentAt,
+			},
+		},
+		ServerTimestamp: sentAt,
+	}
+}
+
+func MakePacketError(code, message string) *ServerPacket {
+	return &ServerPacket{
+		Payload: &ServerPacket_Error_{
+			Error: &ServerPacket_Error{
+				Code:    code,
+				Message: message,
+			},
+		},
+		ServerTimestamp: now(),
+	}
+}
+
 // --- Gameplay Mechanics ---
 
 func MakePacketDrawingData(data []byte) *ServerPacket {
@@ -140,6 +231,27 @@ func MakePacketDrawingData(data []byte) *ServerPacket {
 	}
 }
 
+func MakePacketDrawingOps(ops []*DrawingOp) *ServerPacket {
+	return &ServerPacket{
+		Payload: &ServerPacket_DrawingData{
+			DrawingData: &DrawingData{
+				Ops: ops,
+			},
+		},
+	}
+}
+
+func MakePacketDrawingLimitReached(reason string) *ServerPacket {
+	return &ServerPacket{
+		Payload: &ServerPacket_DrawingLimitReached_{
+			DrawingLimitReached: &ServerPacket_DrawingLimitReached{
+				Reason: reason,
+			},
+		},
+		ServerTimestamp: now(),
+	}
+}
+
 func MakePacketPleaseChooseAWord(words []string) *ServerPacket {
 	return &ServerPacket{
 		Payload: &ServerPacket_PleaseChooseAWord_{
@@ -39,10 +39,31 @@ type ServerPacket struct {
 	//	*ServerPacket_InitialRoomSnapshot_
 	//	*ServerPacket_YourTurnToDraw_
 	//	*ServerPacket_PlayerLeft_
+	//	*ServerPacket_DrawingLimitReached_
+	//	*ServerPacket_Welcome_
+	//	*ServerPacket_Error_
+	//	*ServerPacket_TimeSync_
 	Payload         isServerPacket_Payload `protobuf_oneof:"payload"`
 	ServerTimestamp int64                  `protobuf:"varint,16,opt,name=server_timestamp,json=serverTimestamp,proto3" json:"server_timestamp,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// Numbers the packets every player of the room gets, one after the other.
+	// Packets for some players only (word choices, errors, chat) have none,
+	// a snapshot has the seq of the last packet it includes. A client that
+	// sees a gap sends a Resume with the last seq it applied and ignores what
+	// it gets until the replay or a snapshot fills it, and it always ignores
+	// packets it already applied.
+	Seq uint64 `protobuf:"varint,21,opt,name=seq,proto3" json:"seq,omitempty"`
+	// Set when the write path merged the drawing ops of several packets, the
+	// packet then holds first_seq to seq.
+	FirstSeq uint64 `protobuf:"varint,22,opt,name=first_seq,json=firstSeq,proto3" json:"first_seq,omitempty"`
+	// Time left in the phase when the packet was sent, on the packets that
+	// start a phase and on snapshots. Unlike next_tick it does not depend on
+	// the client's clock.
+	PhaseRemainingMs int64 `protobuf:"varint,24,opt,name=phase_remaining_ms,json=phaseRemainingMs,proto3" json:"phase_remaining_ms,omitempty"`
+	// Packets the server wrote as a single frame, in order, when write
+	// batching is on. A batch frame has nothing else set.
+	Batch         []*ServerPacket `protobuf:"bytes,18,rep,name=batch,proto3" json:"batch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ServerPacket) Reset() {
@@ -208,6 +229,42 @@ func (x *ServerPacket) GetPlayerLeft() *ServerPacket_PlayerLeft {
 	return nil
 }
 
+func (x *ServerPacket) GetDrawingLimitReached() *ServerPacket_DrawingLimitReached {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerPacket_DrawingLimitReached_); ok {
+			return x.DrawingLimitReached
+		}
+	}
+	return nil
+}
+
+func (x *ServerPacket) GetWelcome() *ServerPacket_Welcome {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerPacket_Welcome_); ok {
+			return x.Welcome
+		}
+	}
+	return nil
+}
+
+func (x *ServerPacket) GetError() *ServerPacket_Error {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerPacket_Error_); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *ServerPacket) GetTimeSync() *ServerPacket_TimeSync {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerPacket_TimeSync_); ok {
+			return x.TimeSync
+		}
+	}
+	return nil
+}
+
 func (x *ServerPacket) GetServerTimestamp() int64 {
 	if x != nil {
 		return x.ServerTimestamp
@@ -215,6 +272,34 @@ func (x *ServerPacket) GetServerTimestamp() int64 {
 	return 0
 }
 
+func (x *ServerPacket) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ServerPacket) GetFirstSeq() uint64 {
+	if x != nil {
+		return x.FirstSeq
+	}
+	return 0
+}
+
+func (x *ServerPacket) GetPhaseRemainingMs() int64 {
+	if x != nil {
+		return x.PhaseRemainingMs
+	}
+	return 0
+}
+
+func (x *ServerPacket) GetBatch() []*ServerPacket {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
 type isServerPacket_Payload interface {
 	isServerPacket_Payload()
 }
@@ -275,6 +360,22 @@ type ServerPacket_PlayerLeft_ struct {
 	PlayerLeft *ServerPacket_PlayerLeft `protobuf:"bytes,14,opt,name=player_left,json=playerLeft,proto3,oneof"`
 }
 
+type ServerPacket_DrawingLimitReached_ struct {
+	DrawingLimitReached *ServerPacket_DrawingLimitReached `protobuf:"bytes,17,opt,name=drawing_limit_reached,json=drawingLimitReached,proto3,oneof"`
+}
+
+type ServerPacket_Welcome_ struct {
+	Welcome *ServerPacket_Welcome `protobuf:"bytes,19,opt,name=welcome,proto3,oneof"`
+}
+
+type ServerPacket_Error_ struct {
+	Error *ServerPacket_Error `protobuf:"bytes,20,opt,name=error,proto3,oneof"`
+}
+
+type ServerPacket_TimeSync_ struct {
+	TimeSync *ServerPacket_TimeSync `protobuf:"bytes,23,opt,name=time_sync,json=timeSync,proto3,oneof"`
+}
+
 func (*ServerPacket_DrawingData) isServerPacket_Payload() {}
 
 func (*ServerPacket_PlayerJoined_) isServerPacket_Payload() {}
@@ -303,6 +404,14 @@ func (*ServerPacket_YourTurnToDraw_) isServerPacket_Payload() {}
 
 func (*ServerPacket_PlayerLeft_) isServerPacket_Payload() {}
 
+func (*ServerPacket_DrawingLimitReached_) isServerPacket_Payload() {}
+
+func (*ServerPacket_Welcome_) isServerPacket_Payload() {}
+
+func (*ServerPacket_Error_) isServerPacket_Payload() {}
+
+func (*ServerPacket_TimeSync_) isServerPacket_Payload() {}
+
 type ClientPacket struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Payload:
@@ -311,6 +420,12 @@ type ClientPacket struct {
 	//	*ClientPacket_PlayerMessage_
 	//	*ClientPacket_WordChoice_
 	//	*ClientPacket_StartGame_
+	//	*ClientPacket_Undo_
+	//	*ClientPacket_Redo_
+	//	*ClientPacket_ClearCanvas_
+	//	*ClientPacket_Hello_
+	//	*ClientPacket_Resume_
+	//	*ClientPacket_TimeSync_
 	Payload       isClientPacket_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -389,6 +504,60 @@ func (x *ClientPacket) GetStartGame() *ClientPacket_StartGame {
 	return nil
 }
 
+func (x *ClientPacket) GetUndo() *ClientPacket_Undo {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_Undo_); ok {
+			return x.Undo
+		}
+	}
+	return nil
+}
+
+func (x *ClientPacket) GetRedo() *ClientPacket_Redo {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_Redo_); ok {
+			return x.Redo
+		}
+	}
+	return nil
+}
+
+func (x *ClientPacket) GetClearCanvas() *ClientPacket_ClearCanvas {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_ClearCanvas_); ok {
+			return x.ClearCanvas
+		}
+	}
+	return nil
+}
+
+func (x *ClientPacket) GetHello() *ClientPacket_Hello {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_Hello_); ok {
+			return x.Hello
+		}
+	}
+	return nil
+}
+
+func (x *ClientPacket) GetResume() *ClientPacket_Resume {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_Resume_); ok {
+			return x.Resume
+		}
+	}
+	return nil
+}
+
+func (x *ClientPacket) GetTimeSync() *ClientPacket_TimeSync {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientPacket_TimeSync_); ok {
+			return x.TimeSync
+		}
+	}
+	return nil
+}
+
 type isClientPacket_Payload interface {
 	isClientPacket_Payload()
 }
@@ -409,6 +578,30 @@ type ClientPacket_StartGame_ struct {
 	StartGame *ClientPacket_StartGame `protobuf:"bytes,5,opt,name=start_game,json=startGame,proto3,oneof"`
 }
 
+type ClientPacket_Undo_ struct {
+	Undo *ClientPacket_Undo `protobuf:"bytes,6,opt,name=undo,proto3,oneof"`
+}
+
+type ClientPacket_Redo_ struct {
+	Redo *ClientPacket_Redo `protobuf:"bytes,7,opt,name=redo,proto3,oneof"`
+}
+
+type ClientPacket_ClearCanvas_ struct {
+	ClearCanvas *ClientPacket_ClearCanvas `protobuf:"bytes,8,opt,name=clear_canvas,json=clearCanvas,proto3,oneof"`
+}
+
+type ClientPacket_Hello_ struct {
+	Hello *ClientPacket_Hello `protobuf:"bytes,9,opt,name=hello,proto3,oneof"`
+}
+
+type ClientPacket_Resume_ struct {
+	Resume *ClientPacket_Resume `protobuf:"bytes,10,opt,name=resume,proto3,oneof"`
+}
+
+type ClientPacket_TimeSync_ struct {
+	TimeSync *ClientPacket_TimeSync `protobuf:"bytes,11,opt,name=time_sync,json=timeSync,proto3,oneof"`
+}
+
 func (*ClientPacket_DrawingData) isClientPacket_Payload() {}
 
 func (*ClientPacket_PlayerMessage_) isClientPacket_Payload() {}
@@ -417,9 +610,26 @@ func (*ClientPacket_WordChoice_) isClientPacket_Payload() {}
 
 func (*ClientPacket_StartGame_) isClientPacket_Payload() {}
 
+func (*ClientPacket_Undo_) isClientPacket_Payload() {}
+
+func (*ClientPacket_Redo_) isClientPacket_Payload() {}
+
+func (*ClientPacket_ClearCanvas_) isClientPacket_Payload() {}
+
+func (*ClientPacket_Hello_) isClientPacket_Payload() {}
+
+func (*ClientPacket_Resume_) isClientPacket_Payload() {}
+
+func (*ClientPacket_TimeSync_) isClientPacket_Payload() {}
+
 type DrawingData struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Opaque stroke stream from before typed ops existed, only accepted while
+	// the legacy drawing data compatibility flag is on.
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	// Only accepted, and so only sent, while every player of the room agreed
+	// to drawing ops.
+	Ops           []*DrawingOp `protobuf:"bytes,2,rep,name=ops,proto3" json:"ops,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -461,27 +671,47 @@ func (x *DrawingData) GetData() []byte {
 	return nil
 }
 
-type ServerPacket_YourTurnToDraw struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+func (x *DrawingData) GetOps() []*DrawingOp {
+	if x != nil {
+		return x.Ops
+	}
+	return nil
+}
+
+// DrawingOp is a single validated drawing instruction. Strokes and fills use
+// the colour and brush size last set during the turn.
+type DrawingOp struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Op:
+	//
+	//	*DrawingOp_StrokeBegin_
+	//	*DrawingOp_StrokePoints_
+	//	*DrawingOp_StrokeEnd_
+	//	*DrawingOp_Fill_
+	//	*DrawingOp_Clear_
+	//	*DrawingOp_Undo_
+	//	*DrawingOp_Redo_
+	//	*DrawingOp_SetColor_
+	//	*DrawingOp_SetBrushSize_
+	Op            isDrawingOp_Op `protobuf_oneof:"op"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_YourTurnToDraw) Reset() {
-	*x = ServerPacket_YourTurnToDraw{}
+func (x *DrawingOp) Reset() {
+	*x = DrawingOp{}
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_YourTurnToDraw) String() string {
+func (x *DrawingOp) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_YourTurnToDraw) ProtoMessage() {}
+func (*DrawingOp) ProtoMessage() {}
 
-func (x *ServerPacket_YourTurnToDraw) ProtoReflect() protoreflect.Message {
+func (x *DrawingOp) ProtoReflect() protoreflect.Message {
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -493,148 +723,187 @@ func (x *ServerPacket_YourTurnToDraw) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_YourTurnToDraw.ProtoReflect.Descriptor instead.
-func (*ServerPacket_YourTurnToDraw) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 0}
+// Deprecated: Use DrawingOp.ProtoReflect.Descriptor instead.
+func (*DrawingOp) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ServerPacket_YourTurnToDraw) GetWord() string {
+func (x *DrawingOp) GetOp() isDrawingOp_Op {
 	if x != nil {
-		return x.Word
+		return x.Op
 	}
-	return ""
-}
-
-type ServerPacket_InitialRoomSnapshot struct {
-	state                protoimpl.MessageState                          `protogen:"open.v1"`
-	PlayersStates        []*ServerPacket_InitialRoomSnapshot_PlayerState `protobuf:"bytes,1,rep,name=players_states,json=playersStates,proto3" json:"players_states,omitempty"`
-	DrawingHistory       [][]byte                                        `protobuf:"bytes,2,rep,name=drawing_history,json=drawingHistory,proto3" json:"drawing_history,omitempty"`
-	CurrentDrawer        string                                          `protobuf:"bytes,3,opt,name=current_drawer,json=currentDrawer,proto3" json:"current_drawer,omitempty"`
-	CurrentRound         int32                                           `protobuf:"varint,4,opt,name=current_round,json=currentRound,proto3" json:"current_round,omitempty"`
-	RoomId               string                                          `protobuf:"bytes,5,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
-	NextTick             int64                                           `protobuf:"varint,6,opt,name=next_tick,json=nextTick,proto3" json:"next_tick,omitempty"`
-	ChoosingWordDuration int64                                           `protobuf:"varint,7,opt,name=choosing_word_duration,json=choosingWordDuration,proto3" json:"choosing_word_duration,omitempty"`
-	DrawingDuration      int64                                           `protobuf:"varint,8,opt,name=drawing_duration,json=drawingDuration,proto3" json:"drawing_duration,omitempty"`
-	CurrentPhase         int32                                           `protobuf:"varint,9,opt,name=current_phase,json=currentPhase,proto3" json:"current_phase,omitempty"`
-	unknownFields        protoimpl.UnknownFields
-	sizeCache            protoimpl.SizeCache
-}
-
-func (x *ServerPacket_InitialRoomSnapshot) Reset() {
-	*x = ServerPacket_InitialRoomSnapshot{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[4]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
-}
-
-func (x *ServerPacket_InitialRoomSnapshot) String() string {
-	return protoimpl.X.MessageStringOf(x)
+	return nil
 }
 
-func (*ServerPacket_InitialRoomSnapshot) ProtoMessage() {}
-
-func (x *ServerPacket_InitialRoomSnapshot) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[4]
+func (x *DrawingOp) GetStrokeBegin() *DrawingOp_StrokeBegin {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
+		if x, ok := x.Op.(*DrawingOp_StrokeBegin_); ok {
+			return x.StrokeBegin
 		}
-		return ms
 	}
-	return mi.MessageOf(x)
-}
-
-// Deprecated: Use ServerPacket_InitialRoomSnapshot.ProtoReflect.Descriptor instead.
-func (*ServerPacket_InitialRoomSnapshot) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 1}
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetPlayersStates() []*ServerPacket_InitialRoomSnapshot_PlayerState {
+func (x *DrawingOp) GetStrokePoints() *DrawingOp_StrokePoints {
 	if x != nil {
-		return x.PlayersStates
+		if x, ok := x.Op.(*DrawingOp_StrokePoints_); ok {
+			return x.StrokePoints
+		}
 	}
 	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetDrawingHistory() [][]byte {
+func (x *DrawingOp) GetStrokeEnd() *DrawingOp_StrokeEnd {
 	if x != nil {
-		return x.DrawingHistory
+		if x, ok := x.Op.(*DrawingOp_StrokeEnd_); ok {
+			return x.StrokeEnd
+		}
 	}
 	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetCurrentDrawer() string {
+func (x *DrawingOp) GetFill() *DrawingOp_Fill {
 	if x != nil {
-		return x.CurrentDrawer
+		if x, ok := x.Op.(*DrawingOp_Fill_); ok {
+			return x.Fill
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetCurrentRound() int32 {
+func (x *DrawingOp) GetClear() *DrawingOp_Clear {
 	if x != nil {
-		return x.CurrentRound
+		if x, ok := x.Op.(*DrawingOp_Clear_); ok {
+			return x.Clear
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetRoomId() string {
+func (x *DrawingOp) GetUndo() *DrawingOp_Undo {
 	if x != nil {
-		return x.RoomId
+		if x, ok := x.Op.(*DrawingOp_Undo_); ok {
+			return x.Undo
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetNextTick() int64 {
+func (x *DrawingOp) GetRedo() *DrawingOp_Redo {
 	if x != nil {
-		return x.NextTick
+		if x, ok := x.Op.(*DrawingOp_Redo_); ok {
+			return x.Redo
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetChoosingWordDuration() int64 {
+func (x *DrawingOp) GetSetColor() *DrawingOp_SetColor {
 	if x != nil {
-		return x.ChoosingWordDuration
+		if x, ok := x.Op.(*DrawingOp_SetColor_); ok {
+			return x.SetColor
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetDrawingDuration() int64 {
+func (x *DrawingOp) GetSetBrushSize() *DrawingOp_SetBrushSize {
 	if x != nil {
-		return x.DrawingDuration
+		if x, ok := x.Op.(*DrawingOp_SetBrushSize_); ok {
+			return x.SetBrushSize
+		}
 	}
-	return 0
+	return nil
 }
 
-func (x *ServerPacket_InitialRoomSnapshot) GetCurrentPhase() int32 {
-	if x != nil {
-		return x.CurrentPhase
-	}
-	return 0
+type isDrawingOp_Op interface {
+	isDrawingOp_Op()
 }
 
-type ServerPacket_PlayerJoined struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type DrawingOp_StrokeBegin_ struct {
+	StrokeBegin *DrawingOp_StrokeBegin `protobuf:"bytes,1,opt,name=stroke_begin,json=strokeBegin,proto3,oneof"`
 }
 
-func (x *ServerPacket_PlayerJoined) Reset() {
-	*x = ServerPacket_PlayerJoined{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[5]
+type DrawingOp_StrokePoints_ struct {
+	StrokePoints *DrawingOp_StrokePoints `protobuf:"bytes,2,opt,name=stroke_points,json=strokePoints,proto3,oneof"`
+}
+
+type DrawingOp_StrokeEnd_ struct {
+	StrokeEnd *DrawingOp_StrokeEnd `protobuf:"bytes,3,opt,name=stroke_end,json=strokeEnd,proto3,oneof"`
+}
+
+type DrawingOp_Fill_ struct {
+	Fill *DrawingOp_Fill `protobuf:"bytes,4,opt,name=fill,proto3,oneof"`
+}
+
+type DrawingOp_Clear_ struct {
+	Clear *DrawingOp_Clear `protobuf:"bytes,5,opt,name=clear,proto3,oneof"`
+}
+
+type DrawingOp_Undo_ struct {
+	Undo *DrawingOp_Undo `protobuf:"bytes,6,opt,name=undo,proto3,oneof"`
+}
+
+type DrawingOp_Redo_ struct {
+	Redo *DrawingOp_Redo `protobuf:"bytes,7,opt,name=redo,proto3,oneof"`
+}
+
+type DrawingOp_SetColor_ struct {
+	SetColor *DrawingOp_SetColor `protobuf:"bytes,8,opt,name=set_color,json=setColor,proto3,oneof"`
+}
+
+type DrawingOp_SetBrushSize_ struct {
+	SetBrushSize *DrawingOp_SetBrushSize `protobuf:"bytes,9,opt,name=set_brush_size,json=setBrushSize,proto3,oneof"`
+}
+
+func (*DrawingOp_StrokeBegin_) isDrawingOp_Op() {}
+
+func (*DrawingOp_StrokePoints_) isDrawingOp_Op() {}
+
+func (*DrawingOp_StrokeEnd_) isDrawingOp_Op() {}
+
+func (*DrawingOp_Fill_) isDrawingOp_Op() {}
+
+func (*DrawingOp_Clear_) isDrawingOp_Op() {}
+
+func (*DrawingOp_Undo_) isDrawingOp_Op() {}
+
+func (*DrawingOp_Redo_) isDrawingOp_Op() {}
+
+func (*DrawingOp_SetColor_) isDrawingOp_Op() {}
+
+func (*DrawingOp_SetBrushSize_) isDrawingOp_Op() {}
+
+// Answers a ClientPacket.TimeSync right away, times are Unix ms. With t3
+// the time the client got it, the client's clock is behind by
+// ((server_receive_time - client_time) + (server_send_time - t3)) / 2 and
+// the round trip took (t3 - client_time) - (server_send_time -
+// server_receive_time). Clients take a few samples and keep the offset
+// of the one with the shortest round trip.
+type ServerPacket_TimeSync struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	ClientTime        int64                  `protobuf:"varint,1,opt,name=client_time,json=clientTime,proto3" json:"client_time,omitempty"`
+	ServerReceiveTime int64                  `protobuf:"varint,2,opt,name=server_receive_time,json=serverReceiveTime,proto3" json:"server_receive_time,omitempty"`
+	ServerSendTime    int64                  `protobuf:"varint,3,opt,name=server_send_time,json=serverSendTime,proto3" json:"server_send_time,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ServerPacket_TimeSync) Reset() {
+	*x = ServerPacket_TimeSync{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerJoined) String() string {
+func (x *ServerPacket_TimeSync) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerJoined) ProtoMessage() {}
+func (*ServerPacket_TimeSync) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerJoined) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[5]
+func (x *ServerPacket_TimeSync) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -645,39 +914,114 @@ func (x *ServerPacket_PlayerJoined) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerJoined.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerJoined) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 2}
+// Deprecated: Use ServerPacket_TimeSync.ProtoReflect.Descriptor instead.
+func (*ServerPacket_TimeSync) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 0}
 }
 
-func (x *ServerPacket_PlayerJoined) GetUsername() string {
+func (x *ServerPacket_TimeSync) GetClientTime() int64 {
 	if x != nil {
-		return x.Username
+		return x.ClientTime
 	}
-	return ""
+	return 0
 }
 
-type ServerPacket_PlayerLeft struct {
+func (x *ServerPacket_TimeSync) GetServerReceiveTime() int64 {
+	if x != nil {
+		return x.ServerReceiveTime
+	}
+	return 0
+}
+
+func (x *ServerPacket_TimeSync) GetServerSendTime() int64 {
+	if x != nil {
+		return x.ServerSendTime
+	}
+	return 0
+}
+
+// Answers a Hello with the version both sides speak and the features the
+// server will use with this client.
+type ServerPacket_Welcome struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Features        []string               `protobuf:"bytes,2,rep,name=features,proto3" json:"features,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ServerPacket_Welcome) Reset() {
+	*x = ServerPacket_Welcome{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_Welcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_Welcome) ProtoMessage() {}
+
+func (x *ServerPacket_Welcome) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_Welcome.ProtoReflect.Descriptor instead.
+func (*ServerPacket_Welcome) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 1}
+}
+
+func (x *ServerPacket_Welcome) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *ServerPacket_Welcome) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+// Sent to the client that caused it only, and rate limited so some may be
+// left out. code is one of rate-limited, not-host, not-your-turn,
+// invalid-choice, invalid-drawing-op, drawing-ops-unsupported,
+// malformed-packet and unsupported-version, which is followed by the
+// server closing the connection. drawing-ops-unsupported tells the drawer
+// that a player without drawing ops is in the room, it sends data instead.
+type ServerPacket_Error struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PlayerLeft) Reset() {
-	*x = ServerPacket_PlayerLeft{}
+func (x *ServerPacket_Error) Reset() {
+	*x = ServerPacket_Error{}
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerLeft) String() string {
+func (x *ServerPacket_Error) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerLeft) ProtoMessage() {}
+func (*ServerPacket_Error) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerLeft) ProtoReflect() protoreflect.Message {
+func (x *ServerPacket_Error) ProtoReflect() protoreflect.Message {
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -689,38 +1033,49 @@ func (x *ServerPacket_PlayerLeft) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerLeft.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerLeft) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 3}
+// Deprecated: Use ServerPacket_Error.ProtoReflect.Descriptor instead.
+func (*ServerPacket_Error) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 2}
 }
 
-func (x *ServerPacket_PlayerLeft) GetUsername() string {
+func (x *ServerPacket_Error) GetCode() string {
 	if x != nil {
-		return x.Username
+		return x.Code
 	}
 	return ""
 }
 
-type ServerPacket_GameStarted struct {
+func (x *ServerPacket_Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Sent to the drawer, once per turn, when drawing data starts being
+// rejected. reason is drawing-budget-exceeded or drawing-history-full,
+// clearing the canvas frees the history.
+type ServerPacket_DrawingLimitReached struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_GameStarted) Reset() {
-	*x = ServerPacket_GameStarted{}
+func (x *ServerPacket_DrawingLimitReached) Reset() {
+	*x = ServerPacket_DrawingLimitReached{}
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_GameStarted) String() string {
+func (x *ServerPacket_DrawingLimitReached) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_GameStarted) ProtoMessage() {}
+func (*ServerPacket_DrawingLimitReached) ProtoMessage() {}
 
-func (x *ServerPacket_GameStarted) ProtoReflect() protoreflect.Message {
+func (x *ServerPacket_DrawingLimitReached) ProtoReflect() protoreflect.Message {
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -732,32 +1087,39 @@ func (x *ServerPacket_GameStarted) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_GameStarted.ProtoReflect.Descriptor instead.
-func (*ServerPacket_GameStarted) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 4}
+// Deprecated: Use ServerPacket_DrawingLimitReached.ProtoReflect.Descriptor instead.
+func (*ServerPacket_DrawingLimitReached) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 3}
 }
 
-type ServerPacket_RoundUpdate struct {
+func (x *ServerPacket_DrawingLimitReached) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ServerPacket_YourTurnToDraw struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	RoundNumber   int64                  `protobuf:"varint,1,opt,name=round_number,json=roundNumber,proto3" json:"round_number,omitempty"`
+	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_RoundUpdate) Reset() {
-	*x = ServerPacket_RoundUpdate{}
+func (x *ServerPacket_YourTurnToDraw) Reset() {
+	*x = ServerPacket_YourTurnToDraw{}
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_RoundUpdate) String() string {
+func (x *ServerPacket_YourTurnToDraw) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_RoundUpdate) ProtoMessage() {}
+func (*ServerPacket_YourTurnToDraw) ProtoMessage() {}
 
-func (x *ServerPacket_RoundUpdate) ProtoReflect() protoreflect.Message {
+func (x *ServerPacket_YourTurnToDraw) ProtoReflect() protoreflect.Message {
 	mi := &file_domain_protobuf_protocol_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -769,40 +1131,1049 @@ func (x *ServerPacket_RoundUpdate) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_RoundUpdate.ProtoReflect.Descriptor instead.
-func (*ServerPacket_RoundUpdate) Descriptor() ([]byte, []int) {
+// Deprecated: Use ServerPacket_YourTurnToDraw.ProtoReflect.Descriptor instead.
+func (*ServerPacket_YourTurnToDraw) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 4}
+}
+
+func (x *ServerPacket_YourTurnToDraw) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+// Sent on join, and again to a player that fell behind, in which case it
+// replaces the whole room state and canvas.
+type ServerPacket_InitialRoomSnapshot struct {
+	state         protoimpl.MessageState                          `protogen:"open.v1"`
+	PlayersStates []*ServerPacket_InitialRoomSnapshot_PlayerState `protobuf:"bytes,1,rep,name=players_states,json=playersStates,proto3" json:"players_states,omitempty"`
+	// visible strokes of the turn only, one chunk per stroke or fill
+	DrawingHistory       [][]byte `protobuf:"bytes,2,rep,name=drawing_history,json=drawingHistory,proto3" json:"drawing_history,omitempty"`
+	CurrentDrawer        string   `protobuf:"bytes,3,opt,name=current_drawer,json=currentDrawer,proto3" json:"current_drawer,omitempty"`
+	CurrentRound         int32    `protobuf:"varint,4,opt,name=current_round,json=currentRound,proto3" json:"current_round,omitempty"`
+	RoomId               string   `protobuf:"bytes,5,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	NextTick             int64    `protobuf:"varint,6,opt,name=next_tick,json=nextTick,proto3" json:"next_tick,omitempty"`
+	ChoosingWordDuration int64    `protobuf:"varint,7,opt,name=choosing_word_duration,json=choosingWordDuration,proto3" json:"choosing_word_duration,omitempty"`
+	DrawingDuration      int64    `protobuf:"varint,8,opt,name=drawing_duration,json=drawingDuration,proto3" json:"drawing_duration,omitempty"`
+	CurrentPhase         int32    `protobuf:"varint,9,opt,name=current_phase,json=currentPhase,proto3" json:"current_phase,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) Reset() {
+	*x = ServerPacket_InitialRoomSnapshot{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_InitialRoomSnapshot) ProtoMessage() {}
+
+func (x *ServerPacket_InitialRoomSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_InitialRoomSnapshot.ProtoReflect.Descriptor instead.
+func (*ServerPacket_InitialRoomSnapshot) Descriptor() ([]byte, []int) {
 	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 5}
 }
 
-func (x *ServerPacket_RoundUpdate) GetRoundNumber() int64 {
-	if x != nil {
-		return x.RoundNumber
-	}
-	return 0
+func (x *ServerPacket_InitialRoomSnapshot) GetPlayersStates() []*ServerPacket_InitialRoomSnapshot_PlayerState {
+	if x != nil {
+		return x.PlayersStates
+	}
+	return nil
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetDrawingHistory() [][]byte {
+	if x != nil {
+		return x.DrawingHistory
+	}
+	return nil
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetCurrentDrawer() string {
+	if x != nil {
+		return x.CurrentDrawer
+	}
+	return ""
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetCurrentRound() int32 {
+	if x != nil {
+		return x.CurrentRound
+	}
+	return 0
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetNextTick() int64 {
+	if x != nil {
+		return x.NextTick
+	}
+	return 0
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetChoosingWordDuration() int64 {
+	if x != nil {
+		return x.ChoosingWordDuration
+	}
+	return 0
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetDrawingDuration() int64 {
+	if x != nil {
+		return x.DrawingDuration
+	}
+	return 0
+}
+
+func (x *ServerPacket_InitialRoomSnapshot) GetCurrentPhase() int32 {
+	if x != nil {
+		return x.CurrentPhase
+	}
+	return 0
+}
+
+type ServerPacket_PlayerJoined struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerJoined) Reset() {
+	*x = ServerPacket_PlayerJoined{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerJoined) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerJoined) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerJoined) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerJoined.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerJoined) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 6}
+}
+
+func (x *ServerPacket_PlayerJoined) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ServerPacket_PlayerLeft struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerLeft) Reset() {
+	*x = ServerPacket_PlayerLeft{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerLeft) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerLeft) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerLeft) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerLeft.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerLeft) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 7}
+}
+
+func (x *ServerPacket_PlayerLeft) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ServerPacket_GameStarted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_GameStarted) Reset() {
+	*x = ServerPacket_GameStarted{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_GameStarted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_GameStarted) ProtoMessage() {}
+
+func (x *ServerPacket_GameStarted) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_GameStarted.ProtoReflect.Descriptor instead.
+func (*ServerPacket_GameStarted) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 8}
+}
+
+type ServerPacket_RoundUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoundNumber   int64                  `protobuf:"varint,1,opt,name=round_number,json=roundNumber,proto3" json:"round_number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_RoundUpdate) Reset() {
+	*x = ServerPacket_RoundUpdate{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_RoundUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_RoundUpdate) ProtoMessage() {}
+
+func (x *ServerPacket_RoundUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_RoundUpdate.ProtoReflect.Descriptor instead.
+func (*ServerPacket_RoundUpdate) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 9}
+}
+
+func (x *ServerPacket_RoundUpdate) GetRoundNumber() int64 {
+	if x != nil {
+		return x.RoundNumber
+	}
+	return 0
+}
+
+type ServerPacket_PlayerIsChoosingWord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerIsChoosingWord) Reset() {
+	*x = ServerPacket_PlayerIsChoosingWord{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerIsChoosingWord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerIsChoosingWord) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerIsChoosingWord) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerIsChoosingWord.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerIsChoosingWord) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 10}
+}
+
+func (x *ServerPacket_PlayerIsChoosingWord) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ServerPacket_PlayerIsDrawing struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerIsDrawing) Reset() {
+	*x = ServerPacket_PlayerIsDrawing{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerIsDrawing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerIsDrawing) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerIsDrawing) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerIsDrawing.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerIsDrawing) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 11}
+}
+
+func (x *ServerPacket_PlayerIsDrawing) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ServerPacket_TurnSummary struct {
+	state         protoimpl.MessageState                  `protogen:"open.v1"`
+	WordReveal    string                                  `protobuf:"bytes,1,opt,name=word_reveal,json=wordReveal,proto3" json:"word_reveal,omitempty"`
+	Deltas        []*ServerPacket_TurnSummary_ScoreDeltas `protobuf:"bytes,2,rep,name=deltas,proto3" json:"deltas,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_TurnSummary) Reset() {
+	*x = ServerPacket_TurnSummary{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_TurnSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_TurnSummary) ProtoMessage() {}
+
+func (x *ServerPacket_TurnSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_TurnSummary.ProtoReflect.Descriptor instead.
+func (*ServerPacket_TurnSummary) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 12}
+}
+
+func (x *ServerPacket_TurnSummary) GetWordReveal() string {
+	if x != nil {
+		return x.WordReveal
+	}
+	return ""
+}
+
+func (x *ServerPacket_TurnSummary) GetDeltas() []*ServerPacket_TurnSummary_ScoreDeltas {
+	if x != nil {
+		return x.Deltas
+	}
+	return nil
+}
+
+type ServerPacket_PlayerGuessedTheWord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerGuessedTheWord) Reset() {
+	*x = ServerPacket_PlayerGuessedTheWord{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerGuessedTheWord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerGuessedTheWord) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerGuessedTheWord) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerGuessedTheWord.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerGuessedTheWord) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 13}
+}
+
+func (x *ServerPacket_PlayerGuessedTheWord) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type ServerPacket_LeaderBoard struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_LeaderBoard) Reset() {
+	*x = ServerPacket_LeaderBoard{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_LeaderBoard) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_LeaderBoard) ProtoMessage() {}
+
+func (x *ServerPacket_LeaderBoard) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_LeaderBoard.ProtoReflect.Descriptor instead.
+func (*ServerPacket_LeaderBoard) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 14}
+}
+
+type ServerPacket_PlayerMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PlayerMessage) Reset() {
+	*x = ServerPacket_PlayerMessage{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PlayerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PlayerMessage) ProtoMessage() {}
+
+func (x *ServerPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PlayerMessage.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PlayerMessage) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 15}
+}
+
+func (x *ServerPacket_PlayerMessage) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ServerPacket_PlayerMessage) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type ServerPacket_PleaseChooseAWord struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Words         []string               `protobuf:"bytes,1,rep,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_PleaseChooseAWord) Reset() {
+	*x = ServerPacket_PleaseChooseAWord{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_PleaseChooseAWord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_PleaseChooseAWord) ProtoMessage() {}
+
+func (x *ServerPacket_PleaseChooseAWord) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_PleaseChooseAWord.ProtoReflect.Descriptor instead.
+func (*ServerPacket_PleaseChooseAWord) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 16}
+}
+
+func (x *ServerPacket_PleaseChooseAWord) GetWords() []string {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+type ServerPacket_InitialRoomSnapshot_PlayerState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Score         int64                  `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
+	IsGuesser     bool                   `protobuf:"varint,3,opt,name=is_guesser,json=isGuesser,proto3" json:"is_guesser,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) Reset() {
+	*x = ServerPacket_InitialRoomSnapshot_PlayerState{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_InitialRoomSnapshot_PlayerState) ProtoMessage() {}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_InitialRoomSnapshot_PlayerState.ProtoReflect.Descriptor instead.
+func (*ServerPacket_InitialRoomSnapshot_PlayerState) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 5, 0}
+}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetScore() int64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetIsGuesser() bool {
+	if x != nil {
+		return x.IsGuesser
+	}
+	return false
+}
+
+type ServerPacket_TurnSummary_ScoreDeltas struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	ScoreDelta    int64                  `protobuf:"varint,2,opt,name=score_delta,json=scoreDelta,proto3" json:"score_delta,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerPacket_TurnSummary_ScoreDeltas) Reset() {
+	*x = ServerPacket_TurnSummary_ScoreDeltas{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerPacket_TurnSummary_ScoreDeltas) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerPacket_TurnSummary_ScoreDeltas) ProtoMessage() {}
+
+func (x *ServerPacket_TurnSummary_ScoreDeltas) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerPacket_TurnSummary_ScoreDeltas.ProtoReflect.Descriptor instead.
+func (*ServerPacket_TurnSummary_ScoreDeltas) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 12, 0}
+}
+
+func (x *ServerPacket_TurnSummary_ScoreDeltas) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *ServerPacket_TurnSummary_ScoreDeltas) GetScoreDelta() int64 {
+	if x != nil {
+		return x.ScoreDelta
+	}
+	return 0
+}
+
+// Asks for a ServerPacket.TimeSync, client_time is when the client sent
+// it in Unix ms by its own clock. It is answered outside of the game and
+// says nothing about the connection being alive, the websocket ping does.
+type ClientPacket_TimeSync struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClientTime    int64                  `protobuf:"varint,1,opt,name=client_time,json=clientTime,proto3" json:"client_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_TimeSync) Reset() {
+	*x = ClientPacket_TimeSync{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_TimeSync) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_TimeSync) ProtoMessage() {}
+
+func (x *ClientPacket_TimeSync) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_TimeSync.ProtoReflect.Descriptor instead.
+func (*ClientPacket_TimeSync) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *ClientPacket_TimeSync) GetClientTime() int64 {
+	if x != nil {
+		return x.ClientTime
+	}
+	return 0
+}
+
+// Asks for the packets after last_seq again. The room replays them while
+// it still has them and sends a fresh InitialRoomSnapshot otherwise.
+type ClientPacket_Resume struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LastSeq       uint64                 `protobuf:"varint,1,opt,name=last_seq,json=lastSeq,proto3" json:"last_seq,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_Resume) Reset() {
+	*x = ClientPacket_Resume{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_Resume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_Resume) ProtoMessage() {}
+
+func (x *ClientPacket_Resume) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_Resume.ProtoReflect.Descriptor instead.
+func (*ClientPacket_Resume) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 1}
+}
+
+func (x *ClientPacket_Resume) GetLastSeq() uint64 {
+	if x != nil {
+		return x.LastSeq
+	}
+	return 0
+}
+
+// Hello must be the first packet, a client that does not send one speaks
+// protocol version 1 with no optional features. It accepts any version
+// from min_protocol_version to protocol_version.
+type ClientPacket_Hello struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion    uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	MinProtocolVersion uint32                 `protobuf:"varint,2,opt,name=min_protocol_version,json=minProtocolVersion,proto3" json:"min_protocol_version,omitempty"`
+	Capabilities       []string               `protobuf:"bytes,3,rep,name=capabilities,proto3" json:"capabilities,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ClientPacket_Hello) Reset() {
+	*x = ClientPacket_Hello{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_Hello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_Hello) ProtoMessage() {}
+
+func (x *ClientPacket_Hello) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_Hello.ProtoReflect.Descriptor instead.
+func (*ClientPacket_Hello) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 2}
+}
+
+func (x *ClientPacket_Hello) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *ClientPacket_Hello) GetMinProtocolVersion() uint32 {
+	if x != nil {
+		return x.MinProtocolVersion
+	}
+	return 0
+}
+
+func (x *ClientPacket_Hello) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+type ClientPacket_StartGame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_StartGame) Reset() {
+	*x = ClientPacket_StartGame{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_StartGame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_StartGame) ProtoMessage() {}
+
+func (x *ClientPacket_StartGame) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_StartGame.ProtoReflect.Descriptor instead.
+func (*ClientPacket_StartGame) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 3}
+}
+
+// Undo, redo and clear are drawer only. The room keeps the stroke stack and
+// relays them as a DrawingData op when the canvas changed.
+type ClientPacket_Undo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_Undo) Reset() {
+	*x = ClientPacket_Undo{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_Undo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_Undo) ProtoMessage() {}
+
+func (x *ClientPacket_Undo) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_Undo.ProtoReflect.Descriptor instead.
+func (*ClientPacket_Undo) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 4}
+}
+
+type ClientPacket_Redo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_Redo) Reset() {
+	*x = ClientPacket_Redo{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_Redo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_Redo) ProtoMessage() {}
+
+func (x *ClientPacket_Redo) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_Redo.ProtoReflect.Descriptor instead.
+func (*ClientPacket_Redo) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 5}
+}
+
+type ClientPacket_ClearCanvas struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientPacket_ClearCanvas) Reset() {
+	*x = ClientPacket_ClearCanvas{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientPacket_ClearCanvas) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPacket_ClearCanvas) ProtoMessage() {}
+
+func (x *ClientPacket_ClearCanvas) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPacket_ClearCanvas.ProtoReflect.Descriptor instead.
+func (*ClientPacket_ClearCanvas) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 6}
 }
 
-type ServerPacket_PlayerIsChoosingWord struct {
+type ClientPacket_WordChoice struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Choice        int64                  `protobuf:"varint,1,opt,name=choice,proto3" json:"choice,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PlayerIsChoosingWord) Reset() {
-	*x = ServerPacket_PlayerIsChoosingWord{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[9]
+func (x *ClientPacket_WordChoice) Reset() {
+	*x = ClientPacket_WordChoice{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerIsChoosingWord) String() string {
+func (x *ClientPacket_WordChoice) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerIsChoosingWord) ProtoMessage() {}
+func (*ClientPacket_WordChoice) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerIsChoosingWord) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[9]
+func (x *ClientPacket_WordChoice) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -813,40 +2184,40 @@ func (x *ServerPacket_PlayerIsChoosingWord) ProtoReflect() protoreflect.Message
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerIsChoosingWord.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerIsChoosingWord) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 6}
+// Deprecated: Use ClientPacket_WordChoice.ProtoReflect.Descriptor instead.
+func (*ClientPacket_WordChoice) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 7}
 }
 
-func (x *ServerPacket_PlayerIsChoosingWord) GetUsername() string {
+func (x *ClientPacket_WordChoice) GetChoice() int64 {
 	if x != nil {
-		return x.Username
+		return x.Choice
 	}
-	return ""
+	return 0
 }
 
-type ServerPacket_PlayerIsDrawing struct {
+type ClientPacket_PlayerMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PlayerIsDrawing) Reset() {
-	*x = ServerPacket_PlayerIsDrawing{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[10]
+func (x *ClientPacket_PlayerMessage) Reset() {
+	*x = ClientPacket_PlayerMessage{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerIsDrawing) String() string {
+func (x *ClientPacket_PlayerMessage) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerIsDrawing) ProtoMessage() {}
+func (*ClientPacket_PlayerMessage) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerIsDrawing) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[10]
+func (x *ClientPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -857,41 +2228,41 @@ func (x *ServerPacket_PlayerIsDrawing) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerIsDrawing.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerIsDrawing) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 7}
+// Deprecated: Use ClientPacket_PlayerMessage.ProtoReflect.Descriptor instead.
+func (*ClientPacket_PlayerMessage) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 8}
 }
 
-func (x *ServerPacket_PlayerIsDrawing) GetUsername() string {
+func (x *ClientPacket_PlayerMessage) GetMessage() string {
 	if x != nil {
-		return x.Username
+		return x.Message
 	}
 	return ""
 }
 
-type ServerPacket_TurnSummary struct {
-	state         protoimpl.MessageState                  `protogen:"open.v1"`
-	WordReveal    string                                  `protobuf:"bytes,1,opt,name=word_reveal,json=wordReveal,proto3" json:"word_reveal,omitempty"`
-	Deltas        []*ServerPacket_TurnSummary_ScoreDeltas `protobuf:"bytes,2,rep,name=deltas,proto3" json:"deltas,omitempty"`
+type DrawingOp_Point struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             uint32                 `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             uint32                 `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_TurnSummary) Reset() {
-	*x = ServerPacket_TurnSummary{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[11]
+func (x *DrawingOp_Point) Reset() {
+	*x = DrawingOp_Point{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_TurnSummary) String() string {
+func (x *DrawingOp_Point) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_TurnSummary) ProtoMessage() {}
+func (*DrawingOp_Point) ProtoMessage() {}
 
-func (x *ServerPacket_TurnSummary) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[11]
+func (x *DrawingOp_Point) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -902,47 +2273,47 @@ func (x *ServerPacket_TurnSummary) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_TurnSummary.ProtoReflect.Descriptor instead.
-func (*ServerPacket_TurnSummary) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 8}
+// Deprecated: Use DrawingOp_Point.ProtoReflect.Descriptor instead.
+func (*DrawingOp_Point) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 0}
 }
 
-func (x *ServerPacket_TurnSummary) GetWordReveal() string {
+func (x *DrawingOp_Point) GetX() uint32 {
 	if x != nil {
-		return x.WordReveal
+		return x.X
 	}
-	return ""
+	return 0
 }
 
-func (x *ServerPacket_TurnSummary) GetDeltas() []*ServerPacket_TurnSummary_ScoreDeltas {
+func (x *DrawingOp_Point) GetY() uint32 {
 	if x != nil {
-		return x.Deltas
+		return x.Y
 	}
-	return nil
+	return 0
 }
 
-type ServerPacket_PlayerGuessedTheWord struct {
+type DrawingOp_StrokeBegin struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	At            *DrawingOp_Point       `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PlayerGuessedTheWord) Reset() {
-	*x = ServerPacket_PlayerGuessedTheWord{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[12]
+func (x *DrawingOp_StrokeBegin) Reset() {
+	*x = DrawingOp_StrokeBegin{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerGuessedTheWord) String() string {
+func (x *DrawingOp_StrokeBegin) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerGuessedTheWord) ProtoMessage() {}
+func (*DrawingOp_StrokeBegin) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerGuessedTheWord) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[12]
+func (x *DrawingOp_StrokeBegin) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -953,39 +2324,40 @@ func (x *ServerPacket_PlayerGuessedTheWord) ProtoReflect() protoreflect.Message
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerGuessedTheWord.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerGuessedTheWord) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 9}
+// Deprecated: Use DrawingOp_StrokeBegin.ProtoReflect.Descriptor instead.
+func (*DrawingOp_StrokeBegin) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 1}
 }
 
-func (x *ServerPacket_PlayerGuessedTheWord) GetUsername() string {
+func (x *DrawingOp_StrokeBegin) GetAt() *DrawingOp_Point {
 	if x != nil {
-		return x.Username
+		return x.At
 	}
-	return ""
+	return nil
 }
 
-type ServerPacket_LeaderBoard struct {
+type DrawingOp_StrokePoints struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Points        []*DrawingOp_Point     `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_LeaderBoard) Reset() {
-	*x = ServerPacket_LeaderBoard{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[13]
+func (x *DrawingOp_StrokePoints) Reset() {
+	*x = DrawingOp_StrokePoints{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_LeaderBoard) String() string {
+func (x *DrawingOp_StrokePoints) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_LeaderBoard) ProtoMessage() {}
+func (*DrawingOp_StrokePoints) ProtoMessage() {}
 
-func (x *ServerPacket_LeaderBoard) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[13]
+func (x *DrawingOp_StrokePoints) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -996,34 +2368,39 @@ func (x *ServerPacket_LeaderBoard) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_LeaderBoard.ProtoReflect.Descriptor instead.
-func (*ServerPacket_LeaderBoard) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 10}
+// Deprecated: Use DrawingOp_StrokePoints.ProtoReflect.Descriptor instead.
+func (*DrawingOp_StrokePoints) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 2}
 }
 
-type ServerPacket_PlayerMessage struct {
+func (x *DrawingOp_StrokePoints) GetPoints() []*DrawingOp_Point {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+type DrawingOp_StrokeEnd struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PlayerMessage) Reset() {
-	*x = ServerPacket_PlayerMessage{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[14]
+func (x *DrawingOp_StrokeEnd) Reset() {
+	*x = DrawingOp_StrokeEnd{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PlayerMessage) String() string {
+func (x *DrawingOp_StrokeEnd) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PlayerMessage) ProtoMessage() {}
+func (*DrawingOp_StrokeEnd) ProtoMessage() {}
 
-func (x *ServerPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[14]
+func (x *DrawingOp_StrokeEnd) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1034,47 +2411,33 @@ func (x *ServerPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PlayerMessage.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PlayerMessage) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 11}
-}
-
-func (x *ServerPacket_PlayerMessage) GetFrom() string {
-	if x != nil {
-		return x.From
-	}
-	return ""
-}
-
-func (x *ServerPacket_PlayerMessage) GetMessage() string {
-	if x != nil {
-		return x.Message
-	}
-	return ""
+// Deprecated: Use DrawingOp_StrokeEnd.ProtoReflect.Descriptor instead.
+func (*DrawingOp_StrokeEnd) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 3}
 }
 
-type ServerPacket_PleaseChooseAWord struct {
+type DrawingOp_Fill struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Words         []string               `protobuf:"bytes,1,rep,name=words,proto3" json:"words,omitempty"`
+	At            *DrawingOp_Point       `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_PleaseChooseAWord) Reset() {
-	*x = ServerPacket_PleaseChooseAWord{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[15]
+func (x *DrawingOp_Fill) Reset() {
+	*x = DrawingOp_Fill{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_PleaseChooseAWord) String() string {
+func (x *DrawingOp_Fill) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_PleaseChooseAWord) ProtoMessage() {}
+func (*DrawingOp_Fill) ProtoMessage() {}
 
-func (x *ServerPacket_PleaseChooseAWord) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[15]
+func (x *DrawingOp_Fill) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1085,42 +2448,39 @@ func (x *ServerPacket_PleaseChooseAWord) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_PleaseChooseAWord.ProtoReflect.Descriptor instead.
-func (*ServerPacket_PleaseChooseAWord) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 12}
+// Deprecated: Use DrawingOp_Fill.ProtoReflect.Descriptor instead.
+func (*DrawingOp_Fill) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 4}
 }
 
-func (x *ServerPacket_PleaseChooseAWord) GetWords() []string {
+func (x *DrawingOp_Fill) GetAt() *DrawingOp_Point {
 	if x != nil {
-		return x.Words
+		return x.At
 	}
 	return nil
 }
 
-type ServerPacket_InitialRoomSnapshot_PlayerState struct {
+type DrawingOp_Clear struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	Score         int64                  `protobuf:"varint,2,opt,name=score,proto3" json:"score,omitempty"`
-	IsGuesser     bool                   `protobuf:"varint,3,opt,name=is_guesser,json=isGuesser,proto3" json:"is_guesser,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) Reset() {
-	*x = ServerPacket_InitialRoomSnapshot_PlayerState{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[16]
+func (x *DrawingOp_Clear) Reset() {
+	*x = DrawingOp_Clear{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) String() string {
+func (x *DrawingOp_Clear) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_InitialRoomSnapshot_PlayerState) ProtoMessage() {}
+func (*DrawingOp_Clear) ProtoMessage() {}
 
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[16]
+func (x *DrawingOp_Clear) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1131,55 +2491,32 @@ func (x *ServerPacket_InitialRoomSnapshot_PlayerState) ProtoReflect() protorefle
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_InitialRoomSnapshot_PlayerState.ProtoReflect.Descriptor instead.
-func (*ServerPacket_InitialRoomSnapshot_PlayerState) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 1, 0}
-}
-
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetUsername() string {
-	if x != nil {
-		return x.Username
-	}
-	return ""
-}
-
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetScore() int64 {
-	if x != nil {
-		return x.Score
-	}
-	return 0
-}
-
-func (x *ServerPacket_InitialRoomSnapshot_PlayerState) GetIsGuesser() bool {
-	if x != nil {
-		return x.IsGuesser
-	}
-	return false
+// Deprecated: Use DrawingOp_Clear.ProtoReflect.Descriptor instead.
+func (*DrawingOp_Clear) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 5}
 }
 
-type ServerPacket_TurnSummary_ScoreDeltas struct {
+type DrawingOp_Undo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	ScoreDelta    int64                  `protobuf:"varint,2,opt,name=score_delta,json=scoreDelta,proto3" json:"score_delta,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ServerPacket_TurnSummary_ScoreDeltas) Reset() {
-	*x = ServerPacket_TurnSummary_ScoreDeltas{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[17]
+func (x *DrawingOp_Undo) Reset() {
+	*x = DrawingOp_Undo{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ServerPacket_TurnSummary_ScoreDeltas) String() string {
+func (x *DrawingOp_Undo) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ServerPacket_TurnSummary_ScoreDeltas) ProtoMessage() {}
+func (*DrawingOp_Undo) ProtoMessage() {}
 
-func (x *ServerPacket_TurnSummary_ScoreDeltas) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[17]
+func (x *DrawingOp_Undo) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1190,46 +2527,32 @@ func (x *ServerPacket_TurnSummary_ScoreDeltas) ProtoReflect() protoreflect.Messa
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ServerPacket_TurnSummary_ScoreDeltas.ProtoReflect.Descriptor instead.
-func (*ServerPacket_TurnSummary_ScoreDeltas) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{0, 8, 0}
-}
-
-func (x *ServerPacket_TurnSummary_ScoreDeltas) GetUsername() string {
-	if x != nil {
-		return x.Username
-	}
-	return ""
-}
-
-func (x *ServerPacket_TurnSummary_ScoreDeltas) GetScoreDelta() int64 {
-	if x != nil {
-		return x.ScoreDelta
-	}
-	return 0
+// Deprecated: Use DrawingOp_Undo.ProtoReflect.Descriptor instead.
+func (*DrawingOp_Undo) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 6}
 }
 
-type ClientPacket_StartGame struct {
+type DrawingOp_Redo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ClientPacket_StartGame) Reset() {
-	*x = ClientPacket_StartGame{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[18]
+func (x *DrawingOp_Redo) Reset() {
+	*x = DrawingOp_Redo{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ClientPacket_StartGame) String() string {
+func (x *DrawingOp_Redo) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientPacket_StartGame) ProtoMessage() {}
+func (*DrawingOp_Redo) ProtoMessage() {}
 
-func (x *ClientPacket_StartGame) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[18]
+func (x *DrawingOp_Redo) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1240,33 +2563,33 @@ func (x *ClientPacket_StartGame) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientPacket_StartGame.ProtoReflect.Descriptor instead.
-func (*ClientPacket_StartGame) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 0}
+// Deprecated: Use DrawingOp_Redo.ProtoReflect.Descriptor instead.
+func (*DrawingOp_Redo) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 7}
 }
 
-type ClientPacket_WordChoice struct {
+type DrawingOp_SetColor struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Choice        int64                  `protobuf:"varint,1,opt,name=choice,proto3" json:"choice,omitempty"`
+	Rgba          uint32                 `protobuf:"varint,1,opt,name=rgba,proto3" json:"rgba,omitempty"` // 0xRRGGBBAA
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ClientPacket_WordChoice) Reset() {
-	*x = ClientPacket_WordChoice{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[19]
+func (x *DrawingOp_SetColor) Reset() {
+	*x = DrawingOp_SetColor{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[40]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ClientPacket_WordChoice) String() string {
+func (x *DrawingOp_SetColor) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientPacket_WordChoice) ProtoMessage() {}
+func (*DrawingOp_SetColor) ProtoMessage() {}
 
-func (x *ClientPacket_WordChoice) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[19]
+func (x *DrawingOp_SetColor) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[40]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1277,40 +2600,40 @@ func (x *ClientPacket_WordChoice) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientPacket_WordChoice.ProtoReflect.Descriptor instead.
-func (*ClientPacket_WordChoice) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 1}
+// Deprecated: Use DrawingOp_SetColor.ProtoReflect.Descriptor instead.
+func (*DrawingOp_SetColor) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 8}
 }
 
-func (x *ClientPacket_WordChoice) GetChoice() int64 {
+func (x *DrawingOp_SetColor) GetRgba() uint32 {
 	if x != nil {
-		return x.Choice
+		return x.Rgba
 	}
 	return 0
 }
 
-type ClientPacket_PlayerMessage struct {
+type DrawingOp_SetBrushSize struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	Size          uint32                 `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ClientPacket_PlayerMessage) Reset() {
-	*x = ClientPacket_PlayerMessage{}
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[20]
+func (x *DrawingOp_SetBrushSize) Reset() {
+	*x = DrawingOp_SetBrushSize{}
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ClientPacket_PlayerMessage) String() string {
+func (x *DrawingOp_SetBrushSize) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientPacket_PlayerMessage) ProtoMessage() {}
+func (*DrawingOp_SetBrushSize) ProtoMessage() {}
 
-func (x *ClientPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_domain_protobuf_protocol_proto_msgTypes[20]
+func (x *DrawingOp_SetBrushSize) ProtoReflect() protoreflect.Message {
+	mi := &file_domain_protobuf_protocol_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1321,23 +2644,23 @@ func (x *ClientPacket_PlayerMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientPacket_PlayerMessage.ProtoReflect.Descriptor instead.
-func (*ClientPacket_PlayerMessage) Descriptor() ([]byte, []int) {
-	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{1, 2}
+// Deprecated: Use DrawingOp_SetBrushSize.ProtoReflect.Descriptor instead.
+func (*DrawingOp_SetBrushSize) Descriptor() ([]byte, []int) {
+	return file_domain_protobuf_protocol_proto_rawDescGZIP(), []int{3, 9}
 }
 
-func (x *ClientPacket_PlayerMessage) GetMessage() string {
+func (x *DrawingOp_SetBrushSize) GetSize() uint32 {
 	if x != nil {
-		return x.Message
+		return x.Size
 	}
-	return ""
+	return 0
 }
 
 var File_domain_protobuf_protocol_proto protoreflect.FileDescriptor
 
 const file_domain_protobuf_protocol_proto_rawDesc = "" +
 	"\n" +
-	"\x1edomain/protobuf/protocol.proto\x12\bprotobuf\"\xd3\x12\n" +
+	"\x1edomain/protobuf/protocol.proto\x12\bprotobuf\"\xb2\x18\n" +
 	"\fServerPacket\x12:\n" +
 	"\fdrawing_data\x18\x01 \x01(\v2\x15.protobuf.DrawingDataH\x00R\vdrawingData\x12J\n" +
 	"\rplayer_joined\x18\x02 \x01(\v2#.protobuf.ServerPacket.PlayerJoinedH\x00R\fplayerJoined\x12G\n" +
@@ -1354,8 +2677,29 @@ const file_domain_protobuf_protocol_proto_rawDesc = "" +
 	"\x15initial_room_snapshot\x18\f \x01(\v2*.protobuf.ServerPacket.InitialRoomSnapshotH\x00R\x13initialRoomSnapshot\x12R\n" +
 	"\x11your_turn_to_draw\x18\r \x01(\v2%.protobuf.ServerPacket.YourTurnToDrawH\x00R\x0eyourTurnToDraw\x12D\n" +
 	"\vplayer_left\x18\x0e \x01(\v2!.protobuf.ServerPacket.PlayerLeftH\x00R\n" +
-	"playerLeft\x12)\n" +
-	"\x10server_timestamp\x18\x10 \x01(\x03R\x0fserverTimestamp\x1a$\n" +
+	"playerLeft\x12`\n" +
+	"\x15drawing_limit_reached\x18\x11 \x01(\v2*.protobuf.ServerPacket.DrawingLimitReachedH\x00R\x13drawingLimitReached\x12:\n" +
+	"\awelcome\x18\x13 \x01(\v2\x1e.protobuf.ServerPacket.WelcomeH\x00R\awelcome\x124\n" +
+	"\x05error\x18\x14 \x01(\v2\x1c.protobuf.ServerPacket.ErrorH\x00R\x05error\x12>\n" +
+	"\ttime_sync\x18\x17 \x01(\v2\x1f.protobuf.ServerPacket.TimeSyncH\x00R\btimeSync\x12)\n" +
+	"\x10server_timestamp\x18\x10 \x01(\x03R\x0fserverTimestamp\x12\x10\n" +
+	"\x03seq\x18\x15 \x01(\x04R\x03seq\x12\x1b\n" +
+	"\tfirst_seq\x18\x16 \x01(\x04R\bfirstSeq\x12,\n" +
+	"\x12phase_remaining_ms\x18\x18 \x01(\x03R\x10phaseRemainingMs\x12,\n" +
+	"\x05batch\x18\x12 \x03(\v2\x16.protobuf.ServerPacketR\x05batch\x1a\x85\x01\n" +
+	"\bTimeSync\x12\x1f\n" +
+	"\vclient_time\x18\x01 \x01(\x03R\n" +
+	"clientTime\x12.\n" +
+	"\x13server_receive_time\x18\x02 \x01(\x03R\x11serverReceiveTime\x12(\n" +
+	"\x10server_send_time\x18\x03 \x01(\x03R\x0eserverSendTime\x1aP\n" +
+	"\aWelcome\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bfeatures\x18\x02 \x03(\tR\bfeatures\x1a5\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x1a-\n" +
+	"\x13DrawingLimitReached\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x1a$\n" +
 	"\x0eYourTurnToDraw\x12\x12\n" +
 	"\x04word\x18\x01 \x01(\tR\x04word\x1a\x85\x04\n" +
 	"\x13InitialRoomSnapshot\x12]\n" +
@@ -1401,23 +2745,72 @@ const file_domain_protobuf_protocol_proto_rawDesc = "" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x1a)\n" +
 	"\x11PleaseChooseAWord\x12\x14\n" +
 	"\x05words\x18\x01 \x03(\tR\x05wordsB\t\n" +
-	"\apayload\"\x8b\x03\n" +
+	"\apayload\"\xe5\a\n" +
 	"\fClientPacket\x12:\n" +
 	"\fdrawing_data\x18\x01 \x01(\v2\x15.protobuf.DrawingDataH\x00R\vdrawingData\x12M\n" +
 	"\x0eplayer_message\x18\x02 \x01(\v2$.protobuf.ClientPacket.PlayerMessageH\x00R\rplayerMessage\x12D\n" +
 	"\vword_choice\x18\x03 \x01(\v2!.protobuf.ClientPacket.WordChoiceH\x00R\n" +
 	"wordChoice\x12A\n" +
 	"\n" +
-	"start_game\x18\x05 \x01(\v2 .protobuf.ClientPacket.StartGameH\x00R\tstartGame\x1a\v\n" +
-	"\tStartGame\x1a$\n" +
+	"start_game\x18\x05 \x01(\v2 .protobuf.ClientPacket.StartGameH\x00R\tstartGame\x121\n" +
+	"\x04undo\x18\x06 \x01(\v2\x1b.protobuf.ClientPacket.UndoH\x00R\x04undo\x121\n" +
+	"\x04redo\x18\a \x01(\v2\x1b.protobuf.ClientPacket.RedoH\x00R\x04redo\x12G\n" +
+	"\fclear_canvas\x18\b \x01(\v2\".protobuf.ClientPacket.ClearCanvasH\x00R\vclearCanvas\x124\n" +
+	"\x05hello\x18\t \x01(\v2\x1c.protobuf.ClientPacket.HelloH\x00R\x05hello\x127\n" +
+	"\x06resume\x18\n" +
+	" \x01(\v2\x1d.protobuf.ClientPacket.ResumeH\x00R\x06resume\x12>\n" +
+	"\ttime_sync\x18\v \x01(\v2\x1f.protobuf.ClientPacket.TimeSyncH\x00R\btimeSync\x1a+\n" +
+	"\bTimeSync\x12\x1f\n" +
+	"\vclient_time\x18\x01 \x01(\x03R\n" +
+	"clientTime\x1a#\n" +
+	"\x06Resume\x12\x19\n" +
+	"\blast_seq\x18\x01 \x01(\x04R\alastSeq\x1a\x88\x01\n" +
+	"\x05Hello\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x120\n" +
+	"\x14min_protocol_version\x18\x02 \x01(\rR\x12minProtocolVersion\x12\"\n" +
+	"\fcapabilities\x18\x03 \x03(\tR\fcapabilities\x1a\v\n" +
+	"\tStartGame\x1a\x06\n" +
+	"\x04Undo\x1a\x06\n" +
+	"\x04Redo\x1a\r\n" +
+	"\vClearCanvas\x1a$\n" +
 	"\n" +
 	"WordChoice\x12\x16\n" +
 	"\x06choice\x18\x01 \x01(\x03R\x06choice\x1a)\n" +
 	"\rPlayerMessage\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessageB\t\n" +
-	"\apayload\"!\n" +
+	"\apayload\"H\n" +
 	"\vDrawingData\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04dataB\x1eZ\x1capi/domain/protobuf;protobufb\x06proto3"
+	"\x04data\x18\x01 \x01(\fR\x04data\x12%\n" +
+	"\x03ops\x18\x02 \x03(\v2\x13.protobuf.DrawingOpR\x03ops\"\xe9\x06\n" +
+	"\tDrawingOp\x12D\n" +
+	"\fstroke_begin\x18\x01 \x01(\v2\x1f.protobuf.DrawingOp.StrokeBeginH\x00R\vstrokeBegin\x12G\n" +
+	"\rstroke_points\x18\x02 \x01(\v2 .protobuf.DrawingOp.StrokePointsH\x00R\fstrokePoints\x12>\n" +
+	"\n" +
+	"stroke_end\x18\x03 \x01(\v2\x1d.protobuf.DrawingOp.StrokeEndH\x00R\tstrokeEnd\x12.\n" +
+	"\x04fill\x18\x04 \x01(\v2\x18.protobuf.DrawingOp.FillH\x00R\x04fill\x121\n" +
+	"\x05clear\x18\x05 \x01(\v2\x19.protobuf.DrawingOp.ClearH\x00R\x05clear\x12.\n" +
+	"\x04undo\x18\x06 \x01(\v2\x18.protobuf.DrawingOp.UndoH\x00R\x04undo\x12.\n" +
+	"\x04redo\x18\a \x01(\v2\x18.protobuf.DrawingOp.RedoH\x00R\x04redo\x12;\n" +
+	"\tset_color\x18\b \x01(\v2\x1c.protobuf.DrawingOp.SetColorH\x00R\bsetColor\x12H\n" +
+	"\x0eset_brush_size\x18\t \x01(\v2 .protobuf.DrawingOp.SetBrushSizeH\x00R\fsetBrushSize\x1a#\n" +
+	"\x05Point\x12\f\n" +
+	"\x01x\x18\x01 \x01(\rR\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\rR\x01y\x1a8\n" +
+	"\vStrokeBegin\x12)\n" +
+	"\x02at\x18\x01 \x01(\v2\x19.protobuf.DrawingOp.PointR\x02at\x1aA\n" +
+	"\fStrokePoints\x121\n" +
+	"\x06points\x18\x01 \x03(\v2\x19.protobuf.DrawingOp.PointR\x06points\x1a\v\n" +
+	"\tStrokeEnd\x1a1\n" +
+	"\x04Fill\x12)\n" +
+	"\x02at\x18\x01 \x01(\v2\x19.protobuf.DrawingOp.PointR\x02at\x1a\a\n" +
+	"\x05Clear\x1a\x06\n" +
+	"\x04Undo\x1a\x06\n" +
+	"\x04Redo\x1a\x1e\n" +
+	"\bSetColor\x12\x12\n" +
+	"\x04rgba\x18\x01 \x01(\rR\x04rgba\x1a\"\n" +
+	"\fSetBrushSize\x12\x12\n" +
+	"\x04size\x18\x01 \x01(\rR\x04sizeB\x04\n" +
+	"\x02opB\x1eZ\x1capi/domain/protobuf;protobufb\x06proto3"
 
 var (
 	file_domain_protobuf_protocol_proto_rawDescOnce sync.Once
@@ -1431,56 +2824,101 @@ func file_domain_protobuf_protocol_proto_rawDescGZIP() []byte {
 	return file_domain_protobuf_protocol_proto_rawDescData
 }
 
-var file_domain_protobuf_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_domain_protobuf_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 42)
 var file_domain_protobuf_protocol_proto_goTypes = []any{
 	(*ServerPacket)(nil),                                 // 0: protobuf.ServerPacket
 	(*ClientPacket)(nil),                                 // 1: protobuf.ClientPacket
 	(*DrawingData)(nil),                                  // 2: protobuf.DrawingData
-	(*ServerPacket_YourTurnToDraw)(nil),                  // 3: protobuf.ServerPacket.YourTurnToDraw
-	(*ServerPacket_InitialRoomSnapshot)(nil),             // 4: protobuf.ServerPacket.InitialRoomSnapshot
-	(*ServerPacket_PlayerJoined)(nil),                    // 5: protobuf.ServerPacket.PlayerJoined
-	(*ServerPacket_PlayerLeft)(nil),                      // 6: protobuf.ServerPacket.PlayerLeft
-	(*ServerPacket_GameStarted)(nil),                     // 7: protobuf.ServerPacket.GameStarted
-	(*ServerPacket_RoundUpdate)(nil),                     // 8: protobuf.ServerPacket.RoundUpdate
-	(*ServerPacket_PlayerIsChoosingWord)(nil),            // 9: protobuf.ServerPacket.PlayerIsChoosingWord
-	(*ServerPacket_PlayerIsDrawing)(nil),                 // 10: protobuf.ServerPacket.PlayerIsDrawing
-	(*ServerPacket_TurnSummary)(nil),                     // 11: protobuf.ServerPacket.TurnSummary
-	(*ServerPacket_PlayerGuessedTheWord)(nil),            // 12: protobuf.ServerPacket.PlayerGuessedTheWord
-	(*ServerPacket_LeaderBoard)(nil),                     // 13: protobuf.ServerPacket.LeaderBoard
-	(*ServerPacket_PlayerMessage)(nil),                   // 14: protobuf.ServerPacket.PlayerMessage
-	(*ServerPacket_PleaseChooseAWord)(nil),               // 15: protobuf.ServerPacket.PleaseChooseAWord
-	(*ServerPacket_InitialRoomSnapshot_PlayerState)(nil), // 16: protobuf.ServerPacket.InitialRoomSnapshot.PlayerState
-	(*ServerPacket_TurnSummary_ScoreDeltas)(nil),         // 17: protobuf.ServerPacket.TurnSummary.ScoreDeltas
-	(*ClientPacket_StartGame)(nil),                       // 18: protobuf.ClientPacket.StartGame
-	(*ClientPacket_WordChoice)(nil),                      // 19: protobuf.ClientPacket.WordChoice
-	(*ClientPacket_PlayerMessage)(nil),                   // 20: protobuf.ClientPacket.PlayerMessage
+	(*DrawingOp)(nil),                                    // 3: protobuf.DrawingOp
+	(*ServerPacket_TimeSync)(nil),                        // 4: protobuf.ServerPacket.TimeSync
+	(*ServerPacket_Welcome)(nil),                         // 5: protobuf.ServerPacket.Welcome
+	(*ServerPacket_Error)(nil),                           // 6: protobuf.ServerPacket.Error
+	(*ServerPacket_DrawingLimitReached)(nil),             // 7: protobuf.ServerPacket.DrawingLimitReached
+	(*ServerPacket_YourTurnToDraw)(nil),                  // 8: protobuf.ServerPacket.YourTurnToDraw
+	(*ServerPacket_InitialRoomSnapshot)(nil),             // 9: protobuf.ServerPacket.InitialRoomSnapshot
+	(*ServerPacket_PlayerJoined)(nil),                    // 10: protobuf.ServerPacket.PlayerJoined
+	(*ServerPacket_PlayerLeft)(nil),                      // 11: protobuf.ServerPacket.PlayerLeft
+	(*ServerPacket_GameStarted)(nil),                     // 12: protobuf.ServerPacket.GameStarted
+	(*ServerPacket_RoundUpdate)(nil),                     // 13: protobuf.ServerPacket.RoundUpdate
+	(*ServerPacket_PlayerIsChoosingWord)(nil),            // 14: protobuf.ServerPacket.PlayerIsChoosingWord
+	(*ServerPacket_PlayerIsDrawing)(nil),                 // 15: protobuf.ServerPacket.PlayerIsDrawing
+	(*ServerPacket_TurnSummary)(nil),                     // 16: protobuf.ServerPacket.TurnSummary
+	(*ServerPacket_PlayerGuessedTheWord)(nil),            // 17: protobuf.ServerPacket.PlayerGuessedTheWord
+	(*ServerPacket_LeaderBoard)(nil),                     // 18: protobuf.ServerPacket.LeaderBoard
+	(*ServerPacket_PlayerMessage)(nil),                   // 19: protobuf.ServerPacket.PlayerMessage
+	(*ServerPacket_PleaseChooseAWord)(nil),               // 20: protobuf.ServerPacket.PleaseChooseAWord
+	(*ServerPacket_InitialRoomSnapshot_PlayerState)(nil), // 21: protobuf.ServerPacket.InitialRoomSnapshot.PlayerState
+	(*ServerPacket_TurnSummary_ScoreDeltas)(nil),         // 22: protobuf.ServerPacket.TurnSummary.ScoreDeltas
+	(*ClientPacket_TimeSync)(nil),                        // 23: protobuf.ClientPacket.TimeSync
+	(*ClientPacket_Resume)(nil),                          // 24: protobuf.ClientPacket.Resume
+	(*ClientPacket_Hello)(nil),                           // 25: protobuf.ClientPacket.Hello
+	(*ClientPacket_StartGame)(nil),                       // 26: protobuf.ClientPacket.StartGame
+	(*ClientPacket_Undo)(nil),                            // 27: protobuf.ClientPacket.Undo
+	(*ClientPacket_Redo)(nil),                            // 28: protobuf.ClientPacket.Redo
+	(*ClientPacket_ClearCanvas)(nil),                     // 29: protobuf.ClientPacket.ClearCanvas
+	(*ClientPacket_WordChoice)(nil),                      // 30: protobuf.ClientPacket.WordChoice
+	(*ClientPacket_PlayerMessage)(nil),                   // 31: protobuf.ClientPacket.PlayerMessage
+	(*DrawingOp_Point)(nil),                              // 32: protobuf.DrawingOp.Point
+	(*DrawingOp_StrokeBegin)(nil),                        // 33: protobuf.DrawingOp.StrokeBegin
+	(*DrawingOp_StrokePoints)(nil),                       // 34: protobuf.DrawingOp.StrokePoints
+	(*DrawingOp_StrokeEnd)(nil),                          // 35: protobuf.DrawingOp.StrokeEnd
+	(*DrawingOp_Fill)(nil),                               // 36: protobuf.DrawingOp.Fill
+	(*DrawingOp_Clear)(nil),                              // 37: protobuf.DrawingOp.Clear
+	(*DrawingOp_Undo)(nil),                               // 38: protobuf.DrawingOp.Undo
+	(*DrawingOp_Redo)(nil),                               // 39: protobuf.DrawingOp.Redo
+	(*DrawingOp_SetColor)(nil),                           // 40: protobuf.DrawingOp.SetColor
+	(*DrawingOp_SetBrushSize)(nil),                       // 41: protobuf.DrawingOp.SetBrushSize
 }
 var file_domain_protobuf_protocol_proto_depIdxs = []int32{
 	2,  // 0: protobuf.ServerPacket.drawing_data:type_name -> protobuf.DrawingData
-	5,  // 1: protobuf.ServerPacket.player_joined:type_name -> protobuf.ServerPacket.PlayerJoined
-	7,  // 2: protobuf.ServerPacket.game_started:type_name -> protobuf.ServerPacket.GameStarted
-	8,  // 3: protobuf.ServerPacket.round_update:type_name -> protobuf.ServerPacket.RoundUpdate
-	9,  // 4: protobuf.ServerPacket.player_is_choosing_word:type_name -> protobuf.ServerPacket.PlayerIsChoosingWord
-	10, // 5: protobuf.ServerPacket.player_is_drawing:type_name -> protobuf.ServerPacket.PlayerIsDrawing
-	11, // 6: protobuf.ServerPacket.turn_summary:type_name -> protobuf.ServerPacket.TurnSummary
-	12, // 7: protobuf.ServerPacket.player_guessed_the_word:type_name -> protobuf.ServerPacket.PlayerGuessedTheWord
-	13, // 8: protobuf.ServerPacket.leaderboard:type_name -> protobuf.ServerPacket.LeaderBoard
-	14, // 9: protobuf.ServerPacket.player_message:type_name -> protobuf.ServerPacket.PlayerMessage
-	15, // 10: protobuf.ServerPacket.please_choose_a_word:type_name -> protobuf.ServerPacket.PleaseChooseAWord
-	4,  // 11: protobuf.ServerPacket.initial_room_snapshot:type_name -> protobuf.ServerPacket.InitialRoomSnapshot
-	3,  // 12: protobuf.ServerPacket.your_turn_to_draw:type_name -> protobuf.ServerPacket.YourTurnToDraw
-	6,  // 13: protobuf.ServerPacket.player_left:type_name -> protobuf.ServerPacket.PlayerLeft
-	2,  // 14: protobuf.ClientPacket.drawing_data:type_name -> protobuf.DrawingData
-	20, // 15: protobuf.ClientPacket.player_message:type_name -> protobuf.ClientPacket.PlayerMessage
-	19, // 16: protobuf.ClientPacket.word_choice:type_name -> protobuf.ClientPacket.WordChoice
-	18, // 17: protobuf.ClientPacket.start_game:type_name -> protobuf.ClientPacket.StartGame
-	16, // 18: protobuf.ServerPacket.InitialRoomSnapshot.players_states:type_name -> protobuf.ServerPacket.InitialRoomSnapshot.PlayerState
-	17, // 19: protobuf.ServerPacket.TurnSummary.deltas:type_name -> protobuf.ServerPacket.TurnSummary.ScoreDeltas
-	20, // [20:20] is the sub-list for method output_type
-	20, // [20:20] is the sub-list for method input_type
-	20, // [20:20] is the sub-list for extension type_name
-	20, // [20:20] is the sub-list for extension extendee
-	0,  // [0:20] is the sub-list for field type_name
+	10, // 1: protobuf.ServerPacket.player_joined:type_name -> protobuf.ServerPacket.PlayerJoined
+	12, // 2: protobuf.ServerPacket.game_started:type_name -> protobuf.ServerPacket.GameStarted
+	13, // 3: protobuf.ServerPacket.round_update:type_name -> protobuf.ServerPacket.RoundUpdate
+	14, // 4: protobuf.ServerPacket.player_is_choosing_word:type_name -> protobuf.ServerPacket.PlayerIsChoosingWord
+	15, // 5: protobuf.ServerPacket.player_is_drawing:type_name -> protobuf.ServerPacket.PlayerIsDrawing
+	16, // 6: protobuf.ServerPacket.turn_summary:type_name -> protobuf.ServerPacket.TurnSummary
+	17, // 7: protobuf.ServerPacket.player_guessed_the_word:type_name -> protobuf.ServerPacket.PlayerGuessedTheWord
+	18, // 8: protobuf.ServerPacket.leaderboard:type_name -> protobuf.ServerPacket.LeaderBoard
+	19, // 9: protobuf.ServerPacket.player_message:type_name -> protobuf.ServerPacket.PlayerMessage
+	20, // 10: protobuf.ServerPacket.please_choose_a_word:type_name -> protobuf.ServerPacket.PleaseChooseAWord
+	9,  // 11: protobuf.ServerPacket.initial_room_snapshot:type_name -> protobuf.ServerPacket.InitialRoomSnapshot
+	8,  // 12: protobuf.ServerPacket.your_turn_to_draw:type_name -> protobuf.ServerPacket.YourTurnToDraw
+	11, // 13: protobuf.ServerPacket.player_left:type_name -> protobuf.ServerPacket.PlayerLeft
+	7,  // 14: protobuf.ServerPacket.drawing_limit_reached:type_name -> protobuf.ServerPacket.DrawingLimitReached
+	5,  // 15: protobuf.ServerPacket.welcome:type_name -> protobuf.ServerPacket.Welcome
+	6,  // 16: protobuf.ServerPacket.error:type_name -> protobuf.ServerPacket.Error
+	4,  // 17: protobuf.ServerPacket.time_sync:type_name -> protobuf.ServerPacket.TimeSync
+	0,  // 18: protobuf.ServerPacket.batch:type_name -> protobuf.ServerPacket
+	2,  // 19: protobuf.ClientPacket.drawing_data:type_name -> protobuf.DrawingData
+	31, // 20: protobuf.ClientPacket.player_message:type_name -> protobuf.ClientPacket.PlayerMessage
+	30, // 21: protobuf.ClientPacket.word_choice:type_name -> protobuf.ClientPacket.WordChoice
+	26, // 22: protobuf.ClientPacket.start_game:type_name -> protobuf.ClientPacket.StartGame
+	27, // 23: protobuf.ClientPacket.undo:type_name -> protobuf.ClientPacket.Undo
+	28, // 24: protobuf.ClientPacket.redo:type_name -> protobuf.ClientPacket.Redo
+	29, // 25: protobuf.ClientPacket.clear_canvas:type_name -> protobuf.ClientPacket.ClearCanvas
+	25, // 26: protobuf.ClientPacket.hello:type_name -> protobuf.ClientPacket.Hello
+	24, // 27: protobuf.ClientPacket.resume:type_name -> protobuf.ClientPacket.Resume
+	23, // 28: protobuf.ClientPacket.time_sync:type_name -> protobuf.ClientPacket.TimeSync
+	3,  // 29: protobuf.DrawingData.ops:type_name -> protobuf.DrawingOp
+	33, // 30: protobuf.DrawingOp.stroke_begin:type_name -> protobuf.DrawingOp.StrokeBegin
+	34, // 31: protobuf.DrawingOp.stroke_points:type_name -> protobuf.DrawingOp.StrokePoints
+	35, // 32: protobuf.DrawingOp.stroke_end:type_name -> protobuf.DrawingOp.StrokeEnd
+	36, // 33: protobuf.DrawingOp.fill:type_name -> protobuf.DrawingOp.Fill
+	37, // 34: protobuf.DrawingOp.clear:type_name -> protobuf.DrawingOp.Clear
+	38, // 35: protobuf.DrawingOp.undo:type_name -> protobuf.DrawingOp.Undo
+	39, // 36: protobuf.DrawingOp.redo:type_name -> protobuf.DrawingOp.Redo
+	40, // 37: protobuf.DrawingOp.set_color:type_name -> protobuf.DrawingOp.SetColor
+	41, // 38: protobuf.DrawingOp.set_brush_size:type_name -> protobuf.DrawingOp.SetBrushSize
+	21, // 39: protobuf.ServerPacket.InitialRoomSnapshot.players_states:type_name -> protobuf.ServerPacket.InitialRoomSnapshot.PlayerState
+	22, // 40: protobuf.ServerPacket.TurnSummary.deltas:type_name -> protobuf.ServerPacket.TurnSummary.ScoreDeltas
+	32, // 41: protobuf.DrawingOp.StrokeBegin.at:type_name -> protobuf.DrawingOp.Point
+	32, // 42: protobuf.DrawingOp.StrokePoints.points:type_name -> protobuf.DrawingOp.Point
+	32, // 43: protobuf.DrawingOp.Fill.at:type_name -> protobuf.DrawingOp.Point
+	44, // [44:44] is the sub-list for method output_type
+	44, // [44:44] is the sub-list for method input_type
+	44, // [44:44] is the sub-list for extension type_name
+	44, // [44:44] is the sub-list for extension extendee
+	0,  // [0:44] is the sub-list for field type_name
 }
 
 func init() { file_domain_protobuf_protocol_proto_init() }
@@ -1503,12 +2941,33 @@ func file_domain_protobuf_protocol_proto_init() {
 		(*ServerPacket_InitialRoomSnapshot_)(nil),
 		(*ServerPacket_YourTurnToDraw_)(nil),
 		(*ServerPacket_PlayerLeft_)(nil),
+		(*ServerPacket_DrawingLimitReached_)(nil),
+		(*ServerPacket_Welcome_)(nil),
+		(*ServerPacket_Error_)(nil),
+		(*ServerPacket_TimeSync_)(nil),
 	}
 	file_domain_protobuf_protocol_proto_msgTypes[1].OneofWrappers = []any{
 		(*ClientPacket_DrawingData)(nil),
 		(*ClientPacket_PlayerMessage_)(nil),
 		(*ClientPacket_WordChoice_)(nil),
 		(*ClientPacket_StartGame_)(nil),
+		(*ClientPacket_Undo_)(nil),
+		(*ClientPacket_Redo_)(nil),
+		(*ClientPacket_ClearCanvas_)(nil),
+		(*ClientPacket_Hello_)(nil),
+		(*ClientPacket_Resume_)(nil),
+		(*ClientPacket_TimeSync_)(nil),
+	}
+	file_domain_protobuf_protocol_proto_msgTypes[3].OneofWrappers = []any{
+		(*DrawingOp_StrokeBegin_)(nil),
+		(*DrawingOp_StrokePoints_)(nil),
+		(*DrawingOp_StrokeEnd_)(nil),
+		(*DrawingOp_Fill_)(nil),
+		(*DrawingOp_Clear_)(nil),
+		(*DrawingOp_Undo_)(nil),
+		(*DrawingOp_Redo_)(nil),
+		(*DrawingOp_SetColor_)(nil),
+		(*DrawingOp_SetBrushSize_)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1516,7 +2975,7 @@ func file_domain_protobuf_protocol_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_domain_protobuf_protocol_proto_rawDesc), len(file_domain_protobuf_protocol_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   21,
+			NumMessages:   42,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
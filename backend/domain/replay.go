@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// Replay is the packet stream of a room as it was sent. The names in it are
+// the ones the players had then, renames and deleted accounts don't rewrite
+// it.
+type Replay struct {
+	Id             string
+	RoomId         string
+	Private        bool
+	ParticipantIds []string // everyone who played in the room, only they may watch
+	StartedAt      time.Time
+	Size           int
+	Data           []byte // only filled by GetReplay
+}
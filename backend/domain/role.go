@@ -0,0 +1,47 @@
+package domain
+
+import "slices"
+
+// Role is what a user may do beyond playing, every account starts a player.
+type Role string
+
+const (
+	RolePlayer    Role = "player"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission gates an operation, routes ask for permissions and never for
+// roles so a role can be given more later.
+type Permission string
+
+const (
+	// PermModerateDrawings removes anyone's drawings from the gallery.
+	PermModerateDrawings Permission = "drawings:moderate"
+	// PermManageWords edits the words players draw.
+	PermManageWords Permission = "words:manage"
+	// PermManageRoles gives and takes roles.
+	PermManageRoles Permission = "roles:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RolePlayer:    {},
+	RoleModerator: {PermModerateDrawings},
+	RoleAdmin:     {PermModerateDrawings, PermManageWords, PermManageRoles},
+}
+
+// Valid reports whether r is one of the roles above.
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role has every permission.
+func (r Role) Can(permissions ...Permission) bool {
+	for _, permission := range permissions {
+		if !slices.Contains(rolePermissions[r], permission) {
+			return false
+		}
+	}
+	return true
+}
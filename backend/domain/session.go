@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// RefreshToken is a stored refresh token. Only its hash is kept, the token
+// itself is known to the client alone.
+type RefreshToken struct {
+	Hash      []byte
+	FamilyId  string
+	UserId    string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,16 @@
+package domain
+
+// TOTP is the second factor of a user, pending until a first code confirms
+// it.
+type TOTP struct {
+	Secret  []byte
+	Enabled bool
+	// the time step of the last code used, a code can't be used twice
+	LastStep int64
+}
+
+// RecoveryCode stands in for a TOTP code once, only its hash is kept.
+type RecoveryCode struct {
+	Id   string
+	Hash string
+}
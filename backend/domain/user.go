@@ -1,7 +1,30 @@
 package domain
 
+import "strings"
+
 type User struct {
 	Id           string
 	Username     string
 	PasswordHash string
+	// Guest users have no row, their id carries their nickname.
+	Guest bool
+	// TOTPEnabled users log in with a code after their password.
+	TOTPEnabled bool
+}
+
+// GuestIdPrefix starts the ids of guests, account ids are UUIDs.
+const GuestIdPrefix = "guest:"
+
+func GuestId(nickname string) string {
+	return GuestIdPrefix + nickname
+}
+
+// GuestNickname returns the nickname a guest id carries, false when the id is
+// an account's.
+func GuestNickname(id string) (string, bool) {
+	return strings.CutPrefix(id, GuestIdPrefix)
+}
+
+func IsGuestId(id string) bool {
+	return strings.HasPrefix(id, GuestIdPrefix)
 }
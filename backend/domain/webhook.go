@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+type Webhook struct {
+	Id        string
+	OwnerId   string
+	URL       string
+	Secret    string
+	CreatedAt time.Time
+}
+
+type WebhookDeadLetter struct {
+	WebhookId string // empty for deployment-wide webhooks
+	URL       string
+	EventType GameEventType
+	Payload   []byte
+	Attempts  int
+	LastError string
+}
@@ -0,0 +1,35 @@
+/*
+Package drawing decodes the drawing stream of a turn and renders it to PNG or
+SVG.
+
+The stream is this server's own encoding of the typed DrawingOps, it is not
+the wire format of @rakaoran/dende. Chunks legacy clients send as opaque
+DrawingData are dende parts, they are relayed and archived untouched but not
+understood here: that format is only defined by the dende library, so the
+room marks the drawings holding them and the gallery never hands them to
+Parse.
+
+The stream is the concatenation of every chunk of a turn, in the order the
+drawer sent them. An op never needs to fit in a single chunk. The
+canvas is Width x Height pixels with a white background, the origin is the
+top left corner and all integers are little endian.
+
+Each op starts with an opcode byte:
+
+	0x01 BEGIN   r g b a (u8 each) | width (u8) | x y (u16 each)
+	             starts a stroke at (x, y)
+	0x02 POINTS  count (u8) | count * (x y (u16 each))
+	             extends the open stroke
+	0x03 END     closes the open stroke, a stroke without points is a dot
+	0x04 UNDO    hides the last visible stroke or fill
+	0x05 REDO    shows the last undone stroke or fill again
+	0x06 CLEAR   wipes the canvas, it can not be undone and empties the redo stack
+	0x07 FILL    r g b a (u8 each) | x y (u16 each)
+	             flood fills the area around (x, y) as it looks at that point
+
+Opening a stroke drops the redo stack, like fills do. POINTS and END are
+only valid while a stroke is open, every other op is only valid while none
+is. A stream may end with an open stroke, it is drawn as is. Coordinates
+outside the canvas are clipped.
+*/
+package drawing
@@ -0,0 +1,92 @@
+package drawing
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+func (s *Scene) WritePNG(w io.Writer) error {
+	img, err := s.Rasterize()
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// WriteSVG writes strokes as polylines and fills as the pixel runs they
+// covered when they were applied.
+func (s *Scene) WriteSVG(w io.Writer) error {
+	fills := map[int][]run{}
+	if _, err := s.render(func(i int, runs []run) { fills[i] = runs }); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", Width, Height, Width, Height)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="%s"/>`+"\n", Width, Height, hex(background))
+
+	for i, a := range s.Actions() {
+		switch {
+		case a.Fill:
+			runs := fills[i]
+			if len(runs) == 0 {
+				continue
+			}
+			fmt.Fprintf(bw, `<path fill="%s"%s shape-rendering="crispEdges" d="`, hex(a.Color), opacity("fill", a.Color))
+			for _, r := range mergeRuns(runs) {
+				fmt.Fprintf(bw, "M%d %dh%dv%dh-%dz", r.x, r.y, r.w, r.h, r.w)
+			}
+			bw.WriteString("\"/>\n")
+		case len(a.Points) == 1:
+			p := a.Points[0]
+			fmt.Fprintf(bw, `<circle cx="%d" cy="%d" r="%g" fill="%s"%s/>`+"\n", p.X, p.Y, float64(a.Width)/2, hex(a.Color), opacity("fill", a.Color))
+		default:
+			bw.WriteString(`<polyline points="`)
+			for j, p := range a.Points {
+				if j > 0 {
+					bw.WriteByte(' ')
+				}
+				fmt.Fprintf(bw, "%d,%d", p.X, p.Y)
+			}
+			fmt.Fprintf(bw, `" fill="none" stroke="%s"%s stroke-width="%d" stroke-linecap="round" stroke-linejoin="round"/>`+"\n", hex(a.Color), opacity("stroke", a.Color), a.Width)
+		}
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+type rect struct {
+	x, y, w, h int
+}
+
+// mergeRuns joins runs spanning the same columns on consecutive rows into
+// rectangles, keeping the path of a plain fill small.
+func mergeRuns(runs []run) []rect {
+	rects := []rect{}
+	open := map[[2]int]int{} // columns -> index in rects
+	for _, r := range runs {
+		key := [2]int{r.x0, r.x1}
+		if i, ok := open[key]; ok && rects[i].y+rects[i].h == r.y {
+			rects[i].h++
+			continue
+		}
+		open[key] = len(rects)
+		rects = append(rects, rect{x: r.x0, y: r.y, w: r.x1 - r.x0, h: 1})
+	}
+	return rects
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func opacity(attr string, c color.RGBA) string {
+	if c.A == 255 {
+		return ""
+	}
+	return fmt.Sprintf(` %s-opacity="%.3g"`, attr, float64(c.A)/255)
+}
@@ -0,0 +1,8 @@
+package drawing
+
+import "errors"
+
+var (
+	ErrMalformedStrokes  = errors.New("malformed-strokes")
+	ErrDrawingTooComplex = errors.New("drawing-too-complex")
+)
@@ -0,0 +1,105 @@
+package drawing
+
+import (
+	"encoding/binary"
+	"image/color"
+)
+
+const (
+	Width  = 800
+	Height = 600
+)
+
+const (
+	OpBegin  byte = 0x01
+	OpPoints byte = 0x02
+	OpEnd    byte = 0x03
+	OpUndo   byte = 0x04
+	OpRedo   byte = 0x05
+	OpClear  byte = 0x06
+	OpFill   byte = 0x07
+)
+
+type Point struct {
+	X, Y uint16
+}
+
+// The Append functions encode a single op, they are the reference encoder
+// used by tests and by the server when it emits strokes itself.
+
+func AppendBegin(buf []byte, c color.RGBA, width uint8, p Point) []byte {
+	buf = append(buf, OpBegin, c.R, c.G, c.B, c.A, width)
+	return appendPoint(buf, p)
+}
+
+// AppendPoints splits points into as many POINTS ops as needed.
+func AppendPoints(buf []byte, points ...Point) []byte {
+	for len(points) > 0 {
+		n := min(len(points), 255)
+		buf = append(buf, OpPoints, byte(n))
+		for _, p := range points[:n] {
+			buf = appendPoint(buf, p)
+		}
+		points = points[n:]
+	}
+	return buf
+}
+
+func AppendEnd(buf []byte) []byte {
+	return append(buf, OpEnd)
+}
+
+func AppendUndo(buf []byte) []byte {
+	return append(buf, OpUndo)
+}
+
+func AppendRedo(buf []byte) []byte {
+	return append(buf, OpRedo)
+}
+
+func AppendClear(buf []byte) []byte {
+	return append(buf, OpClear)
+}
+
+func AppendFill(buf []byte, c color.RGBA, p Point) []byte {
+	buf = append(buf, OpFill, c.R, c.G, c.B, c.A)
+	return appendPoint(buf, p)
+}
+
+func appendPoint(buf []byte, p Point) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, p.X)
+	return binary.LittleEndian.AppendUint16(buf, p.Y)
+}
+
+// reader walks an op stream, every method reports false when the stream is
+// truncated.
+type reader struct {
+	buf []byte
+}
+
+func (r *reader) byte() (byte, bool) {
+	if len(r.buf) < 1 {
+		return 0, false
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b, true
+}
+
+func (r *reader) color() (color.RGBA, bool) {
+	if len(r.buf) < 4 {
+		return color.RGBA{}, false
+	}
+	c := color.RGBA{R: r.buf[0], G: r.buf[1], B: r.buf[2], A: r.buf[3]}
+	r.buf = r.buf[4:]
+	return c, true
+}
+
+func (r *reader) point() (Point, bool) {
+	if len(r.buf) < 4 {
+		return Point{}, false
+	}
+	p := Point{X: binary.LittleEndian.Uint16(r.buf), Y: binary.LittleEndian.Uint16(r.buf[2:])}
+	r.buf = r.buf[4:]
+	return p, true
+}
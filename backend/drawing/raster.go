@@ -0,0 +1,220 @@
+package drawing
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+)
+
+// maxWork bounds how many pixels a render may touch so a crafted stream
+// can't pin a CPU, roughly a second of work.
+const maxWork = 1 << 28
+
+// fills spread over pixels whose channels are all within this distance of
+// the seed, so anti-aliased edges don't leave a halo
+const fillTolerance = 48
+
+var background = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+// run is a horizontal span of filled pixels, x1 is exclusive.
+type run struct {
+	y, x0, x1 int
+}
+
+// Rasterize renders the scene on a white canvas.
+func (s *Scene) Rasterize() (*image.RGBA, error) {
+	return s.render(nil)
+}
+
+// render draws every visible action and reports the area of each fill to
+// onFill, the SVG writer needs it since fills depend on what is below them.
+func (s *Scene) render(onFill func(i int, runs []run)) (*image.RGBA, error) {
+	if s.work() > maxWork {
+		return nil, ErrDrawingTooComplex
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+	var visited []bool
+
+	for i, a := range s.Actions() {
+		if !a.Fill {
+			drawStroke(img, a)
+			continue
+		}
+		if visited == nil {
+			visited = make([]bool, Width*Height)
+		} else {
+			clear(visited)
+		}
+		runs := floodFill(img, visited, a)
+		if onFill != nil {
+			onFill(i, runs)
+		}
+	}
+	return img, nil
+}
+
+func (s *Scene) work() int {
+	total := 0
+	for _, a := range s.Actions() {
+		if a.Fill {
+			total += Width * Height
+			continue
+		}
+		side := int(a.Width) + 2
+		total += side * side
+		for i := 1; i < len(a.Points); i++ {
+			length := int(math.Hypot(float64(a.Points[i].X)-float64(a.Points[i-1].X), float64(a.Points[i].Y)-float64(a.Points[i-1].Y)))
+			total += (length + side) * side
+		}
+	}
+	return total
+}
+
+// drawStroke computes the stroke coverage first and blends it once, so
+// translucent strokes don't darken where segments overlap.
+func drawStroke(img *image.RGBA, a Action) {
+	radius := float64(a.Width) / 2
+	pad := int(math.Ceil(radius)) + 1
+
+	bounds := image.Rectangle{}
+	for _, p := range a.Points {
+		bounds = bounds.Union(image.Rect(int(p.X)-pad, int(p.Y)-pad, int(p.X)+pad+1, int(p.Y)+pad+1))
+	}
+	bounds = bounds.Intersect(img.Rect)
+	if bounds.Empty() {
+		return
+	}
+	coverage := make([]float64, bounds.Dx()*bounds.Dy())
+
+	segment := func(p0, p1 Point) {
+		x0, y0, x1, y1 := float64(p0.X), float64(p0.Y), float64(p1.X), float64(p1.Y)
+		box := image.Rect(min(int(p0.X), int(p1.X))-pad, min(int(p0.Y), int(p1.Y))-pad, max(int(p0.X), int(p1.X))+pad+1, max(int(p0.Y), int(p1.Y))+pad+1).Intersect(bounds)
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				d := distanceToSegment(float64(x)+0.5, float64(y)+0.5, x0, y0, x1, y1)
+				c := min(max(radius+0.5-d, 0), 1)
+				i := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+				coverage[i] = max(coverage[i], c)
+			}
+		}
+	}
+
+	if len(a.Points) == 1 {
+		segment(a.Points[0], a.Points[0])
+	}
+	for i := 1; i < len(a.Points); i++ {
+		segment(a.Points[i-1], a.Points[i])
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := coverage[(y-bounds.Min.Y)*bounds.Dx()+x-bounds.Min.X]
+			if c > 0 {
+				blend(img, x, y, a.Color, c)
+			}
+		}
+	}
+}
+
+func distanceToSegment(px, py, x0, y0, x1, y1 float64) float64 {
+	dx, dy := x1-x0, y1-y0
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = min(max(((px-x0)*dx+(py-y0)*dy)/lengthSq, 0), 1)
+	}
+	return math.Hypot(px-(x0+t*dx), py-(y0+t*dy))
+}
+
+// floodFill paints the 4-connected area around the seed and returns it as
+// runs, ordered by row.
+func floodFill(img *image.RGBA, visited []bool, a Action) []run {
+	seed := a.Points[0]
+	if int(seed.X) >= Width || int(seed.Y) >= Height {
+		return nil
+	}
+	target := img.RGBAAt(int(seed.X), int(seed.Y))
+	matches := func(x, y int) bool {
+		if visited[y*Width+x] {
+			return false
+		}
+		c := img.RGBAAt(x, y)
+		return absDiff(c.R, target.R) <= fillTolerance && absDiff(c.G, target.G) <= fillTolerance && absDiff(c.B, target.B) <= fillTolerance
+	}
+
+	runs := []run{}
+	stack := []image.Point{{X: int(seed.X), Y: int(seed.Y)}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !matches(p.X, p.Y) {
+			continue
+		}
+		x0, x1 := p.X, p.X+1
+		for x0 > 0 && matches(x0-1, p.Y) {
+			x0--
+		}
+		for x1 < Width && matches(x1, p.Y) {
+			x1++
+		}
+		for x := x0; x < x1; x++ {
+			visited[p.Y*Width+x] = true
+		}
+		runs = append(runs, run{y: p.Y, x0: x0, x1: x1})
+
+		for _, y := range []int{p.Y - 1, p.Y + 1} {
+			if y < 0 || y >= Height {
+				continue
+			}
+			inSpan := false
+			for x := x0; x < x1; x++ {
+				m := matches(x, y)
+				if m && !inSpan {
+					stack = append(stack, image.Point{X: x, Y: y})
+				}
+				inSpan = m
+			}
+		}
+	}
+
+	// blend after the whole area is known, blending while filling would
+	// change what matches
+	for _, r := range runs {
+		for x := r.x0; x < r.x1; x++ {
+			blend(img, x, r.y, a.Color, 1)
+		}
+	}
+	sortRuns(runs)
+	return runs
+}
+
+func sortRuns(runs []run) {
+	slices.SortFunc(runs, func(a, b run) int {
+		if a.y != b.y {
+			return a.y - b.y
+		}
+		return a.x0 - b.x0
+	})
+}
+
+// blend draws c over the opaque pixel at (x, y) with the given coverage.
+func blend(img *image.RGBA, x, y int, c color.RGBA, coverage float64) {
+	alpha := coverage * float64(c.A) / 255
+	i := img.PixOffset(x, y)
+	mix := func(dst, src uint8) uint8 {
+		return uint8(math.Round(float64(src)*alpha + float64(dst)*(1-alpha)))
+	}
+	img.Pix[i] = mix(img.Pix[i], c.R)
+	img.Pix[i+1] = mix(img.Pix[i+1], c.G)
+	img.Pix[i+2] = mix(img.Pix[i+2], c.B)
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,111 @@
+package drawing
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// splitChunks cuts a stream at arbitrary offsets, like the drawer's client
+// does when it flushes mid stroke.
+func splitChunks(stream []byte, size int) [][]byte {
+	chunks := [][]byte{}
+	for len(stream) > size {
+		chunks = append(chunks, stream[:size])
+		stream = stream[size:]
+	}
+	return append(chunks, stream)
+}
+
+// TestRender_Golden renders the streams in testdata. They were written op by
+// op against the format in doc.go, no dende client produced them.
+func TestRender_Golden(t *testing.T) {
+	streams, err := filepath.Glob("testdata/*.strokes")
+	require.NoError(t, err)
+	require.NotEmpty(t, streams)
+
+	for _, path := range streams {
+		name := strings.TrimSuffix(filepath.Base(path), ".strokes")
+		t.Run(name, func(t *testing.T) {
+			stream, err := os.ReadFile(path)
+			require.NoError(t, err)
+			scene, err := Parse(splitChunks(stream, 7))
+			require.NoError(t, err)
+
+			var svg bytes.Buffer
+			require.NoError(t, scene.WriteSVG(&svg))
+			img, err := scene.Rasterize()
+			require.NoError(t, err)
+
+			svgPath := filepath.Join("testdata", name+".svg")
+			pngPath := filepath.Join("testdata", name+".png")
+			if *update {
+				require.NoError(t, os.WriteFile(svgPath, svg.Bytes(), 0o644))
+				var out bytes.Buffer
+				require.NoError(t, scene.WritePNG(&out))
+				require.NoError(t, os.WriteFile(pngPath, out.Bytes(), 0o644))
+				return
+			}
+
+			golden, err := os.ReadFile(svgPath)
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), svg.String())
+
+			// compare pixels, the png compressor may change between Go versions
+			f, err := os.Open(pngPath)
+			require.NoError(t, err)
+			defer f.Close()
+			goldenImg, err := png.Decode(f)
+			require.NoError(t, err)
+			goldenRGBA := image.NewRGBA(goldenImg.Bounds())
+			for y := range Height {
+				for x := range Width {
+					goldenRGBA.Set(x, y, goldenImg.At(x, y))
+				}
+			}
+			assert.True(t, bytes.Equal(goldenRGBA.Pix, img.Pix), "rendered png differs from %s", pngPath)
+		})
+	}
+}
+
+func TestRender_Rejects_Too_Complex_Drawings(t *testing.T) {
+	t.Parallel()
+	var stream []byte
+	for range 1000 {
+		stream = AppendFill(stream, background, Point{X: 1, Y: 1})
+	}
+	scene, err := Parse([][]byte{stream})
+	require.NoError(t, err)
+
+	_, err = scene.Rasterize()
+	assert.ErrorIs(t, err, ErrDrawingTooComplex)
+	assert.ErrorIs(t, scene.WriteSVG(&bytes.Buffer{}), ErrDrawingTooComplex)
+}
+
+func TestFloodFill_Stays_Inside_Outline(t *testing.T) {
+	t.Parallel()
+	var stream []byte
+	stream = AppendBegin(stream, black, 4, Point{X: 10, Y: 10})
+	stream = AppendPoints(stream, Point{X: 50, Y: 10}, Point{X: 50, Y: 50}, Point{X: 10, Y: 50}, Point{X: 10, Y: 10})
+	stream = AppendEnd(stream)
+	stream = AppendFill(stream, red, Point{X: 30, Y: 30})
+	scene, err := Parse([][]byte{stream})
+	require.NoError(t, err)
+
+	img, err := scene.Rasterize()
+	require.NoError(t, err)
+	assert.Equal(t, red, img.RGBAAt(30, 30))
+	assert.Equal(t, red, img.RGBAAt(14, 14))
+	assert.Equal(t, background, img.RGBAAt(5, 5))
+	assert.Equal(t, background, img.RGBAAt(60, 30))
+}
@@ -0,0 +1,92 @@
+package drawing
+
+import (
+	"bytes"
+	"image/color"
+)
+
+// Action is a stroke, or a fill when Fill is set, in which case Points only
+// holds the seed.
+type Action struct {
+	Fill   bool
+	Color  color.RGBA
+	Width  uint8
+	Points []Point
+}
+
+// Scene is what is left on the canvas once undo, redo and clear are applied.
+type Scene struct {
+	actions []Action
+	visible int
+}
+
+// Parse decodes the DrawingData chunks of a turn.
+func Parse(chunks [][]byte) (*Scene, error) {
+	s := &Scene{}
+	r := reader{buf: bytes.Join(chunks, nil)}
+	open := false
+
+	for len(r.buf) > 0 {
+		op, _ := r.byte()
+		switch op {
+		case OpBegin:
+			c, okColor := r.color()
+			width, okWidth := r.byte()
+			p, okPoint := r.point()
+			if open || !okColor || !okWidth || !okPoint {
+				return nil, ErrMalformedStrokes
+			}
+			s.push(Action{Color: c, Width: max(width, 1), Points: []Point{p}})
+			open = true
+		case OpPoints:
+			count, ok := r.byte()
+			if !open || !ok || len(r.buf) < int(count)*4 {
+				return nil, ErrMalformedStrokes
+			}
+			stroke := &s.actions[s.visible-1]
+			for range count {
+				p, _ := r.point()
+				stroke.Points = append(stroke.Points, p)
+			}
+		case OpEnd:
+			if !open {
+				return nil, ErrMalformedStrokes
+			}
+			open = false
+		case OpUndo, OpRedo, OpClear:
+			if open {
+				return nil, ErrMalformedStrokes
+			}
+			switch op {
+			case OpUndo:
+				s.visible = max(s.visible-1, 0)
+			case OpRedo:
+				s.visible = min(s.visible+1, len(s.actions))
+			case OpClear:
+				s.actions, s.visible = nil, 0
+			}
+		case OpFill:
+			c, okColor := r.color()
+			p, okPoint := r.point()
+			if open || !okColor || !okPoint {
+				return nil, ErrMalformedStrokes
+			}
+			s.push(Action{Fill: true, Color: c, Points: []Point{p}})
+		default:
+			return nil, ErrMalformedStrokes
+		}
+	}
+
+	return s, nil
+}
+
+// push drops the redo stack and appends a visible action.
+func (s *Scene) push(a Action) {
+	s.actions = append(s.actions[:s.visible], a)
+	s.visible++
+}
+
+// Actions returns the visible actions in drawing order.
+func (s *Scene) Actions() []Action {
+	return s.actions[:s.visible]
+}
@@ -0,0 +1,95 @@
+package drawing
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	black = color.RGBA{A: 255}
+	red   = color.RGBA{R: 255, A: 255}
+)
+
+func stroke(c color.RGBA, points ...Point) []byte {
+	b := AppendBegin(nil, c, 3, points[0])
+	b = AppendPoints(b, points[1:]...)
+	return AppendEnd(b)
+}
+
+func TestParse_History(t *testing.T) {
+	t.Parallel()
+	a := stroke(black, Point{X: 1, Y: 1}, Point{X: 2, Y: 2})
+	b := stroke(red, Point{X: 3, Y: 3})
+	c := AppendFill(nil, red, Point{X: 9, Y: 9})
+
+	testCases := []struct {
+		name     string
+		stream   [][]byte
+		expected int
+	}{
+		{name: "strokes", stream: [][]byte{a, b}, expected: 2},
+		{name: "undo", stream: [][]byte{a, b, AppendUndo(nil)}, expected: 1},
+		{name: "undo past start", stream: [][]byte{a, AppendUndo(nil), AppendUndo(nil)}, expected: 0},
+		{name: "redo", stream: [][]byte{a, b, AppendUndo(nil), AppendRedo(nil)}, expected: 2},
+		{name: "redo past end", stream: [][]byte{a, AppendRedo(nil)}, expected: 1},
+		{name: "new stroke drops redo", stream: [][]byte{a, b, AppendUndo(nil), c, AppendRedo(nil)}, expected: 2},
+		{name: "clear", stream: [][]byte{a, b, AppendClear(nil), AppendUndo(nil), AppendRedo(nil)}, expected: 0},
+		{name: "unfinished stroke", stream: [][]byte{a, AppendBegin(nil, red, 1, Point{})}, expected: 2},
+		{name: "empty", stream: nil, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			scene, err := Parse(tc.stream)
+			require.NoError(t, err)
+			assert.Len(t, scene.Actions(), tc.expected)
+		})
+	}
+}
+
+func TestParse_Ops_Across_Chunks(t *testing.T) {
+	t.Parallel()
+	stream := stroke(red, Point{X: 10, Y: 20}, Point{X: 300, Y: 400}, Point{X: 65535, Y: 0})
+
+	scene, err := Parse(splitChunks(stream, 3))
+	require.NoError(t, err)
+	require.Len(t, scene.Actions(), 1)
+	assert.Equal(t, Action{Color: red, Width: 3, Points: []Point{{10, 20}, {300, 400}, {65535, 0}}}, scene.Actions()[0])
+}
+
+func TestParse_Rejects_Malformed(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		stream []byte
+	}{
+		{name: "unknown op", stream: []byte{0x42}},
+		{name: "truncated begin", stream: AppendBegin(nil, red, 1, Point{})[:5]},
+		{name: "truncated points", stream: AppendPoints(AppendBegin(nil, red, 1, Point{}), Point{}, Point{})[:17]},
+		{name: "points without stroke", stream: AppendPoints(nil, Point{})},
+		{name: "end without stroke", stream: AppendEnd(nil)},
+		{name: "begin inside stroke", stream: AppendBegin(AppendBegin(nil, red, 1, Point{}), red, 1, Point{})},
+		{name: "fill inside stroke", stream: AppendFill(AppendBegin(nil, red, 1, Point{}), red, Point{})},
+		{name: "undo inside stroke", stream: AppendUndo(AppendBegin(nil, red, 1, Point{}))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse([][]byte{tc.stream})
+			assert.ErrorIs(t, err, ErrMalformedStrokes)
+		})
+	}
+}
+
+func TestAppendPoints_Splits_Long_Strokes(t *testing.T) {
+	t.Parallel()
+	points := make([]Point, 600)
+	stream := AppendPoints(nil, points...)
+	assert.Len(t, stream, 3*2+600*4)
+	assert.Equal(t, []byte{OpPoints, 255}, stream[:2])
+}
@@ -0,0 +1,129 @@
+package gallery
+
+import (
+	"api/domain"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Config struct {
+	QueueSize          int
+	MaxDrawingBytes    int           // bigger drawings are not archived
+	MaxDrawingsPerUser int           // oldest non favourited drawings are pruned, 0 keeps everything
+	MaxAge             time.Duration // 0 keeps drawings forever
+	PruneInterval      time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		QueueSize:          256,
+		MaxDrawingBytes:    512 * 1024,
+		MaxDrawingsPerUser: 100,
+		MaxAge:             time.Hour * 24 * 90,
+		PruneInterval:      time.Hour,
+	}
+}
+
+// Archiver saves finished drawings in the background so room actors never
+// wait on the database.
+type Archiver struct {
+	config   Config
+	store    DrawingStore
+	drawings chan domain.Drawing
+	done     chan struct{}
+	now      func() time.Time
+}
+
+func NewArchiver(config Config, store DrawingStore) *Archiver {
+	return &Archiver{
+		config:   config,
+		store:    store,
+		drawings: make(chan domain.Drawing, config.QueueSize),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+}
+
+func (a *Archiver) Start() {
+	go a.run()
+}
+
+// SaveDrawing never blocks, drawings are dropped when the queue is full.
+func (a *Archiver) SaveDrawing(d domain.Drawing) {
+	size := 0
+	for _, s := range d.Strokes {
+		size += len(s)
+	}
+	if size == 0 {
+		return
+	}
+	if size > a.config.MaxDrawingBytes {
+		slog.Warn("Gallery: drawing too big, not archiving", "room_id", d.RoomId, "size", size)
+		return
+	}
+	d.Size = size
+
+	select {
+	case a.drawings <- d:
+	default:
+		slog.Warn("Gallery: queue full, dropping drawing", "room_id", d.RoomId, "drawer", d.Drawer)
+	}
+}
+
+// Close stops accepting drawings and waits until the queued ones are saved or
+// ctx expires.
+func (a *Archiver) Close(ctx context.Context) {
+	close(a.drawings)
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+	}
+}
+
+func (a *Archiver) run() {
+	defer close(a.done)
+
+	var prune <-chan time.Time
+	if a.config.MaxAge > 0 {
+		ticker := time.NewTicker(a.config.PruneInterval)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+
+	for {
+		select {
+		case d, ok := <-a.drawings:
+			if !ok {
+				return
+			}
+			a.save(d)
+		case <-prune:
+			a.pruneExpired()
+		}
+	}
+}
+
+func (a *Archiver) save(d domain.Drawing) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if _, err := a.store.SaveDrawing(ctx, d); err != nil {
+		slog.Error("Gallery: failed to save drawing", "error", err.Error(), "room_id", d.RoomId, "drawer_id", d.DrawerId)
+		return
+	}
+	if a.config.MaxDrawingsPerUser > 0 {
+		if err := a.store.PruneDrawings(ctx, d.DrawerId, a.config.MaxDrawingsPerUser); err != nil {
+			slog.Error("Gallery: failed to prune drawings", "error", err.Error(), "drawer_id", d.DrawerId)
+		}
+	}
+}
+
+func (a *Archiver) pruneExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if err := a.store.DeleteDrawingsBefore(ctx, a.now().Add(-a.config.MaxAge)); err != nil {
+		slog.Error("Gallery: failed to delete expired drawings", "error", err.Error())
+	}
+}
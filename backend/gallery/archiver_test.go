@@ -0,0 +1,114 @@
+package gallery
+
+import (
+	"api/domain"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDrawingStore struct {
+	mock.Mock
+}
+
+func (m *MockDrawingStore) SaveDrawing(ctx context.Context, d domain.Drawing) (string, error) {
+	args := m.Called(ctx, d)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockDrawingStore) PruneDrawings(ctx context.Context, drawerId string, keep int) error {
+	args := m.Called(ctx, drawerId, keep)
+	return args.Error(0)
+}
+
+func (m *MockDrawingStore) DeleteDrawingsBefore(ctx context.Context, cutoff time.Time) error {
+	args := m.Called(ctx, cutoff)
+	return args.Error(0)
+}
+
+func testDrawing(strokes ...[]byte) domain.Drawing {
+	return domain.Drawing{DrawerId: "drawer-id", Drawer: "naruto", RoomId: "ROOM1", Word: "apple", Strokes: strokes}
+}
+
+func TestArchiver_Saves_And_Prunes(t *testing.T) {
+	t.Parallel()
+	store := &MockDrawingStore{}
+	store.On("SaveDrawing", mock.Anything, mock.MatchedBy(func(d domain.Drawing) bool {
+		return d.Word == "apple" && d.Size == 5
+	})).Return("drawing-1", nil).Once()
+	store.On("PruneDrawings", mock.Anything, "drawer-id", 100).Return(nil).Once()
+
+	a := NewArchiver(DefaultConfig(), store)
+	a.Start()
+	a.SaveDrawing(testDrawing([]byte{1, 2}, []byte{3, 4, 5}))
+	a.Close(context.Background())
+
+	store.AssertExpectations(t)
+}
+
+func TestArchiver_Skips_Empty_And_Oversized_Drawings(t *testing.T) {
+	t.Parallel()
+	store := &MockDrawingStore{}
+	config := DefaultConfig()
+	config.MaxDrawingBytes = 4
+
+	a := NewArchiver(config, store)
+	a.Start()
+	a.SaveDrawing(testDrawing())
+	a.SaveDrawing(testDrawing([]byte{1, 2, 3}, []byte{4, 5}))
+	a.Close(context.Background())
+
+	store.AssertNotCalled(t, "SaveDrawing", mock.Anything, mock.Anything)
+}
+
+func TestArchiver_Does_Not_Prune_After_Failed_Save(t *testing.T) {
+	t.Parallel()
+	store := &MockDrawingStore{}
+	store.On("SaveDrawing", mock.Anything, mock.Anything).Return("", errors.New("boom")).Once()
+
+	a := NewArchiver(DefaultConfig(), store)
+	a.Start()
+	a.SaveDrawing(testDrawing([]byte{1}))
+	a.Close(context.Background())
+
+	store.AssertExpectations(t)
+	store.AssertNotCalled(t, "PruneDrawings", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestArchiver_SaveDrawing_Never_Blocks(t *testing.T) {
+	t.Parallel()
+	config := DefaultConfig()
+	config.QueueSize = 1
+	a := NewArchiver(config, &MockDrawingStore{})
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			a.SaveDrawing(testDrawing([]byte{1}))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "SaveDrawing blocked on a full queue")
+	}
+}
+
+func TestArchiver_Deletes_Expired_Drawings(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	store := &MockDrawingStore{}
+	store.On("DeleteDrawingsBefore", mock.Anything, now.Add(-time.Hour*24*90)).Return(nil)
+
+	a := NewArchiver(DefaultConfig(), store)
+	a.now = func() time.Time { return now }
+	a.pruneExpired()
+
+	store.AssertExpectations(t)
+}
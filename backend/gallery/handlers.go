@@ -0,0 +1,222 @@
+package gallery
+
+import (
+	"api/domain"
+	"api/drawing"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrDrawingNotFoundStr     = "drawing-not-found"
+	ErrUserNotFoundStr        = "user-not-found"
+	ErrUnrenderableDrawingStr = "unrenderable-drawing"
+)
+
+const listLimit = 50
+
+type galleryHandler struct {
+	repo GalleryRepo
+}
+
+func NewGalleryHandler(repo GalleryRepo) *galleryHandler {
+	return &galleryHandler{repo: repo}
+}
+
+type DrawingResponse struct {
+	Id         string    `json:"id"`
+	Drawer     string    `json:"drawer"`
+	Word       string    `json:"word"`
+	Guessers   []string  `json:"guessers"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Likes      int       `json:"likes"`
+	Liked      bool      `json:"liked"`
+	Favourited bool      `json:"favourited"`
+	// the DrawingData chunks in the order the drawer sent them, base64 encoded
+	Strokes [][]byte `json:"strokes,omitempty"`
+}
+
+func makeDrawingResponse(d domain.Drawing) DrawingResponse {
+	guessers := d.Guessers
+	if guessers == nil {
+		guessers = []string{}
+	}
+	return DrawingResponse{
+		Id:         d.Id,
+		Drawer:     d.Drawer,
+		Word:       d.Word,
+		Guessers:   guessers,
+		CreatedAt:  d.CreatedAt,
+		Likes:      d.Likes,
+		Liked:      d.Liked,
+		Favourited: d.Favourited,
+		Strokes:    d.Strokes,
+	}
+}
+
+// ListDrawingsHandler lists the newest drawings of the user given by the
+// "user" query param (the caller by default), or the caller's favourites when
+// "favourites" is true.
+func (gh *galleryHandler) ListDrawingsHandler(ctx *gin.Context) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+	reqCtx := ctx.Request.Context()
+
+	var drawings []domain.Drawing
+	var err error
+	if ctx.Query("favourites") == "true" {
+		drawings, err = gh.repo.ListFavouriteDrawings(reqCtx, userId, listLimit)
+	} else {
+		drawerId := userId
+		if username := ctx.Query("user"); username != "" {
+			user, err := gh.repo.GetUserByUsername(reqCtx, username)
+			if err != nil {
+				if errors.Is(err, domain.ErrUserNotFound) {
+					ctx.String(http.StatusNotFound, ErrUserNotFoundStr)
+					return
+				}
+				slog.Error("ListDrawings: failed to get user", "error", err.Error(), "username", username)
+				ctx.String(http.StatusInternalServerError, "unknown-error")
+				return
+			}
+			drawerId = user.Id
+		}
+		drawings, err = gh.repo.ListDrawingsByDrawer(reqCtx, drawerId, userId, listLimit)
+	}
+	if err != nil {
+		slog.Error("ListDrawings: failed to list drawings", "error", err.Error(), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+
+	response := make([]DrawingResponse, 0, len(drawings))
+	for _, d := range drawings {
+		response = append(response, makeDrawingResponse(d))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetDrawingHandler returns the drawing with its raw strokes, or the rendered
+// image when the id ends with .png or .svg.
+func (gh *galleryHandler) GetDrawingHandler(ctx *gin.Context) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	drawingId := ctx.Param("drawingid")
+	ext := path.Ext(drawingId)
+	if ext != ".png" && ext != ".svg" {
+		ext = ""
+	}
+	drawingId = strings.TrimSuffix(drawingId, ext)
+
+	saved, err := gh.repo.GetDrawing(ctx.Request.Context(), drawingId, userId)
+	if err != nil {
+		if errors.Is(err, domain.ErrDrawingNotFound) {
+			ctx.String(http.StatusNotFound, ErrDrawingNotFoundStr)
+			return
+		}
+		slog.Error("GetDrawing: failed to get drawing", "error", err.Error(), "drawing_id", drawingId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	if ext == "" {
+		ctx.JSON(http.StatusOK, makeDrawingResponse(saved))
+		return
+	}
+
+	// dende parts are only understood by the frontend, they are not parsed
+	// in the hope they look like drawing ops
+	if !saved.DrawingOps {
+		ctx.String(http.StatusUnprocessableEntity, ErrUnrenderableDrawingStr)
+		return
+	}
+	scene, err := drawing.Parse(saved.Strokes)
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if err == nil {
+		if ext == ".png" {
+			err = scene.WritePNG(&buf)
+		} else {
+			contentType = "image/svg+xml"
+			err = scene.WriteSVG(&buf)
+		}
+	}
+	if err != nil {
+		slog.Warn("GetDrawing: failed to render drawing", "error", err.Error(), "drawing_id", drawingId)
+		ctx.String(http.StatusUnprocessableEntity, ErrUnrenderableDrawingStr)
+		return
+	}
+
+	// drawings never change once saved
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	ctx.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+func (gh *galleryHandler) LikeDrawingHandler(ctx *gin.Context) {
+	gh.toggle(ctx, "LikeDrawing", gh.repo.SetDrawingLiked, true)
+}
+
+func (gh *galleryHandler) UnlikeDrawingHandler(ctx *gin.Context) {
+	gh.toggle(ctx, "UnlikeDrawing", gh.repo.SetDrawingLiked, false)
+}
+
+func (gh *galleryHandler) FavouriteDrawingHandler(ctx *gin.Context) {
+	gh.toggle(ctx, "FavouriteDrawing", gh.repo.SetDrawingFavourited, true)
+}
+
+func (gh *galleryHandler) UnfavouriteDrawingHandler(ctx *gin.Context) {
+	gh.toggle(ctx, "UnfavouriteDrawing", gh.repo.SetDrawingFavourited, false)
+}
+
+// DeleteDrawingHandler takes a drawing down from the gallery, it goes behind
+// auth's RequirePermission(domain.PermModerateDrawings).
+func (gh *galleryHandler) DeleteDrawingHandler(ctx *gin.Context) {
+	drawingId := ctx.Param("drawingid")
+	err := gh.repo.DeleteDrawing(ctx.Request.Context(), drawingId)
+	if err != nil {
+		if errors.Is(err, domain.ErrDrawingNotFound) {
+			ctx.String(http.StatusNotFound, ErrDrawingNotFoundStr)
+			return
+		}
+		slog.Error("DeleteDrawing: failed to delete drawing", "error", err.Error(), "drawing_id", drawingId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	slog.Info("DeleteDrawing: drawing taken down", "drawing_id", drawingId, "user_id", ctx.GetString("id"))
+	ctx.Status(http.StatusNoContent)
+}
+
+// toggle is shared by the like and favourite handlers, both are idempotent.
+func (gh *galleryHandler) toggle(ctx *gin.Context, name string, set func(ctx context.Context, id, userId string, value bool) error, value bool) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	err := set(ctx.Request.Context(), ctx.Param("drawingid"), userId, value)
+	if err != nil {
+		if errors.Is(err, domain.ErrDrawingNotFound) {
+			ctx.String(http.StatusNotFound, ErrDrawingNotFoundStr)
+			return
+		}
+		slog.Error(name+": failed to update drawing", "error", err.Error(), "drawing_id", ctx.Param("drawingid"), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
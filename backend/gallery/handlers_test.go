@@ -0,0 +1,277 @@
+package gallery
+
+import (
+	"api/domain"
+	"api/drawing"
+	"context"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGalleryRepo struct {
+	mock.Mock
+}
+
+func (m *MockGalleryRepo) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).(domain.User), args.Error(1)
+}
+
+func (m *MockGalleryRepo) ListDrawingsByDrawer(ctx context.Context, drawerId, viewerId string, limit int) ([]domain.Drawing, error) {
+	args := m.Called(ctx, drawerId, viewerId, limit)
+	return args.Get(0).([]domain.Drawing), args.Error(1)
+}
+
+func (m *MockGalleryRepo) ListFavouriteDrawings(ctx context.Context, userId string, limit int) ([]domain.Drawing, error) {
+	args := m.Called(ctx, userId, limit)
+	return args.Get(0).([]domain.Drawing), args.Error(1)
+}
+
+func (m *MockGalleryRepo) GetDrawing(ctx context.Context, id, viewerId string) (domain.Drawing, error) {
+	args := m.Called(ctx, id, viewerId)
+	return args.Get(0).(domain.Drawing), args.Error(1)
+}
+
+func (m *MockGalleryRepo) SetDrawingLiked(ctx context.Context, id, userId string, liked bool) error {
+	args := m.Called(ctx, id, userId, liked)
+	return args.Error(0)
+}
+
+func (m *MockGalleryRepo) SetDrawingFavourited(ctx context.Context, id, userId string, favourited bool) error {
+	args := m.Called(ctx, id, userId, favourited)
+	return args.Error(0)
+}
+
+func (m *MockGalleryRepo) DeleteDrawing(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newTestRouter(handler *galleryHandler) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("id", "user-1") })
+	router.GET("/drawings", handler.ListDrawingsHandler)
+	router.GET("/drawings/:drawingid", handler.GetDrawingHandler)
+	router.PUT("/drawings/:drawingid/like", handler.LikeDrawingHandler)
+	router.DELETE("/drawings/:drawingid/like", handler.UnlikeDrawingHandler)
+	router.PUT("/drawings/:drawingid/favourite", handler.FavouriteDrawingHandler)
+	router.DELETE("/drawings/:drawingid/favourite", handler.UnfavouriteDrawingHandler)
+	router.DELETE("/drawings/:drawingid", handler.DeleteDrawingHandler)
+	return router
+}
+
+func TestListDrawingsHandler(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name         string
+		query        string
+		setupMocks   func(r *MockGalleryRepo)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:  "own drawings",
+			query: "",
+			setupMocks: func(r *MockGalleryRepo) {
+				r.On("ListDrawingsByDrawer", mock.Anything, "user-1", "user-1", 50).Return([]domain.Drawing{{Id: "drawing-1", Word: "apple"}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"word":"apple"`,
+		},
+		{
+			name:  "someone else's drawings",
+			query: "?user=sasuke",
+			setupMocks: func(r *MockGalleryRepo) {
+				r.On("GetUserByUsername", mock.Anything, "sasuke").Return(domain.User{Id: "user-2"}, nil)
+				r.On("ListDrawingsByDrawer", mock.Anything, "user-2", "user-1", 50).Return([]domain.Drawing{}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `[]`,
+		},
+		{
+			name:  "unknown user",
+			query: "?user=nobody",
+			setupMocks: func(r *MockGalleryRepo) {
+				r.On("GetUserByUsername", mock.Anything, "nobody").Return(domain.User{}, domain.ErrUserNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: ErrUserNotFoundStr,
+		},
+		{
+			name:  "favourites",
+			query: "?favourites=true",
+			setupMocks: func(r *MockGalleryRepo) {
+				r.On("ListFavouriteDrawings", mock.Anything, "user-1", 50).Return([]domain.Drawing{{Id: "drawing-9", Favourited: true}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"favourited":true`,
+		},
+		{
+			name:  "database error",
+			query: "",
+			setupMocks: func(r *MockGalleryRepo) {
+				r.On("ListDrawingsByDrawer", mock.Anything, "user-1", "user-1", 50).Return([]domain.Drawing{}, domain.UnexpectedDatabaseError)
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "unknown-error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := &MockGalleryRepo{}
+			tc.setupMocks(repo)
+
+			req := httptest.NewRequest(http.MethodGet, "/drawings"+tc.query, nil)
+			res := httptest.NewRecorder()
+			newTestRouter(NewGalleryHandler(repo)).ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Contains(t, res.Body.String(), tc.expectedBody)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetDrawingHandler(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns strokes", func(t *testing.T) {
+		t.Parallel()
+		repo := &MockGalleryRepo{}
+		repo.On("GetDrawing", mock.Anything, "drawing-1", "user-1").Return(domain.Drawing{Id: "drawing-1", Strokes: [][]byte{{1, 2, 3}}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/drawings/drawing-1", nil)
+		res := httptest.NewRecorder()
+		newTestRouter(NewGalleryHandler(repo)).ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Contains(t, res.Body.String(), `"strokes":["AQID"]`)
+		assert.Contains(t, res.Body.String(), `"guessers":[]`)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		repo := &MockGalleryRepo{}
+		repo.On("GetDrawing", mock.Anything, "drawing-2", "user-1").Return(domain.Drawing{}, domain.ErrDrawingNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/drawings/drawing-2", nil)
+		res := httptest.NewRecorder()
+		newTestRouter(NewGalleryHandler(repo)).ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		assert.Equal(t, ErrDrawingNotFoundStr, res.Body.String())
+	})
+}
+
+func TestGetDrawingHandler_Renders_Images(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	strokes := drawing.AppendBegin(nil, color.RGBA{A: 255}, 4, drawing.Point{X: 10, Y: 10})
+	strokes = drawing.AppendPoints(strokes, drawing.Point{X: 100, Y: 100})
+	strokes = drawing.AppendEnd(strokes)
+
+	testCases := []struct {
+		name         string
+		path         string
+		strokes      [][]byte
+		dende        bool
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{name: "png", path: "/drawings/drawing-1.png", strokes: [][]byte{strokes}, expectedCode: http.StatusOK, expectedType: "image/png", expectedBody: "\x89PNG"},
+		{name: "svg", path: "/drawings/drawing-1.svg", strokes: [][]byte{strokes}, expectedCode: http.StatusOK, expectedType: "image/svg+xml", expectedBody: `<polyline points="10,10 100,100"`},
+		{name: "malformed strokes", path: "/drawings/drawing-1.png", strokes: [][]byte{{0x42}}, expectedCode: http.StatusUnprocessableEntity, expectedBody: ErrUnrenderableDrawingStr},
+		{name: "dende parts", path: "/drawings/drawing-1.svg", strokes: [][]byte{strokes}, dende: true, expectedCode: http.StatusUnprocessableEntity, expectedBody: ErrUnrenderableDrawingStr},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := &MockGalleryRepo{}
+			repo.On("GetDrawing", mock.Anything, "drawing-1", "user-1").Return(domain.Drawing{Id: "drawing-1", Strokes: tc.strokes, DrawingOps: !tc.dende}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			res := httptest.NewRecorder()
+			newTestRouter(NewGalleryHandler(repo)).ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			assert.Contains(t, res.Body.String(), tc.expectedBody)
+			if tc.expectedType != "" {
+				assert.Equal(t, tc.expectedType, res.Header().Get("Content-Type"))
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestToggleHandlers(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		repoMethod   string
+		value        bool
+		repoErr      error
+		expectedCode int
+	}{
+		{name: "like", method: http.MethodPut, path: "/like", repoMethod: "SetDrawingLiked", value: true, expectedCode: http.StatusNoContent},
+		{name: "unlike", method: http.MethodDelete, path: "/like", repoMethod: "SetDrawingLiked", value: false, expectedCode: http.StatusNoContent},
+		{name: "favourite", method: http.MethodPut, path: "/favourite", repoMethod: "SetDrawingFavourited", value: true, expectedCode: http.StatusNoContent},
+		{name: "unfavourite", method: http.MethodDelete, path: "/favourite", repoMethod: "SetDrawingFavourited", value: false, expectedCode: http.StatusNoContent},
+		{name: "unknown drawing", method: http.MethodPut, path: "/like", repoMethod: "SetDrawingLiked", value: true, repoErr: domain.ErrDrawingNotFound, expectedCode: http.StatusNotFound},
+		{name: "database error", method: http.MethodPut, path: "/favourite", repoMethod: "SetDrawingFavourited", value: true, repoErr: domain.UnexpectedDatabaseError, expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			repo := &MockGalleryRepo{}
+			repo.On(tc.repoMethod, mock.Anything, "drawing-1", "user-1", tc.value).Return(tc.repoErr)
+
+			req := httptest.NewRequest(tc.method, "/drawings/drawing-1"+tc.path, nil)
+			res := httptest.NewRecorder()
+			newTestRouter(NewGalleryHandler(repo)).ServeHTTP(res, req)
+
+			assert.Equal(t, tc.expectedCode, res.Code)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteDrawingHandler(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	repo := &MockGalleryRepo{}
+	repo.On("DeleteDrawing", mock.Anything, "drawing-1").Return(nil).Once()
+	repo.On("DeleteDrawing", mock.Anything, "drawing-2").Return(domain.ErrDrawingNotFound).Once()
+	repo.On("DeleteDrawing", mock.Anything, "drawing-3").Return(domain.UnexpectedDatabaseError).Once()
+	router := newTestRouter(NewGalleryHandler(repo))
+
+	for id, code := range map[string]int{
+		"drawing-1": http.StatusNoContent,
+		"drawing-2": http.StatusNotFound,
+		"drawing-3": http.StatusInternalServerError,
+	} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodDelete, "/drawings/"+id, nil))
+		assert.Equal(t, code, res.Code, id)
+	}
+	repo.AssertExpectations(t)
+}
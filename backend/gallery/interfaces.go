@@ -0,0 +1,26 @@
+package gallery
+
+import (
+	"api/domain"
+	"context"
+	"time"
+)
+
+type DrawingStore interface {
+	SaveDrawing(ctx context.Context, d domain.Drawing) (string, error)
+	// PruneDrawings deletes the oldest drawings of a drawer beyond keep,
+	// drawings someone favourited are kept.
+	PruneDrawings(ctx context.Context, drawerId string, keep int) error
+	DeleteDrawingsBefore(ctx context.Context, cutoff time.Time) error
+}
+
+type GalleryRepo interface {
+	GetUserByUsername(ctx context.Context, username string) (domain.User, error)
+	ListDrawingsByDrawer(ctx context.Context, drawerId, viewerId string, limit int) ([]domain.Drawing, error)
+	ListFavouriteDrawings(ctx context.Context, userId string, limit int) ([]domain.Drawing, error)
+	GetDrawing(ctx context.Context, id, viewerId string) (domain.Drawing, error)
+	SetDrawingLiked(ctx context.Context, id, userId string, liked bool) error
+	SetDrawingFavourited(ctx context.Context, id, userId string, favourited bool) error
+	// DeleteDrawing removes the drawing whoever drew it.
+	DeleteDrawing(ctx context.Context, id string) error
+}
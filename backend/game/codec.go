@@ -0,0 +1,111 @@
+package game
+
+import (
+	"api/domain/protobuf"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// WireFormat is how packets are encoded on a connection. Clients pick one
+// with the websocket subprotocol, protobuf being the default.
+type WireFormat uint8
+
+const (
+	WireFormatProtobuf WireFormat = iota
+	// WireFormatJSON is the protojson mapping of the same packets, in text
+	// frames, for clients without protobuf codegen.
+	WireFormatJSON
+	wireFormatCount
+)
+
+const (
+	SubprotocolProtobuf = "gto.protobuf"
+	SubprotocolJSON     = "gto.json"
+)
+
+// Subprotocols are offered in order of preference.
+var Subprotocols = []string{SubprotocolProtobuf, SubprotocolJSON}
+
+func (f WireFormat) String() string {
+	if f == WireFormatJSON {
+		return "json"
+	}
+	return "protobuf"
+}
+
+// WireFormatOf maps the negotiated subprotocol to its format, no subprotocol
+// at all meaning protobuf.
+func WireFormatOf(subprotocol string) WireFormat {
+	if subprotocol == SubprotocolJSON {
+		return WireFormatJSON
+	}
+	return WireFormatProtobuf
+}
+
+// codec encodes what a player is sent and decodes what it sends.
+type codec interface {
+	marshal(packet *protobuf.ServerPacket) ([]byte, error)
+	unmarshal(data []byte, packet *protobuf.ClientPacket) error
+}
+
+var codecs = [wireFormatCount]codec{
+	WireFormatProtobuf: protobufCodec{},
+	WireFormatJSON:     jsonCodec{},
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) marshal(packet *protobuf.ServerPacket) ([]byte, error) {
+	return proto.Marshal(packet)
+}
+
+func (protobufCodec) unmarshal(data []byte, packet *protobuf.ClientPacket) error {
+	return proto.Unmarshal(data, packet)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) marshal(packet *protobuf.ServerPacket) ([]byte, error) {
+	return protojson.Marshal(packet)
+}
+
+// unknown fields are skipped, as protobuf does, so that newer clients keep
+// working
+func (jsonCodec) unmarshal(data []byte, packet *protobuf.ClientPacket) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, packet)
+}
+
+// encodedPacket is a packet the room sends, marshalled at most once per wire
+// format and only for the formats asked for.
+type encodedPacket struct {
+	packet *protobuf.ServerPacket
+	data   [wireFormatCount][]byte
+}
+
+func encode(packet *protobuf.ServerPacket) *encodedPacket {
+	return &encodedPacket{packet: packet}
+}
+
+func (e *encodedPacket) bytes(format WireFormat) ([]byte, error) {
+	if e.data[format] == nil {
+		data, err := codecs[format].marshal(e.packet)
+		if err != nil {
+			return nil, err
+		}
+		e.data[format] = data
+	}
+	return e.data[format], nil
+}
+
+// size is what the encodings marshalled so far hold.
+func (e *encodedPacket) size() int {
+	if e == nil {
+		return 0
+	}
+	n := 0
+	for _, data := range e.data {
+		n += len(data)
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// unmarshalServerPacket is what clients do, the server never reads these.
+func unmarshalServerPacket(format WireFormat, data []byte, packet *protobuf.ServerPacket) error {
+	if format == WireFormatJSON {
+		return protojson.Unmarshal(data, packet)
+	}
+	return proto.Unmarshal(data, packet)
+}
+
+func TestCodecs_Round_Trip(t *testing.T) {
+	t.Parallel()
+	for format := range wireFormatCount {
+		t.Run(format.String(), func(t *testing.T) {
+			t.Parallel()
+			sent := protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{opColor(0xff0000ff), opFill(3, 4)})
+			sent.Seq = 7
+			data, err := codecs[format].marshal(sent)
+			require.NoError(t, err)
+			got := &protobuf.ServerPacket{}
+			require.NoError(t, unmarshalServerPacket(format, data, got))
+			assert.True(t, proto.Equal(sent, got))
+		})
+	}
+}
+
+func TestJSONCodec_Reads_Client_Packets(t *testing.T) {
+	t.Parallel()
+	packet := &protobuf.ClientPacket{}
+	err := codecs[WireFormatJSON].unmarshal([]byte(`{"playerMessage":{"message":"hi"},"fromTheFuture":1}`), packet)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", packet.GetPlayerMessage().GetMessage())
+
+	assert.Error(t, codecs[WireFormatJSON].unmarshal([]byte{1, 5}, &protobuf.ClientPacket{}))
+}
+
+func TestEncodedPacket_Marshals_Once_Per_Format(t *testing.T) {
+	t.Parallel()
+	encoded := encode(protobuf.MakePacketPlayerJoined("a"))
+
+	first, err := encoded.bytes(WireFormatJSON)
+	require.NoError(t, err)
+	again, _ := encoded.bytes(WireFormatJSON)
+	assert.Same(t, &first[0], &again[0])
+	assert.Nil(t, encoded.data[WireFormatProtobuf], "nobody asked for protobuf")
+	assert.Equal(t, len(first), encoded.size())
+}
+
+func TestRoom_Sends_Each_Player_Its_Format(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.playerStates[1].wireFormat = WireFormatJSON
+
+	r.broadcastToAll(protobuf.MakePacketGameStarted())
+
+	require.Len(t, r.dataSendTasks, 2)
+	hostPacket, guestPacket := &protobuf.ServerPacket{}, &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, hostPacket))
+	require.NoError(t, protojson.Unmarshal(r.dataSendTasks[1].data, guestPacket))
+	assert.True(t, proto.Equal(hostPacket, guestPacket))
+}
+
+func TestRequestedWireFormat(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		header string
+		want   WireFormat
+	}{
+		{"", WireFormatProtobuf},
+		{SubprotocolJSON, WireFormatJSON},
+		{"mqtt, " + SubprotocolJSON, WireFormatJSON},
+		{SubprotocolJSON + ", " + SubprotocolProtobuf, WireFormatProtobuf},
+		{"mqtt", WireFormatProtobuf},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		if tc.header != "" {
+			req.Header.Set("Sec-WebSocket-Protocol", tc.header)
+		}
+		assert.Equal(t, tc.want, requestedWireFormat(req), tc.header)
+	}
+}
@@ -0,0 +1,325 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"api/drawing"
+	"image/color"
+	"slices"
+)
+
+const (
+	maxDrawingOpsPerPacket = 64
+	maxPointsPerDrawingOp  = 256
+	maxBrushSize           = 64
+	// the canonical encoding of a whole turn, legacy bytes count the same
+	maxDrawingBytesPerTurn = 256 * 1024
+	// what the room holds on to, undone strokes included, see
+	// room.SetMaxDrawingHistoryBytes
+	defaultMaxDrawingHistoryBytes = 128 * 1024
+	// older strokes are folded into checkpoints and can no longer be undone
+	maxUndoDepth         = 32
+	checkpointChunkBytes = 16 * 1024
+)
+
+// drawingValidator checks the drawer's ops against the canvas and the per
+// turn budget and turns them into the drawing package stream, which is what
+// ends up in the history and the gallery. It is reset every turn.
+type drawingValidator struct {
+	color  color.RGBA
+	size   uint8
+	open   bool
+	used   int
+	budget int
+}
+
+func newDrawingValidator() drawingValidator {
+	return drawingValidator{
+		color:  color.RGBA{A: 255},
+		size:   4,
+		budget: maxDrawingBytesPerTurn,
+	}
+}
+
+// apply validates a whole packet, nothing is kept when any op is invalid. It
+// returns the ops rebuilt from their validated values only, so unknown
+// fields never reach the other players, and the encoding of each, nil for
+// colour and brush size. Strokes and fills may add at most headroom bytes
+// to the history. The packet must fit in what is left of the turn budget,
+// the caller charges what it relays.
+func (v *drawingValidator) apply(ops []*protobuf.DrawingOp, headroom int) ([]*protobuf.DrawingOp, [][]byte, error) {
+	if len(ops) == 0 || len(ops) > maxDrawingOpsPerPacket {
+		return nil, nil, ErrInvalidDrawingOp
+	}
+
+	next := *v
+	clean := make([]*protobuf.DrawingOp, 0, len(ops))
+	encoded := make([][]byte, 0, len(ops))
+	size, grows := 0, 0
+	for _, op := range ops {
+		c, err := next.applyOp(op)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf := next.encode(nil, c)
+		clean = append(clean, c)
+		encoded = append(encoded, buf)
+		size += len(buf)
+		if len(buf) > 0 && buf[0] != drawing.OpUndo && buf[0] != drawing.OpRedo && buf[0] != drawing.OpClear {
+			grows += len(buf)
+		}
+	}
+	if next.used+size > next.budget {
+		return nil, nil, ErrDrawingBudgetExceeded
+	}
+	if grows > headroom {
+		return nil, nil, ErrDrawingHistoryFull
+	}
+
+	*v = next
+	return clean, encoded, nil
+}
+
+func (v *drawingValidator) applyOp(op *protobuf.DrawingOp) (*protobuf.DrawingOp, error) {
+	switch o := op.GetOp().(type) {
+	case *protobuf.DrawingOp_StrokeBegin_:
+		p, ok := canvasPoint(o.StrokeBegin.GetAt())
+		if v.open || !ok {
+			return nil, ErrInvalidDrawingOp
+		}
+		v.open = true
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokeBegin_{StrokeBegin: &protobuf.DrawingOp_StrokeBegin{At: p}}}, nil
+	case *protobuf.DrawingOp_StrokePoints_:
+		points := o.StrokePoints.GetPoints()
+		if !v.open || len(points) == 0 || len(points) > maxPointsPerDrawingOp {
+			return nil, ErrInvalidDrawingOp
+		}
+		clean := make([]*protobuf.DrawingOp_Point, 0, len(points))
+		for _, pt := range points {
+			p, ok := canvasPoint(pt)
+			if !ok {
+				return nil, ErrInvalidDrawingOp
+			}
+			clean = append(clean, p)
+		}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokePoints_{StrokePoints: &protobuf.DrawingOp_StrokePoints{Points: clean}}}, nil
+	case *protobuf.DrawingOp_StrokeEnd_:
+		if !v.open {
+			return nil, ErrInvalidDrawingOp
+		}
+		v.open = false
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokeEnd_{StrokeEnd: &protobuf.DrawingOp_StrokeEnd{}}}, nil
+	case *protobuf.DrawingOp_Fill_:
+		p, ok := canvasPoint(o.Fill.GetAt())
+		if v.open || !ok {
+			return nil, ErrInvalidDrawingOp
+		}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Fill_{Fill: &protobuf.DrawingOp_Fill{At: p}}}, nil
+	case *protobuf.DrawingOp_Clear_:
+		if v.open {
+			return nil, ErrInvalidDrawingOp
+		}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Clear_{Clear: &protobuf.DrawingOp_Clear{}}}, nil
+	case *protobuf.DrawingOp_Undo_:
+		if v.open {
+			return nil, ErrInvalidDrawingOp
+		}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Undo_{Undo: &protobuf.DrawingOp_Undo{}}}, nil
+	case *protobuf.DrawingOp_Redo_:
+		if v.open {
+			return nil, ErrInvalidDrawingOp
+		}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Redo_{Redo: &protobuf.DrawingOp_Redo{}}}, nil
+	case *protobuf.DrawingOp_SetColor_:
+		rgba := o.SetColor.GetRgba()
+		v.color = color.RGBA{R: uint8(rgba >> 24), G: uint8(rgba >> 16), B: uint8(rgba >> 8), A: uint8(rgba)}
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_SetColor_{SetColor: &protobuf.DrawingOp_SetColor{Rgba: rgba}}}, nil
+	case *protobuf.DrawingOp_SetBrushSize_:
+		size := o.SetBrushSize.GetSize()
+		if size < 1 || size > maxBrushSize {
+			return nil, ErrInvalidDrawingOp
+		}
+		v.size = uint8(size)
+		return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_SetBrushSize_{SetBrushSize: &protobuf.DrawingOp_SetBrushSize{Size: size}}}, nil
+	default:
+		return nil, ErrInvalidDrawingOp
+	}
+}
+
+// encode appends a validated op to the stream, colour and brush size only
+// live in the validator until a stroke or fill uses them.
+func (v *drawingValidator) encode(buf []byte, op *protobuf.DrawingOp) []byte {
+	switch o := op.GetOp().(type) {
+	case *protobuf.DrawingOp_StrokeBegin_:
+		return drawing.AppendBegin(buf, v.color, v.size, streamPoint(o.StrokeBegin.At))
+	case *protobuf.DrawingOp_StrokePoints_:
+		points := make([]drawing.Point, 0, len(o.StrokePoints.Points))
+		for _, p := range o.StrokePoints.Points {
+			points = append(points, streamPoint(p))
+		}
+		return drawing.AppendPoints(buf, points...)
+	case *protobuf.DrawingOp_StrokeEnd_:
+		return drawing.AppendEnd(buf)
+	case *protobuf.DrawingOp_Fill_:
+		return drawing.AppendFill(buf, v.color, streamPoint(o.Fill.At))
+	case *protobuf.DrawingOp_Clear_:
+		return drawing.AppendClear(buf)
+	case *protobuf.DrawingOp_Undo_:
+		return drawing.AppendUndo(buf)
+	case *protobuf.DrawingOp_Redo_:
+		return drawing.AppendRedo(buf)
+	}
+	return buf
+}
+
+// spend charges n bytes against the turn budget.
+func (v *drawingValidator) spend(n int) error {
+	if v.used+n > v.budget {
+		return ErrDrawingBudgetExceeded
+	}
+	v.charge(n)
+	return nil
+}
+
+// charge counts relayed bytes of a packet apply let through, they fit.
+func (v *drawingValidator) charge(n int) {
+	v.used += n
+}
+
+// strokeHistory is what is left on the canvas this turn, one chunk per
+// stroke or fill with the open stroke last, plus the undone chunks waiting
+// for a redo. Clearing drops both, so late joiners only ever get the
+// visible strokes. Past maxUndoDepth the oldest strokes are merged into
+// checkpoint chunks at the front, which keeps the snapshot to a few large
+// chunks however long the turn. Chunks only grow past what was already
+// handed out, the open stroke and the last checkpoint being appended to in
+// place.
+type strokeHistory struct {
+	visible [][]byte
+	undone  [][]byte
+	open    bool
+	// leading visible chunks that are checkpoints, undo stops there
+	base int
+	// bytes held in visible and undone
+	size int
+	// a typed op was drawn since the last reset, legacy clients can not
+	// draw the history
+	ops bool
+	// a dende part was drawn since the last reset, the server can not
+	// render the history
+	opaque bool
+}
+
+// push applies one encoded op, as returned by drawingValidator.apply, and
+// reports whether the canvas changed.
+func (h *strokeHistory) push(op []byte) bool {
+	if len(op) == 0 {
+		return false
+	}
+	switch op[0] {
+	case drawing.OpBegin:
+		h.dropUndone()
+		h.visible = append(h.visible, op)
+		h.open = true
+		h.ops = true
+		h.size += len(op)
+	case drawing.OpPoints, drawing.OpEnd:
+		if !h.open {
+			return false
+		}
+		last := len(h.visible) - 1
+		h.visible[last] = append(h.visible[last], op...)
+		h.size += len(op)
+		if op[0] == drawing.OpEnd {
+			h.open = false
+			h.compact()
+		}
+	case drawing.OpFill:
+		h.dropUndone()
+		h.visible = append(h.visible, op)
+		h.ops = true
+		h.size += len(op)
+		h.compact()
+	case drawing.OpUndo:
+		if h.open || len(h.visible) == h.base {
+			return false
+		}
+		last := len(h.visible) - 1
+		h.undone = append(h.undone, h.visible[last])
+		h.visible[last] = nil
+		h.visible = h.visible[:last]
+	case drawing.OpRedo:
+		if h.open || len(h.undone) == 0 {
+			return false
+		}
+		last := len(h.undone) - 1
+		h.visible = append(h.visible, h.undone[last])
+		h.undone[last] = nil
+		h.undone = h.undone[:last]
+	case drawing.OpClear:
+		if h.open || len(h.visible)+len(h.undone) == 0 {
+			return false
+		}
+		h.reset()
+	default:
+		return false
+	}
+	return true
+}
+
+// pushOpaque keeps a legacy chunk as a single stroke, whatever it holds.
+func (h *strokeHistory) pushOpaque(chunk []byte) bool {
+	if h.open {
+		return false
+	}
+	h.dropUndone()
+	h.visible = append(h.visible, chunk)
+	h.opaque = true
+	h.size += len(chunk)
+	h.compact()
+	return true
+}
+
+// compact folds the strokes that fell out of the undo depth into the last
+// checkpoint, starting a new one once it is full.
+func (h *strokeHistory) compact() {
+	for len(h.visible)-h.base > maxUndoDepth {
+		oldest := h.visible[h.base]
+		if h.base > 0 && len(h.visible[h.base-1])+len(oldest) <= checkpointChunkBytes {
+			h.visible[h.base-1] = append(h.visible[h.base-1], oldest...)
+			h.visible = slices.Delete(h.visible, h.base, h.base+1)
+			continue
+		}
+		h.visible[h.base] = append(make([]byte, 0, max(checkpointChunkBytes, len(oldest))), oldest...)
+		h.base++
+	}
+}
+
+func (h *strokeHistory) dropUndone() {
+	for _, chunk := range h.undone {
+		h.size -= len(chunk)
+	}
+	clear(h.undone)
+	h.undone = h.undone[:0]
+}
+
+func (h *strokeHistory) reset() {
+	clear(h.visible)
+	h.visible = h.visible[:0]
+	h.dropUndone()
+	h.open = false
+	h.ops = false
+	h.opaque = false
+	h.base = 0
+	h.size = 0
+}
+
+func canvasPoint(p *protobuf.DrawingOp_Point) (*protobuf.DrawingOp_Point, bool) {
+	if p == nil || p.GetX() >= drawing.Width || p.GetY() >= drawing.Height {
+		return nil, false
+	}
+	return &protobuf.DrawingOp_Point{X: p.GetX(), Y: p.GetY()}, true
+}
+
+func streamPoint(p *protobuf.DrawingOp_Point) drawing.Point {
+	return drawing.Point{X: uint16(p.X), Y: uint16(p.Y)}
+}
@@ -0,0 +1,333 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"api/drawing"
+	"bytes"
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func pt(x, y uint32) *protobuf.DrawingOp_Point {
+	return &protobuf.DrawingOp_Point{X: x, Y: y}
+}
+
+func opBegin(x, y uint32) *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokeBegin_{StrokeBegin: &protobuf.DrawingOp_StrokeBegin{At: pt(x, y)}}}
+}
+
+func opPoints(points ...*protobuf.DrawingOp_Point) *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokePoints_{StrokePoints: &protobuf.DrawingOp_StrokePoints{Points: points}}}
+}
+
+func opEnd() *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_StrokeEnd_{StrokeEnd: &protobuf.DrawingOp_StrokeEnd{}}}
+}
+
+func opFill(x, y uint32) *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Fill_{Fill: &protobuf.DrawingOp_Fill{At: pt(x, y)}}}
+}
+
+func opUndo() *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Undo_{Undo: &protobuf.DrawingOp_Undo{}}}
+}
+
+func opRedo() *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Redo_{Redo: &protobuf.DrawingOp_Redo{}}}
+}
+
+func opClear() *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_Clear_{Clear: &protobuf.DrawingOp_Clear{}}}
+}
+
+func opColor(rgba uint32) *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_SetColor_{SetColor: &protobuf.DrawingOp_SetColor{Rgba: rgba}}}
+}
+
+func opSize(size uint32) *protobuf.DrawingOp {
+	return &protobuf.DrawingOp{Op: &protobuf.DrawingOp_SetBrushSize_{SetBrushSize: &protobuf.DrawingOp_SetBrushSize{Size: size}}}
+}
+
+func TestDrawingValidator_Encodes_Stream(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+
+	ops, encoded, err := v.apply([]*protobuf.DrawingOp{
+		opColor(0xff000080), opSize(10), opBegin(1, 2), opPoints(pt(3, 4), pt(799, 599)), opEnd(), opFill(5, 6), opUndo(),
+	}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	assert.Len(t, ops, 7)
+
+	red := color.RGBA{R: 255, A: 128}
+	expected := drawing.AppendBegin(nil, red, 10, drawing.Point{X: 1, Y: 2})
+	expected = drawing.AppendPoints(expected, drawing.Point{X: 3, Y: 4}, drawing.Point{X: 799, Y: 599})
+	expected = drawing.AppendEnd(expected)
+	expected = drawing.AppendFill(expected, red, drawing.Point{X: 5, Y: 6})
+	expected = drawing.AppendUndo(expected)
+	assert.Len(t, encoded, 7)
+	assert.Nil(t, encoded[0], "colour and brush size have no encoding of their own")
+	assert.Equal(t, expected, bytes.Join(encoded, nil))
+	assert.Zero(t, v.used, "the room charges what it relays")
+
+	_, err = drawing.Parse(encoded)
+	assert.NoError(t, err)
+}
+
+func TestDrawingValidator_Keeps_Stroke_Open_Across_Packets(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+
+	_, _, err := v.apply([]*protobuf.DrawingOp{opBegin(1, 1)}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	_, _, err = v.apply([]*protobuf.DrawingOp{opPoints(pt(2, 2))}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	_, _, err = v.apply([]*protobuf.DrawingOp{opEnd()}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	assert.False(t, v.open)
+}
+
+func TestDrawingValidator_Rejects(t *testing.T) {
+	t.Parallel()
+	tooManyPoints := make([]*protobuf.DrawingOp_Point, maxPointsPerDrawingOp+1)
+	for i := range tooManyPoints {
+		tooManyPoints[i] = pt(1, 1)
+	}
+	tooManyOps := make([]*protobuf.DrawingOp, maxDrawingOpsPerPacket+1)
+	for i := range tooManyOps {
+		tooManyOps[i] = opUndo()
+	}
+
+	testCases := []struct {
+		name string
+		ops  []*protobuf.DrawingOp
+	}{
+		{name: "no ops", ops: nil},
+		{name: "too many ops", ops: tooManyOps},
+		{name: "x out of bounds", ops: []*protobuf.DrawingOp{opBegin(800, 0)}},
+		{name: "y out of bounds", ops: []*protobuf.DrawingOp{opBegin(0, 600)}},
+		{name: "missing point", ops: []*protobuf.DrawingOp{{Op: &protobuf.DrawingOp_Fill_{Fill: &protobuf.DrawingOp_Fill{}}}}},
+		{name: "points out of bounds", ops: []*protobuf.DrawingOp{opBegin(0, 0), opPoints(pt(1, 1), pt(4000, 1))}},
+		{name: "empty points", ops: []*protobuf.DrawingOp{opBegin(0, 0), opPoints()}},
+		{name: "too many points", ops: []*protobuf.DrawingOp{opBegin(0, 0), opPoints(tooManyPoints...)}},
+		{name: "points without stroke", ops: []*protobuf.DrawingOp{opPoints(pt(1, 1))}},
+		{name: "end without stroke", ops: []*protobuf.DrawingOp{opEnd()}},
+		{name: "nested stroke", ops: []*protobuf.DrawingOp{opBegin(0, 0), opBegin(0, 0)}},
+		{name: "fill inside stroke", ops: []*protobuf.DrawingOp{opBegin(0, 0), opFill(0, 0)}},
+		{name: "brush too small", ops: []*protobuf.DrawingOp{opSize(0)}},
+		{name: "brush too big", ops: []*protobuf.DrawingOp{opSize(maxBrushSize + 1)}},
+		{name: "empty op", ops: []*protobuf.DrawingOp{{}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			v := newDrawingValidator()
+			_, _, err := v.apply(tc.ops, maxDrawingBytesPerTurn)
+			assert.ErrorIs(t, err, ErrInvalidDrawingOp)
+			assert.Equal(t, newDrawingValidator(), v, "a rejected packet must not change the state")
+		})
+	}
+}
+
+func TestDrawingValidator_Enforces_Turn_Budget(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+	v.budget = 40
+
+	_, encoded, err := v.apply([]*protobuf.DrawingOp{opBegin(1, 1), opPoints(pt(2, 2), pt(3, 3)), opEnd()}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	v.charge(len(bytes.Join(encoded, nil)))
+	_, _, err = v.apply([]*protobuf.DrawingOp{opBegin(1, 1), opPoints(pt(2, 2), pt(3, 3)), opEnd()}, maxDrawingBytesPerTurn)
+	assert.ErrorIs(t, err, ErrDrawingBudgetExceeded)
+	assert.Equal(t, 21, v.used)
+	assert.ErrorIs(t, v.spend(20), ErrDrawingBudgetExceeded)
+	assert.NoError(t, v.spend(19))
+}
+
+func TestDrawingValidator_Enforces_History_Headroom(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+
+	_, _, err := v.apply([]*protobuf.DrawingOp{opFill(1, 1), opFill(2, 2)}, 10)
+	assert.ErrorIs(t, err, ErrDrawingHistoryFull)
+	assert.Zero(t, v.used)
+
+	_, _, err = v.apply([]*protobuf.DrawingOp{opClear(), opUndo(), opRedo()}, 0)
+	assert.NoError(t, err, "a full history can still be cleared")
+}
+
+func TestDrawingValidator_Drops_Unknown_Fields(t *testing.T) {
+	t.Parallel()
+	op := opBegin(1, 1)
+	op.ProtoReflect().SetUnknown([]byte{0xfa, 0x3f, 0x03, 'b', 'a', 'd'})
+
+	v := newDrawingValidator()
+	ops, _, err := v.apply([]*protobuf.DrawingOp{op}, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	assert.Empty(t, ops[0].ProtoReflect().GetUnknown())
+	assert.True(t, proto.Equal(opBegin(1, 1), ops[0]))
+}
+
+func pushOps(t *testing.T, v *drawingValidator, h *strokeHistory, ops ...*protobuf.DrawingOp) (changed bool) {
+	t.Helper()
+	_, encoded, err := v.apply(ops, maxDrawingBytesPerTurn)
+	require.NoError(t, err)
+	for _, op := range encoded {
+		changed = h.push(op) || changed
+	}
+	return changed
+}
+
+func TestStrokeHistory_Keeps_Visible_Strokes(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+	h := strokeHistory{}
+
+	pushOps(t, &v, &h, opBegin(1, 1), opPoints(pt(2, 2)))
+	pushOps(t, &v, &h, opPoints(pt(3, 3)), opEnd(), opFill(5, 5))
+	require.Len(t, h.visible, 2, "an open stroke spans packets but stays one chunk")
+
+	assert.True(t, pushOps(t, &v, &h, opUndo()))
+	assert.True(t, pushOps(t, &v, &h, opUndo()))
+	assert.False(t, pushOps(t, &v, &h, opUndo()), "nothing left to undo")
+	assert.Empty(t, h.visible)
+	assert.Len(t, h.undone, 2)
+
+	assert.True(t, pushOps(t, &v, &h, opRedo()))
+	scene, err := drawing.Parse(h.visible)
+	require.NoError(t, err)
+	require.Len(t, scene.Actions(), 1)
+	assert.Len(t, scene.Actions()[0].Points, 3)
+
+	pushOps(t, &v, &h, opFill(9, 9))
+	assert.Empty(t, h.undone, "drawing drops the redo stack")
+	assert.False(t, pushOps(t, &v, &h, opRedo()))
+
+	assert.True(t, pushOps(t, &v, &h, opClear()))
+	assert.Empty(t, h.visible)
+	assert.False(t, pushOps(t, &v, &h, opClear()), "clearing an empty canvas changes nothing")
+}
+
+func TestStrokeHistory_Matches_Scene(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+	h := strokeHistory{}
+	var stream [][]byte
+
+	packets := [][]*protobuf.DrawingOp{
+		{opBegin(1, 1), opPoints(pt(2, 2)), opEnd()},
+		{opFill(10, 10), opBegin(4, 4)},
+		{opEnd(), opUndo(), opUndo(), opRedo()},
+		{opClear(), opFill(3, 3), opUndo(), opRedo(), opRedo()},
+	}
+	for _, ops := range packets {
+		_, encoded, err := v.apply(ops, maxDrawingBytesPerTurn)
+		require.NoError(t, err)
+		for _, op := range encoded {
+			h.push(op)
+		}
+		stream = append(stream, encoded...)
+	}
+
+	full, err := drawing.Parse(stream)
+	require.NoError(t, err)
+	compact, err := drawing.Parse(h.visible)
+	require.NoError(t, err)
+	assert.Equal(t, full.Actions(), compact.Actions())
+	assert.Len(t, h.visible, 1)
+}
+
+func TestStrokeHistory_Opaque_Chunks(t *testing.T) {
+	t.Parallel()
+	h := strokeHistory{undone: [][]byte{{1}}}
+
+	assert.True(t, h.pushOpaque([]byte{7}))
+	assert.Empty(t, h.undone)
+	assert.True(t, h.push(drawing.AppendUndo(nil)))
+	assert.Empty(t, h.visible)
+
+	h.push(drawing.AppendBegin(nil, color.RGBA{A: 255}, 1, drawing.Point{}))
+	assert.False(t, h.pushOpaque([]byte{7}), "would split the open stroke")
+}
+
+func TestStrokeHistory_Folds_Old_Strokes_Into_Checkpoints(t *testing.T) {
+	t.Parallel()
+	v := newDrawingValidator()
+	h := strokeHistory{}
+	var stream [][]byte
+
+	for i := range 2000 {
+		ops := []*protobuf.DrawingOp{opBegin(uint32(i%800), 1), opPoints(pt(2, 2), pt(3, 3)), opEnd()}
+		_, encoded, err := v.apply(ops, maxDrawingBytesPerTurn)
+		require.NoError(t, err)
+		for _, op := range encoded {
+			h.push(op)
+		}
+		stream = append(stream, encoded...)
+	}
+
+	size := 0
+	for _, chunk := range h.visible {
+		size += len(chunk)
+	}
+	assert.Equal(t, size, h.size)
+	assert.Equal(t, 2000*21, h.size)
+	assert.Len(t, h.visible, h.base+maxUndoDepth)
+	assert.LessOrEqual(t, h.base, 2000*21/checkpointChunkBytes+1)
+
+	full, err := drawing.Parse(stream)
+	require.NoError(t, err)
+	compact, err := drawing.Parse(h.visible)
+	require.NoError(t, err)
+	assert.Equal(t, full.Actions(), compact.Actions())
+
+	for range maxUndoDepth {
+		require.True(t, h.push(drawing.AppendUndo(nil)))
+	}
+	assert.False(t, h.push(drawing.AppendUndo(nil)), "checkpoints cannot be undone")
+	assert.Len(t, h.visible, h.base)
+
+	h.push(drawing.AppendClear(nil))
+	assert.Zero(t, h.size)
+	assert.Zero(t, h.base)
+}
+
+// BenchmarkInitialRoomSnapshot measures what a player joining late in a long
+// turn receives, with and without checkpoints.
+func BenchmarkInitialRoomSnapshot(b *testing.B) {
+	for _, strokes := range []int{100, 1000, 5000} {
+		for _, compacted := range []bool{false, true} {
+			var history [][]byte
+			h := strokeHistory{}
+			for i := range strokes {
+				stroke := drawing.AppendBegin(nil, color.RGBA{A: 255}, 4, drawing.Point{X: uint16(i % 800), Y: 1})
+				stroke = drawing.AppendPoints(stroke, drawing.Point{X: 2, Y: 2}, drawing.Point{X: 3, Y: 3})
+				stroke = drawing.AppendEnd(stroke)
+				history = append(history, stroke)
+				h.pushOpaque(stroke)
+			}
+			if compacted {
+				history = h.visible
+			}
+
+			b.Run(fmt.Sprintf("strokes=%d/compacted=%t", strokes, compacted), func(b *testing.B) {
+				var size int
+				for b.Loop() {
+					packet := protobuf.MakePacketInitialRoomSnapshot(nil, history, "drawer", 1, "room", int32(PHASE_DRAWING), 0, 15, 60)
+					data, err := proto.Marshal(packet)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = len(data)
+				}
+				b.ReportMetric(float64(size), "snapshot-bytes")
+				b.ReportMetric(float64(len(history)), "chunks")
+			})
+		}
+	}
+}
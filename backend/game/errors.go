@@ -7,4 +7,32 @@ var (
 	ErrRoomFull     = errors.New("room-full")
 )
 
-var ErrSendBufferFull = errors.New("send-buffer-full")
+var (
+	ErrStreamingUnsupported = errors.New("streaming-unsupported")
+	ErrTransportClosed      = errors.New("transport-closed")
+	ErrTransportBusy        = errors.New("transport-busy")
+	ErrUnknownSession       = errors.New("unknown-session")
+)
+
+var (
+	ErrSendBufferFull    = errors.New("send-buffer-full")
+	ErrResyncNeeded      = errors.New("resync-needed")
+	ErrInvalidSendPolicy = errors.New("invalid-send-policy")
+)
+
+// Sent back in ServerPacket.Error, see room.sendError.
+var (
+	ErrUnsupportedVersion = errors.New("unsupported-version")
+	ErrRateLimited        = errors.New("rate-limited")
+	ErrNotHost            = errors.New("not-host")
+	ErrNotYourTurn        = errors.New("not-your-turn")
+	ErrInvalidChoice      = errors.New("invalid-choice")
+	ErrMalformedPacket    = errors.New("malformed-packet")
+)
+
+var (
+	ErrInvalidDrawingOp      = errors.New("invalid-drawing-op")
+	ErrDrawingOpsUnsupported = errors.New("drawing-ops-unsupported")
+	ErrDrawingBudgetExceeded = errors.New("drawing-budget-exceeded")
+	ErrDrawingHistoryFull    = errors.New("drawing-history-full")
+)
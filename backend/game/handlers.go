@@ -4,13 +4,18 @@ import (
 	"api/domain"
 	"context"
 	"errors"
+	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
+// smaller frames cost more to deflate than they save
+const minCompressedFrameBytes = 256
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -24,11 +29,107 @@ func NewGameHandler(
 	lobby Lobby,
 	userGetter UserGetter,
 	randomWordsGenerator RandomWordsGenerator,
+	eventPublisher GameEventPublisher,
+	recorderCreator PacketRecorderCreator,
+	drawingSaver DrawingSaver,
 ) *GameHandler {
 	return &GameHandler{
 		lobby:                lobby,
-		userGetter:           userGetter,
+		userGetter:           guestUsers{userGetter},
 		randomWordsGenerator: randomWordsGenerator,
+		eventPublisher:       eventPublisher,
+		recorderCreator:      recorderCreator,
+		drawingSaver:         drawingSaver,
+		legacyDrawingData:    true,
+		maxDrawingHistory:    defaultMaxDrawingHistoryBytes,
+		sendPolicy:           SendPolicyResync,
+		sseSessions:          newSSESessions(),
+	}
+}
+
+func (gu guestUsers) GetUserById(ctx context.Context, id string) (domain.User, error) {
+	if nickname, ok := domain.GuestNickname(id); ok {
+		return domain.User{Id: id, Username: nickname, Guest: true}, nil
+	}
+	return gu.UserGetter.GetUserById(ctx, id)
+}
+
+// SetLegacyDrawingData is applied to rooms created afterwards, see
+// room.SetLegacyDrawingData.
+func (gh *GameHandler) SetLegacyDrawingData(enabled bool) {
+	gh.legacyDrawingData = enabled
+}
+
+// SetMaxDrawingHistoryBytes is applied to rooms created afterwards, see
+// room.SetMaxDrawingHistoryBytes.
+func (gh *GameHandler) SetMaxDrawingHistoryBytes(n int) {
+	gh.maxDrawingHistory = n
+}
+
+// SetSendPolicy is applied to players joining afterwards.
+func (gh *GameHandler) SetSendPolicy(policy SendPolicy) {
+	gh.sendPolicy = policy
+}
+
+// SetWriteBatchWindow is applied to players joining afterwards, see
+// player.SetWriteBatchWindow.
+func (gh *GameHandler) SetWriteBatchWindow(window time.Duration) {
+	gh.writeBatchWindow = window
+}
+
+// SetCompression lets clients negotiate permessage-deflate.
+func (gh *GameHandler) SetCompression(enabled bool) {
+	gh.compression = enabled
+}
+
+func (gh *GameHandler) upgrader() *websocket.Upgrader {
+	u := upgrader
+	u.EnableCompression = gh.compression
+	u.Subprotocols = Subprotocols
+	return &u
+}
+
+// requestedWireFormat is the format the upgrade will settle on, known before
+// it happens: the first of our subprotocols the client offered. SSE clients
+// have no subprotocol and use the format query parameter.
+func requestedWireFormat(r *http.Request) WireFormat {
+	if isEventStream(r) {
+		if r.URL.Query().Get("format") == "json" {
+			return WireFormatJSON
+		}
+		return WireFormatProtobuf
+	}
+	offered := websocket.Subprotocols(r)
+	for _, subprotocol := range Subprotocols {
+		if slices.Contains(offered, subprotocol) {
+			return WireFormatOf(subprotocol)
+		}
+	}
+	return WireFormatProtobuf
+}
+
+// connect upgrades to a websocket, or opens an event stream for clients
+// that cannot use one.
+func (gh *GameHandler) connect(ctx *gin.Context, userId string) (WebsocketConnection, error) {
+	if isEventStream(ctx.Request) {
+		conn, err := gh.sseSessions.open(ctx.Writer, userId, requestedWireFormat(ctx.Request))
+		if errors.Is(err, ErrStreamingUnsupported) {
+			ctx.String(http.StatusInternalServerError, err.Error())
+		}
+		return conn, err
+	}
+	conn, err := gh.upgrader().Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewGorillaWebSocketWrapper(conn), nil
+}
+
+// hold keeps the handler running as long as a transport that lives in the
+// request does.
+func hold(ctx *gin.Context, conn WebsocketConnection) {
+	if sse, ok := conn.(*SSEConnection); ok {
+		sse.wait(ctx.Request.Context())
 	}
 }
 
@@ -109,12 +210,14 @@ func (gh *GameHandler) CreateGameHandler(ctx *gin.Context) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	wsConn, err := gh.connect(ctx, userIdStr)
 	if err != nil {
 		return
 	}
-	wsConn := NewGorillaWebSocketWrapper(conn)
 	player := NewPlayer(userIdStr, user.Username)
+	player.SetSendPolicy(gh.sendPolicy)
+	player.SetWriteBatchWindow(gh.writeBatchWindow)
+	player.SetWireFormat(requestedWireFormat(ctx.Request))
 
 	room := NewRoom(
 		player,
@@ -126,11 +229,19 @@ func (gh *GameHandler) CreateGameHandler(ctx *gin.Context) {
 		time.Duration(req.DrawingDuration)*time.Second,
 		gh.randomWordsGenerator,
 	)
+	room.SetEventPublisher(gh.eventPublisher)
+	room.SetDrawingSaver(gh.drawingSaver)
+	room.SetLegacyDrawingData(gh.legacyDrawingData)
+	room.SetMaxDrawingHistoryBytes(gh.maxDrawingHistory)
+	if gh.recorderCreator != nil {
+		room.SetRecorder(gh.recorderCreator.Create())
+	}
 
 	gh.lobby.RequestAddAndRunRoom(ctx.Request.Context(), room)
 
 	go player.ReadPump(wsConn)
 	go player.WritePump(wsConn)
+	hold(ctx, wsConn)
 }
 
 func (gh *GameHandler) JoinGameHandler(ctx *gin.Context) {
@@ -163,6 +274,9 @@ func (gh *GameHandler) JoinGameHandler(ctx *gin.Context) {
 	}
 
 	player := NewPlayer(userIdStr, user.Username)
+	player.SetSendPolicy(gh.sendPolicy)
+	player.SetWriteBatchWindow(gh.writeBatchWindow)
+	player.SetWireFormat(requestedWireFormat(ctx.Request))
 
 	errChan := make(chan error, 1)
 	joinReq := roomJoinRequest{
@@ -180,14 +294,53 @@ func (gh *GameHandler) JoinGameHandler(ctx *gin.Context) {
 		ctx.Abort()
 		return
 	}
-	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	wsConn, err := gh.connect(ctx, userIdStr)
 	if err != nil {
 		return
 	}
 
-	wsConn := NewGorillaWebSocketWrapper(conn)
 	go player.ReadPump(wsConn)
 	go player.WritePump(wsConn)
+	hold(ctx, wsConn)
+}
+
+// SSEPostHandler takes one packet from an SSE client, in the body as its
+// wire format encodes it, and hands it to the session's read pump.
+func (gh *GameHandler) SSEPostHandler(ctx *gin.Context) {
+	userId, exists := ctx.Get("id")
+	if !exists {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	userIdStr, ok := userId.(string)
+	if !ok {
+		ctx.String(http.StatusInternalServerError, "invalid-user-id")
+		return
+	}
+
+	conn, ok := gh.sseSessions.get(ctx.Param("token"), userIdStr)
+	if !ok {
+		ctx.String(http.StatusNotFound, ErrUnknownSession.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSSEPacketBytes))
+	if err != nil {
+		ctx.String(http.StatusRequestEntityTooLarge, "packet-too-large")
+		return
+	}
+
+	err = conn.deliver(data)
+	if errors.Is(err, ErrTransportBusy) {
+		ctx.String(http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		ctx.String(http.StatusNotFound, ErrUnknownSession.Error())
+		return
+	}
+	ctx.Status(http.StatusNoContent)
 }
 
 type PublicGameResponse struct {
@@ -227,15 +380,28 @@ func (gh *GameHandler) GetPublicGamesHandler(ctx *gin.Context) {
 }
 
 func NewGorillaWebSocketWrapper(conn *websocket.Conn) *GorillaWebSocketWrapper {
-	return &GorillaWebSocketWrapper{conn: conn}
+	messageType := websocket.BinaryMessage
+	if WireFormatOf(conn.Subprotocol()) == WireFormatJSON {
+		messageType = websocket.TextMessage
+	}
+	return &GorillaWebSocketWrapper{conn: conn, messageType: messageType}
 }
 
 func (g *GorillaWebSocketWrapper) Close() {
 	g.conn.Close()
 }
 
+// Write only deflates frames big enough to gain from it, when the client
+// negotiated compression at all.
 func (g *GorillaWebSocketWrapper) Write(data []byte) error {
-	return g.conn.WriteMessage(websocket.BinaryMessage, data)
+	g.conn.EnableWriteCompression(len(data) >= minCompressedFrameBytes)
+	return g.conn.WriteMessage(g.messageType, data)
+}
+
+func (g *GorillaWebSocketWrapper) CloseWith(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	g.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	g.conn.Close()
 }
 
 func (g *GorillaWebSocketWrapper) Read() ([]byte, error) {
@@ -2,10 +2,16 @@ package game
 
 import (
 	"api/domain"
+	"api/domain/protobuf"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +19,8 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestCreateGameHandler_Validation(t *testing.T) {
@@ -157,7 +165,7 @@ func TestCreateGameHandler_Validation(t *testing.T) {
 
 			tc.setupMocks(mockLobby, mockUserGetter)
 
-			handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+			handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 			router := gin.New()
 			router.GET("/create", func(c *gin.Context) {
@@ -242,7 +250,7 @@ func TestJoinGameHandler_Validation(t *testing.T) {
 
 			tc.setupMocks(mockLobby, mockUserGetter)
 
-			handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+			handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 			router := gin.New()
 			router.GET("/join/:roomid", func(c *gin.Context) {
@@ -366,6 +374,112 @@ func TestGorillaWebSocketWrapper(t *testing.T) {
 		_, _, err = conn.ReadMessage()
 		assert.Error(t, err)
 	})
+
+	t.Run("close with code", func(t *testing.T) {
+		t.Parallel()
+
+		conn, _ := dialWrapper(t, false, func(wrapper *GorillaWebSocketWrapper) {
+			wrapper.CloseWith(CloseUnsupportedVersion, "unsupported-version")
+		})
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, CloseUnsupportedVersion), err)
+	})
+
+	t.Run("compressed write", func(t *testing.T) {
+		t.Parallel()
+
+		payload := bytes.Repeat([]byte("stroke "), 1024)
+		conn, wire := dialWrapper(t, true, func(wrapper *GorillaWebSocketWrapper) {
+			wrapper.Write(payload)
+		})
+
+		_, msg, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, payload, msg)
+		assert.Less(t, wire.Load(), int64(len(payload)/4))
+	})
+}
+
+// countingConn counts what the client reads off the wire.
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// dialWrapper connects to a GameHandler upgrader and hands the server side,
+// wrapped, to serve.
+func dialWrapper(tb testing.TB, compression bool, serve func(wrapper *GorillaWebSocketWrapper)) (*websocket.Conn, *atomic.Int64) {
+	tb.Helper()
+	gh := NewGameHandler(nil, nil, nil, nil, nil, nil)
+	gh.SetCompression(compression)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := gh.upgrader().Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(NewGorillaWebSocketWrapper(conn))
+	}))
+	tb.Cleanup(server.Close)
+
+	wire := &atomic.Int64{}
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			return countingConn{Conn: conn, read: wire}, err
+		},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	return conn, wire
+}
+
+// BenchmarkGorillaWebSocketWrapper_Write writes batch frames of drawing ops,
+// reporting what reaches the client.
+func BenchmarkGorillaWebSocketWrapper_Write(b *testing.B) {
+	var frame []byte
+	for i := range 64 {
+		packet, _ := proto.Marshal(protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{
+			opBegin(uint32(i), uint32(i)), opPoints(pt(uint32(i)+1, 2), pt(uint32(i)+2, 3), pt(uint32(i)+3, 4)), opEnd(),
+		}))
+		frame = protobuf.AppendToBatch(frame, packet)
+	}
+
+	for _, compression := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compression=%t", compression), func(b *testing.B) {
+			frames := make(chan struct{})
+			conn, wire := dialWrapper(b, compression, func(wrapper *GorillaWebSocketWrapper) {
+				for range frames {
+					if wrapper.Write(frame) != nil {
+						return
+					}
+				}
+			})
+			defer close(frames)
+
+			b.SetBytes(int64(len(frame)))
+			for b.Loop() {
+				frames <- struct{}{}
+				if _, _, err := conn.ReadMessage(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(wire.Load())/float64(b.N), "wire-bytes/op")
+		})
+	}
 }
 
 func TestCreateGameHandler_Success(t *testing.T) {
@@ -384,7 +498,7 @@ func TestCreateGameHandler_Success(t *testing.T) {
 		assert.True(t, desc.private)
 	}).Return()
 
-	handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+	handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -435,7 +549,7 @@ func TestJoinGameHandler_Success(t *testing.T) {
 		close(req.errChan)
 	}).Return()
 
-	handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+	handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -485,7 +599,7 @@ func TestGameHandler_GetPublicGamesHandler(t *testing.T) {
 
 		mockLobby.On("GetPublicGames", mock.Anything).Return(expectedGames)
 
-		handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+		handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 		router := gin.New()
 		router.GET("/games", func(c *gin.Context) {
@@ -521,7 +635,7 @@ func TestGameHandler_GetPublicGamesHandler(t *testing.T) {
 
 		mockLobby.On("GetPublicGames", mock.Anything).Return([]roomDescription{})
 
-		handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen)
+		handler := NewGameHandler(mockLobby, mockUserGetter, mockWordGen, nil, nil, nil)
 
 		router := gin.New()
 		router.GET("/games", func(c *gin.Context) {
@@ -540,3 +654,80 @@ func TestGameHandler_GetPublicGamesHandler(t *testing.T) {
 		mockLobby.AssertExpectations(t)
 	})
 }
+
+func TestGorillaWebSocketWrapper_Frame_Type_Follows_Subprotocol(t *testing.T) {
+	testCases := []struct {
+		subprotocols []string
+		messageType  int
+	}{
+		{nil, websocket.BinaryMessage},
+		{[]string{SubprotocolProtobuf}, websocket.BinaryMessage},
+		{[]string{SubprotocolJSON}, websocket.TextMessage},
+	}
+	gh := NewGameHandler(nil, nil, nil, nil, nil, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := gh.upgrader().Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		NewGorillaWebSocketWrapper(conn).Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		dialer := websocket.Dialer{Subprotocols: tc.subprotocols}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		require.NoError(t, err)
+		messageType, _, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, tc.messageType, messageType, tc.subprotocols)
+		conn.Close()
+	}
+}
+
+func TestGuestUsers_Resolves_Guests_Without_A_Row(t *testing.T) {
+	t.Parallel()
+	mockUserGetter := &MockUserGetter{}
+	mockUserGetter.On("GetUserById", mock.Anything, "user-123").Return(domain.User{Id: "user-123", Username: "oussama"}, nil)
+	users := guestUsers{mockUserGetter}
+
+	user, err := users.GetUserById(context.Background(), "guest:guest_0a1b2c3d")
+	require.NoError(t, err)
+	assert.Equal(t, domain.User{Id: "guest:guest_0a1b2c3d", Username: "guest_0a1b2c3d", Guest: true}, user)
+
+	user, err = users.GetUserById(context.Background(), "user-123")
+	require.NoError(t, err)
+	assert.Equal(t, "oussama", user.Username)
+	mockUserGetter.AssertNumberOfCalls(t, "GetUserById", 1)
+}
+
+func TestSSEPostHandler_Rejects_Other_Sessions(t *testing.T) {
+	gh := NewGameHandler(nil, nil, nil, nil, nil, nil)
+	recorder := httptest.NewRecorder()
+	conn, err := gh.sseSessions.open(recorder, "owner", WireFormatProtobuf)
+	require.NoError(t, err)
+	assert.Contains(t, recorder.Body.String(), "event: session\ndata: "+conn.token+"\n\n")
+
+	post := func(userId, token string, body []byte) int {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("POST", "/sse/"+token, bytes.NewReader(body))
+		ctx.Params = gin.Params{{Key: "token", Value: token}}
+		ctx.Set("id", userId)
+		gh.SSEPostHandler(ctx)
+		ctx.Writer.WriteHeaderNow()
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, post("intruder", conn.token, []byte{1}))
+	assert.Equal(t, http.StatusNotFound, post("owner", "guessed", []byte{1}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("owner", conn.token, make([]byte, maxSSEPacketBytes+1)))
+	for range sseInboxSize {
+		require.Equal(t, http.StatusNoContent, post("owner", conn.token, []byte{1}))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, post("owner", conn.token, []byte{1}))
+
+	conn.Close()
+	assert.Equal(t, http.StatusNotFound, post("owner", conn.token, []byte{1}), "the token dies with the stream")
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"fmt"
+)
+
+const (
+	// ProtocolVersion is the newest version the server speaks, the first
+	// one with a handshake.
+	ProtocolVersion uint32 = 2
+	// MinProtocolVersion is the oldest version still served, 1 being the
+	// clients from before the handshake.
+	MinProtocolVersion uint32 = 1
+	// CloseUnsupportedVersion is the websocket close code following an
+	// unsupported-version error.
+	CloseUnsupportedVersion = 4001
+)
+
+// Optional features a client lists in its Hello, the Welcome lists those the
+// server agreed to.
+const (
+	// typed DrawingOps, refused while a player without it is in the room
+	FeatureDrawingOps = "drawing-ops"
+	// several packets per frame in ServerPacket.batch
+	FeatureBatching = "batching"
+)
+
+// features is the set negotiated with one player.
+type features uint8
+
+const (
+	featureDrawingOps features = 1 << iota
+	featureBatching
+)
+
+var featureNames = []struct {
+	name string
+	flag features
+}{
+	{FeatureDrawingOps, featureDrawingOps},
+	{FeatureBatching, featureBatching},
+}
+
+func (f features) has(flag features) bool {
+	return f&flag != 0
+}
+
+func (f features) names() []string {
+	names := []string{}
+	for _, feature := range featureNames {
+		if f.has(feature.flag) {
+			names = append(names, feature.name)
+		}
+	}
+	return names
+}
+
+// negotiate picks the newest version both sides speak and the features
+// both support out of those the server offers. Version 1 has none.
+func negotiate(hello *protobuf.ClientPacket_Hello, offered features) (uint32, features, error) {
+	version := min(hello.GetProtocolVersion(), ProtocolVersion)
+	if version < max(hello.GetMinProtocolVersion(), MinProtocolVersion) {
+		return 0, 0, ErrUnsupportedVersion
+	}
+	if version < 2 {
+		return version, 0, nil
+	}
+
+	var agreed features
+	for _, capability := range hello.GetCapabilities() {
+		for _, feature := range featureNames {
+			if feature.name == capability {
+				agreed |= feature.flag & offered
+			}
+		}
+	}
+	return version, agreed, nil
+}
+
+// handshake answers a Hello, queueing either the Welcome or the error and
+// the close that follows it.
+func (p *player) handshake(hello *protobuf.ClientPacket_Hello) (features, error) {
+	offered := featureDrawingOps
+	// batch frames are protobuf only
+	if p.batchWindow > 0 && p.wireFormat == WireFormatProtobuf {
+		offered |= featureBatching
+	}
+
+	version, agreed, err := negotiate(hello, offered)
+	if err != nil {
+		message := fmt.Sprintf("protocol versions %d to %d are supported", MinProtocolVersion, ProtocolVersion)
+		p.sendPacket(protobuf.MakePacketError(err.Error(), message))
+		p.outbox.closeAfterFlush(CloseUnsupportedVersion, err.Error())
+		return 0, err
+	}
+
+	p.batching.Store(agreed.has(featureBatching))
+	p.sendPacket(protobuf.MakePacketWelcome(version, agreed.names()))
+	return agreed, nil
+}
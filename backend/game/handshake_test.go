@@ -0,0 +1,182 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func helloPacket(version, minVersion uint32, capabilities ...string) []byte {
+	data, _ := proto.Marshal(&protobuf.ClientPacket{
+		Payload: &protobuf.ClientPacket_Hello_{Hello: &protobuf.ClientPacket_Hello{
+			ProtocolVersion:    version,
+			MinProtocolVersion: minVersion,
+			Capabilities:       capabilities,
+		}},
+	})
+	return data
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		hello    *protobuf.ClientPacket_Hello
+		offered  features
+		version  uint32
+		features features
+		err      error
+	}{
+		{
+			name:     "current version",
+			hello:    &protobuf.ClientPacket_Hello{ProtocolVersion: 2, MinProtocolVersion: 2, Capabilities: []string{"drawing-ops", "batching", "hints"}},
+			offered:  featureDrawingOps | featureBatching,
+			version:  2,
+			features: featureDrawingOps | featureBatching,
+		},
+		{
+			name:     "only offered features",
+			hello:    &protobuf.ClientPacket_Hello{ProtocolVersion: 2, Capabilities: []string{"batching"}},
+			offered:  featureDrawingOps,
+			version:  2,
+			features: 0,
+		},
+		{
+			name:     "newer client falls back",
+			hello:    &protobuf.ClientPacket_Hello{ProtocolVersion: 7, MinProtocolVersion: 1, Capabilities: []string{"drawing-ops"}},
+			offered:  featureDrawingOps,
+			version:  2,
+			features: featureDrawingOps,
+		},
+		{
+			name:    "version 1 has no features",
+			hello:   &protobuf.ClientPacket_Hello{ProtocolVersion: 1, Capabilities: []string{"drawing-ops"}},
+			offered: featureDrawingOps,
+			version: 1,
+		},
+		{
+			name:  "client too new",
+			hello: &protobuf.ClientPacket_Hello{ProtocolVersion: 5, MinProtocolVersion: 4},
+			err:   ErrUnsupportedVersion,
+		},
+		{
+			name:  "client too old",
+			hello: &protobuf.ClientPacket_Hello{ProtocolVersion: 0},
+			err:   ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			version, agreed, err := negotiate(tc.hello, tc.offered)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.version, version)
+			assert.Equal(t, tc.features, agreed)
+		})
+	}
+}
+
+func TestReadPump_Handshake(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Welcome", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		mockRoom := &MockRoom{}
+		p := NewPlayer("id", "username")
+		p.SetWriteBatchWindow(1)
+		p.SetRoom(mockRoom)
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		mockSocket.On("Read").Return(helloPacket(2, 2, "drawing-ops", "batching"), nil).Twice()
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+		mockRoom.On("Send", p.ctx, mock.Anything).Run(func(args mock.Arguments) {
+			envelope := args.Get(1).(ClientPacketEnvelope)
+			assert.Equal(t, featureDrawingOps|featureBatching, envelope.features)
+		}).Return().Once()
+
+		p.ReadPump(mockSocket)
+
+		mockRoom.AssertExpectations(t)
+		assert.True(t, p.batching.Load())
+		out := drainQueue(&p.outbox)
+		require.Len(t, out, 1, "a second Hello is ignored")
+		welcome := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(out[0], welcome))
+		assert.Equal(t, ProtocolVersion, welcome.GetWelcome().GetProtocolVersion())
+		assert.Equal(t, []string{FeatureDrawingOps, FeatureBatching}, welcome.GetWelcome().GetFeatures())
+	})
+
+	t.Run("Hello After Another Packet", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		mockRoom := &MockRoom{}
+		p := NewPlayer("id", "username")
+		p.SetRoom(mockRoom)
+		start, _ := proto.Marshal(&protobuf.ClientPacket{Payload: &protobuf.ClientPacket_StartGame_{StartGame: &protobuf.ClientPacket_StartGame{}}})
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		mockSocket.On("Read").Return(start, nil).Once()
+		mockSocket.On("Read").Return(helloPacket(2, 2), nil).Once()
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+		mockRoom.On("Send", p.ctx, mock.Anything).Return().Once()
+
+		p.ReadPump(mockSocket)
+
+		mockRoom.AssertExpectations(t)
+		assert.Empty(t, drainQueue(&p.outbox), "legacy clients get no Welcome")
+	})
+
+	t.Run("Unsupported Version", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		mockRoom := &MockRoom{}
+		p := NewPlayer("id", "username")
+		p.SetRoom(mockRoom)
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		mockSocket.On("Read").Return(helloPacket(9, 9), nil).Once()
+		mockSocket.On("Read").Return(helloPacket(2, 2), nil).Once()
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+
+		p.ReadPump(mockSocket)
+
+		mockRoom.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+		out := drainQueue(&p.outbox)
+		require.Len(t, out, 1)
+		errPacket := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(out[0], errPacket))
+		assert.Equal(t, ErrUnsupportedVersion.Error(), errPacket.GetError().GetCode())
+		code, _, ok := p.outbox.closing()
+		assert.True(t, ok)
+		assert.Equal(t, CloseUnsupportedVersion, code)
+	})
+}
+
+func TestWritePump_Closes_After_Flush(t *testing.T) {
+	t.Parallel()
+	mockSocket := &MockWebsocketConnection{}
+	data := []byte{1, 2, 3}
+	mockSocket.On("Write", data).Return(nil).Once()
+	mockSocket.On("CloseWith", CloseUnsupportedVersion, "unsupported-version").Return().Once()
+	mockSocket.On("Close").Return().Once()
+
+	p := NewPlayer("id", "username")
+	p.Send(data, packetControl)
+	p.outbox.closeAfterFlush(CloseUnsupportedVersion, "unsupported-version")
+	assert.ErrorIs(t, p.Send(data, packetControl), ErrSendBufferFull)
+
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		p.WritePump(mockSocket)
+	})
+	wg.Wait()
+
+	mockSocket.AssertExpectations(t)
+}
@@ -3,9 +3,11 @@ package game
 import (
 	"api/domain"
 	"context"
+	"sync"
 	"time"
 
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/time/rate"
 )
 
 // --- WebsocketConnection ---
@@ -18,6 +20,10 @@ func (m *MockWebsocketConnection) Close() {
 	m.Called()
 }
 
+func (m *MockWebsocketConnection) CloseWith(code int, reason string) {
+	m.Called(code, reason)
+}
+
 func (m *MockWebsocketConnection) Write(data []byte) error {
 	args := m.Called(data)
 	return args.Error(0)
@@ -81,14 +87,51 @@ func (m *MockUserGetter) GetUserById(ctx context.Context, id string) (domain.Use
 	return args.Get(0).(domain.User), args.Error(1)
 }
 
+// --- GameEventPublisher ---
+
+type MockGameEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockGameEventPublisher) Publish(e domain.GameEvent) {
+	m.Called(e)
+}
+
+// --- DrawingSaver ---
+
+type MockDrawingSaver struct {
+	mock.Mock
+}
+
+func (m *MockDrawingSaver) SaveDrawing(d domain.Drawing) {
+	m.Called(d)
+}
+
+// --- PacketRecorder ---
+
+type MockPacketRecorder struct {
+	mock.Mock
+}
+
+func (m *MockPacketRecorder) Record(at time.Time, packet []byte) {
+	m.Called(at, packet)
+}
+
+func (m *MockPacketRecorder) Finish(replay domain.Replay) {
+	m.Called(replay)
+}
+
 // --- Player ---
 
 type MockPlayer struct {
 	mock.Mock
+	format       WireFormat
+	errorsOnce   sync.Once
+	errorLimiter *rate.Limiter
 }
 
-func (m *MockPlayer) Send(data []byte) error {
-	args := m.Called(data)
+func (m *MockPlayer) Send(data []byte, kind packetKind) error {
+	args := m.Called(data, kind)
 	return args.Error(0)
 }
 
@@ -110,6 +153,23 @@ func (m *MockPlayer) Username() string {
 	return args.String(0)
 }
 
+func (m *MockPlayer) Id() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+// WireFormat is a field rather than an expectation, every room test would
+// need one otherwise.
+func (m *MockPlayer) WireFormat() WireFormat {
+	return m.format
+}
+
+// AllowError is the real budget for the same reason.
+func (m *MockPlayer) AllowError() bool {
+	m.errorsOnce.Do(func() { m.errorLimiter = newErrorLimiter() })
+	return m.errorLimiter.Allow()
+}
+
 // --- Room ---
 
 type MockRoom struct {
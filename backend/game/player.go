@@ -3,45 +3,78 @@ package game
 import (
 	"api/domain/protobuf"
 	"context"
+	"time"
 
 	"golang.org/x/time/rate"
-	"google.golang.org/protobuf/proto"
 )
 
 func NewPlayer(id string, username string) *player {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &player{
-		id:          id,
-		username:    username,
-		rateLimiter: *rate.NewLimiter(rate.Limit(2), 5),
-		pingChan:    make(chan struct{}, 1),
-		inbox:       make(chan []byte, 1024),
-		ctx:         ctx,
-		cancelCtx:   cancel,
+		id:           id,
+		username:     username,
+		rateLimiter:  *rate.NewLimiter(rate.Limit(2), 5),
+		errorLimiter: *newErrorLimiter(),
+		codec:        codecs[WireFormatProtobuf],
+		// enough for a handful of samples at once, then one a second
+		timeSyncLimiter: *rate.NewLimiter(rate.Limit(1), 8),
+		sendPolicy:      SendPolicyResync,
+		outbox:          newSendQueue(),
+		pingChan:        make(chan struct{}, 1),
+		ctx:             ctx,
+		cancelCtx:       cancel,
 	}
 }
 
 func (p *player) ReadPump(socket WebsocketConnection) {
 	defer socket.Close()
 	defer p.cancelCtx()
+	// a Hello only counts as the first packet, once rejected the write
+	// pump closes the connection and nothing else is read
+	first, rejected := true, false
 	for {
 
 		data, err := socket.Read()
+		receivedAt := time.Now().UnixMilli()
 		if err != nil {
 			p.room.RemoveMe(p.ctx, p)
 			return
 		}
+		if rejected {
+			continue
+		}
 		packet := &protobuf.ClientPacket{}
-		err = proto.Unmarshal(data, packet)
+		err = p.codec.unmarshal(data, packet)
 		if err != nil {
+			p.sendError(ErrMalformedPacket)
 			continue
 		}
-		if _, ok := packet.Payload.(*protobuf.ClientPacket_PlayerMessage_); ok {
+		envelope := ClientPacketEnvelope{clientPacket: packet, from: p.username}
+		switch payload := packet.Payload.(type) {
+		case *protobuf.ClientPacket_Hello_:
+			if !first {
+				continue
+			}
+			first = false
+			envelope.features, err = p.handshake(payload.Hello)
+			if err != nil {
+				rejected = true
+				continue
+			}
+		case *protobuf.ClientPacket_TimeSync_:
+			// answered here, the room's inbox would only add to the sample
+			if p.timeSyncLimiter.Allow() {
+				p.sendPacket(protobuf.MakePacketTimeSync(payload.TimeSync.ClientTime, receivedAt))
+			}
+			continue
+		case *protobuf.ClientPacket_PlayerMessage_, *protobuf.ClientPacket_Resume_:
+			// a resume costs a replay or a snapshot, it shares the chat's budget
 			if !p.rateLimiter.Allow() {
+				p.sendError(ErrRateLimited)
 				continue
 			}
 		}
-		envelope := ClientPacketEnvelope{clientPacket: packet, from: p.username}
+		first = false
 		p.room.Send(p.ctx, envelope)
 		select {
 		case <-p.ctx.Done():
@@ -56,13 +89,33 @@ func (p *player) WritePump(socket WebsocketConnection) {
 	defer p.cancelCtx()
 	for {
 		select {
-		case marshalledServerPacket, ok := <-p.inbox:
+		case _, ok := <-p.outbox.wake:
 			if !ok {
 				return
 			}
-			err := socket.Write(marshalledServerPacket)
-			if err != nil {
-				p.room.RemoveMe(p.ctx, p)
+			if p.batching.Load() {
+				// let the rest of a burst queue up behind the first packet
+				timer := time.NewTimer(p.batchWindow)
+				select {
+				case <-timer.C:
+				case <-p.ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+			for {
+				marshalledServerPacket, ok := p.nextFrame()
+				if !ok {
+					break
+				}
+				err := socket.Write(marshalledServerPacket)
+				if err != nil {
+					p.room.RemoveMe(p.ctx, p)
+					return
+				}
+			}
+			if code, reason, ok := p.outbox.closing(); ok {
+				socket.CloseWith(code, reason)
 				return
 			}
 		case _, ok := <-p.pingChan:
@@ -80,15 +133,72 @@ func (p *player) WritePump(socket WebsocketConnection) {
 	}
 }
 
-func (p *player) Send(data []byte) error {
-	select {
-	case p.inbox <- data:
-		return nil
-	default:
-		return ErrSendBufferFull
+// Send queues a marshalled ServerPacket, what happens when the queue is full
+// depends on the send policy and on the kind of packet.
+func (p *player) Send(data []byte, kind packetKind) error {
+	if kind == packetDrawingOps && p.wireFormat != WireFormatProtobuf {
+		// ops are merged by concatenating protobuf bytes
+		kind = packetDrawing
+	}
+	return p.outbox.push(data, kind, p.sendPolicy)
+}
+
+// SetWireFormat must be called before the pumps start.
+func (p *player) SetWireFormat(format WireFormat) {
+	p.wireFormat = format
+	p.codec = codecs[format]
+}
+
+func (p *player) WireFormat() WireFormat {
+	return p.wireFormat
+}
+
+// SetSendPolicy must be called before the player joins a room.
+func (p *player) SetSendPolicy(policy SendPolicy) {
+	p.sendPolicy = policy
+}
+
+// SetWriteBatchWindow makes the write pump wait that long after a packet is
+// queued and write everything queued by then as one batch frame, for
+// clients that agreed to batching in their Hello. Zero writes every packet
+// as its own frame. It must be called before the pumps start.
+func (p *player) SetWriteBatchWindow(window time.Duration) {
+	p.batchWindow = window
+}
+
+func (p *player) nextFrame() ([]byte, bool) {
+	if p.batching.Load() {
+		return p.outbox.popBatch()
 	}
+	return p.outbox.pop()
+}
 
+// sendPacket queues a packet of the player's own, from outside the room.
+func (p *player) sendPacket(serverPacket *protobuf.ServerPacket) {
+	data, err := p.codec.marshal(serverPacket)
+	if err != nil {
+		return
+	}
+	p.Send(data, packetControl)
 }
+
+// sendError is room.sendError for what the player rejects itself.
+func (p *player) sendError(err error) {
+	if p.AllowError() {
+		p.sendPacket(protobuf.MakePacketError(err.Error(), ""))
+	}
+}
+
+func (p *player) AllowError() bool {
+	return p.errorLimiter.Allow()
+}
+
+// newErrorLimiter is the error budget of a player, one a second with bursts
+// of five.
+func newErrorLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(1), 5)
+}
+
 func (p *player) Ping() error {
 	select {
 	case p.pingChan <- struct{}{}:
@@ -101,8 +211,7 @@ func (p *player) SetRoom(r Room) {
 	p.room = r
 }
 func (p *player) CancelAndRelease() {
-	close(p.inbox)
-	p.inbox = nil
+	p.outbox.release()
 	close(p.pingChan)
 	p.pingChan = nil
 	p.cancelCtx()
@@ -111,3 +220,7 @@ func (p *player) CancelAndRelease() {
 func (p *player) Username() string {
 	return p.username
 }
+
+func (p *player) Id() string {
+	return p.id
+}
@@ -2,14 +2,25 @@ package game
 
 import (
 	"api/domain/protobuf"
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 )
@@ -120,6 +131,35 @@ func TestReadPump(t *testing.T) {
 		mockSocket.AssertExpectations(t)
 	})
 
+	t.Run("Malformed And Rate Limited Packets Get Errors", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		p := NewPlayer("id", "username")
+		mockRoom := &MockRoom{}
+		p.SetRoom(mockRoom)
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		mockRoom.On("Send", p.ctx, mock.Anything).Return()
+		chat, _ := proto.Marshal(&protobuf.ClientPacket{Payload: &protobuf.ClientPacket_PlayerMessage_{
+			PlayerMessage: &protobuf.ClientPacket_PlayerMessage{Message: "spam"},
+		}})
+		mockSocket.On("Read").Return([]byte{1, 5}, nil).Once()
+		mockSocket.On("Read").Return(chat, nil).Times(50)
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+
+		p.ReadPump(mockSocket)
+
+		codes := []string{}
+		for _, data := range drainQueue(&p.outbox) {
+			packet := &protobuf.ServerPacket{}
+			assert.NoError(t, proto.Unmarshal(data, packet))
+			codes = append(codes, packet.GetError().GetCode())
+		}
+		assert.Equal(t, ErrMalformedPacket.Error(), codes[0])
+		assert.Contains(t, codes, ErrRateLimited.Error())
+		assert.LessOrEqual(t, len(codes), 6, "errors have a budget of their own")
+	})
+
 	t.Run("Read good data", func(t *testing.T) {
 		t.Parallel()
 		mockSocket := &MockWebsocketConnection{}
@@ -215,6 +255,77 @@ func TestReadPump(t *testing.T) {
 		mockSocket.AssertExpectations(t)
 	})
 
+	t.Run("Reads JSON With The JSON Codec", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		p := NewPlayer("id", "username")
+		p.SetWireFormat(WireFormatJSON)
+		mockRoom := &MockRoom{}
+		p.SetRoom(mockRoom)
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		mockRoom.On("Send", p.ctx, mock.MatchedBy(func(e ClientPacketEnvelope) bool {
+			return e.clientPacket.GetPlayerMessage().GetMessage() == "hi"
+		})).Return().Once()
+		mockSocket.On("Read").Return([]byte(`{"playerMessage":{"message":"hi"}}`), nil).Once()
+		mockSocket.On("Read").Return([]byte{1, 5}, nil).Once()
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+
+		p.ReadPump(mockSocket)
+
+		mockRoom.AssertExpectations(t)
+		out := drainQueue(&p.outbox)
+		require.Len(t, out, 1)
+		packet := &protobuf.ServerPacket{}
+		require.NoError(t, unmarshalServerPacket(WireFormatJSON, out[0], packet))
+		assert.Equal(t, ErrMalformedPacket.Error(), packet.GetError().GetCode())
+	})
+
+	t.Run("Time Sync Is Answered Without The Room", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		p := NewPlayer("id", "username")
+		mockRoom := &MockRoom{}
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+		p.SetRoom(mockRoom)
+		sample, _ := proto.Marshal(&protobuf.ClientPacket{Payload: &protobuf.ClientPacket_TimeSync_{
+			TimeSync: &protobuf.ClientPacket_TimeSync{ClientTime: 42},
+		}})
+		mockSocket.On("Read").Return(sample, nil).Times(20)
+		mockSocket.On("Read").Return([]byte{}, assert.AnError).Once()
+		mockSocket.On("Close").Return()
+
+		before := time.Now().UnixMilli()
+		p.ReadPump(mockSocket)
+		after := time.Now().UnixMilli()
+
+		answers := drainQueue(&p.outbox)
+		assert.NotEmpty(t, answers)
+		assert.LessOrEqual(t, len(answers), 9, "samples are rate limited")
+		for _, data := range answers {
+			packet := &protobuf.ServerPacket{}
+			assert.NoError(t, proto.Unmarshal(data, packet))
+			answer := packet.GetTimeSync()
+			assert.Equal(t, int64(42), answer.GetClientTime())
+			assert.LessOrEqual(t, before, answer.GetServerReceiveTime())
+			assert.LessOrEqual(t, answer.GetServerReceiveTime(), answer.GetServerSendTime())
+			assert.LessOrEqual(t, answer.GetServerSendTime(), after)
+		}
+		mockRoom.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPlayer_Send_Does_Not_Merge_JSON(t *testing.T) {
+	t.Parallel()
+	p := NewPlayer("id", "username")
+	p.SetWireFormat(WireFormatJSON)
+	first, _ := codecs[WireFormatJSON].marshal(protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{opFill(1, 1)}))
+	second, _ := codecs[WireFormatJSON].marshal(protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{opFill(2, 2)}))
+
+	require.NoError(t, p.Send(first, packetDrawingOps))
+	require.NoError(t, p.Send(second, packetDrawingOps))
+
+	assert.Equal(t, [][]byte{first, second}, drainQueue(&p.outbox))
 }
 
 func TestWritePump(t *testing.T) {
@@ -256,7 +367,7 @@ func TestWritePump(t *testing.T) {
 			p.WritePump(mockSocket)
 		})
 
-		p.Send(data)
+		p.Send(data, packetControl)
 		wg.Wait()
 
 		mockSocket.AssertExpectations(t)
@@ -284,13 +395,45 @@ func TestWritePump(t *testing.T) {
 		wg.Go(func() {
 			p.WritePump(mockSocket)
 		})
-		p.Send(data)
-		p.Send(data)
+		p.Send(data, packetControl)
+		p.Send(data, packetControl)
 		wg.Wait()
 
 		mockSocket.AssertExpectations(t)
 	})
 
+	t.Run("Batches Queued Packets", func(t *testing.T) {
+		t.Parallel()
+		mockSocket := &MockWebsocketConnection{}
+		first, _ := proto.Marshal(protobuf.MakePacketPlayerJoined("a"))
+		second, _ := proto.Marshal(protobuf.MakePacketPlayerJoined("b"))
+		frame := protobuf.AppendToBatch(protobuf.AppendToBatch(nil, first), second)
+
+		mockSocket.On("Write", frame).Return(assert.AnError).Once()
+		mockSocket.On("Close").Return().Once()
+
+		p := NewPlayer("id", "username")
+		p.SetWriteBatchWindow(50 * time.Millisecond)
+		p.batching.Store(true)
+		mockRoom := &MockRoom{}
+		p.SetRoom(mockRoom)
+		mockRoom.On("RemoveMe", p.ctx, p).Return()
+
+		p.Send(first, packetControl)
+		wg := sync.WaitGroup{}
+		wg.Go(func() {
+			p.WritePump(mockSocket)
+		})
+		p.Send(second, packetControl)
+		wg.Wait()
+
+		mockSocket.AssertExpectations(t)
+		batch := &protobuf.ServerPacket{}
+		assert.NoError(t, proto.Unmarshal(frame, batch))
+		assert.Len(t, batch.Batch, 2)
+		AssertProtoEq(t, protobuf.MakePacketPlayerJoined("b").Payload, batch.Batch[1].Payload)
+	})
+
 	t.Run("Correct Ping Writing", func(t *testing.T) {
 		t.Parallel()
 		mockSocket := &MockWebsocketConnection{}
@@ -337,3 +480,337 @@ func TestWritePump(t *testing.T) {
 		mockSocket.AssertExpectations(t)
 	})
 }
+
+// transportEvent is what the client end of a transport got: a packet, a
+// ping or the close.
+type transportEvent struct {
+	kind   string
+	data   []byte
+	code   int
+	reason string
+}
+
+type transportClient interface {
+	send(t *testing.T, data []byte)
+	next(t *testing.T) transportEvent
+	close()
+}
+
+// transports hands out the server end, what the player pumps run on, and
+// the client end of a real connection.
+var transports = []struct {
+	name string
+	dial func(t *testing.T, format WireFormat) (WebsocketConnection, transportClient)
+}{
+	{"websocket", dialWebsocketTransport},
+	{"sse", dialSSETransport},
+}
+
+type eventsClient struct {
+	events chan transportEvent
+	sendFn func(t *testing.T, data []byte)
+	closer func()
+}
+
+func (c *eventsClient) send(t *testing.T, data []byte) {
+	c.sendFn(t, data)
+}
+
+func (c *eventsClient) next(t *testing.T) transportEvent {
+	t.Helper()
+	select {
+	case e, ok := <-c.events:
+		require.True(t, ok, "the transport ended")
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing came through the transport")
+		return transportEvent{}
+	}
+}
+
+func (c *eventsClient) close() {
+	c.closer()
+}
+
+func dialWebsocketTransport(t *testing.T, format WireFormat) (WebsocketConnection, transportClient) {
+	gh := NewGameHandler(nil, nil, nil, nil, nil, nil)
+	conns := make(chan WebsocketConnection, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := gh.upgrader().Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- NewGorillaWebSocketWrapper(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{}
+	if format == WireFormatJSON {
+		dialer.Subprotocols = []string{SubprotocolJSON}
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	events := make(chan transportEvent, 64)
+	conn.SetPingHandler(func(string) error {
+		events <- transportEvent{kind: "ping"}
+		return nil
+	})
+	go func() {
+		defer close(events)
+		for {
+			_, data, err := conn.ReadMessage()
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				events <- transportEvent{kind: "close", code: closeErr.Code, reason: closeErr.Text}
+			}
+			if err != nil {
+				return
+			}
+			events <- transportEvent{kind: "packet", data: data}
+		}
+	}()
+
+	client := &eventsClient{
+		events: events,
+		sendFn: func(t *testing.T, data []byte) {
+			messageType := websocket.BinaryMessage
+			if format == WireFormatJSON {
+				messageType = websocket.TextMessage
+			}
+			require.NoError(t, conn.WriteMessage(messageType, data))
+		},
+		closer: func() { conn.Close() },
+	}
+	return <-conns, client
+}
+
+func dialSSETransport(t *testing.T, format WireFormat) (WebsocketConnection, transportClient) {
+	gh := NewGameHandler(nil, nil, nil, nil, nil, nil)
+	conns := make(chan WebsocketConnection, 1)
+	engine := gin.New()
+	engine.Use(func(ctx *gin.Context) { ctx.Set("id", "user-id") })
+	engine.GET("/stream", func(ctx *gin.Context) {
+		conn, err := gh.connect(ctx, "user-id")
+		if err != nil {
+			return
+		}
+		conns <- conn
+		hold(ctx, conn)
+	})
+	engine.POST("/sse/:token", gh.SSEPostHandler)
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	url := server.URL + "/stream"
+	if format == WireFormatJSON {
+		url += "?format=json"
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	t.Cleanup(func() { resp.Body.Close() })
+
+	tokens := make(chan string, 1)
+	events := make(chan transportEvent, 64)
+	go func() {
+		defer close(events)
+		reader := bufio.NewReader(resp.Body)
+		name, data := "", []string{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == ": ping":
+				events <- transportEvent{kind: "ping"}
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = append(data, strings.TrimPrefix(line, "data: "))
+			case line == "" && len(data) > 0:
+				payload := strings.Join(data, "\n")
+				switch name {
+				case "session":
+					tokens <- payload
+				case "close":
+					e := transportEvent{kind: "close"}
+					fmt.Sscanf(payload, "%d %s", &e.code, &e.reason)
+					events <- e
+				default:
+					packet := []byte(payload)
+					if format != WireFormatJSON {
+						packet, _ = base64.StdEncoding.DecodeString(payload)
+					}
+					events <- transportEvent{kind: "packet", data: packet}
+				}
+				name, data = "", data[:0]
+			}
+		}
+	}()
+	token := <-tokens
+
+	client := &eventsClient{
+		events: events,
+		sendFn: func(t *testing.T, data []byte) {
+			resp, err := http.Post(server.URL+"/sse/"+token, "application/octet-stream", bytes.NewReader(data))
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		},
+		closer: func() { resp.Body.Close() },
+	}
+	return <-conns, client
+}
+
+// The player pumps run against every transport, they must not tell them
+// apart.
+func TestPlayer_Transports(t *testing.T) {
+	for _, transport := range transports {
+		t.Run(transport.name, func(t *testing.T) {
+			t.Run("Read good data", func(t *testing.T) {
+				server, client := transport.dial(t, WireFormatProtobuf)
+				p := NewPlayer("id", "username")
+				mockRoom := &MockRoom{}
+				p.SetRoom(mockRoom)
+				received, removed := make(chan struct{}), make(chan struct{})
+				mockRoom.On("Send", p.ctx, mock.MatchedBy(func(e ClientPacketEnvelope) bool {
+					return e.clientPacket.GetPlayerMessage().GetMessage() == "hello" && e.from == "username"
+				})).Run(func(mock.Arguments) { close(received) }).Return().Once()
+				mockRoom.On("RemoveMe", p.ctx, p).Run(func(mock.Arguments) { close(removed) }).Return().Once()
+				go p.ReadPump(server)
+
+				chat, _ := proto.Marshal(&protobuf.ClientPacket{Payload: &protobuf.ClientPacket_PlayerMessage_{
+					PlayerMessage: &protobuf.ClientPacket_PlayerMessage{Message: "hello"},
+				}})
+				client.send(t, chat)
+				<-received
+
+				client.close()
+				select {
+				case <-removed:
+				case <-time.After(2 * time.Second):
+					t.Fatal("the player was not removed when the client left")
+				}
+				mockRoom.AssertExpectations(t)
+			})
+
+			t.Run("Correct Data And Ping Writing", func(t *testing.T) {
+				server, client := transport.dial(t, WireFormatProtobuf)
+				p := NewPlayer("id", "username")
+				mockRoom := &MockRoom{}
+				mockRoom.On("RemoveMe", mock.Anything, mock.Anything).Return().Maybe()
+				p.SetRoom(mockRoom)
+				go p.WritePump(server)
+				t.Cleanup(p.cancelCtx)
+
+				data, _ := proto.Marshal(protobuf.MakePacketPlayerJoined("naruto"))
+				require.NoError(t, p.Send(data, packetControl))
+				event := client.next(t)
+				assert.Equal(t, "packet", event.kind)
+				assert.Equal(t, data, event.data)
+
+				require.NoError(t, p.Ping())
+				assert.Equal(t, "ping", client.next(t).kind)
+			})
+
+			t.Run("Closes After Flush", func(t *testing.T) {
+				server, client := transport.dial(t, WireFormatProtobuf)
+				p := NewPlayer("id", "username")
+				p.SetRoom(&MockRoom{})
+				go p.WritePump(server)
+
+				p.sendError(ErrUnsupportedVersion)
+				p.outbox.closeAfterFlush(CloseUnsupportedVersion, ErrUnsupportedVersion.Error())
+
+				packet := &protobuf.ServerPacket{}
+				require.NoError(t, proto.Unmarshal(client.next(t).data, packet))
+				assert.Equal(t, ErrUnsupportedVersion.Error(), packet.GetError().GetCode())
+				event := client.next(t)
+				assert.Equal(t, "close", event.kind)
+				assert.Equal(t, CloseUnsupportedVersion, event.code)
+				assert.Equal(t, ErrUnsupportedVersion.Error(), event.reason)
+			})
+
+			t.Run("JSON Packets Both Ways", func(t *testing.T) {
+				server, client := transport.dial(t, WireFormatJSON)
+				p := NewPlayer("id", "username")
+				p.SetWireFormat(WireFormatJSON)
+				mockRoom := &MockRoom{}
+				mockRoom.On("RemoveMe", mock.Anything, mock.Anything).Return().Maybe()
+				p.SetRoom(mockRoom)
+				go p.ReadPump(server)
+				go p.WritePump(server)
+				t.Cleanup(p.cancelCtx)
+
+				client.send(t, []byte(`{"timeSync":{"clientTime":"42"}}`))
+				event := client.next(t)
+				packet := &protobuf.ServerPacket{}
+				require.NoError(t, unmarshalServerPacket(WireFormatJSON, event.data, packet))
+				assert.Equal(t, int64(42), packet.GetTimeSync().GetClientTime())
+			})
+		})
+	}
+}
+
+// countingConnection takes frames the way a socket would and tells how many
+// packets they held.
+type countingConnection struct {
+	frames  int
+	packets chan int
+}
+
+func (c *countingConnection) Close()                {}
+func (c *countingConnection) CloseWith(int, string) {}
+func (c *countingConnection) Read() ([]byte, error) { return nil, nil }
+func (c *countingConnection) Ping() error           { return nil }
+func (c *countingConnection) Write(data []byte) error {
+	packet := &protobuf.ServerPacket{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return err
+	}
+	c.frames++
+	c.packets <- max(len(packet.Batch), 1)
+	return nil
+}
+
+// BenchmarkWritePump pushes bursts of chat packets, which are never
+// coalesced, through the write pump.
+func BenchmarkWritePump(b *testing.B) {
+	const burst = 64
+	data, _ := proto.Marshal(protobuf.MakePacketPlayerMessage("someone", "is it a cat"))
+
+	for _, window := range []time.Duration{0, time.Millisecond} {
+		b.Run(fmt.Sprintf("window=%s", window), func(b *testing.B) {
+			conn := &countingConnection{packets: make(chan int, burst)}
+			p := NewPlayer("id", "username")
+			p.SetWriteBatchWindow(window)
+			p.batching.Store(window > 0)
+			done := make(chan struct{})
+			go func() {
+				p.WritePump(conn)
+				close(done)
+			}()
+
+			for b.Loop() {
+				for range burst {
+					if err := p.Send(data, packetControl); err != nil {
+						b.Fatal(err)
+					}
+				}
+				for written := 0; written < burst; {
+					written += <-conn.packets
+				}
+			}
+			p.CancelAndRelease()
+			<-done
+			b.ReportMetric(float64(conn.frames)/float64(b.N), "frames/op")
+		})
+	}
+}
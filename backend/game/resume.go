@@ -0,0 +1,54 @@
+package game
+
+const (
+	// the room keeps this many of its last sequenced packets for resumes
+	resumeRingSize = 256
+	// and drops the oldest sooner once they hold that many bytes
+	maxResumeRingBytes = 512 * 1024
+)
+
+func (pr *packetRing) push(e ringEntry) {
+	if pr.entries == nil {
+		pr.entries = make([]ringEntry, resumeRingSize)
+	}
+	if pr.n == len(pr.entries) {
+		pr.dropOldest()
+	}
+	e.size = e.packet.size() + e.exceptPacket.size()
+	pr.entries[(pr.start+pr.n)%len(pr.entries)] = e
+	pr.n++
+	pr.bytes += e.size
+	for pr.bytes > maxResumeRingBytes && pr.n > 1 {
+		pr.dropOldest()
+	}
+}
+
+func (pr *packetRing) dropOldest() {
+	pr.bytes -= pr.entries[pr.start].size
+	pr.entries[pr.start] = ringEntry{}
+	pr.start = (pr.start + 1) % len(pr.entries)
+	pr.n--
+}
+
+// since returns the packets after lastSeq, or false when some of them were
+// already dropped. lastSeq must not be past the room's last seq.
+func (pr *packetRing) since(lastSeq uint64) ([]ringEntry, bool) {
+	if pr.n == 0 {
+		return nil, false
+	}
+	if lastSeq+1 < pr.entries[pr.start].seq {
+		return nil, false
+	}
+	var out []ringEntry
+	for i := range pr.n {
+		e := pr.entries[(pr.start+i)%len(pr.entries)]
+		if e.seq > lastSeq {
+			out = append(out, e)
+		}
+	}
+	return out, true
+}
+
+func (pr *packetRing) reset() {
+	*pr = packetRing{}
+}
@@ -0,0 +1,196 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func seqs(entries []ringEntry) []uint64 {
+	out := []uint64{}
+	for _, e := range entries {
+		out = append(out, e.seq)
+	}
+	return out
+}
+
+func encodedBytes(data []byte) *encodedPacket {
+	return &encodedPacket{data: [wireFormatCount][]byte{WireFormatProtobuf: data}}
+}
+
+func TestPacketRing(t *testing.T) {
+	t.Parallel()
+	var ring packetRing
+	_, ok := ring.since(0)
+	assert.False(t, ok)
+
+	for seq := uint64(1); seq <= resumeRingSize+10; seq++ {
+		ring.push(ringEntry{seq: seq, packet: encodedBytes([]byte{1})})
+	}
+	assert.Equal(t, resumeRingSize, ring.n)
+
+	entries, ok := ring.since(resumeRingSize + 7)
+	require.True(t, ok)
+	assert.Equal(t, []uint64{resumeRingSize + 8, resumeRingSize + 9, resumeRingSize + 10}, seqs(entries))
+	_, ok = ring.since(10)
+	assert.True(t, ok, "seq 11 is the oldest kept")
+	_, ok = ring.since(9)
+	assert.False(t, ok)
+
+	ring.push(ringEntry{seq: resumeRingSize + 11, packet: encodedBytes(make([]byte, maxResumeRingBytes))})
+	assert.Equal(t, 1, ring.n, "big packets push the others out")
+	entries, ok = ring.since(resumeRingSize + 10)
+	require.True(t, ok)
+	assert.Len(t, entries, 1)
+}
+
+func resumeFrom(r *room, from string, lastSeq uint64) {
+	r.handleEnvelope(ClientPacketEnvelope{from: from, clientPacket: &protobuf.ClientPacket{
+		Payload: &protobuf.ClientPacket_Resume_{Resume: &protobuf.ClientPacket_Resume{LastSeq: lastSeq}},
+	}})
+}
+
+func unmarshalTasks(t *testing.T, tasks []dataSendTask) []*protobuf.ServerPacket {
+	t.Helper()
+	packets := []*protobuf.ServerPacket{}
+	for _, task := range tasks {
+		packet := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(task.data, packet))
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+func TestRoom_Sequences_Room_Stream(t *testing.T) {
+	r, host := setupDrawingRoom()
+	base := r.seq
+	require.NotZero(t, base, "the join was sequenced")
+
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1)}}, "host_user")
+	r.sendError("guest_user", ErrNotHost)
+	r.broadcastWithPrivate(protobuf.MakePacketPlayerIsDrawing("host_user"), protobuf.MakePacketYourTurnToDraw("cat"), host)
+	snapshot := r.makeSnapshot(0)
+
+	packets := unmarshalTasks(t, r.dataSendTasks)
+	require.Len(t, packets, 5)
+	assert.Equal(t, base+1, packets[0].Seq)
+	assert.Equal(t, base+1, packets[1].Seq)
+	assert.Zero(t, packets[2].Seq, "errors are not part of the room stream")
+	assert.Equal(t, base+2, packets[3].Seq)
+	assert.Equal(t, base+2, packets[4].Seq, "the private packet shares the seq")
+	assert.Equal(t, r.seq, snapshot.Seq)
+}
+
+func TestRoom_Resume(t *testing.T) {
+	testCases := []struct {
+		name string
+		// runs after three fills, returns the seq to resume from
+		setup    func(r *room, base uint64) uint64
+		snapshot bool
+		seqs     []uint64
+	}{
+		{
+			name:  "replays a small gap",
+			setup: func(r *room, base uint64) uint64 { return base + 1 },
+			seqs:  []uint64{2, 3},
+		},
+		{
+			name:  "nothing missing",
+			setup: func(r *room, base uint64) uint64 { return r.seq },
+			seqs:  []uint64{},
+		},
+		{
+			name: "gap older than the ring",
+			setup: func(r *room, base uint64) uint64 {
+				for range resumeRingSize {
+					r.broadcastToAll(protobuf.MakePacketGameStarted())
+				}
+				return base
+			},
+			snapshot: true,
+		},
+		{
+			name:     "seq past the room's",
+			setup:    func(r *room, base uint64) uint64 { return r.seq + 5 },
+			snapshot: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := setupDrawingRoom()
+			base := r.seq
+			for i := range 3 {
+				r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(uint32(i), 1)}}, "host_user")
+			}
+			lastSeq := tc.setup(r, base)
+			r.dataSendTasks = nil
+
+			resumeFrom(r, "guest_user", lastSeq)
+
+			packets := unmarshalTasks(t, r.dataSendTasks)
+			if tc.snapshot {
+				require.Len(t, packets, 2)
+				assert.True(t, proto.Equal(opClear(), packets[0].GetDrawingData().GetOps()[0]), "the canvas is reset first")
+				assert.Equal(t, packetControl, r.dataSendTasks[0].kind)
+				snapshot := packets[1].GetInitialRoomSnapshot()
+				require.NotNil(t, snapshot)
+				assert.Equal(t, packetSnapshot, r.dataSendTasks[1].kind)
+				assert.Equal(t, r.seq, packets[1].Seq)
+				assert.Len(t, snapshot.DrawingHistory, 3)
+				assert.Len(t, snapshot.PlayersStates, 2, "the player finds itself in it")
+				return
+			}
+			got := []uint64{}
+			for i, packet := range packets {
+				got = append(got, packet.Seq-base)
+				assert.Equal(t, "guest_user", r.dataSendTasks[i].to.Username())
+				assert.Equal(t, packetControl, r.dataSendTasks[i].kind)
+			}
+			assert.Equal(t, tc.seqs, got)
+		})
+	}
+}
+
+func TestRoom_Resume_Replays_What_The_Player_Got(t *testing.T) {
+	r, host := setupDrawingRoom()
+	base := r.seq
+	r.broadcastWithPrivate(protobuf.MakePacketPlayerIsDrawing("host_user"), protobuf.MakePacketYourTurnToDraw("cat"), host)
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1)}}, "host_user")
+	r.dataSendTasks = nil
+
+	resumeFrom(r, "host_user", base)
+	packets := unmarshalTasks(t, r.dataSendTasks)
+	require.Len(t, packets, 2)
+	assert.Equal(t, "cat", packets[0].GetYourTurnToDraw().GetWord())
+	assert.NotEmpty(t, packets[1].GetDrawingData().GetOps())
+	assert.Equal(t, base+2, packets[1].Seq)
+
+	// came back without drawing ops, the ops it missed can not be replayed
+	r.playerStates[1].features = 0
+	r.dataSendTasks = nil
+	resumeFrom(r, "guest_user", base)
+	packets = unmarshalTasks(t, r.dataSendTasks)
+	require.NotEmpty(t, packets)
+	snapshot := packets[len(packets)-1].GetInitialRoomSnapshot()
+	require.NotNil(t, snapshot)
+	assert.Empty(t, snapshot.DrawingHistory, "dende can not draw typed ops")
+	for _, packet := range packets {
+		assert.Empty(t, packet.GetDrawingData().GetOps())
+	}
+}
+
+func TestRoom_Resume_Sends_Only_The_Snapshot_To_Players_Without_Drawing_Ops(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.playerStates[1].features = 0
+	r.dataSendTasks = nil
+
+	resumeFrom(r, "guest_user", r.seq+5)
+
+	packets := unmarshalTasks(t, r.dataSendTasks)
+	require.Len(t, packets, 1, "no clear for dende to take as stroke data")
+	assert.NotNil(t, packets[0].GetInitialRoomSnapshot())
+}
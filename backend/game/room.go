@@ -1,12 +1,13 @@
 package game
 
 import (
+	"api/domain"
 	"api/domain/protobuf"
 	"context"
+	"errors"
+	"slices"
 	"strings"
 	"time"
-
-	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -17,6 +18,9 @@ const (
 	PHASE_GAMEEND
 )
 
+// how long the word is revealed before the next turn
+const turnSummaryDuration = 5 * time.Second
+
 func NewRoom(
 	host Player,
 	private bool,
@@ -32,7 +36,7 @@ func NewRoom(
 		private: private,
 		host:    hUsername,
 		playerStates: []*playerGameState{
-			{player: host, username: hUsername},
+			{player: host, username: hUsername, wireFormat: host.WireFormat()},
 		},
 		drawerIndex:           0,
 		maxPlayers:            maxPlayers,
@@ -44,7 +48,10 @@ func NewRoom(
 		choosingWordDuration:  choosingWordDuration,
 		drawingDuration:       drawingDuration,
 		wordChoices:           nil,
-		drawingHistory:        make([][]byte, 0, 1024),
+		drawingHistory:        strokeHistory{visible: make([][]byte, 0, 1024)},
+		drawingValidator:      newDrawingValidator(),
+		maxDrawingHistory:     defaultMaxDrawingHistoryBytes,
+		legacyDrawingData:     true,
 		inbox:                 make(chan ClientPacketEnvelope, 2048),
 		ticks:                 make(chan time.Time, 1),
 		pingPlayers:           make(chan struct{}, 1),
@@ -53,6 +60,11 @@ func NewRoom(
 		randomWordsGenerator:  randomWordsGenerator,
 	}
 
+	// guests own no webhooks, a guest host leaves it empty
+	if !domain.IsGuestId(host.Id()) {
+		r.hostId = host.Id()
+	}
+	r.participantIds = []string{host.Id()}
 	host.SetRoom(r)
 
 	return r
@@ -122,10 +134,40 @@ func (r *room) SetId(id string) {
 	r.id = id
 }
 
+// SetEventPublisher enables lifecycle events (webhooks) for this room.
+func (r *room) SetEventPublisher(p GameEventPublisher) {
+	r.eventPublisher = p
+}
+
+// SetDrawingSaver enables archiving each turn's drawing to the gallery.
+func (r *room) SetDrawingSaver(s DrawingSaver) {
+	r.drawingSaver = s
+}
+
+// SetLegacyDrawingData controls whether the opaque DrawingData bytes are
+// still relayed, typed ops are always accepted.
+func (r *room) SetLegacyDrawingData(enabled bool) {
+	r.legacyDrawingData = enabled
+}
+
+// SetMaxDrawingHistoryBytes caps what a turn's history may hold, undone
+// strokes included. Past it drawing data is rejected until the drawer
+// clears the canvas.
+func (r *room) SetMaxDrawingHistoryBytes(n int) {
+	r.maxDrawingHistory = n
+}
+
+// SetRecorder enables replay recording for this room.
+func (r *room) SetRecorder(rec PacketRecorder) {
+	r.recorder = rec
+}
+
 func (r *room) GameLoop() {
 	m := protobuf.MakePacketInitialRoomSnapshot(nil, nil, "", 0, r.id, 0, 0, int64(r.choosingWordDuration.Seconds()), int64(r.drawingDuration.Seconds()))
-	mb, _ := proto.Marshal(m)
-	r.playerStates[0].player.Send(mb)
+	mb, _ := encode(m).bytes(r.playerStates[0].wireFormat)
+	r.playerStates[0].player.Send(mb, packetSnapshot)
+	r.publishEvent(domain.GameEventRoomCreated)
+	r.recordSnapshot()
 loop:
 	for {
 		if r.phase == PHASE_GAMEEND {
@@ -166,6 +208,9 @@ loop:
 	for _, ps := range r.playerStates {
 		ps.player.CancelAndRelease()
 	}
+	if r.recorder != nil && r.round > 0 {
+		r.recorder.Finish(domain.Replay{RoomId: r.id, Private: r.private, ParticipantIds: r.participantIds})
+	}
 }
 
 func (r *room) executeAndClearTasks() {
@@ -177,17 +222,32 @@ func (r *room) executeAndClearTasks() {
 		}
 	}
 
+	// the snapshot owed to a lagging player goes after the rest of the
+	// batch, so its seq covers all of it, and holds the batch's drawing
+	var resynced []Player
 	i := 0
 	for i < len(r.dataSendTasks) {
 		to := r.dataSendTasks[i].to
 		data := r.dataSendTasks[i].data
-		err := to.Send(data)
+		kind := r.dataSendTasks[i].kind
+		i++
+		if kind.droppable() && slices.Contains(resynced, to) {
+			continue
+		}
+		err := to.Send(data, kind)
 
+		if errors.Is(err, ErrResyncNeeded) {
+			resynced = append(resynced, to)
+			continue
+		}
 		if err != nil {
 			r.handleRemovePlayer(to)
 		}
-
-		i++
+	}
+	for _, p := range resynced {
+		if err := r.resync(p); err != nil {
+			r.handleRemovePlayer(p)
+		}
 	}
 
 	clear(r.dataSendTasks)
@@ -225,6 +285,28 @@ func (r *room) addPlayer(p Player) error {
 		}
 	}
 
+	playerJoined := protobuf.MakePacketPlayerJoined(pUsername)
+	r.broadcastToAll(playerJoined)
+	// what the newcomer speaks is only known once its Hello comes
+	initialRoomSnapshot := r.makeSnapshot(0)
+
+	r.playerStates = append(r.playerStates, &playerGameState{username: pUsername, player: p, wireFormat: p.WireFormat()})
+	p.SetRoom(r)
+	if r.recorder != nil && !slices.Contains(r.participantIds, p.Id()) {
+		r.participantIds = append(r.participantIds, p.Id())
+	}
+
+	r.broadcastTo(initialRoomSnapshot, p)
+
+	r.updateDescription()
+	return nil
+}
+
+// makeSnapshot describes the room as it is to a recipient with the given
+// features. A newcomer is not part of the player states yet, any other
+// recipient finds itself in it. Recipients without drawing ops get no
+// history once it holds typed ops, dende can not draw them.
+func (r *room) makeSnapshot(f features) *protobuf.ServerPacket {
 	pStates := make([]*protobuf.ServerPacket_InitialRoomSnapshot_PlayerState, 0, len(r.playerStates))
 	for _, ps := range r.playerStates {
 		pStates = append(pStates, &protobuf.ServerPacket_InitialRoomSnapshot_PlayerState{
@@ -233,19 +315,70 @@ func (r *room) addPlayer(p Player) error {
 			IsGuesser: ps.hasGuessed,
 		})
 	}
-	playerJoined := protobuf.MakePacketPlayerJoined(pUsername)
-	r.broadcastToAll(playerJoined)
-	initialRoomSnapshot := protobuf.MakePacketInitialRoomSnapshot(pStates, r.drawingHistory, r.currentDrawer, int32(r.round), r.id, int32(r.phase), r.nextTick.UnixMilli(), int64(r.choosingWordDuration.Seconds()), int64(r.drawingDuration.Seconds()))
-
-	r.playerStates = append(r.playerStates, &playerGameState{username: pUsername, player: p})
-	p.SetRoom(r)
+	history := r.drawingHistory.visible
+	if r.drawingHistory.ops && !f.has(featureDrawingOps) {
+		history = nil
+	}
+	snapshot := protobuf.MakePacketInitialRoomSnapshot(pStates, history, r.currentDrawer, int32(r.round), r.id, int32(r.phase), r.nextTick.UnixMilli(), int64(r.choosingWordDuration.Seconds()), int64(r.drawingDuration.Seconds()))
+	snapshot.Seq = r.seq
+	snapshot.PhaseRemainingMs = r.phaseRemainingMs()
+	return snapshot
+}
 
-	r.broadcastTo(initialRoomSnapshot, p)
+// phaseRemainingMs is the time left before the next tick moves the game on,
+// zero in phases that wait for the players.
+func (r *room) phaseRemainingMs() int64 {
+	switch r.phase {
+	case PHASE_CHOOSING_WORD, PHASE_DRAWING, PHASE_TURN_SUMMARY:
+		return max(0, time.Until(r.nextTick).Milliseconds())
+	}
+	return 0
+}
 
-	r.updateDescription()
+// resync replaces the drawing a lagging player missed with a fresh snapshot.
+func (r *room) resync(p Player) error {
+	for _, ps := range r.playerStates {
+		if ps.player != p {
+			continue
+		}
+		tasks, err := r.snapshotTasks(ps)
+		if err != nil {
+			return err
+		}
+		sendMetrics.Add("resyncs", 1)
+		for _, task := range tasks {
+			if err := p.Send(task.data, task.kind); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// snapshotTasks bring a player that missed packets back in line. A player
+// with drawing ops gets its canvas cleared first, a snapshot's history is
+// drawn over what it holds. Legacy clients only get the snapshot, they
+// reset their canvas on it and would hand a clear to dende as stroke data.
+func (r *room) snapshotTasks(ps *playerGameState) ([]dataSendTask, error) {
+	snapshotData, err := encode(r.makeSnapshot(ps.features)).bytes(ps.wireFormat)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := dataSendTask{to: ps.player, data: snapshotData, kind: packetSnapshot}
+	if !ps.features.has(featureDrawingOps) {
+		return []dataSendTask{snapshot}, nil
+	}
+
+	reset := protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{{Op: &protobuf.DrawingOp_Clear_{Clear: &protobuf.DrawingOp_Clear{}}}})
+	resetData, err := encode(reset).bytes(ps.wireFormat)
+	if err != nil {
+		return nil, err
+	}
+	// drawing is dropped while the player is owed the snapshot, the reset
+	// is not
+	return []dataSendTask{{to: ps.player, data: resetData, kind: packetControl}, snapshot}, nil
+}
+
 func (r *room) handleRemovePlayer(toRemove Player) {
 	for i, ps := range r.playerStates {
 		if ps.player == toRemove {
@@ -321,6 +454,16 @@ func (r *room) handleEnvelope(env ClientPacketEnvelope) {
 	switch payload := env.clientPacket.Payload.(type) {
 	case *protobuf.ClientPacket_DrawingData:
 		r.handleDrawingDataEnvelope(payload.DrawingData, env.from)
+	case *protobuf.ClientPacket_Undo_:
+		r.handleCanvasEnvelope(&protobuf.DrawingOp{Op: &protobuf.DrawingOp_Undo_{Undo: &protobuf.DrawingOp_Undo{}}}, env.from)
+	case *protobuf.ClientPacket_Redo_:
+		r.handleCanvasEnvelope(&protobuf.DrawingOp{Op: &protobuf.DrawingOp_Redo_{Redo: &protobuf.DrawingOp_Redo{}}}, env.from)
+	case *protobuf.ClientPacket_ClearCanvas_:
+		r.handleCanvasEnvelope(&protobuf.DrawingOp{Op: &protobuf.DrawingOp_Clear_{Clear: &protobuf.DrawingOp_Clear{}}}, env.from)
+	case *protobuf.ClientPacket_Hello_:
+		r.handleHelloEnvelope(env)
+	case *protobuf.ClientPacket_Resume_:
+		r.handleResumeEnvelope(payload.Resume, env.from)
 	case *protobuf.ClientPacket_StartGame_:
 		r.handleStartGameEnvelope(env.from)
 	case *protobuf.ClientPacket_WordChoice_:
@@ -330,15 +473,172 @@ func (r *room) handleEnvelope(env ClientPacketEnvelope) {
 	}
 }
 
+// sendError tells a player why their packet was ignored. Each player has an
+// error budget of their own so that a flood of bad packets never turns into
+// a flood of errors.
+func (r *room) sendError(to string, err error) {
+	for _, ps := range r.playerStates {
+		if ps.username != to {
+			continue
+		}
+		if ps.player.AllowError() {
+			r.broadcastTo(protobuf.MakePacketError(err.Error(), ""), ps.player)
+		}
+		return
+	}
+}
+
+// handleHelloEnvelope records what the player's handshake agreed to, the
+// Welcome was already sent by the player itself. A player that joined
+// mid-turn got no typed ops in its snapshot, it gets them now.
+func (r *room) handleHelloEnvelope(env ClientPacketEnvelope) {
+	for _, ps := range r.playerStates {
+		if ps.username != env.from {
+			continue
+		}
+		ps.features = env.features
+		if r.drawingHistory.ops && ps.features.has(featureDrawingOps) {
+			if tasks, err := r.snapshotTasks(ps); err == nil {
+				r.dataSendTasks = append(r.dataSendTasks, tasks...)
+			}
+		}
+		return
+	}
+}
+
+// handleResumeEnvelope sends a player the packets after the last seq it
+// applied, replayed from the ring when it still has them all and as a fresh
+// snapshot otherwise.
+func (r *room) handleResumeEnvelope(resume *protobuf.ClientPacket_Resume, from string) {
+	var ps *playerGameState
+	for _, state := range r.playerStates {
+		if state.username == from {
+			ps = state
+			break
+		}
+	}
+	if ps == nil || resume.LastSeq == r.seq {
+		return
+	}
+
+	entries, ok := r.resumeRing.since(resume.LastSeq)
+	if resume.LastSeq > r.seq || !ok || !ps.features.has(featureDrawingOps) && holdsDrawingOps(entries) {
+		if tasks, err := r.snapshotTasks(ps); err == nil {
+			r.dataSendTasks = append(r.dataSendTasks, tasks...)
+			sendMetrics.Add("resyncs", 1)
+		}
+		return
+	}
+	sendMetrics.Add("replays", 1)
+	for _, e := range entries {
+		packet := e.packet
+		if e.except == from {
+			packet = e.exceptPacket
+		}
+		// replayed packets are never merged nor dropped, the client
+		// already asked for them once
+		r.queue(ps, packet, packetControl)
+	}
+}
+
 func (r *room) handleDrawingDataEnvelope(drawingData *protobuf.DrawingData, from string) {
-	if r.currentDrawer == from {
-		pkt := protobuf.MakePacketDrawingData(drawingData.Data)
+	if r.currentDrawer != from || r.phase != PHASE_DRAWING {
+		r.sendError(from, ErrNotYourTurn)
+		return
+	}
 
-		r.broadcastToAll(pkt)
-		r.drawingHistory = append(r.drawingHistory, drawingData.Data)
+	if len(drawingData.Ops) > 0 {
+		r.applyDrawingOps(drawingData.Ops)
 		return
 	}
 
+	if !r.legacyDrawingData || len(drawingData.Data) == 0 {
+		return
+	}
+	if len(drawingData.Data) > r.drawingHeadroom() {
+		r.rejectDrawing(ErrDrawingHistoryFull)
+		return
+	}
+	if err := r.drawingValidator.spend(len(drawingData.Data)); err != nil {
+		r.rejectDrawing(err)
+		return
+	}
+	if !r.drawingHistory.pushOpaque(drawingData.Data) {
+		return
+	}
+	pkt := protobuf.MakePacketDrawingData(drawingData.Data)
+
+	r.broadcastToAll(pkt)
+}
+
+// handleCanvasEnvelope applies an undo, redo or clear sent on its own, it is
+// only relayed when it changed the canvas.
+func (r *room) handleCanvasEnvelope(op *protobuf.DrawingOp, from string) {
+	if r.currentDrawer != from || r.phase != PHASE_DRAWING {
+		r.sendError(from, ErrNotYourTurn)
+		return
+	}
+
+	r.applyDrawingOps([]*protobuf.DrawingOp{op})
+}
+
+// applyDrawingOps validates the drawer's typed ops and relays them. An undo
+// or redo with nothing to act on, say past the checkpoints, is not relayed
+// so clients never diverge from the history, and only what is relayed
+// counts against the turn budget.
+func (r *room) applyDrawingOps(ops []*protobuf.DrawingOp) {
+	if !r.drawingOpsAllowed() {
+		r.sendError(r.currentDrawer, ErrDrawingOpsUnsupported)
+		return
+	}
+	clean, encoded, err := r.drawingValidator.apply(ops, r.drawingHeadroom())
+	if err != nil {
+		r.rejectDrawing(err)
+		return
+	}
+
+	relayed := clean[:0]
+	size := 0
+	for i, op := range encoded {
+		if op == nil || r.drawingHistory.push(op) {
+			relayed = append(relayed, clean[i])
+			size += len(op)
+		}
+	}
+	r.drawingValidator.charge(size)
+	if len(relayed) > 0 {
+		r.broadcastToAll(protobuf.MakePacketDrawingOps(relayed))
+	}
+}
+
+// drawingOpsAllowed reports whether every player agreed to typed ops. Legacy
+// clients hand any DrawingData they get to dende, so typed ops are refused
+// while one of them is in the room and the drawer falls back to data.
+func (r *room) drawingOpsAllowed() bool {
+	for _, ps := range r.playerStates {
+		if !ps.features.has(featureDrawingOps) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *room) drawingHeadroom() int {
+	return max(r.maxDrawingHistory-r.drawingHistory.size, 0)
+}
+
+// rejectDrawing tells the drawer why their drawing data was dropped, the
+// limits only once per turn.
+func (r *room) rejectDrawing(err error) {
+	if !errors.Is(err, ErrDrawingBudgetExceeded) && !errors.Is(err, ErrDrawingHistoryFull) {
+		r.sendError(r.currentDrawer, err)
+		return
+	}
+	if r.drawingLimitNotified {
+		return
+	}
+	r.drawingLimitNotified = true
+	r.broadcastTo(protobuf.MakePacketDrawingLimitReached(err.Error()), r.playerStates[r.drawerIndex].player)
 }
 
 func (r *room) handleStartGameEnvelope(from string) {
@@ -346,18 +646,21 @@ func (r *room) handleStartGameEnvelope(from string) {
 		return
 	}
 	if r.host != from {
+		r.sendError(from, ErrNotHost)
 		return
 	}
 
 	pkt := protobuf.MakePacketGameStarted()
 	r.broadcastToAll(pkt)
 	r.round = 1
+	r.publishEvent(domain.GameEventGameStarted)
 	r.transitionToChoosingWord()
 	r.updateDescription()
 }
 
 func (r *room) handleWordChoiceEnvelope(wordChoice *protobuf.ClientPacket_WordChoice, from string) {
 	if r.phase != PHASE_CHOOSING_WORD || from != r.currentDrawer {
+		r.sendError(from, ErrNotYourTurn)
 		return
 	}
 
@@ -365,6 +668,7 @@ func (r *room) handleWordChoiceEnvelope(wordChoice *protobuf.ClientPacket_WordCh
 	choiceIndex := wordChoice.Choice
 
 	if choiceIndex < 0 || choiceIndex >= n {
+		r.sendError(from, ErrInvalidChoice)
 		return
 	}
 	r.currentWord = r.wordChoices[choiceIndex]
@@ -390,13 +694,10 @@ func (r *room) handlePlayerMessageEnvelope(clientMessage *protobuf.ClientPacket_
 		}
 		return
 	}
-	serverPacket := protobuf.MakePacketPlayerMessage(from, clientMessage.Message)
+	serverPacket := encode(protobuf.MakePacketPlayerMessage(from, clientMessage.Message))
 
-	bytesPacket, err := proto.Marshal(serverPacket)
-
-	if err != nil {
-		return
-	}
+	// replays are watched after the game, so even guessers-only chat is kept
+	r.record(serverPacket)
 
 	if r.playerStates[senderIndex].hasGuessed || r.playerStates[r.drawerIndex].username == from {
 		for i, ps := range r.playerStates {
@@ -404,7 +705,7 @@ func (r *room) handlePlayerMessageEnvelope(clientMessage *protobuf.ClientPacket_
 				continue
 			}
 			if ps.hasGuessed || i == r.drawerIndex {
-				r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: bytesPacket})
+				r.queue(ps, serverPacket, packetControl)
 			}
 		}
 	} else {
@@ -412,54 +713,184 @@ func (r *room) handlePlayerMessageEnvelope(clientMessage *protobuf.ClientPacket_
 			if ps.username == from {
 				continue
 			}
-			r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: bytesPacket})
+			r.queue(ps, serverPacket, packetControl)
 		}
 	}
 }
 
 /*
-	Broadcasting Functions
+	Lifecycle events
 */
 
-func (r *room) broadcastToAll(serverPacket *protobuf.ServerPacket) {
-	bytesPacket, err := proto.Marshal(serverPacket)
+func (r *room) publishEvent(eventType domain.GameEventType) {
+	if r.eventPublisher == nil {
+		return
+	}
+	players := make([]domain.GameEventPlayer, 0, len(r.playerStates))
+	for _, ps := range r.playerStates {
+		players = append(players, domain.GameEventPlayer{
+			Username: ps.username,
+			Score:    ps.score + ps.scoreIncrement,
+			Guest:    domain.IsGuestId(ps.player.Id()),
+		})
+	}
+
+	r.eventPublisher.Publish(domain.GameEvent{
+		Type:       eventType,
+		RoomId:     r.id,
+		Private:    r.private,
+		Host:       r.host,
+		HostId:     r.hostId,
+		Round:      r.round,
+		Players:    players,
+		OccurredAt: time.Now(),
+	})
+}
+
+/*
+	Gallery
+*/
 
+func (r *room) saveDrawing() {
+	if r.drawingSaver == nil || len(r.drawingHistory.visible) == 0 {
+		return
+	}
+	drawing := domain.Drawing{
+		RoomId:    r.id,
+		Word:      r.currentWord,
+		Drawer:    r.currentDrawer,
+		Guessers:  []string{},
+		CreatedAt: time.Now(),
+	}
+	for _, ps := range r.playerStates {
+		if ps.username == r.currentDrawer {
+			drawing.DrawerId = ps.player.Id()
+		}
+		if ps.hasGuessed {
+			drawing.Guessers = append(drawing.Guessers, ps.username)
+		}
+	}
+	// the drawer already left, nobody to attribute the drawing to, and guests
+	// keep no gallery
+	if drawing.DrawerId == "" || domain.IsGuestId(drawing.DrawerId) {
+		return
+	}
+	// only the visible strokes, and the chunks are never rewritten in place
+	drawing.Strokes = append([][]byte(nil), r.drawingHistory.visible...)
+	drawing.DrawingOps = !r.drawingHistory.opaque
+	r.drawingSaver.SaveDrawing(drawing)
+}
+
+/*
+	Replay recording: only packets every spectator would see are recorded,
+	private ones (word choices, your turn) are skipped.
+*/
+
+// record keeps the protobuf encoding, whatever the players use.
+func (r *room) record(serverPacket *encodedPacket) {
+	if r.recorder == nil {
+		return
+	}
+	bytesPacket, err := serverPacket.bytes(WireFormatProtobuf)
 	if err != nil {
 		return
 	}
+	r.recorder.Record(time.Now(), bytesPacket)
+}
 
+func (r *room) recordSnapshot() {
+	if r.recorder == nil {
+		return
+	}
+	pStates := make([]*protobuf.ServerPacket_InitialRoomSnapshot_PlayerState, 0, len(r.playerStates))
 	for _, ps := range r.playerStates {
-		r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: bytesPacket})
+		pStates = append(pStates, &protobuf.ServerPacket_InitialRoomSnapshot_PlayerState{Username: ps.username})
 	}
+	snapshot := protobuf.MakePacketInitialRoomSnapshot(pStates, nil, "", 0, r.id, int32(r.phase), 0, int64(r.choosingWordDuration.Seconds()), int64(r.drawingDuration.Seconds()))
+	r.record(encode(snapshot))
 }
 
-func (r *room) broadcastTo(serverPacket *protobuf.ServerPacket, player Player) {
-	bytesPacket, err := proto.Marshal(serverPacket)
+/*
+	Broadcasting Functions
+*/
+
+// sequence gives a packet of the room stream the next seq.
+func (r *room) sequence(serverPacket *protobuf.ServerPacket) *encodedPacket {
+	r.seq++
+	serverPacket.Seq = r.seq
+	return encode(serverPacket)
+}
 
+// queue sends a packet to a player in the player's wire format.
+func (r *room) queue(ps *playerGameState, serverPacket *encodedPacket, kind packetKind) {
+	data, err := serverPacket.bytes(ps.wireFormat)
 	if err != nil {
 		return
 	}
+	r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: data, kind: kind})
+}
+
+func (r *room) broadcastToAll(serverPacket *protobuf.ServerPacket) {
+	encoded := r.sequence(serverPacket)
+	r.record(encoded)
+	kind := packetKindOf(serverPacket)
+
+	for _, ps := range r.playerStates {
+		r.queue(ps, encoded, kind)
+	}
+	r.resumeRing.push(ringEntry{seq: r.seq, packet: encoded, kind: kind})
+}
 
+func (r *room) broadcastTo(serverPacket *protobuf.ServerPacket, player Player) {
 	for _, ps := range r.playerStates {
 		if ps.player == player {
-			r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: bytesPacket})
+			r.queue(ps, encode(serverPacket), packetKindOf(serverPacket))
 			return
 		}
 	}
 }
 
-func (r *room) broadcastToAllExcept(serverPacket *protobuf.ServerPacket, player Player) {
-	bytesPacket, err := proto.Marshal(serverPacket)
+// broadcastWithPrivate sends a packet to everyone but player, who gets the
+// private one in its place. Both share a seq, only the public one is
+// recorded.
+func (r *room) broadcastWithPrivate(serverPacket, private *protobuf.ServerPacket, player Player) {
+	encoded := r.sequence(serverPacket)
+	private.Seq = r.seq
+	encodedPrivate := encode(private)
+	r.record(encoded)
+	kind := packetKindOf(serverPacket)
 
-	if err != nil {
-		return
+	for _, ps := range r.playerStates {
+		if ps.player == player {
+			r.queue(ps, encodedPrivate, packetKindOf(private))
+			continue
+		}
+		r.queue(ps, encoded, kind)
 	}
+	r.resumeRing.push(ringEntry{seq: r.seq, packet: encoded, kind: kind, except: player.Username(), exceptPacket: encodedPrivate})
+}
 
-	for _, ps := range r.playerStates {
-		if ps.player != player {
-			r.dataSendTasks = append(r.dataSendTasks, dataSendTask{to: ps.player, data: bytesPacket})
+// holdsDrawingOps reports whether a replay would hand typed ops to the player.
+func holdsDrawingOps(entries []ringEntry) bool {
+	for _, e := range entries {
+		if e.kind == packetDrawingOps {
+			return true
+		}
+	}
+	return false
+}
+
+func packetKindOf(serverPacket *protobuf.ServerPacket) packetKind {
+	switch payload := serverPacket.Payload.(type) {
+	case *protobuf.ServerPacket_DrawingData:
+		if len(payload.DrawingData.Data) > 0 {
+			return packetDrawing
 		}
+		return packetDrawingOps
+	case *protobuf.ServerPacket_InitialRoomSnapshot_:
+		return packetSnapshot
 	}
+	return packetControl
 }
 
 /*
@@ -487,37 +918,41 @@ func (r *room) transitionToChoosingWord() {
 
 	words := r.randomWordsGenerator.Generate(r.wordsCount)
 	r.wordChoices = words
+	r.nextTick = time.Now().Add(r.choosingWordDuration)
 
 	plzChoose := protobuf.MakePacketPleaseChooseAWord(words)
+	plzChoose.PhaseRemainingMs = r.choosingWordDuration.Milliseconds()
 
 	playerIsChoosing := protobuf.MakePacketPlayerIsChoosingWord(r.currentDrawer)
+	playerIsChoosing.PhaseRemainingMs = r.choosingWordDuration.Milliseconds()
 
-	r.broadcastTo(plzChoose, r.playerStates[r.drawerIndex].player)
-	r.broadcastToAllExcept(playerIsChoosing, r.playerStates[r.drawerIndex].player)
-	r.nextTick = time.Now().Add(r.choosingWordDuration)
+	r.broadcastWithPrivate(playerIsChoosing, plzChoose, r.playerStates[r.drawerIndex].player)
 }
 
 func (r *room) transitionToDrawing() {
 	r.phase = PHASE_DRAWING
+	r.drawingValidator = newDrawingValidator()
+	r.drawingLimitNotified = false
 	if r.currentWord == "" {
 		r.currentWord = r.wordChoices[0]
 	}
 
 	drawerState := r.playerStates[r.drawerIndex]
+	r.nextTick = time.Now().Add(r.drawingDuration)
 
 	playerStartedDrawing := protobuf.MakePacketPlayerIsDrawing(drawerState.username)
+	playerStartedDrawing.PhaseRemainingMs = r.drawingDuration.Milliseconds()
 
 	yourTurn := protobuf.MakePacketYourTurnToDraw(r.currentWord)
+	yourTurn.PhaseRemainingMs = r.drawingDuration.Milliseconds()
 
-	r.broadcastToAllExcept(playerStartedDrawing, drawerState.player)
-	r.broadcastTo(yourTurn, drawerState.player)
-	r.nextTick = time.Now().Add(r.drawingDuration)
+	r.broadcastWithPrivate(playerStartedDrawing, yourTurn, drawerState.player)
 }
 
 func (r *room) transitionToTurnSummary() {
 	r.phase = PHASE_TURN_SUMMARY
-	clear(r.drawingHistory)
-	r.drawingHistory = r.drawingHistory[:0]
+	r.saveDrawing()
+	r.drawingHistory.reset()
 
 	deltas := []*protobuf.ServerPacket_TurnSummary_ScoreDeltas{}
 
@@ -528,10 +963,11 @@ func (r *room) transitionToTurnSummary() {
 		})
 	}
 
+	r.nextTick = time.Now().Add(turnSummaryDuration)
 	turnSummary := protobuf.MakePacketTurnSummary(r.currentWord, deltas)
+	turnSummary.PhaseRemainingMs = turnSummaryDuration.Milliseconds()
 
 	r.broadcastToAll(turnSummary)
-	r.nextTick = time.Now().Add(5 * time.Second)
 }
 
 func (r *room) transitionToNextRound() {
@@ -550,6 +986,9 @@ func (r *room) transitionToNextRound() {
 
 func (r *room) transitionToGameEnd() {
 	r.phase = PHASE_GAMEEND
+	if r.round > 0 {
+		r.publishEvent(domain.GameEventGameEnded)
+	}
 	leaderboard := protobuf.MakePacketLeaderBoard()
 
 	r.broadcastToAll(leaderboard)
@@ -557,5 +996,6 @@ func (r *room) transitionToGameEnd() {
 
 	r.parentLobby.RemoveRoom(r.id)
 	r.wordChoices = nil
-	r.drawingHistory = nil
+	r.drawingHistory = strokeHistory{}
+	r.resumeRing.reset()
 }
@@ -8,7 +8,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"google.golang.org/protobuf/proto"
 )
 
 func (st dataSendTask) String() string {
@@ -16,8 +15,12 @@ func (st dataSendTask) String() string {
 	if st.to != nil {
 		toName = st.to.Username()
 	}
+	format := WireFormatProtobuf
+	if st.to != nil {
+		format = st.to.WireFormat()
+	}
 	serverPacket := &protobuf.ServerPacket{}
-	if err := proto.Unmarshal(st.data, serverPacket); err != nil {
+	if err := unmarshalServerPacket(format, st.data, serverPacket); err != nil {
 		return fmt.Sprintf("dataSendTask{to: %s, data: <invalid proto: %v>}", toName, st.data)
 	}
 	if p, ok := serverPacket.Payload.(*protobuf.ServerPacket_InitialRoomSnapshot_); ok {
@@ -41,7 +44,7 @@ func MakeDataSendTasks(args ...any) []dataSendTask {
 			panic(fmt.Sprintf("Bad types at index %d, expected (Player, *ServerPacket)", i))
 		}
 
-		m, _ := proto.Marshal(serverPacket)
+		m, _ := codecs[to.WireFormat()].marshal(serverPacket)
 
 		dst.to = to
 		dst.data = m
@@ -67,18 +70,29 @@ func AssertEqualDataSendTasks(t *testing.T, expected []dataSendTask, actual []da
 	assert.ElementsMatch(t, expectedStr, actualStr)
 }
 
+// The scenarios run once per wire format, the room must not care.
 func TestGame_GameScenario_1(t *testing.T) {
 	t.Parallel()
-	naruto := &MockPlayer{}
+	for format := range wireFormatCount {
+		t.Run(fmt.Sprint(format), func(t *testing.T) {
+			t.Parallel()
+			testGameScenario1(t, format)
+		})
+	}
+}
+
+func testGameScenario1(t *testing.T, format WireFormat) {
+	naruto := &MockPlayer{format: format}
 	naruto.On("Username").Return("naruto")
-	sasuke := &MockPlayer{}
+	naruto.On("Id").Return("naruto-id")
+	sasuke := &MockPlayer{format: format}
 	sasuke.On("Username").Return("sasuke")
-	sakura := &MockPlayer{}
-	itachi := &MockPlayer{}
+	sakura := &MockPlayer{format: format}
+	itachi := &MockPlayer{format: format}
 	itachi.On("Username").Return("itachi")
-	jiraiya := &MockPlayer{}
+	jiraiya := &MockPlayer{format: format}
 	jiraiya.On("Username").Return("jiraiya")
-	itachi2 := &MockPlayer{}
+	itachi2 := &MockPlayer{format: format}
 	sasuke.On("SetRoom", mock.Anything).Return().Once()
 	naruto.On("SetRoom", mock.Anything).Return().Once()
 	itachi.On("SetRoom", mock.Anything).Return().Once()
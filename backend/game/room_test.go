@@ -1,19 +1,25 @@
 package game
 
 import (
+	"api/domain"
 	"api/domain/protobuf"
+	"api/drawing"
 	"context"
+	"image/color"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 func setupRoom() (*room, *MockPlayer, *MockRandomWordsGenerator) {
 	host := &MockPlayer{}
 	host.On("Username").Return("host_user")
+	host.On("Id").Return("host-id")
 	host.On("SetRoom", mock.Anything).Return()
 
 	gen := &MockRandomWordsGenerator{}
@@ -103,7 +109,7 @@ func TestRoom_Send(t *testing.T) {
 
 func TestRoom_RequestJoin(t *testing.T) {
 	r, h, _ := setupRoom()
-	h.On("Send", mock.Anything).Return(nil)
+	h.On("Send", mock.Anything, mock.Anything).Return(nil)
 
 	r.maxPlayers = 1
 	p := &MockPlayer{}
@@ -151,7 +157,7 @@ func TestRoom_CloseAndRelease(t *testing.T) {
 
 func TestRoom_GameLoop_Close_And_Release(t *testing.T) {
 	r, h, _ := setupRoom()
-	h.On("Send", mock.Anything).Return(nil)
+	h.On("Send", mock.Anything, mock.Anything).Return(nil)
 	h.On("CancelAndRelease").Return()
 
 	wg := sync.WaitGroup{}
@@ -163,9 +169,9 @@ func TestRoom_GameLoop_Close_And_Release(t *testing.T) {
 
 func TestRoom_GameLoop_Reads_Ticks_And_Updates_Phase(t *testing.T) {
 	r, p, wgen := setupRoom()
-	p.On("Send", mock.Anything).Return(nil)
+	p.On("Send", mock.Anything, mock.Anything).Return(nil)
 	wgen.On("Generate", r.wordsCount).Return([]string{"word1", "word2", "word3"})
-	p.On("Send", mock.Anything).Return(nil)
+	p.On("Send", mock.Anything, mock.Anything).Return(nil)
 	assert.Equal(t, PHASE_PENDING, r.phase)
 
 	go r.GameLoop()
@@ -185,7 +191,7 @@ func TestRoom_GameLoop_Reads_Ticks_And_Updates_Phase(t *testing.T) {
 
 func TestRoom_GameLoop_Reads_Ping_And_Queues_Task(t *testing.T) {
 	r, p, _ := setupRoom()
-	p.On("Send", mock.Anything).Return(nil)
+	p.On("Send", mock.Anything, mock.Anything).Return(nil)
 	p.On("Ping").Return(nil)
 	go r.GameLoop()
 	r.PingPlayers()
@@ -199,7 +205,7 @@ func TestRoom_GameLoop_Inbox_Sends_Data(t *testing.T) {
 	lobby.On("RequestUpdateDescription", mock.Anything).Return()
 	r.SetParentLobby(lobby)
 
-	host.On("Send", mock.Anything).Return(nil)
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
 	wgen.On("Generate", 3).Return([]string{"lil"})
 
 	go r.GameLoop()
@@ -232,8 +238,8 @@ func TestRoom_GameLoop_Player_Removal(t *testing.T) {
 
 	r.addPlayer(victim)
 
-	host.On("Send", mock.Anything).Return(nil)
-	victim.On("Send", mock.Anything).Return(nil)
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+	victim.On("Send", mock.Anything, mock.Anything).Return(nil)
 	// Victim should be cancelled
 	victim.On("CancelAndRelease").Return()
 
@@ -248,3 +254,571 @@ func TestRoom_GameLoop_Player_Removal(t *testing.T) {
 	host.AssertExpectations(t)
 	victim.AssertExpectations(t)
 }
+
+func TestRoom_Publishes_Lifecycle_Events(t *testing.T) {
+	r, host, wgen := setupRoom()
+	r.SetId("evt-room")
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+	host.On("CancelAndRelease").Return()
+
+	lobby := &MockLobby{}
+	lobby.On("RequestUpdateDescription", mock.Anything).Return()
+	lobby.On("RemoveRoom", "evt-room").Return()
+	r.SetParentLobby(lobby)
+
+	guest := &MockPlayer{}
+	guest.On("Username").Return("guest_user")
+	guest.On("Id").Return("guest-id")
+	guest.On("SetRoom", r).Return()
+	guest.On("CancelAndRelease").Return()
+	r.addPlayer(guest)
+
+	wgen.On("Generate", 3).Return([]string{"word"})
+
+	publisher := &MockGameEventPublisher{}
+	publisher.On("Publish", mock.MatchedBy(func(e domain.GameEvent) bool {
+		return e.Type == domain.GameEventGameStarted && e.HostId == "host-id" && e.RoomId == "evt-room" && len(e.Players) == 2
+	})).Return().Once()
+	publisher.On("Publish", mock.MatchedBy(func(e domain.GameEvent) bool {
+		return e.Type == domain.GameEventGameEnded && e.HostId == "host-id" && len(e.Players) == 1
+	})).Return().Once()
+	r.SetEventPublisher(publisher)
+
+	r.handleStartGameEnvelope("host_user")
+	r.handleRemovePlayer(guest)
+
+	publisher.AssertExpectations(t)
+}
+
+func TestRoom_GameLoop_Publishes_Room_Created(t *testing.T) {
+	r, host, _ := setupRoom()
+	r.SetId("evt-room")
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+	host.On("CancelAndRelease").Return()
+
+	publisher := &MockGameEventPublisher{}
+	publisher.On("Publish", mock.MatchedBy(func(e domain.GameEvent) bool {
+		return e.Type == domain.GameEventRoomCreated && e.Host == "host_user" && e.HostId == "host-id"
+	})).Return().Once()
+	r.SetEventPublisher(publisher)
+
+	wg := sync.WaitGroup{}
+	wg.Go(func() { r.GameLoop() })
+	r.CloseAndRelease()
+	wg.Wait()
+
+	publisher.AssertExpectations(t)
+}
+
+func TestRoom_Records_Public_Packets_Only(t *testing.T) {
+	r, host, wgen := setupRoom()
+	r.SetId("rec-room")
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+
+	lobby := &MockLobby{}
+	lobby.On("RequestUpdateDescription", mock.Anything).Return()
+	r.SetParentLobby(lobby)
+
+	guest := &MockPlayer{}
+	guest.On("Username").Return("guest_user")
+	guest.On("SetRoom", r).Return()
+	r.addPlayer(guest)
+
+	wgen.On("Generate", 3).Return([]string{"apple", "banana", "cherry"})
+
+	recorded := []*protobuf.ServerPacket{}
+	recorder := &MockPacketRecorder{}
+	recorder.On("Record", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		packet := &protobuf.ServerPacket{}
+		assert.NoError(t, proto.Unmarshal(args.Get(1).([]byte), packet))
+		recorded = append(recorded, packet)
+	}).Return()
+	r.SetRecorder(recorder)
+
+	r.handleStartGameEnvelope("host_user")
+	r.handlePlayerMessageEnvelope(&protobuf.ClientPacket_PlayerMessage{Message: "hello"}, "guest_user")
+
+	assert.NotEmpty(t, recorded)
+	sawChat := false
+	for _, p := range recorded {
+		_, private := p.Payload.(*protobuf.ServerPacket_PleaseChooseAWord_)
+		assert.False(t, private, "word choices must not be recorded")
+		if msg, ok := p.Payload.(*protobuf.ServerPacket_PlayerMessage_); ok {
+			sawChat = msg.PlayerMessage.Message == "hello"
+		}
+	}
+	assert.True(t, sawChat)
+}
+
+func TestRoom_GameLoop_Finishes_Recording_Of_Started_Games(t *testing.T) {
+	r, host, _ := setupRoom()
+	r.SetId("rec-room")
+	r.private = true
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+	host.On("CancelAndRelease").Return()
+
+	recorder := &MockPacketRecorder{}
+	recorder.On("Record", mock.Anything, mock.Anything).Return()
+	recorder.On("Finish", domain.Replay{RoomId: "rec-room", Private: true, ParticipantIds: []string{"host-id", "left-id"}}).Return().Once()
+	r.SetRecorder(recorder)
+
+	// a player who left still played in the room
+	left := &MockPlayer{}
+	left.On("Username").Return("left_user")
+	left.On("Id").Return("left-id")
+	left.On("SetRoom", r).Return()
+	left.On("Send", mock.Anything, mock.Anything).Return(nil)
+	left.On("CancelAndRelease").Return()
+	r.SetParentLobby(func() *MockLobby {
+		lobby := &MockLobby{}
+		lobby.On("RequestUpdateDescription", mock.Anything).Return()
+		return lobby
+	}())
+	r.addPlayer(left)
+	r.handleRemovePlayer(left)
+
+	r.round = 1
+
+	wg := sync.WaitGroup{}
+	wg.Go(func() { r.GameLoop() })
+	r.CloseAndRelease()
+	wg.Wait()
+
+	recorder.AssertExpectations(t)
+}
+
+func TestRoom_Saves_Drawing_At_Turn_End(t *testing.T) {
+	r, _, _ := setupRoom()
+	r.SetId("gallery-room")
+
+	guest := &MockPlayer{}
+	guest.On("Username").Return("guest_user")
+	guest.On("SetRoom", r).Return()
+	lobby := &MockLobby{}
+	lobby.On("RequestUpdateDescription", mock.Anything).Return()
+	r.SetParentLobby(lobby)
+	r.addPlayer(guest)
+
+	r.phase = PHASE_DRAWING
+	r.currentDrawer = "host_user"
+	r.currentWord = "apple"
+	r.playerStates[1].hasGuessed = true
+	r.drawingHistory.pushOpaque([]byte{1, 2})
+	r.drawingHistory.pushOpaque([]byte{3})
+
+	saver := &MockDrawingSaver{}
+	saver.On("SaveDrawing", mock.MatchedBy(func(d domain.Drawing) bool {
+		return d.DrawerId == "host-id" && d.Drawer == "host_user" && d.Word == "apple" && d.RoomId == "gallery-room" &&
+			assert.ObjectsAreEqual([]string{"guest_user"}, d.Guessers) &&
+			assert.ObjectsAreEqual([][]byte{{1, 2}, {3}}, d.Strokes) && !d.DrawingOps
+	})).Return().Once()
+	r.SetDrawingSaver(saver)
+
+	r.transitionToTurnSummary()
+
+	saver.AssertExpectations(t)
+	assert.Empty(t, r.drawingHistory.visible)
+}
+
+func TestRoom_Does_Not_Save_Empty_Drawings(t *testing.T) {
+	r, _, _ := setupRoom()
+	r.currentDrawer = "host_user"
+
+	saver := &MockDrawingSaver{}
+	r.SetDrawingSaver(saver)
+
+	r.transitionToTurnSummary()
+
+	saver.AssertNotCalled(t, "SaveDrawing", mock.Anything)
+}
+
+func TestRoom_Keeps_Guests_Out_Of_History(t *testing.T) {
+	r, host := setupDrawingRoom()
+	host.On("Id").Unset()
+	host.On("Id").Return("guest:host_user")
+	r.hostId = ""
+	r.playerStates[1].player.(*MockPlayer).On("Id").Return("guest-id")
+	r.currentWord = "apple"
+	r.drawingHistory.visible = append(r.drawingHistory.visible, []byte{1, 2})
+
+	saver := &MockDrawingSaver{}
+	r.SetDrawingSaver(saver)
+	publisher := &MockGameEventPublisher{}
+	publisher.On("Publish", mock.MatchedBy(func(e domain.GameEvent) bool {
+		return e.HostId == "" && assert.ObjectsAreEqual([]domain.GameEventPlayer{
+			{Username: "host_user", Guest: true},
+			{Username: "guest_user"},
+		}, e.Players)
+	})).Return().Once()
+	r.SetEventPublisher(publisher)
+
+	r.transitionToTurnSummary()
+	r.publishEvent(domain.GameEventGameEnded)
+
+	saver.AssertNotCalled(t, "SaveDrawing", mock.Anything)
+	publisher.AssertExpectations(t)
+}
+
+func setupDrawingRoom() (*room, *MockPlayer) {
+	r, host, _ := setupRoom()
+	guest := &MockPlayer{}
+	guest.On("Username").Return("guest_user")
+	guest.On("SetRoom", r).Return()
+	lobby := &MockLobby{}
+	lobby.On("RequestUpdateDescription", mock.Anything).Return()
+	r.SetParentLobby(lobby)
+	r.addPlayer(guest)
+	r.dataSendTasks = nil
+
+	r.phase = PHASE_DRAWING
+	r.currentDrawer = "host_user"
+	for _, ps := range r.playerStates {
+		ps.features = featureDrawingOps
+	}
+	return r, host
+}
+
+func TestRoom_Relays_Validated_Drawing_Ops(t *testing.T) {
+	r, _ := setupDrawingRoom()
+
+	ops := []*protobuf.DrawingOp{opBegin(10, 10), opPoints(pt(20, 20)), opEnd()}
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: ops}, "host_user")
+
+	assert.Len(t, r.dataSendTasks, 2)
+	require.Len(t, r.drawingHistory.visible, 1)
+	scene, err := drawing.Parse(r.drawingHistory.visible)
+	require.NoError(t, err)
+	assert.Len(t, scene.Actions(), 1)
+
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, packet))
+	assert.Len(t, packet.GetDrawingData().Ops, 3)
+}
+
+func TestRoom_Drops_Invalid_Drawing_Data(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(r *room)
+		from  string
+		data  *protobuf.DrawingData
+	}{
+		{name: "out of bounds", from: "host_user", data: &protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(900, 10)}}},
+		{name: "not the drawer", from: "guest_user", data: &protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1)}}},
+		{name: "not drawing", setup: func(r *room) { r.phase = PHASE_TURN_SUMMARY }, from: "host_user", data: &protobuf.DrawingData{Data: []byte{1}}},
+		{name: "legacy disabled", setup: func(r *room) { r.SetLegacyDrawingData(false) }, from: "host_user", data: &protobuf.DrawingData{Data: []byte{1}}},
+		{name: "legacy over budget", setup: func(r *room) { r.drawingValidator.used = maxDrawingBytesPerTurn }, from: "host_user", data: &protobuf.DrawingData{Data: []byte{1}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := setupDrawingRoom()
+			if tc.setup != nil {
+				tc.setup(r)
+			}
+
+			r.handleDrawingDataEnvelope(tc.data, tc.from)
+
+			for _, task := range r.dataSendTasks {
+				packet := &protobuf.ServerPacket{}
+				require.NoError(t, proto.Unmarshal(task.data, packet))
+				assert.Nil(t, packet.GetDrawingData(), "nothing may be relayed")
+			}
+			assert.Empty(t, r.drawingHistory.visible)
+		})
+	}
+}
+
+func TestRoom_Undo_Redo_And_Clear(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	send := func(from string, payload *protobuf.ClientPacket) int {
+		r.dataSendTasks = nil
+		r.handleEnvelope(ClientPacketEnvelope{from: from, clientPacket: payload})
+		return len(r.dataSendTasks)
+	}
+	undo := &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_Undo_{Undo: &protobuf.ClientPacket_Undo{}}}
+	redo := &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_Redo_{Redo: &protobuf.ClientPacket_Redo{}}}
+	clearCanvas := &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_ClearCanvas_{ClearCanvas: &protobuf.ClientPacket_ClearCanvas{}}}
+
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1), opFill(2, 2)}}, "host_user")
+	require.Len(t, r.drawingHistory.visible, 2)
+
+	assert.Equal(t, 1, send("guest_user", undo), "only the drawer can undo")
+	notYourTurn := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, notYourTurn))
+	assert.Equal(t, ErrNotYourTurn.Error(), notYourTurn.GetError().GetCode())
+	assert.Equal(t, 2, send("host_user", undo))
+	assert.Len(t, r.drawingHistory.visible, 1)
+
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, packet))
+	assert.True(t, proto.Equal(opUndo(), packet.GetDrawingData().Ops[0]))
+
+	assert.Equal(t, 2, send("host_user", redo))
+	assert.Zero(t, send("host_user", redo), "nothing left to redo")
+	assert.Len(t, r.drawingHistory.visible, 2)
+
+	assert.Equal(t, 2, send("host_user", clearCanvas))
+	assert.Empty(t, r.drawingHistory.visible)
+	assert.Zero(t, send("host_user", undo))
+}
+
+func TestRoom_Snapshot_Only_Carries_Visible_Strokes(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1), opFill(2, 2), opUndo()}}, "host_user")
+
+	late := &MockPlayer{}
+	late.On("Username").Return("late_user")
+	late.On("SetRoom", r).Return()
+	late.On("AllowError").Return(true).Maybe()
+	lastSnapshot := func() *protobuf.ServerPacket_InitialRoomSnapshot {
+		var snapshot *protobuf.ServerPacket_InitialRoomSnapshot
+		for _, task := range r.dataSendTasks {
+			packet := &protobuf.ServerPacket{}
+			require.NoError(t, proto.Unmarshal(task.data, packet))
+			if s := packet.GetInitialRoomSnapshot(); s != nil {
+				snapshot = s
+			}
+		}
+		require.NotNil(t, snapshot)
+		return snapshot
+	}
+	r.dataSendTasks = nil
+	r.addPlayer(late)
+	assert.Empty(t, lastSnapshot().DrawingHistory, "typed ops wait for the newcomer's Hello")
+
+	r.dataSendTasks = nil
+	r.handleEnvelope(ClientPacketEnvelope{from: "late_user", features: featureDrawingOps, clientPacket: &protobuf.ClientPacket{
+		Payload: &protobuf.ClientPacket_Hello_{Hello: &protobuf.ClientPacket_Hello{ProtocolVersion: ProtocolVersion}},
+	}})
+	snapshot := lastSnapshot()
+	require.Len(t, snapshot.DrawingHistory, 1)
+	assert.Equal(t, drawing.AppendFill(nil, color.RGBA{A: 255}, drawing.Point{X: 1, Y: 1}), snapshot.DrawingHistory[0])
+}
+
+func TestRoom_Notifies_Drawer_When_History_Is_Full(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.SetMaxDrawingHistoryBytes(18)
+	fill := &protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1)}}
+
+	r.handleDrawingDataEnvelope(fill, "host_user")
+	r.handleDrawingDataEnvelope(fill, "host_user")
+	require.Len(t, r.drawingHistory.visible, 2)
+	r.dataSendTasks = nil
+
+	r.handleDrawingDataEnvelope(fill, "host_user")
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Data: []byte{1}}, "host_user")
+	assert.Len(t, r.drawingHistory.visible, 2)
+	require.Len(t, r.dataSendTasks, 1, "the drawer is only told once")
+	assert.Equal(t, "host_user", r.dataSendTasks[0].to.Username())
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, packet))
+	assert.Equal(t, ErrDrawingHistoryFull.Error(), packet.GetDrawingLimitReached().GetReason())
+
+	r.dataSendTasks = nil
+	r.handleCanvasEnvelope(opClear(), "host_user")
+	r.handleDrawingDataEnvelope(fill, "host_user")
+	assert.Len(t, r.dataSendTasks, 4)
+	assert.Len(t, r.drawingHistory.visible, 1)
+}
+
+func TestRoom_Does_Not_Relay_Noop_Undo(t *testing.T) {
+	r, _ := setupDrawingRoom()
+
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1), opUndo(), opUndo()}}, "host_user")
+
+	require.Len(t, r.dataSendTasks, 2)
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(r.dataSendTasks[0].data, packet))
+	assert.Len(t, packet.GetDrawingData().Ops, 2)
+	assert.Empty(t, r.drawingHistory.visible)
+}
+
+func TestRoom_Resyncs_Lagging_Player(t *testing.T) {
+	r, host := setupDrawingRoom()
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1)}}, "host_user")
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(2, 2)}}, "host_user")
+
+	guest := r.playerStates[1].player.(*MockPlayer)
+	host.On("Send", mock.Anything, mock.Anything).Return(nil)
+	guest.On("Send", mock.Anything, packetDrawingOps).Return(ErrResyncNeeded).Once()
+	guest.On("Send", mock.Anything, packetControl).Return(nil).Once()
+	guest.On("Send", mock.Anything, packetSnapshot).Return(nil).Once()
+
+	r.executeAndClearTasks()
+
+	guest.AssertExpectations(t)
+	guest.AssertNumberOfCalls(t, "Send", 3)
+	assert.Len(t, r.playerStates, 2)
+	sent := []*protobuf.ServerPacket{}
+	for _, call := range guest.Calls[len(guest.Calls)-2:] {
+		packet := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(call.Arguments.Get(0).([]byte), packet))
+		sent = append(sent, packet)
+	}
+	assert.True(t, proto.Equal(opClear(), sent[0].GetDrawingData().GetOps()[0]), "the canvas is reset before the snapshot")
+	snapshot := sent[1].GetInitialRoomSnapshot()
+	require.NotNil(t, snapshot)
+	assert.Len(t, snapshot.DrawingHistory, 2)
+	assert.Len(t, snapshot.PlayersStates, 2, "the lagging player is still in the room")
+}
+
+func TestRoom_Refuses_Drawing_Ops_With_Players_Without_Them(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.handleEnvelope(ClientPacketEnvelope{from: "guest_user", clientPacket: &protobuf.ClientPacket{
+		Payload: &protobuf.ClientPacket_Hello_{Hello: &protobuf.ClientPacket_Hello{ProtocolVersion: 1}},
+	}})
+	require.Zero(t, r.playerStates[1].features)
+
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opColor(0xff0000ff), opFill(1, 1)}}, "host_user")
+	r.handleCanvasEnvelope(opClear(), "host_user")
+
+	assert.Equal(t, []string{ErrDrawingOpsUnsupported.Error(), ErrDrawingOpsUnsupported.Error()}, errorCodes(t, r.dataSendTasks, "host_user"))
+	assert.Empty(t, r.drawingHistory.visible)
+	assert.Zero(t, r.drawingValidator.used)
+
+	r.dataSendTasks = nil
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Data: []byte{1, 2, 3}}, "host_user")
+	require.Len(t, r.dataSendTasks, 2, "data still goes to everyone")
+	for _, packet := range unmarshalTasks(t, r.dataSendTasks) {
+		assert.Equal(t, []byte{1, 2, 3}, packet.GetDrawingData().GetData())
+	}
+}
+
+func TestRoom_Charges_Only_Relayed_Drawing_Ops(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.drawingValidator.budget = 2 * maxDrawingOpsPerPacket
+	undos := make([]*protobuf.DrawingOp, maxDrawingOpsPerPacket)
+	for i := range undos {
+		undos[i] = opUndo()
+	}
+
+	for range 10 {
+		r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: undos}, "host_user")
+	}
+	assert.Empty(t, r.dataSendTasks, "nothing to undo, nothing relayed nor refused")
+	assert.Zero(t, r.drawingValidator.used)
+
+	r.handleDrawingDataEnvelope(&protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opFill(1, 1), opUndo(), opUndo()}}, "host_user")
+	assert.Len(t, r.dataSendTasks, 2)
+	assert.Equal(t, len(drawing.AppendFill(nil, color.RGBA{}, drawing.Point{}))+1, r.drawingValidator.used, "the second undo had nothing to act on")
+}
+
+func errorCodes(t *testing.T, tasks []dataSendTask, to string) []string {
+	t.Helper()
+	codes := []string{}
+	for _, task := range tasks {
+		packet := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(task.data, packet))
+		if e := packet.GetError(); e != nil {
+			assert.Equal(t, to, task.to.Username(), "errors only go to the offender")
+			codes = append(codes, e.Code)
+		}
+	}
+	return codes
+}
+
+func TestRoom_Sends_Errors_To_Offender(t *testing.T) {
+	choice := func(n int64) *protobuf.ClientPacket {
+		return &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_WordChoice_{WordChoice: &protobuf.ClientPacket_WordChoice{Choice: n}}}
+	}
+	testCases := []struct {
+		name   string
+		setup  func(r *room)
+		from   string
+		packet *protobuf.ClientPacket
+		code   error
+	}{
+		{
+			name:   "start game from guest",
+			setup:  func(r *room) { r.phase = PHASE_PENDING },
+			from:   "guest_user",
+			packet: &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_StartGame_{StartGame: &protobuf.ClientPacket_StartGame{}}},
+			code:   ErrNotHost,
+		},
+		{
+			name:   "word choice from guest",
+			setup:  func(r *room) { r.phase = PHASE_CHOOSING_WORD },
+			from:   "guest_user",
+			packet: choice(0),
+			code:   ErrNotYourTurn,
+		},
+		{
+			name:   "word choice out of range",
+			setup:  func(r *room) { r.phase = PHASE_CHOOSING_WORD; r.wordChoices = []string{"cat"} },
+			from:   "host_user",
+			packet: choice(3),
+			code:   ErrInvalidChoice,
+		},
+		{
+			name:   "drawing from guest",
+			from:   "guest_user",
+			packet: &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_DrawingData{DrawingData: &protobuf.DrawingData{Data: []byte{1}}}},
+			code:   ErrNotYourTurn,
+		},
+		{
+			name:   "invalid drawing op",
+			from:   "host_user",
+			packet: &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_DrawingData{DrawingData: &protobuf.DrawingData{Ops: []*protobuf.DrawingOp{opEnd()}}}},
+			code:   ErrInvalidDrawingOp,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := setupDrawingRoom()
+			if tc.setup != nil {
+				tc.setup(r)
+			}
+
+			r.handleEnvelope(ClientPacketEnvelope{from: tc.from, clientPacket: tc.packet})
+
+			assert.Equal(t, []string{tc.code.Error()}, errorCodes(t, r.dataSendTasks, tc.from))
+		})
+	}
+}
+
+func TestRoom_Rate_Limits_Errors(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	undo := &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_Undo_{Undo: &protobuf.ClientPacket_Undo{}}}
+
+	for range 100 {
+		r.handleEnvelope(ClientPacketEnvelope{from: "guest_user", clientPacket: undo})
+	}
+
+	codes := errorCodes(t, r.dataSendTasks, "guest_user")
+	assert.NotEmpty(t, codes)
+	assert.LessOrEqual(t, len(codes), 6)
+}
+
+func TestRoom_Shares_The_Player_Error_Budget(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	guest := r.playerStates[1].player
+	// the player rejected packets of its own first
+	for guest.AllowError() {
+	}
+	undo := &protobuf.ClientPacket{Payload: &protobuf.ClientPacket_Undo_{Undo: &protobuf.ClientPacket_Undo{}}}
+
+	r.handleEnvelope(ClientPacketEnvelope{from: "guest_user", clientPacket: undo})
+
+	assert.Empty(t, errorCodes(t, r.dataSendTasks, "guest_user"))
+}
+
+func TestRoom_Reports_Phase_Remaining_Time(t *testing.T) {
+	r, _ := setupDrawingRoom()
+	r.currentWord = "cat"
+	r.drawerIndex = 0
+
+	r.transitionToDrawing()
+	packets := unmarshalTasks(t, r.dataSendTasks)
+	require.Len(t, packets, 2)
+	for _, packet := range packets {
+		assert.Equal(t, time.Minute.Milliseconds(), packet.PhaseRemainingMs)
+	}
+
+	r.nextTick = time.Now().Add(10 * time.Second)
+	remaining := r.makeSnapshot(0).PhaseRemainingMs
+	assert.InDelta(t, 10_000, remaining, 1_000)
+
+	r.phase = PHASE_PENDING
+	assert.Zero(t, r.makeSnapshot(0).PhaseRemainingMs)
+}
@@ -0,0 +1,225 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"expvar"
+	"slices"
+)
+
+const (
+	sendQueueSize = 1024
+	// coalesced drawing ops are sent as a single websocket message
+	maxCoalescedBytes = 64 * 1024
+	// a batch frame stops growing past this, a bigger packet goes alone
+	maxBatchBytes = 64 * 1024
+)
+
+// sendMetrics counts what the write paths did under back-pressure, served
+// with the other expvars.
+var sendMetrics = expvar.NewMap("game_send")
+
+func ParseSendPolicy(s string) (SendPolicy, error) {
+	switch policy := SendPolicy(s); policy {
+	case SendPolicyDisconnect, SendPolicyDropOldest, SendPolicyResync:
+		return policy, nil
+	}
+	return "", ErrInvalidSendPolicy
+}
+
+func (k packetKind) droppable() bool {
+	return k == packetDrawing || k == packetDrawingOps
+}
+
+func newSendQueue() sendQueue {
+	return sendQueue{
+		packets: make([]queuedPacket, 0, 64),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// push queues a packet for the write pump. It returns ErrSendBufferFull when
+// the player has to go and ErrResyncNeeded when the caller should push a
+// fresh snapshot.
+func (q *sendQueue) push(data []byte, kind packetKind, policy SendPolicy) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.released || q.closeCode != 0 {
+		return ErrSendBufferFull
+	}
+
+	switch {
+	case kind == packetSnapshot:
+		q.dropDrawing()
+		q.stale = false
+	case q.stale && kind.droppable():
+		// already part of the snapshot the player is owed
+		sendMetrics.Add("dropped_drawing", 1)
+		if len(q.packets) <= sendQueueSize/2 {
+			return ErrResyncNeeded
+		}
+		return nil
+	case policy != SendPolicyDisconnect && kind == packetDrawingOps && q.coalesce(data):
+		sendMetrics.Add("coalesced", 1)
+		return nil
+	}
+
+	if len(q.packets) >= sendQueueSize {
+		switch {
+		case policy == SendPolicyDropOldest && q.dropOldestDrawing():
+			// what was dropped may have begun a stroke the packets left go
+			// on with, the canvas is only right again with a snapshot
+			q.stale = true
+		case policy == SendPolicyResync && q.dropDrawing() > 0:
+			q.stale = true
+			if kind.droppable() {
+				sendMetrics.Add("dropped_drawing", 1)
+				return nil
+			}
+		case policy != SendPolicyDisconnect && kind.droppable():
+			// nothing queued to drop but the packet itself
+			q.stale = true
+			sendMetrics.Add("dropped_drawing", 1)
+			return nil
+		default:
+			sendMetrics.Add("disconnects", 1)
+			return ErrSendBufferFull
+		}
+	}
+
+	q.packets = append(q.packets, queuedPacket{data: data, kind: kind})
+	q.signal()
+	return nil
+}
+
+// coalesce appends drawing ops to the last queued packet when it holds ops
+// too. Two marshalled ServerPackets concatenated decode as one, their ops
+// merged in order and the seq of the last one kept, so the first seq is
+// added for the client to see there is no gap.
+func (q *sendQueue) coalesce(data []byte) bool {
+	if len(q.packets) == 0 {
+		return false
+	}
+	last := &q.packets[len(q.packets)-1]
+	if last.kind != packetDrawingOps || len(last.data)+len(data) > maxCoalescedBytes {
+		return false
+	}
+	// the bytes are shared with every other player of the room
+	if !last.owned {
+		firstSeq := protobuf.PacketSeq(last.data)
+		merged := append(make([]byte, 0, 2*(len(last.data)+len(data))), last.data...)
+		merged = append(merged, data...)
+		if firstSeq != 0 {
+			// later packets have no first_seq to override it
+			merged = protobuf.AppendFirstSeq(merged, firstSeq)
+		}
+		last.data, last.owned = merged, true
+		return true
+	}
+	last.data = append(last.data, data...)
+	return true
+}
+
+func (q *sendQueue) dropOldestDrawing() bool {
+	for i, packet := range q.packets {
+		if packet.kind.droppable() {
+			q.packets = slices.Delete(q.packets, i, i+1)
+			sendMetrics.Add("dropped_drawing", 1)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *sendQueue) dropDrawing() int {
+	kept := q.packets[:0]
+	for _, packet := range q.packets {
+		if !packet.kind.droppable() {
+			kept = append(kept, packet)
+		}
+	}
+	dropped := len(q.packets) - len(kept)
+	clear(q.packets[len(kept):])
+	q.packets = kept
+	if dropped > 0 {
+		sendMetrics.Add("dropped_drawing", int64(dropped))
+	}
+	return dropped
+}
+
+func (q *sendQueue) pop() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.packets) == 0 {
+		return nil, false
+	}
+	data := q.packets[0].data
+	q.packets[0] = queuedPacket{}
+	q.packets = q.packets[1:]
+	return data, true
+}
+
+// popBatch pops queued packets up to maxBatchBytes and returns them as one
+// frame, a lone packet is returned as is.
+func (q *sendQueue) popBatch() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.packets) == 0 {
+		return nil, false
+	}
+
+	n, size := 1, len(q.packets[0].data)
+	for n < len(q.packets) && size+len(q.packets[n].data) <= maxBatchBytes {
+		size += len(q.packets[n].data)
+		n++
+	}
+	var frame []byte
+	if n == 1 {
+		frame = q.packets[0].data
+	} else {
+		frame = make([]byte, 0, size+n*4)
+		for _, packet := range q.packets[:n] {
+			frame = protobuf.AppendToBatch(frame, packet.data)
+		}
+	}
+	clear(q.packets[:n])
+	q.packets = q.packets[n:]
+	return frame, true
+}
+
+// closeAfterFlush stops taking packets, the write pump closes the connection
+// once it wrote what is already queued.
+func (q *sendQueue) closeAfterFlush(code int, reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.released || q.closeCode != 0 {
+		return
+	}
+	q.closeCode, q.closeReason = code, reason
+	q.signal()
+}
+
+// closing reports the close to send once nothing is left to pop.
+func (q *sendQueue) closing() (int, string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closeCode, q.closeReason, q.closeCode != 0 && len(q.packets) == 0
+}
+
+func (q *sendQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *sendQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.released {
+		return
+	}
+	q.released = true
+	clear(q.packets)
+	q.packets = nil
+	close(q.wake)
+}
@@ -0,0 +1,205 @@
+package game
+
+import (
+	"api/domain/protobuf"
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func fillQueue(t *testing.T, q *sendQueue, kind packetKind, policy SendPolicy) {
+	t.Helper()
+	for range sendQueueSize {
+		require.NoError(t, q.push([]byte{byte(kind)}, kind, policy))
+	}
+}
+
+func drainQueue(q *sendQueue) [][]byte {
+	var out [][]byte
+	for {
+		data, ok := q.pop()
+		if !ok {
+			return out
+		}
+		out = append(out, data)
+	}
+}
+
+func marshalOps(t *testing.T, ops ...*protobuf.DrawingOp) []byte {
+	t.Helper()
+	data, err := proto.Marshal(protobuf.MakePacketDrawingOps(ops))
+	require.NoError(t, err)
+	return data
+}
+
+func metric(name string) int64 {
+	if v, ok := sendMetrics.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestSendQueue_Coalesces_Drawing_Ops(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	first := marshalOps(t, opBegin(1, 1))
+	second := marshalOps(t, opPoints(pt(2, 2)), opEnd())
+	shared := append([]byte(nil), first...)
+
+	require.NoError(t, q.push(first, packetDrawingOps, SendPolicyDropOldest))
+	require.NoError(t, q.push(second, packetDrawingOps, SendPolicyDropOldest))
+	require.NoError(t, q.push([]byte{42}, packetControl, SendPolicyDropOldest))
+	require.NoError(t, q.push(first, packetDrawingOps, SendPolicyDropOldest))
+
+	out := drainQueue(&q)
+	require.Len(t, out, 3)
+	assert.Equal(t, shared, first, "the broadcast bytes must not be written to")
+
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(out[0], packet))
+	ops := packet.GetDrawingData().GetOps()
+	require.Len(t, ops, 3)
+	assert.True(t, proto.Equal(opBegin(1, 1), ops[0]))
+	assert.True(t, proto.Equal(opEnd(), ops[2]))
+	assert.Equal(t, []byte{42}, out[1])
+}
+
+func TestSendQueue_Does_Not_Coalesce_Legacy_Data(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+
+	require.NoError(t, q.push([]byte{1}, packetDrawing, SendPolicyResync))
+	require.NoError(t, q.push([]byte{2}, packetDrawing, SendPolicyResync))
+	assert.Len(t, drainQueue(&q), 2)
+}
+
+func TestSendQueue_Disconnect_Policy(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	fillQueue(t, &q, packetDrawing, SendPolicyDisconnect)
+
+	assert.ErrorIs(t, q.push([]byte{1}, packetDrawing, SendPolicyDisconnect), ErrSendBufferFull)
+	assert.ErrorIs(t, q.push([]byte{1}, packetControl, SendPolicyDisconnect), ErrSendBufferFull)
+}
+
+func TestSendQueue_Drop_Oldest_Keeps_Control_Packets(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	require.NoError(t, q.push([]byte{'c'}, packetControl, SendPolicyDropOldest))
+	require.NoError(t, q.push([]byte{'a'}, packetDrawing, SendPolicyDropOldest))
+	for range sendQueueSize - 2 {
+		require.NoError(t, q.push([]byte{'b'}, packetDrawing, SendPolicyDropOldest))
+	}
+	dropped := metric("dropped_drawing")
+
+	require.NoError(t, q.push([]byte{'z'}, packetControl, SendPolicyDropOldest))
+	assert.True(t, q.stale, "the dropped packet may have begun a stroke")
+	out := drainQueue(&q)
+	require.Len(t, out, sendQueueSize)
+	assert.Equal(t, []byte{'c'}, out[0])
+	assert.Equal(t, []byte{'b'}, out[1], "the oldest drawing packet goes first")
+	assert.Equal(t, []byte{'z'}, out[len(out)-1])
+	assert.GreaterOrEqual(t, metric("dropped_drawing"), dropped+1)
+
+	// nothing left to drop, a control packet is the last resort
+	fillQueue(t, &q, packetControl, SendPolicyDropOldest)
+	assert.NoError(t, q.push([]byte{1}, packetDrawing, SendPolicyDropOldest))
+	assert.ErrorIs(t, q.push([]byte{1}, packetControl, SendPolicyDropOldest), ErrSendBufferFull)
+}
+
+func TestSendQueue_Resync(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	require.NoError(t, q.push([]byte{'c'}, packetControl, SendPolicyResync))
+	for range sendQueueSize - 1 {
+		require.NoError(t, q.push([]byte{'d'}, packetDrawing, SendPolicyResync))
+	}
+
+	require.NoError(t, q.push([]byte{'z'}, packetControl, SendPolicyResync))
+	assert.True(t, q.stale)
+	assert.Len(t, q.packets, 2, "the queued drawing is superseded by the snapshot")
+
+	assert.ErrorIs(t, q.push([]byte{'d'}, packetDrawingOps, SendPolicyResync), ErrResyncNeeded)
+	require.NoError(t, q.push([]byte{'s'}, packetSnapshot, SendPolicyResync))
+	assert.False(t, q.stale)
+	require.NoError(t, q.push([]byte{'d'}, packetDrawingOps, SendPolicyResync))
+
+	assert.Equal(t, [][]byte{{'c'}, {'z'}, {'s'}, {'d'}}, drainQueue(&q))
+}
+
+func TestSendQueue_Released(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	q.release()
+	q.release()
+
+	assert.ErrorIs(t, q.push([]byte{1}, packetControl, SendPolicyResync), ErrSendBufferFull)
+	_, ok := <-q.wake
+	assert.False(t, ok)
+}
+
+func TestParseSendPolicy(t *testing.T) {
+	t.Parallel()
+	policy, err := ParseSendPolicy("drop-oldest")
+	assert.NoError(t, err)
+	assert.Equal(t, SendPolicyDropOldest, policy)
+
+	_, err = ParseSendPolicy("kick")
+	assert.ErrorIs(t, err, ErrInvalidSendPolicy)
+}
+
+func TestSendQueue_Pop_Batch(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	first, _ := proto.Marshal(protobuf.MakePacketPlayerJoined("a"))
+	big := make([]byte, maxBatchBytes-len(first))
+
+	require.NoError(t, q.push(first, packetControl, SendPolicyResync))
+	frame, ok := q.popBatch()
+	require.True(t, ok)
+	assert.Equal(t, first, frame, "a lone packet is not wrapped")
+
+	require.NoError(t, q.push(first, packetControl, SendPolicyResync))
+	require.NoError(t, q.push(first, packetControl, SendPolicyResync))
+	require.NoError(t, q.push(big, packetControl, SendPolicyResync))
+	frame, ok = q.popBatch()
+	require.True(t, ok)
+	batch := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(frame, batch))
+	assert.Len(t, batch.Batch, 2, "the big packet would not fit")
+	assert.Nil(t, batch.Payload)
+
+	frame, ok = q.popBatch()
+	require.True(t, ok)
+	assert.Equal(t, big, frame)
+	_, ok = q.popBatch()
+	assert.False(t, ok)
+}
+
+func TestSendQueue_Coalesced_Ops_Keep_First_Seq(t *testing.T) {
+	t.Parallel()
+	q := newSendQueue()
+	sequenced := func(seq uint64, op *protobuf.DrawingOp) []byte {
+		packet := protobuf.MakePacketDrawingOps([]*protobuf.DrawingOp{op})
+		packet.Seq = seq
+		data, err := proto.Marshal(packet)
+		require.NoError(t, err)
+		return data
+	}
+
+	for seq := uint64(7); seq <= 9; seq++ {
+		require.NoError(t, q.push(sequenced(seq, opFill(1, 1)), packetDrawingOps, SendPolicyResync))
+	}
+
+	out := drainQueue(&q)
+	require.Len(t, out, 1)
+	packet := &protobuf.ServerPacket{}
+	require.NoError(t, proto.Unmarshal(out[0], packet))
+	assert.Len(t, packet.GetDrawingData().Ops, 3)
+	assert.Equal(t, uint64(7), packet.FirstSeq)
+	assert.Equal(t, uint64(9), packet.Seq)
+	assert.Equal(t, uint64(9), protobuf.PacketSeq(out[0]))
+}
@@ -0,0 +1,174 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// packets posted faster than the read pump takes them are refused
+	sseInboxSize = 64
+	// a single posted packet, drawing ops included
+	maxSSEPacketBytes = 64 * 1024
+)
+
+// isEventStream tells a request for the SSE transport from a websocket one.
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func newSSESessions() *sseSessions {
+	return &sseSessions{conns: make(map[string]*SSEConnection)}
+}
+
+// open starts the event stream and registers its session, the token being
+// the first event. The session ends with the stream.
+func (s *sseSessions) open(w http.ResponseWriter, userId string, format WireFormat) (*SSEConnection, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingUnsupported
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	conn := &SSEConnection{
+		w:       w,
+		flusher: flusher,
+		text:    format == WireFormatJSON,
+		token:   hex.EncodeToString(b),
+		userId:  userId,
+		inbox:   make(chan []byte, sseInboxSize),
+		done:    make(chan struct{}),
+	}
+	conn.onClose = func() {
+		s.mu.Lock()
+		delete(s.conns, conn.token)
+		s.mu.Unlock()
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// proxies must hand every event over as it comes
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := conn.event("session", conn.token); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.conns[conn.token] = conn
+	s.mu.Unlock()
+	return conn, nil
+}
+
+// get finds the session of a token, only for the user that opened it.
+func (s *sseSessions) get(token, userId string) (*SSEConnection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conn, ok := s.conns[token]
+	if !ok || conn.userId != userId {
+		return nil, false
+	}
+	return conn, true
+}
+
+// event writes one server-sent event. Protobuf packets are base64, JSON ones
+// go as they are.
+func (c *SSEConnection) event(name, data string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrTransportClosed
+	}
+	var sb strings.Builder
+	if name != "" {
+		fmt.Fprintf(&sb, "event: %s\n", name)
+	}
+	for line := range strings.SplitSeq(data, "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+	sb.WriteByte('\n')
+	if _, err := c.w.Write([]byte(sb.String())); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+	return nil
+}
+
+func (c *SSEConnection) Write(data []byte) error {
+	if c.text {
+		return c.event("", string(data))
+	}
+	return c.event("", base64.StdEncoding.EncodeToString(data))
+}
+
+// Ping is a comment line, clients never see it but a dead stream fails it.
+func (c *SSEConnection) Ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrTransportClosed
+	}
+	if _, err := c.w.Write([]byte(": ping\n\n")); err != nil {
+		return err
+	}
+	c.flusher.Flush()
+	return nil
+}
+
+// Read returns the posted packets in order.
+func (c *SSEConnection) Read() ([]byte, error) {
+	select {
+	case data := <-c.inbox:
+		return data, nil
+	case <-c.done:
+		return nil, ErrTransportClosed
+	}
+}
+
+// deliver hands a posted packet over to Read.
+func (c *SSEConnection) deliver(data []byte) error {
+	select {
+	case <-c.done:
+		return ErrTransportClosed
+	default:
+	}
+	select {
+	case c.inbox <- data:
+		return nil
+	default:
+		return ErrTransportBusy
+	}
+}
+
+// CloseWith is the websocket close frame as a close event.
+func (c *SSEConnection) CloseWith(code int, reason string) {
+	c.event("close", fmt.Sprintf("%d %s", code, reason))
+	c.Close()
+}
+
+func (c *SSEConnection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.done)
+	c.onClose()
+}
+
+// wait holds the request open until either side ends the stream, nothing is
+// written once it returns.
+func (c *SSEConnection) wait(ctx context.Context) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		c.Close()
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"api/domain"
 	"api/domain/protobuf"
 	"context"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,6 +17,8 @@ type RoomPhase int
 
 type WebsocketConnection interface {
 	Close()
+	// CloseWith sends a close frame with the code and reason, then closes.
+	CloseWith(code int, reason string)
 	Write(data []byte) error
 	Read() ([]byte, error)
 	Ping() error
@@ -34,12 +38,46 @@ type UserGetter interface {
 	GetUserById(ctx context.Context, id string) (domain.User, error)
 }
 
+// guestUsers is the UserGetter the handler uses, guests are answered from
+// their id and never reach the wrapped one.
+type guestUsers struct {
+	UserGetter
+}
+
+// GameEventPublisher must never block, it is called from the room actor.
+type GameEventPublisher interface {
+	Publish(e domain.GameEvent)
+}
+
+// PacketRecorder receives every packet a spectator would see. It is owned by
+// the room actor and must not block.
+type PacketRecorder interface {
+	Record(at time.Time, packet []byte)
+	// Finish gets the room id, privacy and participants of the replay.
+	Finish(replay domain.Replay)
+}
+
+type PacketRecorderCreator interface {
+	Create() PacketRecorder
+}
+
+// DrawingSaver archives the final drawing of a turn. It must not block and
+// must not modify the strokes it is given.
+type DrawingSaver interface {
+	SaveDrawing(d domain.Drawing)
+}
+
 type Player interface {
-	Send(data []byte) error
+	Send(data []byte, kind packetKind) error
 	Ping() error
 	SetRoom(r Room)
 	CancelAndRelease()
 	Username() string
+	Id() string
+	WireFormat() WireFormat
+	// AllowError spends the player's error budget, shared by the room and
+	// the player itself.
+	AllowError() bool
 }
 
 type Room interface {
@@ -75,15 +113,96 @@ type player struct {
 	username    string
 	room        Room
 	rateLimiter rate.Limiter
-	inbox       chan []byte
-	pingChan    chan struct{}
-	ctx         context.Context
-	cancelCtx   context.CancelFunc
+	// errors about the player's own packets, see sendError
+	errorLimiter rate.Limiter
+	codec        codec
+	wireFormat   WireFormat
+	// answers to time sync samples
+	timeSyncLimiter rate.Limiter
+	sendPolicy      SendPolicy
+	batchWindow     time.Duration
+	// the client agreed to batch frames, set by the handshake
+	batching  atomic.Bool
+	outbox    sendQueue
+	pingChan  chan struct{}
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+}
+
+// SendPolicy is what a player's write path does once its queue is full.
+// Each policy falls back to the previous one, disconnecting stays the last
+// resort.
+type SendPolicy string
+
+const (
+	// SendPolicyDisconnect removes the player, as it always did.
+	SendPolicyDisconnect SendPolicy = "disconnect"
+	// SendPolicyDropOldest coalesces queued drawing ops and drops the oldest
+	// drawing packet to make room, control packets are always kept. The
+	// player is then owed a snapshot, like with SendPolicyResync.
+	SendPolicyDropOldest SendPolicy = "drop-oldest"
+	// SendPolicyResync drops every queued drawing packet instead and sends a
+	// fresh snapshot once the queue has drained.
+	SendPolicyResync SendPolicy = "resync"
+)
+
+// packetKind tells the write path which packets it may merge or drop.
+type packetKind uint8
+
+const (
+	packetControl packetKind = iota
+	// legacy drawing bytes, can be dropped
+	packetDrawing
+	// typed drawing ops, can be dropped or merged with the next ones
+	packetDrawingOps
+	// an InitialRoomSnapshot, supersedes every drawing packet before it
+	packetSnapshot
+)
+
+type queuedPacket struct {
+	data []byte
+	kind packetKind
+	// data was allocated while coalescing and can be appended to
+	owned bool
+}
+
+type sendQueue struct {
+	mu       sync.Mutex
+	packets  []queuedPacket
+	wake     chan struct{}
+	released bool
+	// drawing packets were dropped and a snapshot is owed
+	stale bool
+	// once drained the write pump closes the connection with these
+	closeCode   int
+	closeReason string
+}
+
+// ringEntry is a sequenced packet as every player got it.
+type ringEntry struct {
+	seq    uint64
+	packet *encodedPacket
+	kind   packetKind
+	// the player that got exceptPacket in its place, if any
+	except       string
+	exceptPacket *encodedPacket
+	// what the entry held when pushed
+	size int
+}
+
+// packetRing holds the last sequenced packets of a room, oldest first.
+type packetRing struct {
+	entries []ringEntry
+	start   int
+	n       int
+	bytes   int
 }
 
 type ClientPacketEnvelope struct {
 	clientPacket *protobuf.ClientPacket
 	from         string
+	// what the handshake agreed to, only set along a Hello
+	features features
 }
 
 type room struct {
@@ -104,7 +223,11 @@ type room struct {
 	drawingDuration       time.Duration
 	currentWord           string
 	wordChoices           []string
-	drawingHistory        [][]byte
+	drawingHistory        strokeHistory
+	drawingValidator      drawingValidator
+	maxDrawingHistory     int
+	drawingLimitNotified  bool
+	legacyDrawingData     bool
 	dataSendTasks         []dataSendTask
 	pingSendTasks         []pingSendTask
 	inbox                 chan ClientPacketEnvelope
@@ -114,11 +237,21 @@ type room struct {
 	joinReqs              chan roomJoinRequest
 	randomWordsGenerator  RandomWordsGenerator
 	parentLobby           Lobby
+	eventPublisher        GameEventPublisher
+	hostId                string // empty for guest hosts
+	recorder              PacketRecorder
+	// ids of everyone who played in the room, they may watch its replay
+	participantIds []string
+	drawingSaver   DrawingSaver
+	// last seq given to a packet of the room stream
+	seq        uint64
+	resumeRing packetRing
 }
 
 type dataSendTask struct {
 	to   Player
 	data []byte
+	kind packetKind
 }
 
 type pingSendTask struct {
@@ -128,6 +261,8 @@ type pingSendTask struct {
 type playerGameState struct {
 	player         Player
 	username       string
+	wireFormat     WireFormat
+	features       features
 	score          int
 	hasGuessed     bool
 	scoreIncrement int
@@ -161,12 +296,45 @@ type idgen struct {
 
 type GorillaWebSocketWrapper struct {
 	conn *websocket.Conn
+	// text frames for JSON, binary otherwise
+	messageType int
+}
+
+// SSEConnection is the fallback transport for networks that block
+// websockets: packets go down an event stream and come up as POSTs, tied
+// together by a session token that lives as long as the stream.
+type SSEConnection struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	// JSON packets are sent as they are, protobuf ones in base64
+	text    bool
+	token   string
+	userId  string
+	inbox   chan []byte
+	done    chan struct{}
+	closed  bool
+	onClose func()
+}
+
+type sseSessions struct {
+	mu    sync.Mutex
+	conns map[string]*SSEConnection
 }
 
 type GameHandler struct {
 	lobby                Lobby
 	userGetter           UserGetter
 	randomWordsGenerator RandomWordsGenerator
+	eventPublisher       GameEventPublisher
+	recorderCreator      PacketRecorderCreator
+	drawingSaver         DrawingSaver
+	legacyDrawingData    bool
+	maxDrawingHistory    int
+	sendPolicy           SendPolicy
+	writeBatchWindow     time.Duration
+	compression          bool
+	sseSessions          *sseSessions
 }
 
 type ticker struct{}
@@ -3,16 +3,23 @@ package main
 import (
 	"api/auth"
 	"api/crypto"
+	"api/domain"
+	"api/gallery"
 	"api/game"
 	"api/migrations"
+	"api/replay"
 	"api/storage"
+	"api/webhook"
+	"api/words"
 	"context"
+	"expvar"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,10 +29,23 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CreateServer(allowedOrigins []string) *gin.Engine {
+// CreateServer registers the public routes, jwks among them when not nil and
+// those of public, and guards every route added afterwards with the origin
+// check.
+func CreateServer(allowedOrigins []string, jwks gin.HandlerFunc, public ...func(gin.IRouter)) *gin.Engine {
 	r := gin.New()
 	r.SetTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"})
 	r.GET("/health", func(ctx *gin.Context) { ctx.String(200, "healthyyy") })
+	if jwks != nil {
+		// fetched by other services, they send no origin
+		r.GET("/.well-known/jwks.json", jwks)
+	}
+	if METRICS_ENABLED, exists := os.LookupEnv("METRICS_ENABLED"); exists && METRICS_ENABLED == "true" {
+		r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	}
+	for register := range slices.Values(public) {
+		register(r)
+	}
 
 	r.Use(func(ctx *gin.Context) {
 		origin := ctx.Request.Header.Get("Origin")
@@ -57,12 +77,33 @@ func CreateServer(allowedOrigins []string) *gin.Engine {
 	return r
 }
 
+// intEnv reads an optional positive integer env, empty means def.
+func intEnv(name string, def int) int {
+	value, exists := os.LookupEnv(name)
+	if !exists || value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("Invalid %s", name)
+	}
+	return parsed
+}
+
 func main() {
 
 	// logger setup
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	// server <command> runs a maintenance command instead of serving
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// ENVs
 	ALLOWED_ORIGINS, exists := os.LookupEnv("ALLOWED_ORIGINS")
 	if !exists {
@@ -75,10 +116,91 @@ func main() {
 		log.Fatal("Missing postgres url")
 	}
 
-	JWT_KEY, exists := os.LookupEnv("JWT_KEY")
-	if !exists {
+	// JWT_KEY is the HS256 secret of tokens without a kid, JWT_KEYS the keyset
+	// and JWT_ACTIVE_KID the key of it that signs, its first by default
+	signingKeys := []*crypto.SigningKey{}
+	if JWT_KEYS, exists := os.LookupEnv("JWT_KEYS"); exists && JWT_KEYS != "" {
+		keys, err := crypto.LoadKeys(JWT_KEYS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		signingKeys = keys
+	}
+	if JWT_KEY, exists := os.LookupEnv("JWT_KEY"); exists && JWT_KEY != "" {
+		signingKeys = append(signingKeys, crypto.NewHMACKey("", []byte(JWT_KEY)))
+	}
+	if len(signingKeys) == 0 {
 		log.Fatal("Missing jwt signing key")
 	}
+	activeKid := signingKeys[0].Kid
+	if JWT_ACTIVE_KID, exists := os.LookupEnv("JWT_ACTIVE_KID"); exists && JWT_ACTIVE_KID != "" {
+		activeKid = JWT_ACTIVE_KID
+	}
+	keySet, err := crypto.NewKeySet(activeKid, signingKeys...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	webhookConfig := webhook.DefaultConfig()
+	if WEBHOOK_URLS, exists := os.LookupEnv("WEBHOOK_URLS"); exists && WEBHOOK_URLS != "" {
+		WEBHOOK_SECRET, exists := os.LookupEnv("WEBHOOK_SECRET")
+		if !exists {
+			log.Fatal("Missing webhook secret")
+		}
+		for _, u := range strings.Split(WEBHOOK_URLS, ",") {
+			webhookConfig.DeploymentTargets = append(webhookConfig.DeploymentTargets, webhook.Target{URL: u, Secret: WEBHOOK_SECRET})
+		}
+	}
+
+	replayEnabled := false
+	replayConfig := replay.DefaultConfig()
+	if REPLAY_RECORDING, exists := os.LookupEnv("REPLAY_RECORDING"); exists && REPLAY_RECORDING == "true" {
+		replayEnabled = true
+		replayConfig.MaxBytes = intEnv("REPLAY_MAX_BYTES", replayConfig.MaxBytes)
+		replayConfig.MaxAge = time.Hour * 24 * time.Duration(intEnv("REPLAY_RETENTION_DAYS", int(replayConfig.MaxAge/(time.Hour*24))))
+	}
+
+	galleryEnabled := false
+	galleryConfig := gallery.DefaultConfig()
+	if GALLERY_ENABLED, exists := os.LookupEnv("GALLERY_ENABLED"); exists && GALLERY_ENABLED == "true" {
+		galleryEnabled = true
+		galleryConfig.MaxDrawingBytes = intEnv("GALLERY_MAX_DRAWING_BYTES", galleryConfig.MaxDrawingBytes)
+		galleryConfig.MaxDrawingsPerUser = intEnv("GALLERY_MAX_DRAWINGS_PER_USER", galleryConfig.MaxDrawingsPerUser)
+		galleryConfig.MaxAge = time.Hour * 24 * time.Duration(intEnv("GALLERY_RETENTION_DAYS", int(galleryConfig.MaxAge/(time.Hour*24))))
+	}
+
+	// opaque drawing bytes stay accepted until every client sends typed ops
+	legacyDrawingData := true
+	if LEGACY_DRAWING_DATA, exists := os.LookupEnv("LEGACY_DRAWING_DATA"); exists && LEGACY_DRAWING_DATA == "false" {
+		legacyDrawingData = false
+	}
+
+	// OIDC_PROVIDERS lists name|issuer|client_id|client_secret, the callback
+	// of each is OIDC_CALLBACK_BASE_URL/auth/oidc/<name>/callback
+	oidcProviders := []auth.OIDCProviderConfig{}
+	oidcFrontendURL := allowedOrigins[0]
+	if OIDC_PROVIDERS, exists := os.LookupEnv("OIDC_PROVIDERS"); exists && OIDC_PROVIDERS != "" {
+		OIDC_CALLBACK_BASE_URL, exists := os.LookupEnv("OIDC_CALLBACK_BASE_URL")
+		if !exists || OIDC_CALLBACK_BASE_URL == "" {
+			log.Fatal("Missing oidc callback base url")
+		}
+		for _, p := range strings.Split(OIDC_PROVIDERS, ",") {
+			fields := strings.Split(p, "|")
+			if len(fields) != 4 {
+				log.Fatalf("Bad oidc provider %q, want name|issuer|client_id|client_secret", fields[0])
+			}
+			oidcProviders = append(oidcProviders, auth.OIDCProviderConfig{
+				Name:         fields[0],
+				Issuer:       fields[1],
+				ClientId:     fields[2],
+				ClientSecret: fields[3],
+				RedirectURL:  strings.TrimSuffix(OIDC_CALLBACK_BASE_URL, "/") + "/auth/oidc/" + fields[0] + "/callback",
+			})
+		}
+	}
+	if OIDC_FRONTEND_URL, exists := os.LookupEnv("OIDC_FRONTEND_URL"); exists && OIDC_FRONTEND_URL != "" {
+		oidcFrontendURL = OIDC_FRONTEND_URL
+	}
 
 	// run migrations
 	migrations.Migrate(POSTGRES_URL)
@@ -88,21 +210,51 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	tokenAge := time.Hour * 24 * 7 // 7 days
-	passwordHasher := crypto.NewArgon2idHasher(3, 1024*64, 32, 16, 1)
-	tokenManager := crypto.NewJWTManager(JWT_KEY, tokenAge)
+	// sessions last auth.RefreshTokenAge, access tokens are refreshed well before
+	tokenAge := time.Minute * 15
+	argon2idHasher := crypto.NewArgon2idHasher(3, 1024*64, 32, 16, 1)
+	hashBudgetKB := crypto.HashMemoryBudgetKB(argon2idHasher)
+	if budgetMB := intEnv("HASH_MEMORY_BUDGET_MB", 0); budgetMB > 0 {
+		hashBudgetKB = int64(budgetMB) * 1024
+	}
+	hashQueueTimeout := time.Duration(intEnv("HASH_QUEUE_TIMEOUT_MS", 3000)) * time.Millisecond
+	passwordHasher := crypto.NewLimitedHasher(argon2idHasher, hashBudgetKB, hashQueueTimeout)
+	tokenManager := crypto.NewJWTManagerWithKeys(keySet, tokenAge)
+	tokenManager.SetGuestMaxAge(auth.GuestTokenAge)
 
-	authService := auth.NewService(pgRepo, passwordHasher, tokenManager)
+	authService := auth.NewService(pgRepo, pgRepo, pgRepo, passwordHasher, tokenManager)
+	oidcClient := &http.Client{Timeout: time.Second * 10}
+	authService.SetOIDC(pgRepo, crypto.NewIDTokenVerifier(oidcClient), oidcClient, oidcProviders...)
 	authHandler := auth.NewAuthHandler(authService, tokenAge)
+	authHandler.SetLoginThrottle(auth.NewLoginThrottle(auth.NewMemoryAttemptStore(), auth.DefaultUsernameThrottle(), auth.DefaultIPThrottle()))
+	authHandler.SetFrontendURL(oidcFrontendURL)
 
-	r := CreateServer(allowedOrigins)
+	r := CreateServer(allowedOrigins, auth.JWKSHandler(tokenManager), func(r gin.IRouter) {
+		// navigated to from the frontend and the providers, no origin is sent
+		r.GET("/auth/oidc/:provider", authHandler.StartOIDCHandler)
+		r.GET("/auth/oidc/:provider/callback", authHandler.OIDCCallbackHandler)
+	})
 
 	{
 		auth := r.Group("/auth")
 		auth.POST("/signup", authHandler.SignupHandler)
 		auth.POST("/login", authHandler.LoginHandler)
 		auth.POST("/logout", authHandler.LogoutHandler)
+		auth.POST("/logout-all", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.LogoutEverywhereHandler)
 		auth.GET("/refresh", authHandler.RefreshSessionHandler)
+		auth.POST("/guest", authHandler.GuestHandler)
+		auth.POST("/upgrade", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.UpgradeHandler)
+		auth.POST("/password", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.ChangePasswordHandler)
+		auth.POST("/username", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.ChangeUsernameHandler)
+		auth.POST("/delete-account", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.DeleteAccountHandler)
+		auth.POST("/login/mfa", authHandler.LoginMFAHandler)
+		auth.POST("/totp/enroll", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.EnrollTOTPHandler)
+		auth.POST("/totp/confirm", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.ConfirmTOTPHandler)
+		auth.POST("/totp/disable", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.DisableTOTPHandler)
+		auth.GET("/oidc", authHandler.OIDCProvidersHandler)
+		auth.POST("/oidc/signup", authHandler.OIDCSignupHandler)
+		auth.POST("/oidc/:provider/link", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.StartOIDCLinkHandler)
+		auth.POST("/oidc/:provider/reauth", authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware(), authHandler.StartOIDCReauthHandler)
 	}
 
 	idGen := game.NewIdGen()
@@ -114,7 +266,75 @@ func main() {
 	go lobby.LobbyActor(lobbyStarted)
 	<-lobbyStarted
 
-	gameHandler := game.NewGameHandler(lobby, pgRepo, pgRepo)
+	webhookDispatcher := webhook.NewDispatcher(webhookConfig, pgRepo, pgRepo)
+	webhookDispatcher.Start()
+	webhookHandler := webhook.NewWebhookHandler(pgRepo, 5)
+	{
+		webhooks := r.Group("/webhooks")
+		webhooks.Use(authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware())
+		webhooks.GET("", webhookHandler.ListWebhooksHandler)
+		webhooks.POST("", webhookHandler.CreateWebhookHandler)
+		webhooks.DELETE("/:webhookid", webhookHandler.DeleteWebhookHandler)
+	}
+
+	// left nil unless enabled, a typed nil would still be called by rooms
+	var recorders game.PacketRecorderCreator
+	recorderFactory := replay.NewRecorderFactory(replayConfig, pgRepo)
+	if replayEnabled {
+		recorderFactory.Start()
+		recorders = recorderFactory
+	}
+	replayHandler := replay.NewReplayHandler(pgRepo)
+
+	var drawingSaver game.DrawingSaver
+	galleryArchiver := gallery.NewArchiver(galleryConfig, pgRepo)
+	if galleryEnabled {
+		galleryArchiver.Start()
+		drawingSaver = galleryArchiver
+	}
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	go authService.PruneSessions(pruneCtx, time.Hour)
+	galleryHandler := gallery.NewGalleryHandler(pgRepo)
+	{
+		drawings := r.Group("/drawings")
+		drawings.Use(authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware())
+		drawings.GET("", galleryHandler.ListDrawingsHandler)
+		drawings.GET("/:drawingid", galleryHandler.GetDrawingHandler)
+		drawings.PUT("/:drawingid/like", galleryHandler.LikeDrawingHandler)
+		drawings.DELETE("/:drawingid/like", galleryHandler.UnlikeDrawingHandler)
+		drawings.PUT("/:drawingid/favourite", galleryHandler.FavouriteDrawingHandler)
+		drawings.DELETE("/:drawingid/favourite", galleryHandler.UnfavouriteDrawingHandler)
+		drawings.DELETE("/:drawingid", authHandler.RequirePermission(domain.PermModerateDrawings), galleryHandler.DeleteDrawingHandler)
+	}
+
+	wordsHandler := words.NewWordsHandler(pgRepo)
+	{
+		admin := r.Group("/admin")
+		admin.Use(authHandler.RequireAuthMiddleware(time.Second*2), authHandler.RequireAccountMiddleware())
+		admin.GET("/words", authHandler.RequirePermission(domain.PermManageWords), wordsHandler.ListWordsHandler)
+		admin.POST("/words", authHandler.RequirePermission(domain.PermManageWords), wordsHandler.AddWordHandler)
+		admin.DELETE("/words/:word", authHandler.RequirePermission(domain.PermManageWords), wordsHandler.DeleteWordHandler)
+		admin.POST("/users/:username/role", authHandler.RequirePermission(domain.PermManageRoles), authHandler.SetRoleHandler)
+	}
+
+	gameHandler := game.NewGameHandler(lobby, pgRepo, pgRepo, webhookDispatcher, recorders, drawingSaver)
+	gameHandler.SetLegacyDrawingData(legacyDrawingData)
+	if maxDrawingHistory := intEnv("DRAWING_HISTORY_MAX_BYTES", 0); maxDrawingHistory > 0 {
+		gameHandler.SetMaxDrawingHistoryBytes(maxDrawingHistory)
+	}
+	if PLAYER_SEND_POLICY, exists := os.LookupEnv("PLAYER_SEND_POLICY"); exists && PLAYER_SEND_POLICY != "" {
+		sendPolicy, err := game.ParseSendPolicy(PLAYER_SEND_POLICY)
+		if err != nil {
+			log.Fatalf("Invalid PLAYER_SEND_POLICY %q", PLAYER_SEND_POLICY)
+		}
+		gameHandler.SetSendPolicy(sendPolicy)
+	}
+	if batchWindow := intEnv("WS_WRITE_BATCH_MS", 0); batchWindow > 0 {
+		gameHandler.SetWriteBatchWindow(time.Duration(batchWindow) * time.Millisecond)
+	}
+	if WS_COMPRESSION, exists := os.LookupEnv("WS_COMPRESSION"); exists && WS_COMPRESSION == "true" {
+		gameHandler.SetCompression(true)
+	}
 	{
 		gameGroup := r.Group("/game")
 		gameGroup.Use(authHandler.RequireAuthMiddleware(time.Second * 2))
@@ -122,7 +342,12 @@ func main() {
 		gameGroup.GET("/create", gameHandler.CreateGameHandler)
 
 		gameGroup.GET("/join/:roomid", gameHandler.JoinGameHandler)
+		// packets of clients on the SSE fallback transport
+		gameGroup.POST("/sse/:token", gameHandler.SSEPostHandler)
 		gameGroup.GET("/games", gameHandler.GetPublicGamesHandler)
+
+		gameGroup.GET("/replays", replayHandler.ListReplaysHandler)
+		gameGroup.GET("/replay/:replayid", replayHandler.StreamReplayHandler)
 	}
 
 	go r.Run(":5000")
@@ -134,6 +359,17 @@ func main() {
 	println("SIGTERM or SIGINT received, waiting for rooms to finish before shutting down")
 
 	wg.Wait()
+	stopPruning()
+	println("Flushing pending webhooks, drawings and replays")
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), time.Second*30)
+	webhookDispatcher.Close(flushCtx)
+	if galleryEnabled {
+		galleryArchiver.Close(flushCtx)
+	}
+	if replayEnabled {
+		recorderFactory.Close(flushCtx)
+	}
+	cancelFlush()
 	println("Shutting down now")
 
 }
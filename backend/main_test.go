@@ -1,6 +1,9 @@
 package main
 
 import (
+	"api/auth"
+	"api/domain"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,7 +15,7 @@ import (
 func TestCSRFProtection(t *testing.T) {
 	t.Parallel()
 	gin.SetMode(gin.TestMode)
-	r := CreateServer([]string{"http://localhost:3000", "https://oussama.com"})
+	r := CreateServer([]string{"http://localhost:3000", "https://oussama.com"}, nil)
 
 	r.GET("/testroute", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "success")
@@ -136,7 +139,7 @@ func TestCORSHeaders(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			r := CreateServer(allowedOrigins)
+			r := CreateServer(allowedOrigins, nil)
 			r.GET("/test", func(c *gin.Context) { c.Status(200) })
 			r.POST("/test", func(c *gin.Context) { c.Status(200) })
 
@@ -162,7 +165,7 @@ func TestWebsocketHandshakeHeaders(t *testing.T) {
 	t.Parallel()
 	gin.SetMode(gin.TestMode)
 
-	r := CreateServer([]string{"http://localhost:3000"})
+	r := CreateServer([]string{"http://localhost:3000"}, nil)
 
 	r.GET("/ws-test", func(c *gin.Context) {
 		c.Status(http.StatusSwitchingProtocols)
@@ -211,3 +214,70 @@ func TestWebsocketHandshakeHeaders(t *testing.T) {
 		})
 	}
 }
+
+type staticJWKS string
+
+func (s staticJWKS) PublicJWKS() ([]byte, error) {
+	return []byte(s), nil
+}
+
+func TestJWKS_Is_Public(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	r := CreateServer([]string{"http://localhost:3000"}, auth.JWKSHandler(staticJWKS(`{"keys":[]}`)))
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "other services send no origin")
+	assert.Equal(t, "application/jwk-set+json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+}
+
+func TestPublicRoutes_Skip_The_Origin_Check(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+	r := CreateServer([]string{"http://localhost:3000"}, nil, func(r gin.IRouter) {
+		r.GET("/auth/oidc/:provider", func(ctx *gin.Context) { ctx.Status(http.StatusFound) })
+	})
+	r.GET("/auth/oidc", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/google", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code, "navigations send no origin")
+
+	req = httptest.NewRequest(http.MethodGet, "/auth/oidc", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+type fakeBootstrapper struct {
+	err      error
+	username string
+}
+
+func (f *fakeBootstrapper) BootstrapAdmin(ctx context.Context, username string) error {
+	f.username = username
+	return f.err
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	repo := &fakeBootstrapper{}
+	assert.NoError(t, bootstrapAdmin(ctx, repo, "oussama"))
+	assert.Equal(t, "oussama", repo.username)
+
+	err := bootstrapAdmin(ctx, &fakeBootstrapper{err: domain.ErrAdminExists}, "oussama")
+	assert.ErrorContains(t, err, "an admin exists already")
+	err = bootstrapAdmin(ctx, &fakeBootstrapper{err: domain.ErrUserNotFound}, "nobody")
+	assert.ErrorContains(t, err, `no account is named "nobody"`)
+
+	for _, args := range [][]string{{"bootstrap-admin"}, {"bootstrap-admin", "a", "b"}, {"drop-database"}} {
+		assert.ErrorIs(t, runCommand(ctx, args), errUsage, args)
+	}
+}
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE drawings(
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    drawer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    room_id VARCHAR(16) NOT NULL,
+    word TEXT NOT NULL,
+    guessers TEXT[] NOT NULL,
+    strokes BYTEA NOT NULL,
+    size INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX drawings_drawer_id_created_at_idx ON drawings(drawer_id, created_at DESC);
+CREATE INDEX drawings_created_at_idx ON drawings(created_at);
+
+CREATE TABLE drawing_likes(
+    drawing_id UUID NOT NULL REFERENCES drawings(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (drawing_id, user_id)
+);
+
+CREATE TABLE drawing_favourites(
+    drawing_id UUID NOT NULL REFERENCES drawings(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (drawing_id, user_id)
+);
+CREATE INDEX drawing_favourites_user_id_idx ON drawing_favourites(user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE drawing_favourites;
+DROP TABLE drawing_likes;
+DROP TABLE drawings;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- replays recorded before are visible to nobody, who played in them is unknown
+ALTER TABLE replays ADD COLUMN private BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE replays ADD COLUMN participant_ids TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX replays_participant_ids_idx ON replays USING GIN (participant_ids);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX replays_participant_ids_idx;
+ALTER TABLE replays DROP COLUMN participant_ids;
+ALTER TABLE replays DROP COLUMN private;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- words differing only in case are one word: a lowercase copy goes when a
+-- differently-cased spelling exists, otherwise the first spelling stays
+DELETE FROM words w USING words o
+WHERE lower(w.word) = lower(o.word) AND w.word <> o.word
+    AND (w.word = lower(w.word), w.word) > (o.word = lower(o.word), o.word);
+CREATE UNIQUE INDEX words_lower_word_idx ON words(lower(word));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- only drops the index, the deduplicated words are not restored
+DROP INDEX words_lower_word_idx;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- drawings saved before hold dende parts, the server can not render them
+ALTER TABLE drawings ADD COLUMN drawing_ops BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE drawings DROP COLUMN drawing_ops;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+package replay
+
+import "errors"
+
+var (
+	ErrCorruptedReplay          = errors.New("corrupted-replay")
+	ErrUnsupportedReplayVersion = errors.New("unsupported-replay-version")
+)
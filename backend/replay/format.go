@@ -0,0 +1,104 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+/*
+	Replay blob layout:
+
+	"GTOR" | version (1 byte) | uvarint recording start (unix ms) | gzip(frames)
+
+	Each frame is: uvarint delay since previous frame (ms) | uvarint length | marshalled ServerPacket
+*/
+
+const formatVersion = 1
+
+// a single websocket message never exceeds this
+const maxPacketSize = 1 << 20
+
+var magic = []byte("GTOR")
+
+type Frame struct {
+	Offset time.Duration // since the start of the recording
+	Packet []byte
+}
+
+// appendFrame appends one uncompressed frame to buf.
+func appendFrame(buf []byte, delay time.Duration, packet []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(delay.Milliseconds()))
+	buf = binary.AppendUvarint(buf, uint64(len(packet)))
+	return append(buf, packet...)
+}
+
+// encode wraps raw frames into a replay blob.
+func encode(start time.Time, frames []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(frames)/4))
+	out.Write(magic)
+	out.WriteByte(formatVersion)
+	out.Write(binary.AppendUvarint(nil, uint64(start.UnixMilli())))
+
+	zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(frames); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Decode parses a replay blob produced by a recorder.
+func Decode(blob []byte) (time.Time, []Frame, error) {
+	if len(blob) < len(magic)+1 || !bytes.Equal(blob[:len(magic)], magic) {
+		return time.Time{}, nil, ErrCorruptedReplay
+	}
+	if blob[len(magic)] != formatVersion {
+		return time.Time{}, nil, ErrUnsupportedReplayVersion
+	}
+	r := bytes.NewReader(blob[len(magic)+1:])
+	startMs, err := binary.ReadUvarint(r)
+	if err != nil {
+		return time.Time{}, nil, ErrCorruptedReplay
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return time.Time{}, nil, ErrCorruptedReplay
+	}
+	defer zr.Close()
+	br := bufio.NewReader(zr)
+
+	frames := []Frame{}
+	var offset time.Duration
+	for {
+		delay, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return time.Time{}, nil, ErrCorruptedReplay
+		}
+		length, err := binary.ReadUvarint(br)
+		if err != nil || length > maxPacketSize {
+			return time.Time{}, nil, ErrCorruptedReplay
+		}
+		packet := make([]byte, length)
+		if _, err := io.ReadFull(br, packet); err != nil {
+			return time.Time{}, nil, ErrCorruptedReplay
+		}
+		offset += time.Duration(delay) * time.Millisecond
+		frames = append(frames, Frame{Offset: offset, Packet: packet})
+	}
+
+	return time.UnixMilli(int64(startMs)), frames, nil
+}
@@ -0,0 +1,58 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	t.Parallel()
+	start := time.UnixMilli(1760788800123)
+
+	frames := appendFrame(nil, 0, []byte("first"))
+	frames = appendFrame(frames, 1500*time.Millisecond, []byte{})
+	frames = appendFrame(frames, 250*time.Millisecond, []byte("third"))
+
+	blob, err := encode(start, frames)
+	require.NoError(t, err)
+
+	decodedStart, decoded, err := Decode(blob)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(decodedStart))
+	assert.Equal(t, []Frame{
+		{Offset: 0, Packet: []byte("first")},
+		{Offset: 1500 * time.Millisecond, Packet: []byte{}},
+		{Offset: 1750 * time.Millisecond, Packet: []byte("third")},
+	}, decoded)
+}
+
+func TestDecode_Rejects_Bad_Blobs(t *testing.T) {
+	t.Parallel()
+	valid, err := encode(time.UnixMilli(1), appendFrame(nil, 0, []byte("packet")))
+	require.NoError(t, err)
+
+	wrongVersion := append([]byte{}, valid...)
+	wrongVersion[len(magic)] = 9
+
+	testCases := []struct {
+		name string
+		blob []byte
+		err  error
+	}{
+		{name: "empty", blob: nil, err: ErrCorruptedReplay},
+		{name: "wrong magic", blob: []byte("NOPE\x01"), err: ErrCorruptedReplay},
+		{name: "wrong version", blob: wrongVersion, err: ErrUnsupportedReplayVersion},
+		{name: "truncated", blob: valid[:len(valid)-6], err: ErrCorruptedReplay},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := Decode(tc.blob)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
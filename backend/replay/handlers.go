@@ -0,0 +1,122 @@
+package replay
+
+import (
+	"api/domain"
+	"api/game"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrReplayNotFoundStr  = "replay-not-found"
+	ErrInvalidSpeedStr    = "invalid-speed"
+	ErrCorruptedReplayStr = "corrupted-replay"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		// Origin check is handled by the main CSRF middleware
+		return true
+	},
+}
+
+type replayHandler struct {
+	store ReplayStore
+}
+
+func NewReplayHandler(store ReplayStore) *replayHandler {
+	return &replayHandler{store: store}
+}
+
+type ReplayResponse struct {
+	Id        string    `json:"id"`
+	RoomId    string    `json:"roomId"`
+	Private   bool      `json:"private"`
+	StartedAt time.Time `json:"startedAt"`
+	Size      int       `json:"size"`
+}
+
+// ListReplaysHandler lists the replays of rooms the user played in.
+func (rh *replayHandler) ListReplaysHandler(ctx *gin.Context) {
+	replays, err := rh.store.ListReplays(ctx.Request.Context(), ctx.GetString("id"), 50)
+	if err != nil {
+		slog.Error("ListReplays: failed to list replays", "error", err.Error())
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+
+	response := make([]ReplayResponse, 0, len(replays))
+	for _, r := range replays {
+		response = append(response, ReplayResponse{Id: r.Id, RoomId: r.RoomId, Private: r.Private, StartedAt: r.StartedAt, Size: r.Size})
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// StreamReplayHandler plays a replay back over a websocket using the regular
+// ServerPacket protocol. The optional speed query param accelerates playback.
+// Only those who played in the room may watch it, it holds what guessers said.
+func (rh *replayHandler) StreamReplayHandler(ctx *gin.Context) {
+	speed := 1.0
+	if s := ctx.Query("speed"); s != "" {
+		parsed, err := strconv.ParseFloat(s, 64)
+		if err != nil || parsed < MinSpeed || parsed > MaxSpeed {
+			ctx.String(http.StatusBadRequest, ErrInvalidSpeedStr)
+			return
+		}
+		speed = parsed
+	}
+
+	replay, err := rh.store.GetReplay(ctx.Request.Context(), ctx.Param("replayid"), ctx.GetString("id"))
+	if err != nil {
+		if errors.Is(err, domain.ErrReplayNotFound) {
+			ctx.String(http.StatusNotFound, ErrReplayNotFoundStr)
+			return
+		}
+		slog.Error("StreamReplay: failed to get replay", "error", err.Error(), "replay_id", ctx.Param("replayid"))
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+
+	start, frames, err := Decode(replay.Data)
+	if err != nil {
+		slog.Error("StreamReplay: failed to decode replay", "error", err.Error(), "replay_id", replay.Id)
+		ctx.String(http.StatusInternalServerError, ErrCorruptedReplayStr)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		return
+	}
+	wsConn := game.NewGorillaWebSocketWrapper(conn)
+
+	go func() {
+		defer wsConn.Close()
+		playCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the viewer never sends anything, reading only detects disconnects
+		go func() {
+			defer cancel()
+			for {
+				if _, err := wsConn.Read(); err != nil {
+					return
+				}
+			}
+		}()
+
+		err := Play(playCtx, wsConn, start, frames, speed, time.Now, sleepCtx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Warn("StreamReplay: playback stopped", "error", err.Error(), "replay_id", replay.Id)
+		}
+	}()
+}
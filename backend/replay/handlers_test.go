@@ -0,0 +1,183 @@
+package replay
+
+import (
+	"api/domain"
+	"api/domain/protobuf"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestListReplaysHandler(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name         string
+		setupMocks   func(s *MockReplayStore)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "lists replays",
+			setupMocks: func(s *MockReplayStore) {
+				s.On("ListReplays", mock.Anything, "user-1", 50).Return([]domain.Replay{{Id: "replay-1", RoomId: "ROOM1", Size: 42}}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"id":"replay-1"`,
+		},
+		{
+			name: "database error",
+			setupMocks: func(s *MockReplayStore) {
+				s.On("ListReplays", mock.Anything, "user-1", 50).Return([]domain.Replay{}, errors.New("boom"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "unknown-error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := &MockReplayStore{}
+			tc.setupMocks(store)
+			rh := NewReplayHandler(store)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/game/replays", nil)
+			ctx.Set("id", "user-1")
+
+			rh.ListReplaysHandler(ctx)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+		})
+	}
+}
+
+func TestStreamReplayHandler_Rejects(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name         string
+		query        string
+		setupMocks   func(s *MockReplayStore)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "speed too high",
+			query:        "?speed=64",
+			setupMocks:   func(s *MockReplayStore) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: ErrInvalidSpeedStr,
+		},
+		{
+			name:         "speed not a number",
+			query:        "?speed=fast",
+			setupMocks:   func(s *MockReplayStore) {},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: ErrInvalidSpeedStr,
+		},
+		{
+			name:  "replay of a room the user did not play in",
+			query: "",
+			setupMocks: func(s *MockReplayStore) {
+				s.On("GetReplay", mock.Anything, "replay-1", "user-1").Return(domain.Replay{}, domain.ErrReplayNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: ErrReplayNotFoundStr,
+		},
+		{
+			name:  "unknown replay",
+			query: "",
+			setupMocks: func(s *MockReplayStore) {
+				s.On("GetReplay", mock.Anything, "replay-1", "user-1").Return(domain.Replay{}, domain.ErrReplayNotFound)
+			},
+			expectedCode: http.StatusNotFound,
+			expectedBody: ErrReplayNotFoundStr,
+		},
+		{
+			name:  "corrupted replay",
+			query: "",
+			setupMocks: func(s *MockReplayStore) {
+				s.On("GetReplay", mock.Anything, "replay-1", "user-1").Return(domain.Replay{Id: "replay-1", Data: []byte("garbage")}, nil)
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: ErrCorruptedReplayStr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := &MockReplayStore{}
+			tc.setupMocks(store)
+			rh := NewReplayHandler(store)
+
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/game/replay/replay-1"+tc.query, nil)
+			ctx.Params = gin.Params{{Key: "replayid", Value: "replay-1"}}
+			ctx.Set("id", "user-1")
+
+			rh.StreamReplayHandler(ctx)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestStreamReplayHandler_Streams_Packets(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	start := time.Now()
+	frames := appendFrame(nil, 0, must(proto.Marshal(protobuf.MakePacketPlayerJoined("naruto"))))
+	frames = appendFrame(frames, 20*time.Millisecond, must(proto.Marshal(protobuf.MakePacketGameStarted())))
+	blob := must(encode(start, frames))
+
+	store := &MockReplayStore{}
+	store.On("GetReplay", mock.Anything, "replay-1", "user-1").Return(domain.Replay{Id: "replay-1", Data: blob}, nil)
+
+	r := gin.New()
+	r.GET("/game/replay/:replayid", func(ctx *gin.Context) { ctx.Set("id", "user-1") }, NewReplayHandler(store).StreamReplayHandler)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/game/replay/replay-1?speed=2"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	received := []*protobuf.ServerPacket{}
+	for range 2 {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		packet := &protobuf.ServerPacket{}
+		require.NoError(t, proto.Unmarshal(data, packet))
+		received = append(received, packet)
+	}
+
+	assert.Equal(t, "naruto", received[0].GetPlayerJoined().Username)
+	assert.NotNil(t, received[1].GetGameStarted())
+
+	// the server closes the socket once playback is over
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+}
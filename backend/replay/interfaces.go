@@ -0,0 +1,20 @@
+package replay
+
+import (
+	"api/domain"
+	"context"
+	"time"
+)
+
+type ReplayStore interface {
+	SaveReplay(ctx context.Context, replay domain.Replay) (string, error)
+	// GetReplay and ListReplays only return replays userId played in.
+	GetReplay(ctx context.Context, id string, userId string) (domain.Replay, error)
+	ListReplays(ctx context.Context, userId string, limit int) ([]domain.Replay, error)
+	DeleteReplaysBefore(ctx context.Context, cutoff time.Time) error
+}
+
+// PacketWriter is the write side of game.WebsocketConnection.
+type PacketWriter interface {
+	Write(data []byte) error
+}
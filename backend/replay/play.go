@@ -0,0 +1,67 @@
+package replay
+
+import (
+	"api/domain/protobuf"
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 16
+)
+
+// Play writes the frames to w, waiting between them as they were recorded
+// divided by speed. Timestamps inside the packets are shifted so they look
+// like they were emitted now, which keeps client countdowns correct.
+func Play(ctx context.Context, w PacketWriter, start time.Time, frames []Frame, speed float64, now func() time.Time, sleep func(ctx context.Context, d time.Duration) error) error {
+	playbackStart := now()
+	shift := func(ts int64) int64 {
+		if ts == 0 {
+			return 0
+		}
+		recorded := time.UnixMilli(ts).Sub(start)
+		return playbackStart.Add(time.Duration(float64(recorded) / speed)).UnixMilli()
+	}
+
+	var elapsed time.Duration
+	for _, f := range frames {
+		target := time.Duration(float64(f.Offset) / speed)
+		if target > elapsed {
+			if err := sleep(ctx, target-elapsed); err != nil {
+				return err
+			}
+			elapsed = target
+		}
+
+		packet := &protobuf.ServerPacket{}
+		if err := proto.Unmarshal(f.Packet, packet); err != nil {
+			return ErrCorruptedReplay
+		}
+		packet.ServerTimestamp = shift(packet.ServerTimestamp)
+		if snapshot, ok := packet.Payload.(*protobuf.ServerPacket_InitialRoomSnapshot_); ok {
+			snapshot.InitialRoomSnapshot.NextTick = shift(snapshot.InitialRoomSnapshot.NextTick)
+		}
+		data, err := proto.Marshal(packet)
+		if err != nil {
+			return err
+		}
+		if err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package replay
+
+import (
+	"api/domain/protobuf"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type recordingWriter struct {
+	packets []*protobuf.ServerPacket
+	err     error
+}
+
+func (w *recordingWriter) Write(data []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	packet := &protobuf.ServerPacket{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return err
+	}
+	w.packets = append(w.packets, packet)
+	return nil
+}
+
+func marshal(t *testing.T, p *protobuf.ServerPacket, ts int64) []byte {
+	t.Helper()
+	p.ServerTimestamp = ts
+	data, err := proto.Marshal(p)
+	require.NoError(t, err)
+	return data
+}
+
+func TestPlay(t *testing.T) {
+	t.Parallel()
+	recordedAt := time.UnixMilli(1_000_000)
+	playedAt := time.UnixMilli(9_000_000)
+
+	snapshot := protobuf.MakePacketInitialRoomSnapshot(nil, nil, "", 1, "ROOM1", 2, recordedAt.Add(60*time.Second).UnixMilli(), 15, 60)
+	frames := []Frame{
+		{Offset: 0, Packet: marshal(t, snapshot, recordedAt.UnixMilli())},
+		{Offset: 4 * time.Second, Packet: marshal(t, protobuf.MakePacketPlayerJoined("naruto"), recordedAt.Add(4*time.Second).UnixMilli())},
+		{Offset: 4 * time.Second, Packet: marshal(t, protobuf.MakePacketPlayerLeft("naruto"), 0)},
+		{Offset: 10 * time.Second, Packet: marshal(t, protobuf.MakePacketGameStarted(), recordedAt.Add(10*time.Second).UnixMilli())},
+	}
+
+	testCases := []struct {
+		name           string
+		speed          float64
+		expectedSleeps []time.Duration
+	}{
+		{name: "real time", speed: 1, expectedSleeps: []time.Duration{4 * time.Second, 6 * time.Second}},
+		{name: "accelerated", speed: 4, expectedSleeps: []time.Duration{time.Second, 1500 * time.Millisecond}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			w := &recordingWriter{}
+			sleeps := []time.Duration{}
+			sleep := func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			err := Play(context.Background(), w, recordedAt, frames, tc.speed, func() time.Time { return playedAt }, sleep)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSleeps, sleeps)
+			require.Len(t, w.packets, 4)
+			assert.Equal(t, playedAt.UnixMilli(), w.packets[0].ServerTimestamp)
+			nextTick := playedAt.Add(time.Duration(float64(60*time.Second) / tc.speed)).UnixMilli()
+			assert.Equal(t, nextTick, w.packets[0].GetInitialRoomSnapshot().NextTick)
+			assert.Equal(t, "naruto", w.packets[1].GetPlayerJoined().Username)
+			assert.Zero(t, w.packets[2].ServerTimestamp)
+			assert.Equal(t, playedAt.Add(time.Duration(float64(10*time.Second)/tc.speed)).UnixMilli(), w.packets[3].ServerTimestamp)
+		})
+	}
+}
+
+func TestPlay_Stops_On_Errors(t *testing.T) {
+	t.Parallel()
+	frames := []Frame{
+		{Offset: 0, Packet: marshal(t, protobuf.MakePacketGameStarted(), 0)},
+		{Offset: time.Second, Packet: marshal(t, protobuf.MakePacketGameStarted(), 0)},
+	}
+	now := func() time.Time { return time.UnixMilli(0) }
+	noSleep := func(ctx context.Context, d time.Duration) error { return nil }
+
+	writeErr := errors.New("broken pipe")
+	err := Play(context.Background(), &recordingWriter{err: writeErr}, time.UnixMilli(0), frames, 1, now, noSleep)
+	assert.ErrorIs(t, err, writeErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := &recordingWriter{}
+	err = Play(ctx, w, time.UnixMilli(0), frames, 1, now, sleepCtx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, w.packets, 1)
+
+	err = Play(context.Background(), &recordingWriter{}, time.UnixMilli(0), []Frame{{Packet: []byte{0xff}}}, 1, now, noSleep)
+	assert.ErrorIs(t, err, ErrCorruptedReplay)
+}
@@ -0,0 +1,161 @@
+package replay
+
+import (
+	"api/domain"
+	"api/game"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	MaxBytes      int           // uncompressed, recording stops past it
+	MaxAge        time.Duration // 0 keeps replays forever
+	PruneInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxBytes:      8 * 1024 * 1024,
+		MaxAge:        time.Hour * 24 * 30,
+		PruneInterval: time.Hour,
+	}
+}
+
+// recorder is owned by a single room actor, so it needs no locking. Record only
+// appends to an in-memory buffer; compression and storage happen in Finish on a
+// separate goroutine.
+type recorder struct {
+	factory   *RecorderFactory
+	start     time.Time
+	last      time.Time
+	frames    []byte
+	truncated bool
+}
+
+// RecorderFactory creates the recorders of rooms, saves what they recorded
+// and deletes replays older than Config.MaxAge.
+type RecorderFactory struct {
+	config Config
+	store  ReplayStore
+	saves  sync.WaitGroup
+	stop   chan struct{}
+	done   chan struct{}
+	now    func() time.Time
+}
+
+func NewRecorderFactory(config Config, store ReplayStore) *RecorderFactory {
+	return &RecorderFactory{
+		config: config,
+		store:  store,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		now:    time.Now,
+	}
+}
+
+func (f *RecorderFactory) Create() game.PacketRecorder {
+	return &recorder{
+		factory: f,
+		frames:  make([]byte, 0, 64*1024),
+	}
+}
+
+// Start prunes expired replays in the background until Close.
+func (f *RecorderFactory) Start() {
+	go f.run()
+}
+
+// Close stops pruning and waits until the finished recordings are saved or
+// ctx expires.
+func (f *RecorderFactory) Close(ctx context.Context) {
+	close(f.stop)
+	saved := make(chan struct{})
+	go func() {
+		f.saves.Wait()
+		<-f.done
+		close(saved)
+	}()
+	select {
+	case <-saved:
+	case <-ctx.Done():
+	}
+}
+
+func (f *RecorderFactory) run() {
+	defer close(f.done)
+
+	var prune <-chan time.Time
+	if f.config.MaxAge > 0 {
+		ticker := time.NewTicker(f.config.PruneInterval)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-prune:
+			f.pruneExpired()
+		}
+	}
+}
+
+func (f *RecorderFactory) pruneExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if err := f.store.DeleteReplaysBefore(ctx, f.now().Add(-f.config.MaxAge)); err != nil {
+		slog.Error("Replay: failed to delete expired replays", "error", err.Error())
+	}
+}
+
+func (rec *recorder) Record(at time.Time, packet []byte) {
+	if rec.truncated {
+		return
+	}
+	if rec.start.IsZero() {
+		rec.start = at
+		rec.last = at
+	}
+	if len(rec.frames)+len(packet)+20 > rec.factory.config.MaxBytes {
+		rec.truncated = true
+		return
+	}
+	delay := max(at.Sub(rec.last), 0)
+	rec.last = rec.last.Add(delay.Truncate(time.Millisecond))
+	rec.frames = appendFrame(rec.frames, delay, packet)
+}
+
+// Finish saves the recording with the room id, privacy and participants of
+// replay.
+func (rec *recorder) Finish(replay domain.Replay) {
+	if rec.start.IsZero() {
+		return
+	}
+	frames, truncated := rec.frames, rec.truncated
+	replay.StartedAt = rec.start
+	rec.frames = nil
+
+	f := rec.factory
+	f.saves.Add(1)
+	go func() {
+		defer f.saves.Done()
+		blob, err := encode(replay.StartedAt, frames)
+		if err != nil {
+			slog.Error("Replay: failed to encode replay", "error", err.Error(), "room_id", replay.RoomId)
+			return
+		}
+		replay.Data = blob
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		id, err := f.store.SaveReplay(ctx, replay)
+		if err != nil {
+			slog.Error("Replay: failed to save replay", "error", err.Error(), "room_id", replay.RoomId)
+			return
+		}
+		slog.Info("Replay: saved", "replay_id", id, "room_id", replay.RoomId, "size", len(blob), "truncated", truncated)
+	}()
+}
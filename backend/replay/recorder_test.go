@@ -0,0 +1,167 @@
+package replay
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReplayStore struct {
+	mock.Mock
+}
+
+func (m *MockReplayStore) SaveReplay(ctx context.Context, replay domain.Replay) (string, error) {
+	args := m.Called(ctx, replay)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockReplayStore) GetReplay(ctx context.Context, id string, userId string) (domain.Replay, error) {
+	args := m.Called(ctx, id, userId)
+	return args.Get(0).(domain.Replay), args.Error(1)
+}
+
+func (m *MockReplayStore) ListReplays(ctx context.Context, userId string, limit int) ([]domain.Replay, error) {
+	args := m.Called(ctx, userId, limit)
+	return args.Get(0).([]domain.Replay), args.Error(1)
+}
+
+func (m *MockReplayStore) DeleteReplaysBefore(ctx context.Context, cutoff time.Time) error {
+	return m.Called(ctx, cutoff).Error(0)
+}
+
+func testConfig(maxBytes int) Config {
+	config := DefaultConfig()
+	config.MaxBytes = maxBytes
+	return config
+}
+
+func TestRecorder_Finish_Saves_Decodable_Replay(t *testing.T) {
+	t.Parallel()
+	start := time.UnixMilli(1760788800000)
+
+	saved := make(chan domain.Replay, 1)
+	store := &MockReplayStore{}
+	store.On("SaveReplay", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		saved <- args.Get(1).(domain.Replay)
+	}).Return("replay-1", nil).Once()
+
+	rec := NewRecorderFactory(testConfig(1<<20), store).Create()
+	rec.Record(start, []byte("snapshot"))
+	rec.Record(start.Add(2*time.Second), []byte("joined"))
+	rec.Record(start.Add(2500*time.Millisecond), []byte("message"))
+	rec.Finish(domain.Replay{RoomId: "ROOM1", Private: true, ParticipantIds: []string{"host-id"}})
+
+	var replay domain.Replay
+	select {
+	case replay = <-saved:
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "replay was never saved")
+	}
+
+	assert.Equal(t, "ROOM1", replay.RoomId)
+	assert.True(t, replay.Private)
+	assert.Equal(t, []string{"host-id"}, replay.ParticipantIds)
+	assert.True(t, start.Equal(replay.StartedAt))
+	decodedStart, frames, err := Decode(replay.Data)
+	require.NoError(t, err)
+	assert.True(t, start.Equal(decodedStart))
+	assert.Equal(t, []Frame{
+		{Offset: 0, Packet: []byte("snapshot")},
+		{Offset: 2 * time.Second, Packet: []byte("joined")},
+		{Offset: 2500 * time.Millisecond, Packet: []byte("message")},
+	}, frames)
+}
+
+func TestRecorder_Stops_At_Max_Bytes(t *testing.T) {
+	t.Parallel()
+	rec := NewRecorderFactory(testConfig(64), &MockReplayStore{}).Create().(*recorder)
+	now := time.UnixMilli(1)
+
+	rec.Record(now, make([]byte, 20))
+	rec.Record(now, make([]byte, 40))
+	rec.Record(now, make([]byte, 1))
+
+	assert.True(t, rec.truncated)
+	_, frames, err := Decode(must(encode(rec.start, rec.frames)))
+	require.NoError(t, err)
+	assert.Len(t, frames, 1)
+}
+
+func TestRecorder_Finish_Without_Packets_Saves_Nothing(t *testing.T) {
+	t.Parallel()
+	store := &MockReplayStore{}
+	NewRecorderFactory(testConfig(1024), store).Create().Finish(domain.Replay{RoomId: "ROOM1"})
+	store.AssertNotCalled(t, "SaveReplay", mock.Anything, mock.Anything)
+}
+
+func TestRecorderFactory_Close_Waits_For_Saves(t *testing.T) {
+	t.Parallel()
+	store := &MockReplayStore{}
+	release := make(chan struct{})
+	store.On("SaveReplay", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		<-release
+	}).Return("replay-1", nil).Once()
+
+	f := NewRecorderFactory(testConfig(1024), store)
+	f.Start()
+	rec := f.Create()
+	rec.Record(time.Now(), []byte("snapshot"))
+	rec.Finish(domain.Replay{RoomId: "ROOM1"})
+
+	closed := make(chan struct{})
+	go func() {
+		f.Close(context.Background())
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		require.FailNow(t, "closed before the replay was saved")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "close never returned")
+	}
+	store.AssertExpectations(t)
+}
+
+func TestRecorderFactory_Prunes_Expired_Replays(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	store := &MockReplayStore{}
+	pruned := make(chan time.Time, 1)
+	store.On("DeleteReplaysBefore", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		select {
+		case pruned <- args.Get(1).(time.Time):
+		default:
+		}
+	}).Return(nil)
+
+	config := testConfig(1024)
+	config.PruneInterval = 10 * time.Millisecond
+	f := NewRecorderFactory(config, store)
+	f.now = func() time.Time { return now }
+	f.Start()
+	defer f.Close(context.Background())
+
+	select {
+	case cutoff := <-pruned:
+		assert.Equal(t, now.Add(-config.MaxAge), cutoff)
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "expired replays were never pruned")
+	}
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
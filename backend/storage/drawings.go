@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// strokes are stored as a single blob of uvarint length prefixed chunks
+func encodeStrokes(strokes [][]byte) []byte {
+	size := 0
+	for _, s := range strokes {
+		size += len(s) + binary.MaxVarintLen32
+	}
+	buf := make([]byte, 0, size)
+	for _, s := range strokes {
+		buf = binary.AppendUvarint(buf, uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func decodeStrokes(buf []byte) ([][]byte, error) {
+	strokes := [][]byte{}
+	for len(buf) > 0 {
+		length, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < length {
+			return nil, errors.New("corrupted strokes")
+		}
+		strokes = append(strokes, buf[n:n+int(length)])
+		buf = buf[n+int(length):]
+	}
+	return strokes, nil
+}
+
+// drawingNotFound maps errors caused by unknown or malformed drawing ids.
+func drawingNotFound(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	// "22P02" is invalid_text_representation and "23503" foreign_key_violation
+	return errors.As(err, &pgErr) && (pgErr.Code == "22P02" || pgErr.Code == "23503")
+}
+
+func (pgur *PostgresRepo) SaveDrawing(ctx context.Context, d domain.Drawing) (string, error) {
+	var id string
+
+	row := pgur.pool.QueryRow(ctx,
+		"INSERT INTO drawings(drawer_id, room_id, word, guessers, strokes, drawing_ops, size) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		d.DrawerId, d.RoomId, d.Word, d.Guessers, encodeStrokes(d.Strokes), d.DrawingOps, d.Size,
+	)
+
+	err := row.Scan(&id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return id, nil
+}
+
+func (pgur *PostgresRepo) PruneDrawings(ctx context.Context, drawerId string, keep int) error {
+	_, err := pgur.pool.Exec(ctx, `
+		DELETE FROM drawings WHERE id IN (
+			SELECT id FROM drawings d
+			WHERE drawer_id = $1 AND NOT EXISTS (SELECT 1 FROM drawing_favourites f WHERE f.drawing_id = d.id)
+			ORDER BY created_at DESC
+			OFFSET $2
+		)`, drawerId, keep)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return nil
+}
+
+// DeleteDrawingsBefore deletes old drawings nobody favourited.
+func (pgur *PostgresRepo) DeleteDrawingsBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := pgur.pool.Exec(ctx, `
+		DELETE FROM drawings d
+		WHERE created_at < $1 AND NOT EXISTS (SELECT 1 FROM drawing_favourites f WHERE f.drawing_id = d.id)`, cutoff)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return nil
+}
+
+const drawingColumns = `d.id, d.drawer_id, u.username, d.room_id, d.word, d.guessers, d.drawing_ops, d.size, d.created_at,
+	(SELECT count(*) FROM drawing_likes l WHERE l.drawing_id = d.id),
+	EXISTS (SELECT 1 FROM drawing_likes l WHERE l.drawing_id = d.id AND l.user_id = $2),
+	EXISTS (SELECT 1 FROM drawing_favourites f WHERE f.drawing_id = d.id AND f.user_id = $2)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDrawing(row rowScanner, extra ...any) (domain.Drawing, error) {
+	var d domain.Drawing
+	dest := []any{&d.Id, &d.DrawerId, &d.Drawer, &d.RoomId, &d.Word, &d.Guessers, &d.DrawingOps, &d.Size, &d.CreatedAt, &d.Likes, &d.Liked, &d.Favourited}
+	err := row.Scan(append(dest, extra...)...)
+	return d, err
+}
+
+func (pgur *PostgresRepo) listDrawings(ctx context.Context, query string, args ...any) ([]domain.Drawing, error) {
+	rows, err := pgur.pool.Query(ctx, query, args...)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	defer rows.Close()
+
+	drawings := []domain.Drawing{}
+	for rows.Next() {
+		d, err := scanDrawing(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+		}
+		drawings = append(drawings, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return drawings, nil
+}
+
+func (pgur *PostgresRepo) ListDrawingsByDrawer(ctx context.Context, drawerId, viewerId string, limit int) ([]domain.Drawing, error) {
+	return pgur.listDrawings(ctx, `SELECT `+drawingColumns+`
+		FROM drawings d JOIN users u ON u.id = d.drawer_id
+		WHERE d.drawer_id = $1
+		ORDER BY d.created_at DESC LIMIT $3`, drawerId, viewerId, limit)
+}
+
+func (pgur *PostgresRepo) ListFavouriteDrawings(ctx context.Context, userId string, limit int) ([]domain.Drawing, error) {
+	return pgur.listDrawings(ctx, `SELECT `+drawingColumns+`
+		FROM drawing_favourites fav JOIN drawings d ON d.id = fav.drawing_id JOIN users u ON u.id = d.drawer_id
+		WHERE fav.user_id = $1
+		ORDER BY fav.created_at DESC LIMIT $3`, userId, userId, limit)
+}
+
+func (pgur *PostgresRepo) GetDrawing(ctx context.Context, id, viewerId string) (domain.Drawing, error) {
+	var strokes []byte
+
+	row := pgur.pool.QueryRow(ctx, `SELECT `+drawingColumns+`, d.strokes
+		FROM drawings d JOIN users u ON u.id = d.drawer_id
+		WHERE d.id = $1`, id, viewerId)
+
+	d, err := scanDrawing(row, &strokes)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return domain.Drawing{}, err
+		}
+		if drawingNotFound(err) {
+			return domain.Drawing{}, domain.ErrDrawingNotFound
+		}
+		return domain.Drawing{}, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	d.Strokes, err = decodeStrokes(strokes)
+	if err != nil {
+		return domain.Drawing{}, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return d, nil
+}
+
+// DeleteDrawing removes a drawing whoever drew it, with its likes and
+// favourites.
+func (pgur *PostgresRepo) DeleteDrawing(ctx context.Context, id string) error {
+	tag, err := pgur.pool.Exec(ctx, "DELETE FROM drawings WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if drawingNotFound(err) {
+			return domain.ErrDrawingNotFound
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrDrawingNotFound
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) SetDrawingLiked(ctx context.Context, id, userId string, liked bool) error {
+	return pgur.setDrawingFlag(ctx, "drawing_likes", id, userId, liked)
+}
+
+func (pgur *PostgresRepo) SetDrawingFavourited(ctx context.Context, id, userId string, favourited bool) error {
+	return pgur.setDrawingFlag(ctx, "drawing_favourites", id, userId, favourited)
+}
+
+// setDrawingFlag inserts or deletes a (drawing_id, user_id) row, table is never user input.
+func (pgur *PostgresRepo) setDrawingFlag(ctx context.Context, table, id, userId string, set bool) error {
+	query := "INSERT INTO " + table + "(drawing_id, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+	if !set {
+		query = "DELETE FROM " + table + " WHERE drawing_id = $1 AND user_id = $2"
+	}
+
+	_, err := pgur.pool.Exec(ctx, query, id, userId)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		if drawingNotFound(err) {
+			return domain.ErrDrawingNotFound
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return nil
+}
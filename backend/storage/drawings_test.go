@@ -0,0 +1,109 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrawings(t *testing.T) {
+	ctx := context.Background()
+	drawerId, err := repo.CreateUser(ctx, "gallery_drawer", "hash")
+	require.NoError(t, err)
+	viewerId, err := repo.CreateUser(ctx, "gallery_viewer", "hash")
+	require.NoError(t, err)
+
+	strokes := [][]byte{{1, 2, 3}, {}, make([]byte, 300)}
+	var id string
+
+	t.Run("SaveDrawing", func(t *testing.T) {
+		id, err = repo.SaveDrawing(ctx, domain.Drawing{
+			DrawerId:   drawerId,
+			RoomId:     "ROOM1",
+			Word:       "apple",
+			Guessers:   []string{"gallery_viewer"},
+			Strokes:    strokes,
+			DrawingOps: true,
+			Size:       303,
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+	})
+
+	t.Run("GetDrawing", func(t *testing.T) {
+		d, err := repo.GetDrawing(ctx, id, viewerId)
+		require.NoError(t, err)
+		assert.Equal(t, "gallery_drawer", d.Drawer)
+		assert.Equal(t, "apple", d.Word)
+		assert.Equal(t, []string{"gallery_viewer"}, d.Guessers)
+		assert.Equal(t, strokes, d.Strokes)
+		assert.True(t, d.DrawingOps)
+		assert.False(t, d.Liked)
+	})
+
+	t.Run("GetDrawing_NotFound", func(t *testing.T) {
+		_, err := repo.GetDrawing(ctx, "00000000-0000-0000-0000-000000000000", viewerId)
+		assert.ErrorIs(t, err, domain.ErrDrawingNotFound)
+		_, err = repo.GetDrawing(ctx, "nope", viewerId)
+		assert.ErrorIs(t, err, domain.ErrDrawingNotFound)
+	})
+
+	t.Run("Likes_And_Favourites", func(t *testing.T) {
+		require.NoError(t, repo.SetDrawingLiked(ctx, id, viewerId, true))
+		require.NoError(t, repo.SetDrawingLiked(ctx, id, viewerId, true)) // idempotent
+		require.NoError(t, repo.SetDrawingFavourited(ctx, id, viewerId, true))
+
+		d, err := repo.GetDrawing(ctx, id, viewerId)
+		require.NoError(t, err)
+		assert.Equal(t, 1, d.Likes)
+		assert.True(t, d.Liked)
+		assert.True(t, d.Favourited)
+
+		favourites, err := repo.ListFavouriteDrawings(ctx, viewerId, 10)
+		require.NoError(t, err)
+		require.Len(t, favourites, 1)
+		assert.Equal(t, id, favourites[0].Id)
+
+		err = repo.SetDrawingLiked(ctx, "00000000-0000-0000-0000-000000000000", viewerId, true)
+		assert.ErrorIs(t, err, domain.ErrDrawingNotFound)
+	})
+
+	t.Run("Prune_Keeps_Favourites", func(t *testing.T) {
+		for range 3 {
+			_, err := repo.SaveDrawing(ctx, domain.Drawing{DrawerId: drawerId, RoomId: "ROOM2", Word: "pear", Guessers: []string{}, Strokes: [][]byte{{9}}, Size: 1})
+			require.NoError(t, err)
+		}
+		require.NoError(t, repo.PruneDrawings(ctx, drawerId, 1))
+
+		drawings, err := repo.ListDrawingsByDrawer(ctx, drawerId, viewerId, 10)
+		require.NoError(t, err)
+		// the newest drawing plus the favourited one
+		assert.Len(t, drawings, 2)
+		assert.Nil(t, drawings[0].Strokes)
+	})
+
+	t.Run("DeleteDrawingsBefore", func(t *testing.T) {
+		require.NoError(t, repo.DeleteDrawingsBefore(ctx, time.Now().Add(time.Hour)))
+
+		drawings, err := repo.ListDrawingsByDrawer(ctx, drawerId, viewerId, 10)
+		require.NoError(t, err)
+		require.Len(t, drawings, 1)
+		assert.Equal(t, id, drawings[0].Id)
+	})
+	t.Run("DeleteDrawing", func(t *testing.T) {
+		require.NoError(t, repo.DeleteDrawing(ctx, id))
+
+		_, err := repo.GetDrawing(ctx, id, viewerId)
+		assert.ErrorIs(t, err, domain.ErrDrawingNotFound)
+		favourites, err := repo.ListFavouriteDrawings(ctx, viewerId, 10)
+		require.NoError(t, err)
+		assert.Empty(t, favourites)
+
+		assert.ErrorIs(t, repo.DeleteDrawing(ctx, id), domain.ErrDrawingNotFound)
+		assert.ErrorIs(t, repo.DeleteDrawing(ctx, "not-a-uuid"), domain.ErrDrawingNotFound)
+	})
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (pgur *PostgresRepo) GetUserIdByIdentity(ctx context.Context, provider, subject string) (string, error) {
+	var userId string
+	row := pgur.pool.QueryRow(ctx, "SELECT user_id FROM identities WHERE provider = $1 AND subject = $2", provider, subject)
+	if err := row.Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrIdentityNotFound
+		}
+		return "", wrapDatabaseError(err)
+	}
+	return userId, nil
+}
+
+// CreateUserWithIdentity creates a user without a password, it signs in
+// through the provider only.
+func (pgur *PostgresRepo) CreateUserWithIdentity(ctx context.Context, username, provider, subject string) (string, error) {
+	tx, err := pgur.pool.Begin(ctx)
+	if err != nil {
+		return "", wrapDatabaseError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userId string
+	err = tx.QueryRow(ctx, "INSERT INTO users(username, password_hash) VALUES($1, '') RETURNING id", username).Scan(&userId)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// "23505" is the PostgreSQL error code for unique_violation
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", domain.ErrDuplicateUsername
+		}
+		return "", wrapDatabaseError(err)
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO identities(user_id, provider, subject) VALUES($1, $2, $3)", userId, provider, subject)
+	if err != nil {
+		return "", wrapDatabaseError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", wrapDatabaseError(err)
+	}
+	return userId, nil
+}
+
+// LinkIdentity links the identity to the user, linking it again changes
+// nothing. The conflict returns the user who has it, so one statement tells
+// both apart.
+func (pgur *PostgresRepo) LinkIdentity(ctx context.Context, userId, provider, subject string) error {
+	var owner string
+	row := pgur.pool.QueryRow(ctx,
+		`INSERT INTO identities(user_id, provider, subject) VALUES($1, $2, $3)
+		ON CONFLICT (provider, subject) DO UPDATE SET user_id = identities.user_id
+		RETURNING user_id`,
+		userId, provider, subject,
+	)
+	if err := row.Scan(&owner); err != nil {
+		var pgErr *pgconn.PgError
+		// "23503" is the PostgreSQL error code for foreign_key_violation
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return domain.ErrUserNotFound
+		}
+		return wrapDatabaseError(err)
+	}
+	if owner != userId {
+		return domain.ErrIdentityTaken
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentities(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := repo.GetUserIdByIdentity(ctx, "test", "subject-1")
+	assert.ErrorIs(t, err, domain.ErrIdentityNotFound)
+
+	userId, err := repo.CreateUserWithIdentity(ctx, "oidc_user", "test", "subject-1")
+	require.NoError(t, err)
+
+	linked, err := repo.GetUserIdByIdentity(ctx, "test", "subject-1")
+	assert.NoError(t, err)
+	assert.Equal(t, userId, linked)
+
+	user, err := repo.GetUserById(ctx, userId)
+	require.NoError(t, err)
+	assert.Empty(t, user.PasswordHash, "signs in through the provider only")
+
+	_, err = repo.CreateUserWithIdentity(ctx, "oidc_user", "test", "subject-2")
+	assert.ErrorIs(t, err, domain.ErrDuplicateUsername)
+	_, err = repo.GetUserIdByIdentity(ctx, "test", "subject-2")
+	assert.ErrorIs(t, err, domain.ErrIdentityNotFound, "the user and the link go together")
+
+	t.Run("LinkIdentity", func(t *testing.T) {
+		passwordUser, err := repo.CreateUser(ctx, "link_user", "hash")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.LinkIdentity(ctx, passwordUser, "test", "subject-3"))
+		require.NoError(t, repo.LinkIdentity(ctx, passwordUser, "test", "subject-3"), "linking again changes nothing")
+		linked, err := repo.GetUserIdByIdentity(ctx, "test", "subject-3")
+		assert.NoError(t, err)
+		assert.Equal(t, passwordUser, linked)
+
+		err = repo.LinkIdentity(ctx, passwordUser, "test", "subject-1")
+		assert.ErrorIs(t, err, domain.ErrIdentityTaken)
+		linked, err = repo.GetUserIdByIdentity(ctx, "test", "subject-1")
+		assert.NoError(t, err)
+		assert.Equal(t, userId, linked, "the identity stays with its user")
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -26,9 +27,9 @@ func NewPostgresRepo(ctx context.Context, connString string) (*PostgresRepo, err
 func (pgur *PostgresRepo) GetUserByUsername(ctx context.Context, username string) (domain.User, error) {
 	user := domain.User{Username: username}
 
-	row := pgur.pool.QueryRow(ctx, "SELECT id, password_hash FROM users WHERE username = $1", username)
+	row := pgur.pool.QueryRow(ctx, "SELECT id, password_hash, totp_enabled_at IS NOT NULL FROM users WHERE username = $1", username)
 
-	err := row.Scan(&user.Id, &user.PasswordHash)
+	err := row.Scan(&user.Id, &user.PasswordHash, &user.TOTPEnabled)
 
 	if err != nil {
 		switch {
@@ -47,9 +48,9 @@ func (pgur *PostgresRepo) GetUserByUsername(ctx context.Context, username string
 func (pgur *PostgresRepo) GetUserById(ctx context.Context, id string) (domain.User, error) {
 	user := domain.User{Id: id}
 
-	row := pgur.pool.QueryRow(ctx, "SELECT username, password_hash FROM users WHERE id = $1", id)
+	row := pgur.pool.QueryRow(ctx, "SELECT username, password_hash, totp_enabled_at IS NOT NULL FROM users WHERE id = $1", id)
 
-	err := row.Scan(&user.Username, &user.PasswordHash)
+	err := row.Scan(&user.Username, &user.PasswordHash, &user.TOTPEnabled)
 
 	if err != nil {
 		switch {
@@ -90,6 +91,77 @@ func (pgur *PostgresRepo) CreateUser(ctx context.Context, username string, passw
 	return id, nil
 }
 
+// UpdatePasswordHash only replaces oldHash, so that a rehash at login can't
+// undo a password change that landed in between.
+func (pgur *PostgresRepo) UpdatePasswordHash(ctx context.Context, id string, oldHash, passwordHash string) error {
+	tag, err := pgur.pool.Exec(ctx, "UPDATE users SET password_hash = $2 WHERE id = $1 AND password_hash = $3", id, passwordHash, oldHash)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		if err := pgur.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists); err != nil {
+			return wrapDatabaseError(err)
+		}
+		if exists {
+			return domain.ErrPasswordChanged
+		}
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// UpdateUsername renames the user unless it was renamed after
+// changedBefore, the check and the rename are one statement.
+func (pgur *PostgresRepo) UpdateUsername(ctx context.Context, id string, username string, changedBefore, now time.Time) error {
+	tag, err := pgur.pool.Exec(ctx, `UPDATE users SET username = $2, username_changed_at = $3
+		WHERE id = $1 AND (username_changed_at IS NULL OR username_changed_at <= $4)`, id, username, now, changedBefore)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrDuplicateUsername
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = pgur.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists)
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case err != nil:
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	case !exists:
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrUsernameChangeTooSoon
+}
+
+// DeleteUser removes the user, its sessions, webhooks and drawings go with
+// it.
+func (pgur *PostgresRepo) DeleteUser(ctx context.Context, id string) error {
+	tag, err := pgur.pool.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
 // Generate implements the game.RandomWordsGenerator interface.
 // It fetches 'count' random words from the words table in the database.
 // Returns a slice of random words, or an empty slice if the query fails.
@@ -86,6 +86,61 @@ func TestPostgresRepo(t *testing.T) {
 		assert.Equal(t, "hash2", user.PasswordHash)
 		assert.Equal(t, "tester2", user.Username)
 	})
+
+	t.Run("UpdatePasswordHash", func(t *testing.T) {
+		id, err := repo.CreateUser(ctx, "tester3", "old_hash")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.UpdatePasswordHash(ctx, id, "old_hash", "new_hash"))
+		user, err := repo.GetUserById(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "new_hash", user.PasswordHash)
+
+		err = repo.UpdatePasswordHash(ctx, id, "old_hash", "rehashed_old")
+		assert.ErrorIs(t, err, domain.ErrPasswordChanged, "a stale rehash doesn't undo the change")
+		user, err = repo.GetUserById(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "new_hash", user.PasswordHash)
+	})
+
+	t.Run("UpdatePasswordHash_NotFound", func(t *testing.T) {
+		err := repo.UpdatePasswordHash(ctx, "00000000-0000-0000-0000-000000000000", "old_hash", "new_hash")
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	})
+
+	t.Run("UpdateUsername", func(t *testing.T) {
+		id, err := repo.CreateUser(ctx, "renamer", "hash")
+		require.NoError(t, err)
+		now := time.Now()
+
+		require.NoError(t, repo.UpdateUsername(ctx, id, "renamed", now.Add(-time.Hour), now))
+		user, err := repo.GetUserById(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "renamed", user.Username)
+
+		err = repo.UpdateUsername(ctx, id, "renamed_again", now.Add(-time.Hour), now.Add(time.Minute))
+		assert.ErrorIs(t, err, domain.ErrUsernameChangeTooSoon)
+		assert.NoError(t, repo.UpdateUsername(ctx, id, "renamed_again", now, now.Add(2*time.Hour)))
+
+		err = repo.UpdateUsername(ctx, id, "oussama", now.Add(3*time.Hour), now.Add(3*time.Hour))
+		assert.ErrorIs(t, err, domain.ErrDuplicateUsername)
+
+		err = repo.UpdateUsername(ctx, "00000000-0000-0000-0000-000000000000", "nobody", now, now)
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	})
+
+	t.Run("DeleteUser", func(t *testing.T) {
+		id, err := repo.CreateUser(ctx, "leaver", "hash")
+		require.NoError(t, err)
+		require.NoError(t, repo.CreateRefreshToken(ctx, domain.RefreshToken{
+			Hash: []byte("leaver-token"), UserId: id, ExpiresAt: time.Now().Add(time.Hour),
+		}))
+
+		require.NoError(t, repo.DeleteUser(ctx, id))
+		_, err = repo.GetUserById(ctx, id)
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+		assert.ErrorIs(t, repo.DeleteUser(ctx, id), domain.ErrUserNotFound)
+	})
 }
 
 func TestGenerate(t *testing.T) {
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (pgur *PostgresRepo) SaveReplay(ctx context.Context, replay domain.Replay) (string, error) {
+	var id string
+
+	row := pgur.pool.QueryRow(ctx, "INSERT INTO replays(room_id, private, participant_ids, started_at, size, data) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+		replay.RoomId, replay.Private, replay.ParticipantIds, replay.StartedAt, len(replay.Data), replay.Data)
+
+	err := row.Scan(&id)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return id, nil
+}
+
+// GetReplay finds a replay userId played in, those of other rooms are not found.
+func (pgur *PostgresRepo) GetReplay(ctx context.Context, id string, userId string) (domain.Replay, error) {
+	replay := domain.Replay{Id: id}
+
+	row := pgur.pool.QueryRow(ctx, "SELECT room_id, private, participant_ids, started_at, size, data FROM replays WHERE id = $1 AND $2 = ANY(participant_ids)", id, userId)
+
+	err := row.Scan(&replay.RoomId, &replay.Private, &replay.ParticipantIds, &replay.StartedAt, &replay.Size, &replay.Data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Replay{}, domain.ErrReplayNotFound
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return domain.Replay{}, err
+		}
+		var pgErr *pgconn.PgError
+		// "22P02" is invalid_text_representation, i.e. the id is not a uuid
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return domain.Replay{}, domain.ErrReplayNotFound
+		}
+		return domain.Replay{}, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return replay, nil
+}
+
+// ListReplays returns the most recent replays userId played in, without their
+// data.
+func (pgur *PostgresRepo) ListReplays(ctx context.Context, userId string, limit int) ([]domain.Replay, error) {
+	rows, err := pgur.pool.Query(ctx, "SELECT id, room_id, private, participant_ids, started_at, size FROM replays WHERE $1 = ANY(participant_ids) ORDER BY created_at DESC LIMIT $2", userId, limit)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	defer rows.Close()
+
+	replays := []domain.Replay{}
+	for rows.Next() {
+		var replay domain.Replay
+		if err := rows.Scan(&replay.Id, &replay.RoomId, &replay.Private, &replay.ParticipantIds, &replay.StartedAt, &replay.Size); err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+		}
+		replays = append(replays, replay)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return replays, nil
+}
+
+// DeleteReplaysBefore deletes replays recorded before cutoff.
+func (pgur *PostgresRepo) DeleteReplaysBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := pgur.pool.Exec(ctx, "DELETE FROM replays WHERE created_at < $1", cutoff)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return nil
+}
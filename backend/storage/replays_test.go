@@ -0,0 +1,75 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplays(t *testing.T) {
+	ctx := context.Background()
+	startedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	var id string
+
+	t.Run("SaveReplay", func(t *testing.T) {
+		var err error
+		id, err = repo.SaveReplay(ctx, domain.Replay{
+			RoomId:         "ROOM1",
+			Private:        true,
+			ParticipantIds: []string{"player-1", "player-2"},
+			StartedAt:      startedAt,
+			Data:           []byte("GTOR-blob"),
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+	})
+
+	t.Run("GetReplay", func(t *testing.T) {
+		replay, err := repo.GetReplay(ctx, id, "player-2")
+		require.NoError(t, err)
+		assert.Equal(t, "ROOM1", replay.RoomId)
+		assert.True(t, replay.Private)
+		assert.Equal(t, []string{"player-1", "player-2"}, replay.ParticipantIds)
+		assert.True(t, startedAt.Equal(replay.StartedAt))
+		assert.Equal(t, 9, replay.Size)
+		assert.Equal(t, []byte("GTOR-blob"), replay.Data)
+	})
+
+	t.Run("GetReplay_NotFound", func(t *testing.T) {
+		_, err := repo.GetReplay(ctx, "00000000-0000-0000-0000-000000000000", "player-1")
+		assert.ErrorIs(t, err, domain.ErrReplayNotFound)
+
+		_, err = repo.GetReplay(ctx, "not-a-uuid", "player-1")
+		assert.ErrorIs(t, err, domain.ErrReplayNotFound)
+
+		_, err = repo.GetReplay(ctx, id, "outsider")
+		assert.ErrorIs(t, err, domain.ErrReplayNotFound)
+	})
+
+	t.Run("ListReplays", func(t *testing.T) {
+		replays, err := repo.ListReplays(ctx, "player-1", 10)
+		require.NoError(t, err)
+		require.NotEmpty(t, replays)
+		assert.Equal(t, id, replays[0].Id)
+		assert.Nil(t, replays[0].Data)
+
+		replays, err = repo.ListReplays(ctx, "outsider", 10)
+		require.NoError(t, err)
+		assert.Empty(t, replays)
+	})
+
+	t.Run("DeleteReplaysBefore", func(t *testing.T) {
+		require.NoError(t, repo.DeleteReplaysBefore(ctx, time.Now().Add(-time.Hour)))
+		_, err := repo.GetReplay(ctx, id, "player-1")
+		assert.NoError(t, err)
+
+		require.NoError(t, repo.DeleteReplaysBefore(ctx, time.Now().Add(time.Hour)))
+		_, err = repo.GetReplay(ctx, id, "player-1")
+		assert.ErrorIs(t, err, domain.ErrReplayNotFound)
+	})
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+func (pgur *PostgresRepo) GetUserRole(ctx context.Context, id string) (domain.Role, error) {
+	var role domain.Role
+	err := pgur.pool.QueryRow(ctx, "SELECT role FROM users WHERE id = $1", id).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrUserNotFound
+		}
+		return "", wrapDatabaseError(err)
+	}
+	return role, nil
+}
+
+// SetUserRole gives the user the role and returns its id.
+func (pgur *PostgresRepo) SetUserRole(ctx context.Context, username string, role domain.Role) (string, error) {
+	var id string
+	err := pgur.pool.QueryRow(ctx, "UPDATE users SET role = $2 WHERE username = $1 RETURNING id", username, role).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", domain.ErrUserNotFound
+		}
+		return "", wrapDatabaseError(err)
+	}
+	return id, nil
+}
+
+// BootstrapAdmin makes the user the first admin, the check that there is none
+// and the update are one statement.
+func (pgur *PostgresRepo) BootstrapAdmin(ctx context.Context, username string) error {
+	tag, err := pgur.pool.Exec(ctx, `UPDATE users SET role = 'admin'
+		WHERE username = $1 AND NOT EXISTS(SELECT 1 FROM users WHERE role = 'admin')`, username)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = pgur.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
+	switch {
+	case err != nil:
+		return wrapDatabaseError(err)
+	case !exists:
+		return domain.ErrUserNotFound
+	default:
+		return domain.ErrAdminExists
+	}
+}
@@ -0,0 +1,40 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	firstId, err := repo.CreateUser(ctx, "first_admin", "hash")
+	require.NoError(t, err)
+	_, err = repo.CreateUser(ctx, "second_admin", "hash")
+	require.NoError(t, err)
+
+	role, err := repo.GetUserRole(ctx, firstId)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RolePlayer, role, "accounts start as players")
+
+	assert.ErrorIs(t, repo.BootstrapAdmin(ctx, "nobody_here"), domain.ErrUserNotFound)
+	require.NoError(t, repo.BootstrapAdmin(ctx, "first_admin"))
+	role, err = repo.GetUserRole(ctx, firstId)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, role)
+	assert.ErrorIs(t, repo.BootstrapAdmin(ctx, "second_admin"), domain.ErrAdminExists)
+
+	id, err := repo.SetUserRole(ctx, "second_admin", domain.RoleModerator)
+	require.NoError(t, err)
+	role, err = repo.GetUserRole(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleModerator, role)
+
+	_, err = repo.SetUserRole(ctx, "nobody_here", domain.RoleAdmin)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = repo.GetUserRole(ctx, "00000000-0000-0000-0000-000000000000")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
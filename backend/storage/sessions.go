@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func wrapDatabaseError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+}
+
+func (pgur *PostgresRepo) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	_, err := pgur.pool.Exec(ctx,
+		"INSERT INTO refresh_tokens(token_hash, family_id, user_id, expires_at) VALUES($1, COALESCE(NULLIF($2, '')::uuid, gen_random_uuid()), $3, $4)",
+		token.Hash, token.FamilyId, token.UserId, token.ExpiresAt,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+// RotateRefreshToken locks the row of the hash so that two refreshes racing
+// with the same token can't both spend it.
+func (pgur *PostgresRepo) RotateRefreshToken(ctx context.Context, hash []byte, next domain.RefreshToken, now time.Time, reuseGrace time.Duration) (domain.RefreshToken, error) {
+	tx, err := pgur.pool.Begin(ctx)
+	if err != nil {
+		return domain.RefreshToken{}, wrapDatabaseError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	spent := domain.RefreshToken{Hash: hash}
+	var usedAt, revokedAt *time.Time
+	row := tx.QueryRow(ctx, "SELECT family_id, user_id, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE", hash)
+	err = row.Scan(&spent.FamilyId, &spent.UserId, &spent.ExpiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.RefreshToken{}, domain.ErrRefreshTokenNotFound
+		}
+		return domain.RefreshToken{}, wrapDatabaseError(err)
+	}
+
+	if usedAt != nil && revokedAt == nil && now.Sub(*usedAt) < reuseGrace {
+		// the token rotated moments ago, most likely a second request of the
+		// same client. It gets a token of the family too unless the family
+		// rotated on since.
+		var movedOn bool
+		row := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = $1 AND used_at > $2)", spent.FamilyId, *usedAt)
+		if err := row.Scan(&movedOn); err != nil {
+			return domain.RefreshToken{}, wrapDatabaseError(err)
+		}
+		if !movedOn {
+			usedAt = nil
+		}
+	}
+
+	switch {
+	case revokedAt != nil:
+		return domain.RefreshToken{}, domain.ErrRefreshTokenNotFound
+	case usedAt != nil:
+		if err := revokeFamily(ctx, tx, spent.FamilyId, now); err != nil {
+			return domain.RefreshToken{}, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return domain.RefreshToken{}, wrapDatabaseError(err)
+		}
+		return spent, domain.ErrRefreshTokenReused
+	case !spent.ExpiresAt.After(now):
+		return domain.RefreshToken{}, domain.ErrExpiredToken
+	}
+
+	// a token reused within the grace keeps the time of its first rotation
+	_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET used_at = COALESCE(used_at, $2) WHERE token_hash = $1", hash, now)
+	if err != nil {
+		return domain.RefreshToken{}, wrapDatabaseError(err)
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO refresh_tokens(token_hash, family_id, user_id, expires_at) VALUES($1, $2, $3, $4)",
+		next.Hash, spent.FamilyId, spent.UserId, next.ExpiresAt,
+	)
+	if err != nil {
+		return domain.RefreshToken{}, wrapDatabaseError(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.RefreshToken{}, wrapDatabaseError(err)
+	}
+	return spent, nil
+}
+
+func revokeFamily(ctx context.Context, tx pgx.Tx, familyId string, now time.Time) error {
+	_, err := tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL", familyId, now)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) RevokeRefreshTokenFamily(ctx context.Context, hash []byte) error {
+	tag, err := pgur.pool.Exec(ctx,
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1) AND revoked_at IS NULL",
+		hash,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) RevokeUserRefreshTokens(ctx context.Context, userId string) error {
+	_, err := pgur.pool.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", userId)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+// DeleteRefreshTokensBefore deletes the tokens that expired or were revoked
+// before cutoff. Spent ones are kept until they expire, they are what
+// catches a reuse.
+func (pgur *PostgresRepo) DeleteRefreshTokensBefore(ctx context.Context, cutoff time.Time) error {
+	_, err := pgur.pool.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < $1 OR revoked_at < $1", cutoff)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
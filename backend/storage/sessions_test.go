@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	userId, err := repo.CreateUser(ctx, "session_user", "hash")
+	require.NoError(t, err)
+	now := time.Now()
+	token := func(hash string) domain.RefreshToken {
+		return domain.RefreshToken{Hash: []byte(hash), UserId: userId, ExpiresAt: now.Add(time.Hour)}
+	}
+
+	require.NoError(t, repo.CreateRefreshToken(ctx, token("first")))
+
+	t.Run("RotateRefreshToken", func(t *testing.T) {
+		spent, err := repo.RotateRefreshToken(ctx, []byte("first"), token("second"), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, userId, spent.UserId)
+		assert.NotEmpty(t, spent.FamilyId)
+
+		next, err := repo.RotateRefreshToken(ctx, []byte("second"), token("third"), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, spent.FamilyId, next.FamilyId, "rotations stay in the family")
+	})
+
+	t.Run("RotateRefreshToken_Reused", func(t *testing.T) {
+		_, err := repo.RotateRefreshToken(ctx, []byte("first"), token("stolen"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+
+		_, err = repo.RotateRefreshToken(ctx, []byte("third"), token("fourth"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound, "the whole family was revoked")
+	})
+
+	t.Run("RotateRefreshToken_ReusedWithinGrace", func(t *testing.T) {
+		require.NoError(t, repo.CreateRefreshToken(ctx, token("tab")))
+		_, err := repo.RotateRefreshToken(ctx, []byte("tab"), token("tab-a"), now, time.Minute)
+		require.NoError(t, err)
+
+		_, err = repo.RotateRefreshToken(ctx, []byte("tab"), token("tab-b"), now.Add(time.Second), time.Minute)
+		require.NoError(t, err, "a second tab refreshing at once keeps the session")
+
+		_, err = repo.RotateRefreshToken(ctx, []byte("tab-a"), token("tab-a2"), now.Add(2*time.Second), time.Minute)
+		require.NoError(t, err)
+		_, err = repo.RotateRefreshToken(ctx, []byte("tab"), token("tab-c"), now.Add(3*time.Second), time.Minute)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused, "the family rotated on since")
+	})
+
+	t.Run("RotateRefreshToken_Expired", func(t *testing.T) {
+		require.NoError(t, repo.CreateRefreshToken(ctx, token("old")))
+		_, err := repo.RotateRefreshToken(ctx, []byte("old"), token("newer"), now.Add(2*time.Hour), 0)
+		assert.ErrorIs(t, err, domain.ErrExpiredToken)
+	})
+
+	t.Run("RotateRefreshToken_NotFound", func(t *testing.T) {
+		_, err := repo.RotateRefreshToken(ctx, []byte("never-issued"), token("whatever"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+	})
+
+	t.Run("RevokeRefreshTokenFamily", func(t *testing.T) {
+		require.NoError(t, repo.CreateRefreshToken(ctx, token("laptop")))
+		require.NoError(t, repo.CreateRefreshToken(ctx, token("phone")))
+		require.NoError(t, repo.RevokeRefreshTokenFamily(ctx, []byte("laptop")))
+
+		_, err := repo.RotateRefreshToken(ctx, []byte("laptop"), token("laptop2"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+		_, err = repo.RotateRefreshToken(ctx, []byte("phone"), token("phone2"), now, 0)
+		assert.NoError(t, err, "other sessions live on")
+
+		assert.ErrorIs(t, repo.RevokeRefreshTokenFamily(ctx, []byte("laptop")), domain.ErrRefreshTokenNotFound)
+	})
+
+	t.Run("RevokeUserRefreshTokens", func(t *testing.T) {
+		require.NoError(t, repo.RevokeUserRefreshTokens(ctx, userId))
+		_, err := repo.RotateRefreshToken(ctx, []byte("phone2"), token("phone3"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+	})
+
+	t.Run("DeleteRefreshTokensBefore", func(t *testing.T) {
+		stale := token("stale")
+		stale.ExpiresAt = now.Add(-time.Minute)
+		require.NoError(t, repo.CreateRefreshToken(ctx, stale))
+		require.NoError(t, repo.CreateRefreshToken(ctx, token("fresh")))
+
+		require.NoError(t, repo.DeleteRefreshTokensBefore(ctx, now))
+
+		_, err := repo.RotateRefreshToken(ctx, []byte("stale"), token("stale2"), now, 0)
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+		_, err = repo.RotateRefreshToken(ctx, []byte("fresh"), token("fresh2"), now, 0)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SetTOTPSecret starts an enrollment, replacing one not confirmed yet.
+func (pgur *PostgresRepo) SetTOTPSecret(ctx context.Context, userId string, secret []byte) error {
+	tag, err := pgur.pool.Exec(ctx,
+		"UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled_at IS NULL",
+		userId, secret,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := pgur.GetTOTP(ctx, userId); err != nil {
+			return err
+		}
+		return domain.ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) GetTOTP(ctx context.Context, userId string) (domain.TOTP, error) {
+	var totp domain.TOTP
+	var lastStep *int64
+	row := pgur.pool.QueryRow(ctx,
+		"SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step FROM users WHERE id = $1",
+		userId,
+	)
+	err := row.Scan(&totp.Secret, &totp.Enabled, &lastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.TOTP{}, domain.ErrUserNotFound
+		}
+		return domain.TOTP{}, wrapDatabaseError(err)
+	}
+	if totp.Secret == nil {
+		return domain.TOTP{}, domain.ErrTOTPNotEnrolled
+	}
+	if lastStep != nil {
+		totp.LastStep = *lastStep
+	}
+	return totp, nil
+}
+
+// EnableTOTP confirms the enrollment with the code of the step and replaces
+// the recovery codes.
+func (pgur *PostgresRepo) EnableTOTP(ctx context.Context, userId string, recoveryCodeHashes []string, step int64) error {
+	tx, err := pgur.pool.Begin(ctx)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE users SET totp_enabled_at = now(), totp_last_step = $2 WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL",
+		userId, step,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrTOTPAlreadyEnabled
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userId); err != nil {
+		return wrapDatabaseError(err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(ctx, "INSERT INTO recovery_codes(user_id, code_hash) VALUES($1, $2)", userId, hash); err != nil {
+			return wrapDatabaseError(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) DisableTOTP(ctx context.Context, userId string) error {
+	tx, err := pgur.pool.Begin(ctx)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1",
+		userId,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userId); err != nil {
+		return wrapDatabaseError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+// UseTOTPStep spends the codes up to the step, two logins racing with the
+// same code can't both pass.
+func (pgur *PostgresRepo) UseTOTPStep(ctx context.Context, userId string, step int64) error {
+	tag, err := pgur.pool.Exec(ctx,
+		"UPDATE users SET totp_last_step = $2 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)",
+		userId, step,
+	)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// GetRecoveryCodes returns the codes of the user not used yet.
+func (pgur *PostgresRepo) GetRecoveryCodes(ctx context.Context, userId string) ([]domain.RecoveryCode, error) {
+	rows, err := pgur.pool.Query(ctx,
+		"SELECT id, code_hash FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL ORDER BY created_at",
+		userId,
+	)
+	if err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var codes []domain.RecoveryCode
+	for rows.Next() {
+		var code domain.RecoveryCode
+		if err := rows.Scan(&code.Id, &code.Hash); err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+	return codes, nil
+}
+
+func (pgur *PostgresRepo) UseRecoveryCode(ctx context.Context, id string, now time.Time) error {
+	tag, err := pgur.pool.Exec(ctx, "UPDATE recovery_codes SET used_at = $2 WHERE id = $1 AND used_at IS NULL", id, now)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrRecoveryCodeUsed
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP(t *testing.T) {
+	ctx := context.Background()
+	userId, err := repo.CreateUser(ctx, "totp_user", "hash")
+	require.NoError(t, err)
+
+	t.Run("GetTOTP_NotEnrolled", func(t *testing.T) {
+		_, err := repo.GetTOTP(ctx, userId)
+		assert.ErrorIs(t, err, domain.ErrTOTPNotEnrolled)
+	})
+
+	t.Run("Enroll_And_Enable", func(t *testing.T) {
+		require.NoError(t, repo.SetTOTPSecret(ctx, userId, []byte("first-secret")))
+		require.NoError(t, repo.SetTOTPSecret(ctx, userId, []byte("second-secret")), "a pending enrollment is replaced")
+
+		totp, err := repo.GetTOTP(ctx, userId)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("second-secret"), totp.Secret)
+		assert.False(t, totp.Enabled)
+
+		require.NoError(t, repo.EnableTOTP(ctx, userId, []string{"hash-1", "hash-2"}, 100))
+		totp, err = repo.GetTOTP(ctx, userId)
+		require.NoError(t, err)
+		assert.True(t, totp.Enabled)
+		assert.Equal(t, int64(100), totp.LastStep)
+
+		user, err := repo.GetUserByUsername(ctx, "totp_user")
+		require.NoError(t, err)
+		assert.True(t, user.TOTPEnabled)
+
+		assert.ErrorIs(t, repo.SetTOTPSecret(ctx, userId, []byte("third-secret")), domain.ErrTOTPAlreadyEnabled)
+	})
+
+	t.Run("UseTOTPStep", func(t *testing.T) {
+		assert.ErrorIs(t, repo.UseTOTPStep(ctx, userId, 100), domain.ErrTOTPCodeReused, "spent when enabling")
+		assert.NoError(t, repo.UseTOTPStep(ctx, userId, 101))
+		assert.ErrorIs(t, repo.UseTOTPStep(ctx, userId, 101), domain.ErrTOTPCodeReused)
+	})
+
+	t.Run("UseRecoveryCode", func(t *testing.T) {
+		codes, err := repo.GetRecoveryCodes(ctx, userId)
+		require.NoError(t, err)
+		require.Len(t, codes, 2)
+
+		require.NoError(t, repo.UseRecoveryCode(ctx, codes[0].Id, time.Now()))
+		assert.ErrorIs(t, repo.UseRecoveryCode(ctx, codes[0].Id, time.Now()), domain.ErrRecoveryCodeUsed)
+
+		codes, err = repo.GetRecoveryCodes(ctx, userId)
+		require.NoError(t, err)
+		assert.Len(t, codes, 1)
+	})
+
+	t.Run("DisableTOTP", func(t *testing.T) {
+		require.NoError(t, repo.DisableTOTP(ctx, userId))
+		_, err := repo.GetTOTP(ctx, userId)
+		assert.ErrorIs(t, err, domain.ErrTOTPNotEnrolled)
+
+		codes, err := repo.GetRecoveryCodes(ctx, userId)
+		require.NoError(t, err)
+		assert.Empty(t, codes)
+	})
+}
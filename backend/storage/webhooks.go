@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"errors"
+	"fmt"
+)
+
+func (pgur *PostgresRepo) CreateWebhook(ctx context.Context, ownerId, url, secret string) (domain.Webhook, error) {
+	hook := domain.Webhook{OwnerId: ownerId, URL: url, Secret: secret}
+
+	row := pgur.pool.QueryRow(ctx, "INSERT INTO webhooks(owner_id, url, secret) VALUES($1, $2, $3) RETURNING id, created_at", ownerId, url, secret)
+
+	err := row.Scan(&hook.Id, &hook.CreatedAt)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return domain.Webhook{}, err
+		}
+		return domain.Webhook{}, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return hook, nil
+}
+
+func (pgur *PostgresRepo) GetWebhooksByOwner(ctx context.Context, ownerId string) ([]domain.Webhook, error) {
+	rows, err := pgur.pool.Query(ctx, "SELECT id, url, secret, created_at FROM webhooks WHERE owner_id = $1 ORDER BY created_at", ownerId)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	defer rows.Close()
+
+	hooks := []domain.Webhook{}
+	for rows.Next() {
+		hook := domain.Webhook{OwnerId: ownerId}
+		if err := rows.Scan(&hook.Id, &hook.URL, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+		}
+		hooks = append(hooks, hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+
+	return hooks, nil
+}
+
+func (pgur *PostgresRepo) DeleteWebhook(ctx context.Context, ownerId, id string) error {
+	tag, err := pgur.pool.Exec(ctx, "DELETE FROM webhooks WHERE id = $1 AND owner_id = $2", id, ownerId)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (pgur *PostgresRepo) SaveWebhookDeadLetter(ctx context.Context, dl domain.WebhookDeadLetter) error {
+	var webhookId *string
+	if dl.WebhookId != "" {
+		webhookId = &dl.WebhookId
+	}
+
+	_, err := pgur.pool.Exec(ctx,
+		"INSERT INTO webhook_dead_letters(webhook_id, url, event_type, payload, attempts, last_error) VALUES($1, $2, $3, $4, $5, $6)",
+		webhookId, dl.URL, string(dl.EventType), dl.Payload, dl.Attempts, dl.LastError,
+	)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", domain.UnexpectedDatabaseError, err)
+	}
+	return nil
+}
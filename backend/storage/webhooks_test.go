@@ -0,0 +1,69 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	ownerId, err := repo.CreateUser(ctx, "hook_owner", "hash")
+	require.NoError(t, err)
+
+	var created domain.Webhook
+
+	t.Run("CreateWebhook", func(t *testing.T) {
+		created, err = repo.CreateWebhook(ctx, ownerId, "https://bots.example.com/hook", "s3cr3t")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, created.Id)
+		assert.False(t, created.CreatedAt.IsZero())
+	})
+
+	t.Run("GetWebhooksByOwner", func(t *testing.T) {
+		hooks, err := repo.GetWebhooksByOwner(ctx, ownerId)
+		assert.NoError(t, err)
+		require.Len(t, hooks, 1)
+		assert.Equal(t, "https://bots.example.com/hook", hooks[0].URL)
+		assert.Equal(t, "s3cr3t", hooks[0].Secret)
+	})
+
+	t.Run("SaveWebhookDeadLetter", func(t *testing.T) {
+		err := repo.SaveWebhookDeadLetter(ctx, domain.WebhookDeadLetter{
+			WebhookId: created.Id,
+			URL:       created.URL,
+			EventType: domain.GameEventGameEnded,
+			Payload:   []byte(`{"type":"game-ended"}`),
+			Attempts:  6,
+			LastError: "unexpected status 503",
+		})
+		assert.NoError(t, err)
+
+		err = repo.SaveWebhookDeadLetter(ctx, domain.WebhookDeadLetter{
+			URL:       "https://deployment.example.com",
+			EventType: domain.GameEventRoomCreated,
+			Payload:   []byte(`{}`),
+			Attempts:  1,
+			LastError: "status 404",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("DeleteWebhook_WrongOwner", func(t *testing.T) {
+		otherId, err := repo.CreateUser(ctx, "hook_thief", "hash")
+		require.NoError(t, err)
+		err = repo.DeleteWebhook(ctx, otherId, created.Id)
+		assert.ErrorIs(t, err, domain.ErrWebhookNotFound)
+	})
+
+	t.Run("DeleteWebhook", func(t *testing.T) {
+		err := repo.DeleteWebhook(ctx, ownerId, created.Id)
+		assert.NoError(t, err)
+		hooks, err := repo.GetWebhooksByOwner(ctx, ownerId)
+		assert.NoError(t, err)
+		assert.Empty(t, hooks)
+	})
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"api/domain"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (pgur *PostgresRepo) ListWords(ctx context.Context) ([]string, error) {
+	rows, err := pgur.pool.Query(ctx, "SELECT word FROM words ORDER BY word")
+	if err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+	defer rows.Close()
+
+	words := []string{}
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, wrapDatabaseError(err)
+		}
+		words = append(words, word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, wrapDatabaseError(err)
+	}
+	return words, nil
+}
+
+// AddWord fails with domain.ErrDuplicateWord when the word is there in any
+// case, words are unique by lower(word).
+func (pgur *PostgresRepo) AddWord(ctx context.Context, word string) error {
+	_, err := pgur.pool.Exec(ctx, "INSERT INTO words(word) VALUES($1)", word)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		// "23505" is the PostgreSQL error code for unique_violation
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrDuplicateWord
+		}
+		return wrapDatabaseError(err)
+	}
+	return nil
+}
+
+// DeleteWord deletes the word in whatever case it was stored, the seeded
+// ones are capitalised.
+func (pgur *PostgresRepo) DeleteWord(ctx context.Context, word string) error {
+	tag, err := pgur.pool.Exec(ctx, "DELETE FROM words WHERE lower(word) = lower($1)", word)
+	if err != nil {
+		return wrapDatabaseError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrWordNotFound
+	}
+	return nil
+}
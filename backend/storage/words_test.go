@@ -0,0 +1,38 @@
+package storage_test
+
+import (
+	"api/domain"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWords(t *testing.T) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.AddWord(ctx, "lantern"))
+	assert.ErrorIs(t, repo.AddWord(ctx, "lantern"), domain.ErrDuplicateWord)
+
+	words, err := repo.ListWords(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, words, "lantern")
+	assert.Contains(t, words, "elephant", "the seeded words are there")
+
+	require.NoError(t, repo.DeleteWord(ctx, "lantern"))
+	assert.ErrorIs(t, repo.DeleteWord(ctx, "lantern"), domain.ErrWordNotFound)
+	words, err = repo.ListWords(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, words, "lantern")
+
+	t.Run("case insensitive", func(t *testing.T) {
+		assert.ErrorIs(t, repo.AddWord(ctx, "mario"), domain.ErrDuplicateWord, "Mario is seeded")
+
+		require.NoError(t, repo.DeleteWord(ctx, "mickey mouse"))
+		words, err := repo.ListWords(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, words, "Mickey Mouse")
+		assert.ErrorIs(t, repo.DeleteWord(ctx, "Mickey Mouse"), domain.ErrWordNotFound)
+	})
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is a webhook host resolving inside our own network.
+var ErrBlockedAddress = errors.New("blocked-webhook-address")
+
+// blockedAddr reports addresses a webhook must never reach: loopback,
+// private, link-local (cloud metadata lives there) and the like.
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || !addr.IsValid()
+}
+
+// newClient posts deliveries and never follows redirects, a 3xx is a failed
+// delivery. With guard it refuses to connect to a blocked address, checked
+// once DNS resolved the host so a name pointing inside gets nowhere.
+func newClient(timeout time.Duration, guard bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if guard {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || blockedAddr(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// a proxy would dial for us, past the check
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
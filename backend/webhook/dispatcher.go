@@ -0,0 +1,404 @@
+package webhook
+
+import (
+	"api/domain"
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-GTO-Event"
+	HeaderDelivery  = "X-GTO-Delivery"
+	HeaderTimestamp = "X-GTO-Timestamp"
+	HeaderSignature = "X-GTO-Signature"
+)
+
+// Target is a single endpoint a payload is POSTed to.
+type Target struct {
+	WebhookId string // empty for deployment-wide targets
+	URL       string
+	Secret    string
+}
+
+type Config struct {
+	Workers           int
+	QueueSize         int
+	MaxAttempts       int
+	BaseBackoff       time.Duration
+	MaxBackoff        time.Duration
+	RequestTimeout    time.Duration
+	DeploymentTargets []Target
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		QueueSize:      1024,
+		MaxAttempts:    6,
+		BaseBackoff:    time.Second,
+		MaxBackoff:     time.Minute * 5,
+		RequestTimeout: time.Second * 10,
+	}
+}
+
+type payloadPlayer struct {
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+	Guest    bool   `json:"guest,omitempty"`
+}
+
+type payload struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	RoomId     string          `json:"roomId"`
+	Private    bool            `json:"private"`
+	Host       string          `json:"host"`
+	Round      int             `json:"round"`
+	Players    []payloadPlayer `json:"players"`
+}
+
+type delivery struct {
+	id        string
+	target    Target
+	eventType domain.GameEventType
+	body      []byte
+	attempts  int
+	lastErr   error
+	// when a retry is due
+	due time.Time
+}
+
+type Dispatcher struct {
+	config Config
+	// creator webhooks can't reach our network, deployment targets are ours
+	client           *http.Client
+	deploymentClient *http.Client
+	resolver         TargetResolver
+	deadLetters      DeadLetterStore
+	events           chan domain.GameEvent
+	deliveries       chan delivery
+	// after waits out the backoff of the retry queue
+	after      func(d time.Duration) <-chan time.Time
+	now        func() time.Time
+	ctx        context.Context
+	cancel     context.CancelFunc
+	fanoutDone chan struct{}
+	workersWg  sync.WaitGroup
+	// deliveries not delivered nor dead lettered yet
+	pending sync.WaitGroup
+
+	retryMu     sync.Mutex
+	retries     retryQueue
+	retryWake   chan struct{}
+	retrierDone chan struct{}
+}
+
+func NewDispatcher(config Config, resolver TargetResolver, deadLetters DeadLetterStore) *Dispatcher {
+	config.MaxAttempts = max(config.MaxAttempts, 1)
+	config.Workers = max(config.Workers, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		config:           config,
+		client:           newClient(config.RequestTimeout, true),
+		deploymentClient: newClient(config.RequestTimeout, false),
+		resolver:         resolver,
+		deadLetters:      deadLetters,
+		events:           make(chan domain.GameEvent, config.QueueSize),
+		deliveries:       make(chan delivery, config.QueueSize),
+		after:            time.After,
+		now:              time.Now,
+		ctx:              ctx,
+		cancel:           cancel,
+		fanoutDone:       make(chan struct{}),
+		retryWake:        make(chan struct{}, 1),
+		retrierDone:      make(chan struct{}),
+	}
+}
+
+// Start launches the fanout and retry goroutines and the delivery workers.
+func (d *Dispatcher) Start() {
+	go d.fanout()
+	go d.retrier()
+	for range d.config.Workers {
+		d.workersWg.Go(d.worker)
+	}
+}
+
+// Publish never blocks: it is called from room actors. When the queue is full
+// the event is dropped and logged.
+func (d *Dispatcher) Publish(e domain.GameEvent) {
+	select {
+	case d.events <- e:
+	default:
+		slog.Warn("Webhook: event queue full, dropping event", "type", e.Type, "room_id", e.RoomId)
+	}
+}
+
+// Close stops accepting events and waits for deliveries and their retries
+// until ctx expires, after which pending retries are dead lettered.
+func (d *Dispatcher) Close(ctx context.Context) {
+	close(d.events)
+	done := make(chan struct{})
+	go func() {
+		<-d.fanoutDone
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+	<-d.retrierDone
+	close(d.deliveries)
+	d.workersWg.Wait()
+}
+
+func (d *Dispatcher) fanout() {
+	defer close(d.fanoutDone)
+	for e := range d.events {
+		body, err := json.Marshal(makePayload(e))
+		if err != nil {
+			slog.Error("Webhook: failed to marshal payload", "error", err.Error(), "type", e.Type)
+			continue
+		}
+
+		targets := append([]Target{}, d.config.DeploymentTargets...)
+		if e.HostId != "" && d.resolver != nil {
+			hooks, err := d.resolver.GetWebhooksByOwner(d.ctx, e.HostId)
+			if err != nil {
+				slog.Error("Webhook: failed to resolve creator webhooks", "error", err.Error(), "host_id", e.HostId)
+			}
+			for _, h := range hooks {
+				targets = append(targets, Target{WebhookId: h.Id, URL: h.URL, Secret: h.Secret})
+			}
+		}
+
+		for _, t := range targets {
+			dl := delivery{id: newDeliveryId(), target: t, eventType: e.Type, body: body}
+			d.pending.Add(1)
+			select {
+			case d.deliveries <- dl:
+			case <-d.ctx.Done():
+				d.pending.Done()
+				return
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) worker() {
+	for dl := range d.deliveries {
+		d.attempt(dl)
+	}
+}
+
+// attempt posts the delivery once, a failure worth retrying waits in the
+// retry queue so a dead endpoint never holds a worker.
+func (d *Dispatcher) attempt(dl delivery) {
+	dl.attempts++
+	dl.lastErr = d.post(dl)
+	switch {
+	case dl.lastErr == nil:
+		d.pending.Done()
+	case errors.Is(dl.lastErr, errPermanentFailure), dl.attempts >= d.config.MaxAttempts, d.ctx.Err() != nil:
+		d.deadLetter(dl)
+	default:
+		dl.due = d.now().Add(d.backoff(dl.attempts))
+		d.retryMu.Lock()
+		heap.Push(&d.retries, dl)
+		d.retryMu.Unlock()
+		select {
+		case d.retryWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// retrier hands retries back to the workers once their backoff passed. On
+// shutdown the ones still waiting are dead lettered.
+func (d *Dispatcher) retrier() {
+	defer close(d.retrierDone)
+	for {
+		d.retryMu.Lock()
+		var due []delivery
+		for d.retries.Len() > 0 && !d.retries[0].due.After(d.now()) {
+			due = append(due, heap.Pop(&d.retries).(delivery))
+		}
+		var wait <-chan time.Time
+		if d.retries.Len() > 0 {
+			wait = d.after(d.retries[0].due.Sub(d.now()))
+		}
+		d.retryMu.Unlock()
+
+		for i, dl := range due {
+			select {
+			case d.deliveries <- dl:
+			case <-d.ctx.Done():
+				for _, dl := range due[i:] {
+					d.deadLetter(dl)
+				}
+				d.deadLetterRetries()
+				return
+			}
+		}
+
+		select {
+		case <-wait:
+		case <-d.retryWake:
+		case <-d.ctx.Done():
+			d.deadLetterRetries()
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deadLetterRetries() {
+	d.retryMu.Lock()
+	waiting := d.retries
+	d.retries = nil
+	d.retryMu.Unlock()
+	for _, dl := range waiting {
+		d.deadLetter(dl)
+	}
+}
+
+func (d *Dispatcher) deadLetter(dl delivery) {
+	defer d.pending.Done()
+	slog.Warn("Webhook: delivery failed, moving to dead letters",
+		"url", dl.target.URL,
+		"type", dl.eventType,
+		"attempts", dl.attempts,
+		"error", dl.lastErr.Error(),
+	)
+	if d.deadLetters == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := d.deadLetters.SaveWebhookDeadLetter(ctx, domain.WebhookDeadLetter{
+		WebhookId: dl.target.WebhookId,
+		URL:       dl.target.URL,
+		EventType: dl.eventType,
+		Payload:   dl.body,
+		Attempts:  dl.attempts,
+		LastError: dl.lastErr.Error(),
+	})
+	if err != nil {
+		slog.Error("Webhook: failed to save dead letter", "error", err.Error(), "url", dl.target.URL)
+	}
+}
+
+// retryQueue is a heap of deliveries, the next due first.
+type retryQueue []delivery
+
+func (q retryQueue) Len() int           { return len(q) }
+func (q retryQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+func (q retryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *retryQueue) Push(x any)        { *q = append(*q, x.(delivery)) }
+func (q *retryQueue) Pop() any {
+	old := *q
+	dl := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return dl
+}
+
+func (d *Dispatcher) post(dl delivery) error {
+	ctx, cancel := context.WithTimeout(d.ctx, d.config.RequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.target.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPermanentFailure, err)
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(dl.eventType))
+	req.Header.Set(HeaderDelivery, dl.id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(dl.target.Secret, timestamp, dl.body))
+
+	client := d.client
+	if dl.target.WebhookId == "" {
+		client = d.deploymentClient
+	}
+	res, err := client.Do(req)
+	if errors.Is(err, ErrBlockedAddress) {
+		return fmt.Errorf("%w: %w", errPermanentFailure, err)
+	}
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1024))
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusTooManyRequests, res.StatusCode == http.StatusRequestTimeout, res.StatusCode >= 500:
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	default:
+		return fmt.Errorf("%w: status %d", errPermanentFailure, res.StatusCode)
+	}
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := d.config.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		b *= 2
+		if b >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return min(b, d.config.MaxBackoff)
+}
+
+// Sign returns the value of the signature header: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func makePayload(e domain.GameEvent) payload {
+	players := make([]payloadPlayer, 0, len(e.Players))
+	for _, p := range e.Players {
+		players = append(players, payloadPlayer{Username: p.Username, Score: p.Score, Guest: p.Guest})
+	}
+	return payload{
+		Id:         newDeliveryId(),
+		Type:       string(e.Type),
+		OccurredAt: e.OccurredAt,
+		RoomId:     e.RoomId,
+		Private:    e.Private,
+		Host:       e.Host,
+		Round:      e.Round,
+		Players:    players,
+	}
+}
+
+func newDeliveryId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,340 @@
+package webhook
+
+import (
+	"api/domain"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockTargetResolver struct {
+	mock.Mock
+}
+
+func (m *MockTargetResolver) GetWebhooksByOwner(ctx context.Context, ownerId string) ([]domain.Webhook, error) {
+	args := m.Called(ctx, ownerId)
+	return args.Get(0).([]domain.Webhook), args.Error(1)
+}
+
+type MockDeadLetterStore struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterStore) SaveWebhookDeadLetter(ctx context.Context, dl domain.WebhookDeadLetter) error {
+	args := m.Called(ctx, dl)
+	return args.Error(0)
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver answers with the given statuses in order, then 200 forever.
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 16)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header.Clone(), body: body}
+		i := int(calls.Add(1)) - 1
+		if i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// newTestDispatcher waits out no backoff, it records them and moves its clock
+// past them instead. Its creator webhooks may reach the test receivers.
+func newTestDispatcher(config Config, resolver TargetResolver, deadLetters DeadLetterStore) (*Dispatcher, func() []time.Duration) {
+	d := NewDispatcher(config, resolver, deadLetters)
+	d.client = newClient(config.RequestTimeout, false)
+	var mu sync.Mutex
+	clock := time.Now()
+	sleeps := []time.Duration{}
+	d.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+	d.after = func(dur time.Duration) <-chan time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		sleeps = append(sleeps, dur)
+		clock = clock.Add(dur)
+		fired := make(chan time.Time, 1)
+		fired <- clock
+		return fired
+	}
+	return d, func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return sleeps
+	}
+}
+
+func testConfig(targets ...Target) Config {
+	config := DefaultConfig()
+	config.Workers = 2
+	config.MaxAttempts = 4
+	config.DeploymentTargets = targets
+	return config
+}
+
+func waitRequest(t *testing.T, received chan receivedRequest) receivedRequest {
+	t.Helper()
+	select {
+	case req := <-received:
+		return req
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "webhook receiver got nothing")
+		return receivedRequest{}
+	}
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	t.Parallel()
+	server, received := newReceiver(t)
+	d, _ := newTestDispatcher(testConfig(Target{URL: server.URL, Secret: "deployment-secret"}), nil, nil)
+	d.Start()
+
+	d.Publish(domain.GameEvent{
+		Type:    domain.GameEventGameStarted,
+		RoomId:  "ROOM1",
+		Host:    "naruto",
+		Round:   1,
+		Players: []domain.GameEventPlayer{{Username: "naruto"}, {Username: "sasuke"}},
+	})
+
+	req := waitRequest(t, received)
+	d.Close(context.Background())
+
+	assert.Equal(t, "game-started", req.header.Get(HeaderEvent))
+	assert.NotEmpty(t, req.header.Get(HeaderDelivery))
+	timestamp := req.header.Get(HeaderTimestamp)
+	assert.Equal(t, Sign("deployment-secret", timestamp, req.body), req.header.Get(HeaderSignature))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(req.body, &body))
+	assert.Equal(t, "game-started", body["type"])
+	assert.Equal(t, "ROOM1", body["roomId"])
+	assert.Equal(t, "naruto", body["host"])
+	assert.Len(t, body["players"], 2)
+}
+
+func TestDispatcher_DeliversToCreatorWebhooks(t *testing.T) {
+	t.Parallel()
+	server, received := newReceiver(t)
+	resolver := &MockTargetResolver{}
+	resolver.On("GetWebhooksByOwner", mock.Anything, "host-id").Return([]domain.Webhook{
+		{Id: "hook-1", URL: server.URL, Secret: "creator-secret"},
+	}, nil)
+
+	d, _ := newTestDispatcher(testConfig(), resolver, nil)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventRoomCreated, RoomId: "ROOM1", HostId: "host-id"})
+
+	req := waitRequest(t, received)
+	d.Close(context.Background())
+
+	assert.Equal(t, Sign("creator-secret", req.header.Get(HeaderTimestamp), req.body), req.header.Get(HeaderSignature))
+	resolver.AssertExpectations(t)
+}
+
+func TestDispatcher_RetriesWithExponentialBackoff(t *testing.T) {
+	t.Parallel()
+	server, received := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusTooManyRequests)
+	deadLetters := &MockDeadLetterStore{}
+	d, sleeps := newTestDispatcher(testConfig(Target{URL: server.URL, Secret: "s"}), nil, deadLetters)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+
+	for range 4 {
+		waitRequest(t, received)
+	}
+	d.Close(context.Background())
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, sleeps())
+	deadLetters.AssertNotCalled(t, "SaveWebhookDeadLetter", mock.Anything, mock.Anything)
+}
+
+func TestDispatcher_DeadLettersAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+	server, _ := newReceiver(t, 500, 500, 500, 500)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.URL == server.URL && dl.Attempts == 4 && dl.EventType == domain.GameEventGameEnded && len(dl.Payload) > 0
+	})).Return(nil).Once()
+
+	d, _ := newTestDispatcher(testConfig(Target{URL: server.URL, Secret: "s"}), nil, deadLetters)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+	d.Close(context.Background())
+
+	deadLetters.AssertExpectations(t)
+}
+
+func TestDispatcher_DoesNotRetryClientErrors(t *testing.T) {
+	t.Parallel()
+	server, _ := newReceiver(t, http.StatusGone)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.Attempts == 1 && dl.WebhookId == ""
+	})).Return(nil).Once()
+
+	d, sleeps := newTestDispatcher(testConfig(Target{URL: server.URL, Secret: "s"}), nil, deadLetters)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+	d.Close(context.Background())
+
+	assert.Empty(t, sleeps())
+	deadLetters.AssertExpectations(t)
+}
+
+func TestDispatcher_DeadEndpointDoesNotStallOthers(t *testing.T) {
+	t.Parallel()
+	dead, _ := newReceiver(t, 500, 500, 500, 500)
+	alive, received := newReceiver(t)
+	resolver := &MockTargetResolver{}
+	resolver.On("GetWebhooksByOwner", mock.Anything, "host-id").Return([]domain.Webhook{
+		{Id: "hook-1", URL: alive.URL, Secret: "s"},
+	}, nil)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.Anything).Return(nil)
+
+	config := testConfig(Target{URL: dead.URL, Secret: "s"})
+	config.Workers = 1
+	config.BaseBackoff = time.Hour
+	d := NewDispatcher(config, resolver, deadLetters)
+	d.client = newClient(config.RequestTimeout, false)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+	// the only worker isn't sleeping on the dead endpoint's hour of backoff
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM2", HostId: "host-id"})
+	waitRequest(t, received)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d.Close(ctx)
+	deadLetters.AssertCalled(t, "SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.URL == dead.URL && dl.Attempts == 1
+	}))
+}
+
+func TestDispatcher_RefusesCreatorWebhooksResolvingInside(t *testing.T) {
+	t.Parallel()
+	server, received := newReceiver(t)
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+	resolver := &MockTargetResolver{}
+	resolver.On("GetWebhooksByOwner", mock.Anything, "host-id").Return([]domain.Webhook{
+		{Id: "hook-1", URL: "http://localhost" + port, Secret: "s"},
+	}, nil)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.WebhookId == "hook-1" && dl.Attempts == 1 && strings.Contains(dl.LastError, ErrBlockedAddress.Error())
+	})).Return(nil).Once()
+
+	d := NewDispatcher(testConfig(), resolver, deadLetters)
+	d.Start()
+	d.Publish(domain.GameEvent{Type: domain.GameEventRoomCreated, RoomId: "ROOM1", HostId: "host-id"})
+	d.Close(context.Background())
+
+	assert.Empty(t, received)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestDispatcher_DoesNotFollowRedirects(t *testing.T) {
+	t.Parallel()
+	inside, received := newReceiver(t)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, inside.URL, http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.URL == redirect.URL && dl.Attempts == 1
+	})).Return(nil).Once()
+
+	d, _ := newTestDispatcher(testConfig(Target{URL: redirect.URL, Secret: "s"}), nil, deadLetters)
+	d.Start()
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+	d.Close(context.Background())
+
+	assert.Empty(t, received)
+	deadLetters.AssertExpectations(t)
+}
+
+func TestDispatcher_AttemptsAtLeastOnce(t *testing.T) {
+	t.Parallel()
+	server, received := newReceiver(t, 500)
+	deadLetters := &MockDeadLetterStore{}
+	deadLetters.On("SaveWebhookDeadLetter", mock.Anything, mock.MatchedBy(func(dl domain.WebhookDeadLetter) bool {
+		return dl.Attempts == 1
+	})).Return(nil).Once()
+
+	config := testConfig(Target{URL: server.URL, Secret: "s"})
+	config.MaxAttempts = 0
+	d, _ := newTestDispatcher(config, nil, deadLetters)
+	d.Start()
+
+	d.Publish(domain.GameEvent{Type: domain.GameEventGameEnded, RoomId: "ROOM1"})
+	waitRequest(t, received)
+	d.Close(context.Background())
+	deadLetters.AssertExpectations(t)
+}
+
+func TestDispatcher_PublishNeverBlocks(t *testing.T) {
+	t.Parallel()
+	config := testConfig()
+	config.QueueSize = 1
+	d := NewDispatcher(config, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			d.Publish(domain.GameEvent{Type: domain.GameEventRoomCreated})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "Publish blocked on a full queue")
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	t.Parallel()
+	config := testConfig()
+	config.BaseBackoff = time.Second
+	config.MaxBackoff = 10 * time.Second
+	d := NewDispatcher(config, nil, nil)
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 8*time.Second, d.backoff(4))
+	assert.Equal(t, 10*time.Second, d.backoff(5))
+	assert.Equal(t, 10*time.Second, d.backoff(80))
+}
@@ -0,0 +1,11 @@
+package webhook
+
+import "errors"
+
+var (
+	ErrInvalidWebhookURL = errors.New("invalid-webhook-url")
+	ErrTooManyWebhooks   = errors.New("too-many-webhooks")
+)
+
+// errPermanentFailure marks a delivery that must not be retried (e.g. 4xx).
+var errPermanentFailure = errors.New("permanent-delivery-failure")
@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"api/domain"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidWebhookURLStr = "invalid-webhook-url"
+	ErrTooManyWebhooksStr   = "too-many-webhooks"
+	ErrWebhookNotFoundStr   = "webhook-not-found"
+)
+
+type webhookHandler struct {
+	repo        WebhookRepo
+	maxPerOwner int
+}
+
+func NewWebhookHandler(repo WebhookRepo, maxPerOwner int) *webhookHandler {
+	return &webhookHandler{repo: repo, maxPerOwner: maxPerOwner}
+}
+
+type WebhookResponse struct {
+	Id        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// validateTargetURL only accepts https endpoints that are not obviously
+// pointing inside our own network. Names are checked again once resolved,
+// when the dispatcher dials.
+func validateTargetURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInvalidWebhookURL
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && blockedAddr(addr) {
+		return ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+func (wh *webhookHandler) CreateWebhookHandler(ctx *gin.Context) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.String(http.StatusBadRequest, "bad-request-format")
+		return
+	}
+	if err := validateTargetURL(req.URL); err != nil {
+		ctx.String(http.StatusBadRequest, ErrInvalidWebhookURLStr)
+		return
+	}
+
+	reqCtx := ctx.Request.Context()
+	existing, err := wh.repo.GetWebhooksByOwner(reqCtx, userId)
+	if err != nil {
+		slog.Error("CreateWebhook: failed to list webhooks", "error", err.Error(), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	if len(existing) >= wh.maxPerOwner {
+		ctx.String(http.StatusConflict, ErrTooManyWebhooksStr)
+		return
+	}
+
+	secretBytes := make([]byte, 32)
+	rand.Read(secretBytes)
+	hook, err := wh.repo.CreateWebhook(reqCtx, userId, req.URL, hex.EncodeToString(secretBytes))
+	if err != nil {
+		slog.Error("CreateWebhook: failed to create webhook", "error", err.Error(), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+
+	// the secret is only ever shown once
+	ctx.JSON(http.StatusCreated, WebhookResponse{
+		Id:        hook.Id,
+		URL:       hook.URL,
+		Secret:    hook.Secret,
+		CreatedAt: hook.CreatedAt,
+	})
+}
+
+func (wh *webhookHandler) ListWebhooksHandler(ctx *gin.Context) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	hooks, err := wh.repo.GetWebhooksByOwner(ctx.Request.Context(), userId)
+	if err != nil {
+		slog.Error("ListWebhooks: failed to list webhooks", "error", err.Error(), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(hooks))
+	for _, h := range hooks {
+		response = append(response, WebhookResponse{Id: h.Id, URL: h.URL, CreatedAt: h.CreatedAt})
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (wh *webhookHandler) DeleteWebhookHandler(ctx *gin.Context) {
+	userId := ctx.GetString("id")
+	if userId == "" {
+		ctx.String(http.StatusUnauthorized, "unauthenticated")
+		return
+	}
+
+	err := wh.repo.DeleteWebhook(ctx.Request.Context(), userId, ctx.Param("webhookid"))
+	if err != nil {
+		if errors.Is(err, domain.ErrWebhookNotFound) {
+			ctx.String(http.StatusNotFound, ErrWebhookNotFoundStr)
+			return
+		}
+		slog.Error("DeleteWebhook: failed to delete webhook", "error", err.Error(), "user_id", userId)
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,21 @@
+package webhook
+
+import (
+	"api/domain"
+	"context"
+)
+
+// TargetResolver returns the personal webhooks registered by a room creator.
+type TargetResolver interface {
+	GetWebhooksByOwner(ctx context.Context, ownerId string) ([]domain.Webhook, error)
+}
+
+type DeadLetterStore interface {
+	SaveWebhookDeadLetter(ctx context.Context, dl domain.WebhookDeadLetter) error
+}
+
+type WebhookRepo interface {
+	TargetResolver
+	CreateWebhook(ctx context.Context, ownerId, url, secret string) (domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, ownerId, id string) error
+}
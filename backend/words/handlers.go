@@ -0,0 +1,104 @@
+package words
+
+import (
+	"api/domain"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidWordStr   = "invalid-word"
+	ErrDuplicateWordStr = "duplicate-word"
+	ErrWordNotFoundStr  = "word-not-found"
+)
+
+// maxWordLength is the size of the words column.
+const maxWordLength = 50
+
+type wordsHandler struct {
+	repo WordRepo
+}
+
+// NewWordsHandler manages the words, its routes go behind
+// auth's RequirePermission(domain.PermManageWords).
+func NewWordsHandler(repo WordRepo) *wordsHandler {
+	return &wordsHandler{repo: repo}
+}
+
+// normalizeWord lowercases the word and collapses its spaces, false when it
+// isn't letters, spaces and hyphens.
+func normalizeWord(raw string) (string, bool) {
+	word := strings.Join(strings.Fields(strings.ToLower(raw)), " ")
+	if word == "" || utf8.RuneCountInString(word) > maxWordLength {
+		return "", false
+	}
+	for _, r := range word {
+		if !unicode.IsLetter(r) && r != ' ' && r != '-' {
+			return "", false
+		}
+	}
+	return word, true
+}
+
+func (wh *wordsHandler) ListWordsHandler(ctx *gin.Context) {
+	words, err := wh.repo.ListWords(ctx.Request.Context())
+	if err != nil {
+		slog.Error("ListWords: failed to list words", "error", err.Error(), "user_id", ctx.GetString("id"))
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"words": words})
+}
+
+func (wh *wordsHandler) AddWordHandler(ctx *gin.Context) {
+	var request struct {
+		Word string `json:"word"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.String(http.StatusBadRequest, "bad-request-format")
+		return
+	}
+	word, ok := normalizeWord(request.Word)
+	if !ok {
+		ctx.String(http.StatusBadRequest, ErrInvalidWordStr)
+		return
+	}
+
+	if err := wh.repo.AddWord(ctx.Request.Context(), word); err != nil {
+		if errors.Is(err, domain.ErrDuplicateWord) {
+			ctx.String(http.StatusConflict, ErrDuplicateWordStr)
+			return
+		}
+		slog.Error("AddWord: failed to add word", "error", err.Error(), "user_id", ctx.GetString("id"))
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	slog.Info("AddWord: word added", "word", word, "user_id", ctx.GetString("id"))
+	ctx.JSON(http.StatusCreated, gin.H{"word": word})
+}
+
+func (wh *wordsHandler) DeleteWordHandler(ctx *gin.Context) {
+	word, ok := normalizeWord(ctx.Param("word"))
+	if !ok {
+		ctx.String(http.StatusNotFound, ErrWordNotFoundStr)
+		return
+	}
+
+	if err := wh.repo.DeleteWord(ctx.Request.Context(), word); err != nil {
+		if errors.Is(err, domain.ErrWordNotFound) {
+			ctx.String(http.StatusNotFound, ErrWordNotFoundStr)
+			return
+		}
+		slog.Error("DeleteWord: failed to delete word", "error", err.Error(), "user_id", ctx.GetString("id"))
+		ctx.String(http.StatusInternalServerError, "unknown-error")
+		return
+	}
+	slog.Info("DeleteWord: word deleted", "word", word, "user_id", ctx.GetString("id"))
+	ctx.Status(http.StatusNoContent)
+}
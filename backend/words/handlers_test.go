@@ -0,0 +1,101 @@
+package words
+
+import (
+	"api/domain"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockWordRepo struct {
+	mock.Mock
+}
+
+func (m *MockWordRepo) ListWords(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockWordRepo) AddWord(ctx context.Context, word string) error {
+	args := m.Called(ctx, word)
+	return args.Error(0)
+}
+
+func (m *MockWordRepo) DeleteWord(ctx context.Context, word string) error {
+	args := m.Called(ctx, word)
+	return args.Error(0)
+}
+
+func TestNormalizeWord(t *testing.T) {
+	t.Parallel()
+	for raw, want := range map[string]string{
+		"Banana":          "banana",
+		"  palm   Tree  ": "palm tree",
+		"jack-o-lantern":  "jack-o-lantern",
+		"éclair":          "éclair",
+	} {
+		word, ok := normalizeWord(raw)
+		assert.True(t, ok, raw)
+		assert.Equal(t, want, word)
+	}
+	for _, raw := range []string{"", "   ", "r2d2", "drop;table", strings.Repeat("a", maxWordLength+1)} {
+		_, ok := normalizeWord(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestWordsHandlers(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	repo := new(MockWordRepo)
+	repo.On("ListWords", mock.Anything).Return([]string{"banana", "cat"}, nil).Once()
+	repo.On("AddWord", mock.Anything, "palm tree").Return(nil).Once()
+	repo.On("AddWord", mock.Anything, "cat").Return(domain.ErrDuplicateWord).Once()
+	repo.On("AddWord", mock.Anything, "pizza").Return(errors.New("db down")).Once()
+	repo.On("DeleteWord", mock.Anything, "palm tree").Return(nil).Once()
+	repo.On("DeleteWord", mock.Anything, "dragon").Return(domain.ErrWordNotFound).Once()
+
+	handler := NewWordsHandler(repo)
+	server := gin.New()
+	server.GET("/admin/words", handler.ListWordsHandler)
+	server.POST("/admin/words", handler.AddWordHandler)
+	server.DELETE("/admin/words/:word", handler.DeleteWordHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+		want   string
+	}{
+		{http.MethodGet, "/admin/words", "", http.StatusOK, `{"words":["banana","cat"]}`},
+		{http.MethodPost, "/admin/words", `{"word":" Palm  Tree "}`, http.StatusCreated, `{"word":"palm tree"}`},
+		{http.MethodPost, "/admin/words", `{"word":"cat"}`, http.StatusConflict, ErrDuplicateWordStr},
+		{http.MethodPost, "/admin/words", `{"word":"r2d2"}`, http.StatusBadRequest, ErrInvalidWordStr},
+		{http.MethodPost, "/admin/words", `{"word":"pizza"}`, http.StatusInternalServerError, "unknown-error"},
+		{http.MethodDelete, "/admin/words/palm%20tree", "", http.StatusNoContent, ""},
+		{http.MethodDelete, "/admin/words/dragon", "", http.StatusNotFound, ErrWordNotFoundStr},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+
+		assert.Equal(t, tt.code, res.Code, tt.method+" "+tt.path+" "+tt.body)
+		if strings.HasPrefix(tt.want, "{") {
+			assert.JSONEq(t, tt.want, res.Body.String())
+		} else {
+			assert.Equal(t, tt.want, res.Body.String())
+		}
+	}
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,11 @@
+package words
+
+import "context"
+
+// WordRepo is the list of words players are given to draw.
+type WordRepo interface {
+	ListWords(ctx context.Context) ([]string, error)
+	// AddWord fails with domain.ErrDuplicateWord when the word is listed.
+	AddWord(ctx context.Context, word string) error
+	DeleteWord(ctx context.Context, word string) error
+}
@@ -13,6 +13,10 @@ export const WS_BASE_URL = isDev
 // API Endpoints
 export const endpoints = {
   login: `${API_BASE_URL}/auth/login`,
+  loginMfa: `${API_BASE_URL}/auth/login/mfa`,
+  oidcProviders: `${API_BASE_URL}/auth/oidc`,
+  oidcLogin: (provider: string) => `${API_BASE_URL}/auth/oidc/${provider}`,
+  oidcSignup: `${API_BASE_URL}/auth/oidc/signup`,
   signup: `${API_BASE_URL}/auth/signup`,
   logout: `${API_BASE_URL}/auth/logout`,
   refresh: `${API_BASE_URL}/auth/refresh`,
@@ -24,15 +24,94 @@ export interface ServerPacket {
   initialRoomSnapshot?: ServerPacket_InitialRoomSnapshot | undefined;
   yourTurnToDraw?: ServerPacket_YourTurnToDraw | undefined;
   playerLeft?: ServerPacket_PlayerLeft | undefined;
+  drawingLimitReached?: ServerPacket_DrawingLimitReached | undefined;
+  welcome?: ServerPacket_Welcome | undefined;
+  error?: ServerPacket_Error | undefined;
+  timeSync?: ServerPacket_TimeSync | undefined;
   serverTimestamp: number;
+  /**
+   * Numbers the packets every player of the room gets, one after the other.
+   * Packets for some players only (word choices, errors, chat) have none,
+   * a snapshot has the seq of the last packet it includes. A client that
+   * sees a gap sends a Resume with the last seq it applied and ignores what
+   * it gets until the replay or a snapshot fills it, and it always ignores
+   * packets it already applied.
+   */
+  seq: number;
+  /**
+   * Set when the write path merged the drawing ops of several packets, the
+   * packet then holds first_seq to seq.
+   */
+  firstSeq: number;
+  /**
+   * Time left in the phase when the packet was sent, on the packets that
+   * start a phase and on snapshots. Unlike next_tick it does not depend on
+   * the client's clock.
+   */
+  phaseRemainingMs: number;
+  /**
+   * Packets the server wrote as a single frame, in order, when write
+   * batching is on. A batch frame has nothing else set.
+   */
+  batch: ServerPacket[];
+}
+
+/**
+ * Answers a ClientPacket.TimeSync right away, times are Unix ms. With t3
+ * the time the client got it, the client's clock is behind by
+ * ((server_receive_time - client_time) + (server_send_time - t3)) / 2 and
+ * the round trip took (t3 - client_time) - (server_send_time -
+ * server_receive_time). Clients take a few samples and keep the offset
+ * of the one with the shortest round trip.
+ */
+export interface ServerPacket_TimeSync {
+  clientTime: number;
+  serverReceiveTime: number;
+  serverSendTime: number;
+}
+
+/**
+ * Answers a Hello with the version both sides speak and the features the
+ * server will use with this client.
+ */
+export interface ServerPacket_Welcome {
+  protocolVersion: number;
+  features: string[];
+}
+
+/**
+ * Sent to the client that caused it only, and rate limited so some may be
+ * left out. code is one of rate-limited, not-host, not-your-turn,
+ * invalid-choice, invalid-drawing-op, drawing-ops-unsupported,
+ * malformed-packet and unsupported-version, which is followed by the
+ * server closing the connection. drawing-ops-unsupported tells the drawer
+ * that a player without drawing ops is in the room, it sends data instead.
+ */
+export interface ServerPacket_Error {
+  code: string;
+  message: string;
+}
+
+/**
+ * Sent to the drawer, once per turn, when drawing data starts being
+ * rejected. reason is drawing-budget-exceeded or drawing-history-full,
+ * clearing the canvas frees the history.
+ */
+export interface ServerPacket_DrawingLimitReached {
+  reason: string;
 }
 
 export interface ServerPacket_YourTurnToDraw {
   word: string;
 }
 
+/**
+ * Sent on join, and again to a player that fell behind, in which case it
+ * replaces the whole room state and canvas.
+ */
 export interface ServerPacket_InitialRoomSnapshot {
   playersStates: ServerPacket_InitialRoomSnapshot_PlayerState[];
+  /** visible strokes of the turn only, one chunk per stroke or fill */
   drawingHistory: Uint8Array[];
   currentDrawer: string;
   currentRound: number;
@@ -103,11 +182,58 @@ export interface ClientPacket {
   playerMessage?: ClientPacket_PlayerMessage | undefined;
   wordChoice?: ClientPacket_WordChoice | undefined;
   startGame?: ClientPacket_StartGame | undefined;
+  undo?: ClientPacket_Undo | undefined;
+  redo?: ClientPacket_Redo | undefined;
+  clearCanvas?: ClientPacket_ClearCanvas | undefined;
+  hello?: ClientPacket_Hello | undefined;
+  resume?: ClientPacket_Resume | undefined;
+  timeSync?: ClientPacket_TimeSync | undefined;
+}
+
+/**
+ * Asks for a ServerPacket.TimeSync, client_time is when the client sent
+ * it in Unix ms by its own clock. It is answered outside of the game and
+ * says nothing about the connection being alive, the websocket ping does.
+ */
+export interface ClientPacket_TimeSync {
+  clientTime: number;
+}
+
+/**
+ * Asks for the packets after last_seq again. The room replays them while
+ * it still has them and sends a fresh InitialRoomSnapshot otherwise.
+ */
+export interface ClientPacket_Resume {
+  lastSeq: number;
+}
+
+/**
+ * Hello must be the first packet, a client that does not send one speaks
+ * protocol version 1 with no optional features. It accepts any version
+ * from min_protocol_version to protocol_version.
+ */
+export interface ClientPacket_Hello {
+  protocolVersion: number;
+  minProtocolVersion: number;
+  capabilities: string[];
 }
 
 export interface ClientPacket_StartGame {
 }
 
+/**
+ * Undo, redo and clear are drawer only. The room keeps the stroke stack and
+ * relays them as a DrawingData op when the canvas changed.
+ */
+export interface ClientPacket_Undo {
+}
+
+export interface ClientPacket_Redo {
+}
+
+export interface ClientPacket_ClearCanvas {
+}
+
 export interface ClientPacket_WordChoice {
   choice: number;
 }
@@ -117,7 +243,70 @@ export interface ClientPacket_PlayerMessage {
 }
 
 export interface DrawingData {
+  /**
+   * Opaque stroke stream from before typed ops existed, only accepted while
+   * the legacy drawing data compatibility flag is on.
+   */
   data: Uint8Array;
+  /**
+   * Only accepted, and so only sent, while every player of the room agreed
+   * to drawing ops.
+   */
+  ops: DrawingOp[];
+}
+
+/**
+ * DrawingOp is a single validated drawing instruction. Strokes and fills use
+ * the colour and brush size last set during the turn.
+ */
+export interface DrawingOp {
+  strokeBegin?: DrawingOp_StrokeBegin | undefined;
+  strokePoints?: DrawingOp_StrokePoints | undefined;
+  strokeEnd?: DrawingOp_StrokeEnd | undefined;
+  fill?: DrawingOp_Fill | undefined;
+  clear?: DrawingOp_Clear | undefined;
+  undo?: DrawingOp_Undo | undefined;
+  redo?: DrawingOp_Redo | undefined;
+  setColor?: DrawingOp_SetColor | undefined;
+  setBrushSize?: DrawingOp_SetBrushSize | undefined;
+}
+
+export interface DrawingOp_Point {
+  x: number;
+  y: number;
+}
+
+export interface DrawingOp_StrokeBegin {
+  at: DrawingOp_Point | undefined;
+}
+
+export interface DrawingOp_StrokePoints {
+  points: DrawingOp_Point[];
+}
+
+export interface DrawingOp_StrokeEnd {
+}
+
+export interface DrawingOp_Fill {
+  at: DrawingOp_Point | undefined;
+}
+
+export interface DrawingOp_Clear {
+}
+
+export interface DrawingOp_Undo {
+}
+
+export interface DrawingOp_Redo {
+}
+
+export interface DrawingOp_SetColor {
+  /** 0xRRGGBBAA */
+  rgba: number;
+}
+
+export interface DrawingOp_SetBrushSize {
+  size: number;
 }
 
 function createBaseServerPacket(): ServerPacket {
@@ -136,7 +325,15 @@ function createBaseServerPacket(): ServerPacket {
     initialRoomSnapshot: undefined,
     yourTurnToDraw: undefined,
     playerLeft: undefined,
+    drawingLimitReached: undefined,
+    welcome: undefined,
+    error: undefined,
+    timeSync: undefined,
     serverTimestamp: 0,
+    seq: 0,
+    firstSeq: 0,
+    phaseRemainingMs: 0,
+    batch: [],
   };
 }
 
@@ -184,9 +381,33 @@ export const ServerPacket: MessageFns<ServerPacket> = {
     if (message.playerLeft !== undefined) {
       ServerPacket_PlayerLeft.encode(message.playerLeft, writer.uint32(114).fork()).join();
     }
+    if (message.drawingLimitReached !== undefined) {
+      ServerPacket_DrawingLimitReached.encode(message.drawingLimitReached, writer.uint32(138).fork()).join();
+    }
+    if (message.welcome !== undefined) {
+      ServerPacket_Welcome.encode(message.welcome, writer.uint32(154).fork()).join();
+    }
+    if (message.error !== undefined) {
+      ServerPacket_Error.encode(message.error, writer.uint32(162).fork()).join();
+    }
+    if (message.timeSync !== undefined) {
+      ServerPacket_TimeSync.encode(message.timeSync, writer.uint32(186).fork()).join();
+    }
     if (message.serverTimestamp !== 0) {
       writer.uint32(128).int64(message.serverTimestamp);
     }
+    if (message.seq !== 0) {
+      writer.uint32(168).uint64(message.seq);
+    }
+    if (message.firstSeq !== 0) {
+      writer.uint32(176).uint64(message.firstSeq);
+    }
+    if (message.phaseRemainingMs !== 0) {
+      writer.uint32(192).int64(message.phaseRemainingMs);
+    }
+    for (const v of message.batch) {
+      ServerPacket.encode(v!, writer.uint32(146).fork()).join();
+    }
     return writer;
   },
 
@@ -309,6 +530,38 @@ export const ServerPacket: MessageFns<ServerPacket> = {
           message.playerLeft = ServerPacket_PlayerLeft.decode(reader, reader.uint32());
           continue;
         }
+        case 17: {
+          if (tag !== 138) {
+            break;
+          }
+
+          message.drawingLimitReached = ServerPacket_DrawingLimitReached.decode(reader, reader.uint32());
+          continue;
+        }
+        case 19: {
+          if (tag !== 154) {
+            break;
+          }
+
+          message.welcome = ServerPacket_Welcome.decode(reader, reader.uint32());
+          continue;
+        }
+        case 20: {
+          if (tag !== 162) {
+            break;
+          }
+
+          message.error = ServerPacket_Error.decode(reader, reader.uint32());
+          continue;
+        }
+        case 23: {
+          if (tag !== 186) {
+            break;
+          }
+
+          message.timeSync = ServerPacket_TimeSync.decode(reader, reader.uint32());
+          continue;
+        }
         case 16: {
           if (tag !== 128) {
             break;
@@ -317,6 +570,38 @@ export const ServerPacket: MessageFns<ServerPacket> = {
           message.serverTimestamp = longToNumber(reader.int64());
           continue;
         }
+        case 21: {
+          if (tag !== 168) {
+            break;
+          }
+
+          message.seq = longToNumber(reader.uint64());
+          continue;
+        }
+        case 22: {
+          if (tag !== 176) {
+            break;
+          }
+
+          message.firstSeq = longToNumber(reader.uint64());
+          continue;
+        }
+        case 24: {
+          if (tag !== 192) {
+            break;
+          }
+
+          message.phaseRemainingMs = longToNumber(reader.int64());
+          continue;
+        }
+        case 18: {
+          if (tag !== 146) {
+            break;
+          }
+
+          message.batch.push(ServerPacket.decode(reader, reader.uint32()));
+          continue;
+        }
       }
       if ((tag & 7) === 4 || tag === 0) {
         break;
@@ -356,7 +641,17 @@ export const ServerPacket: MessageFns<ServerPacket> = {
         ? ServerPacket_YourTurnToDraw.fromJSON(object.yourTurnToDraw)
         : undefined,
       playerLeft: isSet(object.playerLeft) ? ServerPacket_PlayerLeft.fromJSON(object.playerLeft) : undefined,
+      drawingLimitReached: isSet(object.drawingLimitReached)
+        ? ServerPacket_DrawingLimitReached.fromJSON(object.drawingLimitReached)
+        : undefined,
+      welcome: isSet(object.welcome) ? ServerPacket_Welcome.fromJSON(object.welcome) : undefined,
+      error: isSet(object.error) ? ServerPacket_Error.fromJSON(object.error) : undefined,
+      timeSync: isSet(object.timeSync) ? ServerPacket_TimeSync.fromJSON(object.timeSync) : undefined,
       serverTimestamp: isSet(object.serverTimestamp) ? globalThis.Number(object.serverTimestamp) : 0,
+      seq: isSet(object.seq) ? globalThis.Number(object.seq) : 0,
+      firstSeq: isSet(object.firstSeq) ? globalThis.Number(object.firstSeq) : 0,
+      phaseRemainingMs: isSet(object.phaseRemainingMs) ? globalThis.Number(object.phaseRemainingMs) : 0,
+      batch: globalThis.Array.isArray(object?.batch) ? object.batch.map((e: any) => ServerPacket.fromJSON(e)) : [],
     };
   },
 
@@ -404,9 +699,33 @@ export const ServerPacket: MessageFns<ServerPacket> = {
     if (message.playerLeft !== undefined) {
       obj.playerLeft = ServerPacket_PlayerLeft.toJSON(message.playerLeft);
     }
+    if (message.drawingLimitReached !== undefined) {
+      obj.drawingLimitReached = ServerPacket_DrawingLimitReached.toJSON(message.drawingLimitReached);
+    }
+    if (message.welcome !== undefined) {
+      obj.welcome = ServerPacket_Welcome.toJSON(message.welcome);
+    }
+    if (message.error !== undefined) {
+      obj.error = ServerPacket_Error.toJSON(message.error);
+    }
+    if (message.timeSync !== undefined) {
+      obj.timeSync = ServerPacket_TimeSync.toJSON(message.timeSync);
+    }
     if (message.serverTimestamp !== 0) {
       obj.serverTimestamp = Math.round(message.serverTimestamp);
     }
+    if (message.seq !== 0) {
+      obj.seq = Math.round(message.seq);
+    }
+    if (message.firstSeq !== 0) {
+      obj.firstSeq = Math.round(message.firstSeq);
+    }
+    if (message.phaseRemainingMs !== 0) {
+      obj.phaseRemainingMs = Math.round(message.phaseRemainingMs);
+    }
+    if (message.batch?.length) {
+      obj.batch = message.batch.map((e) => ServerPacket.toJSON(e));
+    }
     return obj;
   },
 
@@ -457,36 +776,74 @@ export const ServerPacket: MessageFns<ServerPacket> = {
     message.playerLeft = (object.playerLeft !== undefined && object.playerLeft !== null)
       ? ServerPacket_PlayerLeft.fromPartial(object.playerLeft)
       : undefined;
+    message.drawingLimitReached = (object.drawingLimitReached !== undefined && object.drawingLimitReached !== null)
+      ? ServerPacket_DrawingLimitReached.fromPartial(object.drawingLimitReached)
+      : undefined;
+    message.welcome = (object.welcome !== undefined && object.welcome !== null)
+      ? ServerPacket_Welcome.fromPartial(object.welcome)
+      : undefined;
+    message.error = (object.error !== undefined && object.error !== null)
+      ? ServerPacket_Error.fromPartial(object.error)
+      : undefined;
+    message.timeSync = (object.timeSync !== undefined && object.timeSync !== null)
+      ? ServerPacket_TimeSync.fromPartial(object.timeSync)
+      : undefined;
     message.serverTimestamp = object.serverTimestamp ?? 0;
+    message.seq = object.seq ?? 0;
+    message.firstSeq = object.firstSeq ?? 0;
+    message.phaseRemainingMs = object.phaseRemainingMs ?? 0;
+    message.batch = object.batch?.map((e) => ServerPacket.fromPartial(e)) || [];
     return message;
   },
 };
 
-function createBaseServerPacket_YourTurnToDraw(): ServerPacket_YourTurnToDraw {
-  return { word: "" };
+function createBaseServerPacket_TimeSync(): ServerPacket_TimeSync {
+  return { clientTime: 0, serverReceiveTime: 0, serverSendTime: 0 };
 }
 
-export const ServerPacket_YourTurnToDraw: MessageFns<ServerPacket_YourTurnToDraw> = {
-  encode(message: ServerPacket_YourTurnToDraw, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
-    if (message.word !== "") {
-      writer.uint32(10).string(message.word);
+export const ServerPacket_TimeSync: MessageFns<ServerPacket_TimeSync> = {
+  encode(message: ServerPacket_TimeSync, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.clientTime !== 0) {
+      writer.uint32(8).int64(message.clientTime);
+    }
+    if (message.serverReceiveTime !== 0) {
+      writer.uint32(16).int64(message.serverReceiveTime);
+    }
+    if (message.serverSendTime !== 0) {
+      writer.uint32(24).int64(message.serverSendTime);
     }
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_YourTurnToDraw {
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_TimeSync {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseServerPacket_YourTurnToDraw();
+    const message = createBaseServerPacket_TimeSync();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
         case 1: {
-          if (tag !== 10) {
+          if (tag !== 8) {
             break;
           }
 
-          message.word = reader.string();
+          message.clientTime = longToNumber(reader.int64());
+          continue;
+        }
+        case 2: {
+          if (tag !== 16) {
+            break;
+          }
+
+          message.serverReceiveTime = longToNumber(reader.int64());
+          continue;
+        }
+        case 3: {
+          if (tag !== 24) {
+            break;
+          }
+
+          message.serverSendTime = longToNumber(reader.int64());
           continue;
         }
       }
@@ -498,87 +855,68 @@ export const ServerPacket_YourTurnToDraw: MessageFns<ServerPacket_YourTurnToDraw
     return message;
   },
 
-  fromJSON(object: any): ServerPacket_YourTurnToDraw {
-    return { word: isSet(object.word) ? globalThis.String(object.word) : "" };
+  fromJSON(object: any): ServerPacket_TimeSync {
+    return {
+      clientTime: isSet(object.clientTime) ? globalThis.Number(object.clientTime) : 0,
+      serverReceiveTime: isSet(object.serverReceiveTime) ? globalThis.Number(object.serverReceiveTime) : 0,
+      serverSendTime: isSet(object.serverSendTime) ? globalThis.Number(object.serverSendTime) : 0,
+    };
   },
 
-  toJSON(message: ServerPacket_YourTurnToDraw): unknown {
+  toJSON(message: ServerPacket_TimeSync): unknown {
     const obj: any = {};
-    if (message.word !== "") {
-      obj.word = message.word;
+    if (message.clientTime !== 0) {
+      obj.clientTime = Math.round(message.clientTime);
+    }
+    if (message.serverReceiveTime !== 0) {
+      obj.serverReceiveTime = Math.round(message.serverReceiveTime);
+    }
+    if (message.serverSendTime !== 0) {
+      obj.serverSendTime = Math.round(message.serverSendTime);
     }
     return obj;
   },
 
-  create<I extends Exact<DeepPartial<ServerPacket_YourTurnToDraw>, I>>(base?: I): ServerPacket_YourTurnToDraw {
-    return ServerPacket_YourTurnToDraw.fromPartial(base ?? ({} as any));
+  create<I extends Exact<DeepPartial<ServerPacket_TimeSync>, I>>(base?: I): ServerPacket_TimeSync {
+    return ServerPacket_TimeSync.fromPartial(base ?? ({} as any));
   },
-  fromPartial<I extends Exact<DeepPartial<ServerPacket_YourTurnToDraw>, I>>(object: I): ServerPacket_YourTurnToDraw {
-    const message = createBaseServerPacket_YourTurnToDraw();
-    message.word = object.word ?? "";
+  fromPartial<I extends Exact<DeepPartial<ServerPacket_TimeSync>, I>>(object: I): ServerPacket_TimeSync {
+    const message = createBaseServerPacket_TimeSync();
+    message.clientTime = object.clientTime ?? 0;
+    message.serverReceiveTime = object.serverReceiveTime ?? 0;
+    message.serverSendTime = object.serverSendTime ?? 0;
     return message;
   },
 };
 
-function createBaseServerPacket_InitialRoomSnapshot(): ServerPacket_InitialRoomSnapshot {
-  return {
-    playersStates: [],
-    drawingHistory: [],
-    currentDrawer: "",
-    currentRound: 0,
-    roomId: "",
-    nextTick: 0,
-    choosingWordDuration: 0,
-    drawingDuration: 0,
-    currentPhase: 0,
-  };
+function createBaseServerPacket_Welcome(): ServerPacket_Welcome {
+  return { protocolVersion: 0, features: [] };
 }
 
-export const ServerPacket_InitialRoomSnapshot: MessageFns<ServerPacket_InitialRoomSnapshot> = {
-  encode(message: ServerPacket_InitialRoomSnapshot, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
-    for (const v of message.playersStates) {
-      ServerPacket_InitialRoomSnapshot_PlayerState.encode(v!, writer.uint32(10).fork()).join();
-    }
-    for (const v of message.drawingHistory) {
-      writer.uint32(18).bytes(v!);
-    }
-    if (message.currentDrawer !== "") {
-      writer.uint32(26).string(message.currentDrawer);
-    }
-    if (message.currentRound !== 0) {
-      writer.uint32(32).int32(message.currentRound);
-    }
-    if (message.roomId !== "") {
-      writer.uint32(42).string(message.roomId);
-    }
-    if (message.nextTick !== 0) {
-      writer.uint32(48).int64(message.nextTick);
+export const ServerPacket_Welcome: MessageFns<ServerPacket_Welcome> = {
+  encode(message: ServerPacket_Welcome, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.protocolVersion !== 0) {
+      writer.uint32(8).uint32(message.protocolVersion);
     }
-    if (message.choosingWordDuration !== 0) {
-      writer.uint32(56).int64(message.choosingWordDuration);
-    }
-    if (message.drawingDuration !== 0) {
-      writer.uint32(64).int64(message.drawingDuration);
-    }
-    if (message.currentPhase !== 0) {
-      writer.uint32(72).int32(message.currentPhase);
+    for (const v of message.features) {
+      writer.uint32(18).string(v!);
     }
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_InitialRoomSnapshot {
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_Welcome {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseServerPacket_InitialRoomSnapshot();
+    const message = createBaseServerPacket_Welcome();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
         case 1: {
-          if (tag !== 10) {
+          if (tag !== 8) {
             break;
           }
 
-          message.playersStates.push(ServerPacket_InitialRoomSnapshot_PlayerState.decode(reader, reader.uint32()));
+          message.protocolVersion = reader.uint32();
           continue;
         }
         case 2: {
@@ -586,46 +924,349 @@ export const ServerPacket_InitialRoomSnapshot: MessageFns<ServerPacket_InitialRo
             break;
           }
 
-          message.drawingHistory.push(reader.bytes());
+          message.features.push(reader.string());
           continue;
         }
-        case 3: {
-          if (tag !== 26) {
-            break;
-          }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
 
-          message.currentDrawer = reader.string();
-          continue;
-        }
-        case 4: {
-          if (tag !== 32) {
-            break;
-          }
+  fromJSON(object: any): ServerPacket_Welcome {
+    return {
+      protocolVersion: isSet(object.protocolVersion) ? globalThis.Number(object.protocolVersion) : 0,
+      features: globalThis.Array.isArray(object?.features) ? object.features.map((e: any) => globalThis.String(e)) : [],
+    };
+  },
 
-          message.currentRound = reader.int32();
-          continue;
-        }
-        case 5: {
-          if (tag !== 42) {
+  toJSON(message: ServerPacket_Welcome): unknown {
+    const obj: any = {};
+    if (message.protocolVersion !== 0) {
+      obj.protocolVersion = Math.round(message.protocolVersion);
+    }
+    if (message.features?.length) {
+      obj.features = message.features;
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ServerPacket_Welcome>, I>>(base?: I): ServerPacket_Welcome {
+    return ServerPacket_Welcome.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ServerPacket_Welcome>, I>>(object: I): ServerPacket_Welcome {
+    const message = createBaseServerPacket_Welcome();
+    message.protocolVersion = object.protocolVersion ?? 0;
+    message.features = object.features?.map((e) => e) || [];
+    return message;
+  },
+};
+
+function createBaseServerPacket_Error(): ServerPacket_Error {
+  return { code: "", message: "" };
+}
+
+export const ServerPacket_Error: MessageFns<ServerPacket_Error> = {
+  encode(message: ServerPacket_Error, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.code !== "") {
+      writer.uint32(10).string(message.code);
+    }
+    if (message.message !== "") {
+      writer.uint32(18).string(message.message);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_Error {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseServerPacket_Error();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
             break;
           }
 
-          message.roomId = reader.string();
+          message.code = reader.string();
           continue;
         }
-        case 6: {
-          if (tag !== 48) {
+        case 2: {
+          if (tag !== 18) {
             break;
           }
 
-          message.nextTick = longToNumber(reader.int64());
+          message.message = reader.string();
           continue;
         }
-        case 7: {
-          if (tag !== 56) {
-            break;
-          }
-
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ServerPacket_Error {
+    return {
+      code: isSet(object.code) ? globalThis.String(object.code) : "",
+      message: isSet(object.message) ? globalThis.String(object.message) : "",
+    };
+  },
+
+  toJSON(message: ServerPacket_Error): unknown {
+    const obj: any = {};
+    if (message.code !== "") {
+      obj.code = message.code;
+    }
+    if (message.message !== "") {
+      obj.message = message.message;
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ServerPacket_Error>, I>>(base?: I): ServerPacket_Error {
+    return ServerPacket_Error.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ServerPacket_Error>, I>>(object: I): ServerPacket_Error {
+    const message = createBaseServerPacket_Error();
+    message.code = object.code ?? "";
+    message.message = object.message ?? "";
+    return message;
+  },
+};
+
+function createBaseServerPacket_DrawingLimitReached(): ServerPacket_DrawingLimitReached {
+  return { reason: "" };
+}
+
+export const ServerPacket_DrawingLimitReached: MessageFns<ServerPacket_DrawingLimitReached> = {
+  encode(message: ServerPacket_DrawingLimitReached, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.reason !== "") {
+      writer.uint32(10).string(message.reason);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_DrawingLimitReached {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseServerPacket_DrawingLimitReached();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.reason = reader.string();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ServerPacket_DrawingLimitReached {
+    return { reason: isSet(object.reason) ? globalThis.String(object.reason) : "" };
+  },
+
+  toJSON(message: ServerPacket_DrawingLimitReached): unknown {
+    const obj: any = {};
+    if (message.reason !== "") {
+      obj.reason = message.reason;
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ServerPacket_DrawingLimitReached>, I>>(
+    base?: I,
+  ): ServerPacket_DrawingLimitReached {
+    return ServerPacket_DrawingLimitReached.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ServerPacket_DrawingLimitReached>, I>>(
+    object: I,
+  ): ServerPacket_DrawingLimitReached {
+    const message = createBaseServerPacket_DrawingLimitReached();
+    message.reason = object.reason ?? "";
+    return message;
+  },
+};
+
+function createBaseServerPacket_YourTurnToDraw(): ServerPacket_YourTurnToDraw {
+  return { word: "" };
+}
+
+export const ServerPacket_YourTurnToDraw: MessageFns<ServerPacket_YourTurnToDraw> = {
+  encode(message: ServerPacket_YourTurnToDraw, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.word !== "") {
+      writer.uint32(10).string(message.word);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_YourTurnToDraw {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseServerPacket_YourTurnToDraw();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.word = reader.string();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ServerPacket_YourTurnToDraw {
+    return { word: isSet(object.word) ? globalThis.String(object.word) : "" };
+  },
+
+  toJSON(message: ServerPacket_YourTurnToDraw): unknown {
+    const obj: any = {};
+    if (message.word !== "") {
+      obj.word = message.word;
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ServerPacket_YourTurnToDraw>, I>>(base?: I): ServerPacket_YourTurnToDraw {
+    return ServerPacket_YourTurnToDraw.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ServerPacket_YourTurnToDraw>, I>>(object: I): ServerPacket_YourTurnToDraw {
+    const message = createBaseServerPacket_YourTurnToDraw();
+    message.word = object.word ?? "";
+    return message;
+  },
+};
+
+function createBaseServerPacket_InitialRoomSnapshot(): ServerPacket_InitialRoomSnapshot {
+  return {
+    playersStates: [],
+    drawingHistory: [],
+    currentDrawer: "",
+    currentRound: 0,
+    roomId: "",
+    nextTick: 0,
+    choosingWordDuration: 0,
+    drawingDuration: 0,
+    currentPhase: 0,
+  };
+}
+
+export const ServerPacket_InitialRoomSnapshot: MessageFns<ServerPacket_InitialRoomSnapshot> = {
+  encode(message: ServerPacket_InitialRoomSnapshot, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    for (const v of message.playersStates) {
+      ServerPacket_InitialRoomSnapshot_PlayerState.encode(v!, writer.uint32(10).fork()).join();
+    }
+    for (const v of message.drawingHistory) {
+      writer.uint32(18).bytes(v!);
+    }
+    if (message.currentDrawer !== "") {
+      writer.uint32(26).string(message.currentDrawer);
+    }
+    if (message.currentRound !== 0) {
+      writer.uint32(32).int32(message.currentRound);
+    }
+    if (message.roomId !== "") {
+      writer.uint32(42).string(message.roomId);
+    }
+    if (message.nextTick !== 0) {
+      writer.uint32(48).int64(message.nextTick);
+    }
+    if (message.choosingWordDuration !== 0) {
+      writer.uint32(56).int64(message.choosingWordDuration);
+    }
+    if (message.drawingDuration !== 0) {
+      writer.uint32(64).int64(message.drawingDuration);
+    }
+    if (message.currentPhase !== 0) {
+      writer.uint32(72).int32(message.currentPhase);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ServerPacket_InitialRoomSnapshot {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseServerPacket_InitialRoomSnapshot();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.playersStates.push(ServerPacket_InitialRoomSnapshot_PlayerState.decode(reader, reader.uint32()));
+          continue;
+        }
+        case 2: {
+          if (tag !== 18) {
+            break;
+          }
+
+          message.drawingHistory.push(reader.bytes());
+          continue;
+        }
+        case 3: {
+          if (tag !== 26) {
+            break;
+          }
+
+          message.currentDrawer = reader.string();
+          continue;
+        }
+        case 4: {
+          if (tag !== 32) {
+            break;
+          }
+
+          message.currentRound = reader.int32();
+          continue;
+        }
+        case 5: {
+          if (tag !== 42) {
+            break;
+          }
+
+          message.roomId = reader.string();
+          continue;
+        }
+        case 6: {
+          if (tag !== 48) {
+            break;
+          }
+
+          message.nextTick = longToNumber(reader.int64());
+          continue;
+        }
+        case 7: {
+          if (tag !== 56) {
+            break;
+          }
+
           message.choosingWordDuration = longToNumber(reader.int64());
           continue;
         }
@@ -1563,7 +2204,18 @@ export const ServerPacket_PleaseChooseAWord: MessageFns<ServerPacket_PleaseChoos
 };
 
 function createBaseClientPacket(): ClientPacket {
-  return { drawingData: undefined, playerMessage: undefined, wordChoice: undefined, startGame: undefined };
+  return {
+    drawingData: undefined,
+    playerMessage: undefined,
+    wordChoice: undefined,
+    startGame: undefined,
+    undo: undefined,
+    redo: undefined,
+    clearCanvas: undefined,
+    hello: undefined,
+    resume: undefined,
+    timeSync: undefined,
+  };
 }
 
 export const ClientPacket: MessageFns<ClientPacket> = {
@@ -1580,6 +2232,24 @@ export const ClientPacket: MessageFns<ClientPacket> = {
     if (message.startGame !== undefined) {
       ClientPacket_StartGame.encode(message.startGame, writer.uint32(42).fork()).join();
     }
+    if (message.undo !== undefined) {
+      ClientPacket_Undo.encode(message.undo, writer.uint32(50).fork()).join();
+    }
+    if (message.redo !== undefined) {
+      ClientPacket_Redo.encode(message.redo, writer.uint32(58).fork()).join();
+    }
+    if (message.clearCanvas !== undefined) {
+      ClientPacket_ClearCanvas.encode(message.clearCanvas, writer.uint32(66).fork()).join();
+    }
+    if (message.hello !== undefined) {
+      ClientPacket_Hello.encode(message.hello, writer.uint32(74).fork()).join();
+    }
+    if (message.resume !== undefined) {
+      ClientPacket_Resume.encode(message.resume, writer.uint32(82).fork()).join();
+    }
+    if (message.timeSync !== undefined) {
+      ClientPacket_TimeSync.encode(message.timeSync, writer.uint32(90).fork()).join();
+    }
     return writer;
   },
 
@@ -1622,16 +2292,64 @@ export const ClientPacket: MessageFns<ClientPacket> = {
           message.startGame = ClientPacket_StartGame.decode(reader, reader.uint32());
           continue;
         }
-      }
-      if ((tag & 7) === 4 || tag === 0) {
-        break;
-      }
-      reader.skip(tag & 7);
-    }
-    return message;
-  },
+        case 6: {
+          if (tag !== 50) {
+            break;
+          }
 
-  fromJSON(object: any): ClientPacket {
+          message.undo = ClientPacket_Undo.decode(reader, reader.uint32());
+          continue;
+        }
+        case 7: {
+          if (tag !== 58) {
+            break;
+          }
+
+          message.redo = ClientPacket_Redo.decode(reader, reader.uint32());
+          continue;
+        }
+        case 8: {
+          if (tag !== 66) {
+            break;
+          }
+
+          message.clearCanvas = ClientPacket_ClearCanvas.decode(reader, reader.uint32());
+          continue;
+        }
+        case 9: {
+          if (tag !== 74) {
+            break;
+          }
+
+          message.hello = ClientPacket_Hello.decode(reader, reader.uint32());
+          continue;
+        }
+        case 10: {
+          if (tag !== 82) {
+            break;
+          }
+
+          message.resume = ClientPacket_Resume.decode(reader, reader.uint32());
+          continue;
+        }
+        case 11: {
+          if (tag !== 90) {
+            break;
+          }
+
+          message.timeSync = ClientPacket_TimeSync.decode(reader, reader.uint32());
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ClientPacket {
     return {
       drawingData: isSet(object.drawingData) ? DrawingData.fromJSON(object.drawingData) : undefined,
       playerMessage: isSet(object.playerMessage)
@@ -1639,6 +2357,12 @@ export const ClientPacket: MessageFns<ClientPacket> = {
         : undefined,
       wordChoice: isSet(object.wordChoice) ? ClientPacket_WordChoice.fromJSON(object.wordChoice) : undefined,
       startGame: isSet(object.startGame) ? ClientPacket_StartGame.fromJSON(object.startGame) : undefined,
+      undo: isSet(object.undo) ? ClientPacket_Undo.fromJSON(object.undo) : undefined,
+      redo: isSet(object.redo) ? ClientPacket_Redo.fromJSON(object.redo) : undefined,
+      clearCanvas: isSet(object.clearCanvas) ? ClientPacket_ClearCanvas.fromJSON(object.clearCanvas) : undefined,
+      hello: isSet(object.hello) ? ClientPacket_Hello.fromJSON(object.hello) : undefined,
+      resume: isSet(object.resume) ? ClientPacket_Resume.fromJSON(object.resume) : undefined,
+      timeSync: isSet(object.timeSync) ? ClientPacket_TimeSync.fromJSON(object.timeSync) : undefined,
     };
   },
 
@@ -1656,6 +2380,24 @@ export const ClientPacket: MessageFns<ClientPacket> = {
     if (message.startGame !== undefined) {
       obj.startGame = ClientPacket_StartGame.toJSON(message.startGame);
     }
+    if (message.undo !== undefined) {
+      obj.undo = ClientPacket_Undo.toJSON(message.undo);
+    }
+    if (message.redo !== undefined) {
+      obj.redo = ClientPacket_Redo.toJSON(message.redo);
+    }
+    if (message.clearCanvas !== undefined) {
+      obj.clearCanvas = ClientPacket_ClearCanvas.toJSON(message.clearCanvas);
+    }
+    if (message.hello !== undefined) {
+      obj.hello = ClientPacket_Hello.toJSON(message.hello);
+    }
+    if (message.resume !== undefined) {
+      obj.resume = ClientPacket_Resume.toJSON(message.resume);
+    }
+    if (message.timeSync !== undefined) {
+      obj.timeSync = ClientPacket_TimeSync.toJSON(message.timeSync);
+    }
     return obj;
   },
 
@@ -1676,26 +2418,55 @@ export const ClientPacket: MessageFns<ClientPacket> = {
     message.startGame = (object.startGame !== undefined && object.startGame !== null)
       ? ClientPacket_StartGame.fromPartial(object.startGame)
       : undefined;
+    message.undo = (object.undo !== undefined && object.undo !== null)
+      ? ClientPacket_Undo.fromPartial(object.undo)
+      : undefined;
+    message.redo = (object.redo !== undefined && object.redo !== null)
+      ? ClientPacket_Redo.fromPartial(object.redo)
+      : undefined;
+    message.clearCanvas = (object.clearCanvas !== undefined && object.clearCanvas !== null)
+      ? ClientPacket_ClearCanvas.fromPartial(object.clearCanvas)
+      : undefined;
+    message.hello = (object.hello !== undefined && object.hello !== null)
+      ? ClientPacket_Hello.fromPartial(object.hello)
+      : undefined;
+    message.resume = (object.resume !== undefined && object.resume !== null)
+      ? ClientPacket_Resume.fromPartial(object.resume)
+      : undefined;
+    message.timeSync = (object.timeSync !== undefined && object.timeSync !== null)
+      ? ClientPacket_TimeSync.fromPartial(object.timeSync)
+      : undefined;
     return message;
   },
 };
 
-function createBaseClientPacket_StartGame(): ClientPacket_StartGame {
-  return {};
+function createBaseClientPacket_TimeSync(): ClientPacket_TimeSync {
+  return { clientTime: 0 };
 }
 
-export const ClientPacket_StartGame: MessageFns<ClientPacket_StartGame> = {
-  encode(_: ClientPacket_StartGame, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+export const ClientPacket_TimeSync: MessageFns<ClientPacket_TimeSync> = {
+  encode(message: ClientPacket_TimeSync, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.clientTime !== 0) {
+      writer.uint32(8).int64(message.clientTime);
+    }
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_StartGame {
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_TimeSync {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseClientPacket_StartGame();
+    const message = createBaseClientPacket_TimeSync();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 8) {
+            break;
+          }
+
+          message.clientTime = longToNumber(reader.int64());
+          continue;
+        }
       }
       if ((tag & 7) === 4 || tag === 0) {
         break;
@@ -1705,40 +2476,44 @@ export const ClientPacket_StartGame: MessageFns<ClientPacket_StartGame> = {
     return message;
   },
 
-  fromJSON(_: any): ClientPacket_StartGame {
-    return {};
+  fromJSON(object: any): ClientPacket_TimeSync {
+    return { clientTime: isSet(object.clientTime) ? globalThis.Number(object.clientTime) : 0 };
   },
 
-  toJSON(_: ClientPacket_StartGame): unknown {
+  toJSON(message: ClientPacket_TimeSync): unknown {
     const obj: any = {};
+    if (message.clientTime !== 0) {
+      obj.clientTime = Math.round(message.clientTime);
+    }
     return obj;
   },
 
-  create<I extends Exact<DeepPartial<ClientPacket_StartGame>, I>>(base?: I): ClientPacket_StartGame {
-    return ClientPacket_StartGame.fromPartial(base ?? ({} as any));
+  create<I extends Exact<DeepPartial<ClientPacket_TimeSync>, I>>(base?: I): ClientPacket_TimeSync {
+    return ClientPacket_TimeSync.fromPartial(base ?? ({} as any));
   },
-  fromPartial<I extends Exact<DeepPartial<ClientPacket_StartGame>, I>>(_: I): ClientPacket_StartGame {
-    const message = createBaseClientPacket_StartGame();
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_TimeSync>, I>>(object: I): ClientPacket_TimeSync {
+    const message = createBaseClientPacket_TimeSync();
+    message.clientTime = object.clientTime ?? 0;
     return message;
   },
 };
 
-function createBaseClientPacket_WordChoice(): ClientPacket_WordChoice {
-  return { choice: 0 };
+function createBaseClientPacket_Resume(): ClientPacket_Resume {
+  return { lastSeq: 0 };
 }
 
-export const ClientPacket_WordChoice: MessageFns<ClientPacket_WordChoice> = {
-  encode(message: ClientPacket_WordChoice, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
-    if (message.choice !== 0) {
-      writer.uint32(8).int64(message.choice);
+export const ClientPacket_Resume: MessageFns<ClientPacket_Resume> = {
+  encode(message: ClientPacket_Resume, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.lastSeq !== 0) {
+      writer.uint32(8).uint64(message.lastSeq);
     }
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_WordChoice {
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_Resume {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseClientPacket_WordChoice();
+    const message = createBaseClientPacket_Resume();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
@@ -1747,7 +2522,7 @@ export const ClientPacket_WordChoice: MessageFns<ClientPacket_WordChoice> = {
             break;
           }
 
-          message.choice = longToNumber(reader.int64());
+          message.lastSeq = longToNumber(reader.uint64());
           continue;
         }
       }
@@ -1759,53 +2534,75 @@ export const ClientPacket_WordChoice: MessageFns<ClientPacket_WordChoice> = {
     return message;
   },
 
-  fromJSON(object: any): ClientPacket_WordChoice {
-    return { choice: isSet(object.choice) ? globalThis.Number(object.choice) : 0 };
+  fromJSON(object: any): ClientPacket_Resume {
+    return { lastSeq: isSet(object.lastSeq) ? globalThis.Number(object.lastSeq) : 0 };
   },
 
-  toJSON(message: ClientPacket_WordChoice): unknown {
+  toJSON(message: ClientPacket_Resume): unknown {
     const obj: any = {};
-    if (message.choice !== 0) {
-      obj.choice = Math.round(message.choice);
+    if (message.lastSeq !== 0) {
+      obj.lastSeq = Math.round(message.lastSeq);
     }
     return obj;
   },
 
-  create<I extends Exact<DeepPartial<ClientPacket_WordChoice>, I>>(base?: I): ClientPacket_WordChoice {
-    return ClientPacket_WordChoice.fromPartial(base ?? ({} as any));
+  create<I extends Exact<DeepPartial<ClientPacket_Resume>, I>>(base?: I): ClientPacket_Resume {
+    return ClientPacket_Resume.fromPartial(base ?? ({} as any));
   },
-  fromPartial<I extends Exact<DeepPartial<ClientPacket_WordChoice>, I>>(object: I): ClientPacket_WordChoice {
-    const message = createBaseClientPacket_WordChoice();
-    message.choice = object.choice ?? 0;
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_Resume>, I>>(object: I): ClientPacket_Resume {
+    const message = createBaseClientPacket_Resume();
+    message.lastSeq = object.lastSeq ?? 0;
     return message;
   },
 };
 
-function createBaseClientPacket_PlayerMessage(): ClientPacket_PlayerMessage {
-  return { message: "" };
+function createBaseClientPacket_Hello(): ClientPacket_Hello {
+  return { protocolVersion: 0, minProtocolVersion: 0, capabilities: [] };
 }
 
-export const ClientPacket_PlayerMessage: MessageFns<ClientPacket_PlayerMessage> = {
-  encode(message: ClientPacket_PlayerMessage, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
-    if (message.message !== "") {
-      writer.uint32(10).string(message.message);
+export const ClientPacket_Hello: MessageFns<ClientPacket_Hello> = {
+  encode(message: ClientPacket_Hello, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.protocolVersion !== 0) {
+      writer.uint32(8).uint32(message.protocolVersion);
+    }
+    if (message.minProtocolVersion !== 0) {
+      writer.uint32(16).uint32(message.minProtocolVersion);
+    }
+    for (const v of message.capabilities) {
+      writer.uint32(26).string(v!);
     }
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_PlayerMessage {
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_Hello {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseClientPacket_PlayerMessage();
+    const message = createBaseClientPacket_Hello();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
         case 1: {
-          if (tag !== 10) {
+          if (tag !== 8) {
             break;
           }
 
-          message.message = reader.string();
+          message.protocolVersion = reader.uint32();
+          continue;
+        }
+        case 2: {
+          if (tag !== 16) {
+            break;
+          }
+
+          message.minProtocolVersion = reader.uint32();
+          continue;
+        }
+        case 3: {
+          if (tag !== 26) {
+            break;
+          }
+
+          message.capabilities.push(reader.string());
           continue;
         }
       }
@@ -1817,55 +2614,101 @@ export const ClientPacket_PlayerMessage: MessageFns<ClientPacket_PlayerMessage>
     return message;
   },
 
-  fromJSON(object: any): ClientPacket_PlayerMessage {
-    return { message: isSet(object.message) ? globalThis.String(object.message) : "" };
+  fromJSON(object: any): ClientPacket_Hello {
+    return {
+      protocolVersion: isSet(object.protocolVersion) ? globalThis.Number(object.protocolVersion) : 0,
+      minProtocolVersion: isSet(object.minProtocolVersion) ? globalThis.Number(object.minProtocolVersion) : 0,
+      capabilities: globalThis.Array.isArray(object?.capabilities)
+        ? object.capabilities.map((e: any) => globalThis.String(e))
+        : [],
+    };
   },
 
-  toJSON(message: ClientPacket_PlayerMessage): unknown {
+  toJSON(message: ClientPacket_Hello): unknown {
     const obj: any = {};
-    if (message.message !== "") {
-      obj.message = message.message;
+    if (message.protocolVersion !== 0) {
+      obj.protocolVersion = Math.round(message.protocolVersion);
+    }
+    if (message.minProtocolVersion !== 0) {
+      obj.minProtocolVersion = Math.round(message.minProtocolVersion);
+    }
+    if (message.capabilities?.length) {
+      obj.capabilities = message.capabilities;
     }
     return obj;
   },
 
-  create<I extends Exact<DeepPartial<ClientPacket_PlayerMessage>, I>>(base?: I): ClientPacket_PlayerMessage {
-    return ClientPacket_PlayerMessage.fromPartial(base ?? ({} as any));
+  create<I extends Exact<DeepPartial<ClientPacket_Hello>, I>>(base?: I): ClientPacket_Hello {
+    return ClientPacket_Hello.fromPartial(base ?? ({} as any));
   },
-  fromPartial<I extends Exact<DeepPartial<ClientPacket_PlayerMessage>, I>>(object: I): ClientPacket_PlayerMessage {
-    const message = createBaseClientPacket_PlayerMessage();
-    message.message = object.message ?? "";
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_Hello>, I>>(object: I): ClientPacket_Hello {
+    const message = createBaseClientPacket_Hello();
+    message.protocolVersion = object.protocolVersion ?? 0;
+    message.minProtocolVersion = object.minProtocolVersion ?? 0;
+    message.capabilities = object.capabilities?.map((e) => e) || [];
     return message;
   },
 };
 
-function createBaseDrawingData(): DrawingData {
-  return { data: new Uint8Array(0) };
+function createBaseClientPacket_StartGame(): ClientPacket_StartGame {
+  return {};
 }
 
-export const DrawingData: MessageFns<DrawingData> = {
-  encode(message: DrawingData, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
-    if (message.data.length !== 0) {
-      writer.uint32(10).bytes(message.data);
-    }
+export const ClientPacket_StartGame: MessageFns<ClientPacket_StartGame> = {
+  encode(_: ClientPacket_StartGame, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
     return writer;
   },
 
-  decode(input: BinaryReader | Uint8Array, length?: number): DrawingData {
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_StartGame {
     const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
     const end = length === undefined ? reader.len : reader.pos + length;
-    const message = createBaseDrawingData();
+    const message = createBaseClientPacket_StartGame();
     while (reader.pos < end) {
       const tag = reader.uint32();
       switch (tag >>> 3) {
-        case 1: {
-          if (tag !== 10) {
-            break;
-          }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
 
-          message.data = reader.bytes();
-          continue;
-        }
+  fromJSON(_: any): ClientPacket_StartGame {
+    return {};
+  },
+
+  toJSON(_: ClientPacket_StartGame): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ClientPacket_StartGame>, I>>(base?: I): ClientPacket_StartGame {
+    return ClientPacket_StartGame.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_StartGame>, I>>(_: I): ClientPacket_StartGame {
+    const message = createBaseClientPacket_StartGame();
+    return message;
+  },
+};
+
+function createBaseClientPacket_Undo(): ClientPacket_Undo {
+  return {};
+}
+
+export const ClientPacket_Undo: MessageFns<ClientPacket_Undo> = {
+  encode(_: ClientPacket_Undo, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_Undo {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseClientPacket_Undo();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
       }
       if ((tag & 7) === 4 || tag === 0) {
         break;
@@ -1875,24 +2718,1056 @@ export const DrawingData: MessageFns<DrawingData> = {
     return message;
   },
 
-  fromJSON(object: any): DrawingData {
-    return { data: isSet(object.data) ? bytesFromBase64(object.data) : new Uint8Array(0) };
+  fromJSON(_: any): ClientPacket_Undo {
+    return {};
   },
 
-  toJSON(message: DrawingData): unknown {
+  toJSON(_: ClientPacket_Undo): unknown {
     const obj: any = {};
-    if (message.data.length !== 0) {
-      obj.data = base64FromBytes(message.data);
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ClientPacket_Undo>, I>>(base?: I): ClientPacket_Undo {
+    return ClientPacket_Undo.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_Undo>, I>>(_: I): ClientPacket_Undo {
+    const message = createBaseClientPacket_Undo();
+    return message;
+  },
+};
+
+function createBaseClientPacket_Redo(): ClientPacket_Redo {
+  return {};
+}
+
+export const ClientPacket_Redo: MessageFns<ClientPacket_Redo> = {
+  encode(_: ClientPacket_Redo, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_Redo {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseClientPacket_Redo();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
     }
+    return message;
+  },
+
+  fromJSON(_: any): ClientPacket_Redo {
+    return {};
+  },
+
+  toJSON(_: ClientPacket_Redo): unknown {
+    const obj: any = {};
     return obj;
   },
 
-  create<I extends Exact<DeepPartial<DrawingData>, I>>(base?: I): DrawingData {
-    return DrawingData.fromPartial(base ?? ({} as any));
+  create<I extends Exact<DeepPartial<ClientPacket_Redo>, I>>(base?: I): ClientPacket_Redo {
+    return ClientPacket_Redo.fromPartial(base ?? ({} as any));
   },
-  fromPartial<I extends Exact<DeepPartial<DrawingData>, I>>(object: I): DrawingData {
-    const message = createBaseDrawingData();
-    message.data = object.data ?? new Uint8Array(0);
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_Redo>, I>>(_: I): ClientPacket_Redo {
+    const message = createBaseClientPacket_Redo();
+    return message;
+  },
+};
+
+function createBaseClientPacket_ClearCanvas(): ClientPacket_ClearCanvas {
+  return {};
+}
+
+export const ClientPacket_ClearCanvas: MessageFns<ClientPacket_ClearCanvas> = {
+  encode(_: ClientPacket_ClearCanvas, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_ClearCanvas {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseClientPacket_ClearCanvas();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(_: any): ClientPacket_ClearCanvas {
+    return {};
+  },
+
+  toJSON(_: ClientPacket_ClearCanvas): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ClientPacket_ClearCanvas>, I>>(base?: I): ClientPacket_ClearCanvas {
+    return ClientPacket_ClearCanvas.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_ClearCanvas>, I>>(_: I): ClientPacket_ClearCanvas {
+    const message = createBaseClientPacket_ClearCanvas();
+    return message;
+  },
+};
+
+function createBaseClientPacket_WordChoice(): ClientPacket_WordChoice {
+  return { choice: 0 };
+}
+
+export const ClientPacket_WordChoice: MessageFns<ClientPacket_WordChoice> = {
+  encode(message: ClientPacket_WordChoice, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.choice !== 0) {
+      writer.uint32(8).int64(message.choice);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_WordChoice {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseClientPacket_WordChoice();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 8) {
+            break;
+          }
+
+          message.choice = longToNumber(reader.int64());
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ClientPacket_WordChoice {
+    return { choice: isSet(object.choice) ? globalThis.Number(object.choice) : 0 };
+  },
+
+  toJSON(message: ClientPacket_WordChoice): unknown {
+    const obj: any = {};
+    if (message.choice !== 0) {
+      obj.choice = Math.round(message.choice);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ClientPacket_WordChoice>, I>>(base?: I): ClientPacket_WordChoice {
+    return ClientPacket_WordChoice.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_WordChoice>, I>>(object: I): ClientPacket_WordChoice {
+    const message = createBaseClientPacket_WordChoice();
+    message.choice = object.choice ?? 0;
+    return message;
+  },
+};
+
+function createBaseClientPacket_PlayerMessage(): ClientPacket_PlayerMessage {
+  return { message: "" };
+}
+
+export const ClientPacket_PlayerMessage: MessageFns<ClientPacket_PlayerMessage> = {
+  encode(message: ClientPacket_PlayerMessage, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.message !== "") {
+      writer.uint32(10).string(message.message);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): ClientPacket_PlayerMessage {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseClientPacket_PlayerMessage();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.message = reader.string();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): ClientPacket_PlayerMessage {
+    return { message: isSet(object.message) ? globalThis.String(object.message) : "" };
+  },
+
+  toJSON(message: ClientPacket_PlayerMessage): unknown {
+    const obj: any = {};
+    if (message.message !== "") {
+      obj.message = message.message;
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<ClientPacket_PlayerMessage>, I>>(base?: I): ClientPacket_PlayerMessage {
+    return ClientPacket_PlayerMessage.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<ClientPacket_PlayerMessage>, I>>(object: I): ClientPacket_PlayerMessage {
+    const message = createBaseClientPacket_PlayerMessage();
+    message.message = object.message ?? "";
+    return message;
+  },
+};
+
+function createBaseDrawingData(): DrawingData {
+  return { data: new Uint8Array(0), ops: [] };
+}
+
+export const DrawingData: MessageFns<DrawingData> = {
+  encode(message: DrawingData, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.data.length !== 0) {
+      writer.uint32(10).bytes(message.data);
+    }
+    for (const v of message.ops) {
+      DrawingOp.encode(v!, writer.uint32(18).fork()).join();
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingData {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingData();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.data = reader.bytes();
+          continue;
+        }
+        case 2: {
+          if (tag !== 18) {
+            break;
+          }
+
+          message.ops.push(DrawingOp.decode(reader, reader.uint32()));
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingData {
+    return {
+      data: isSet(object.data) ? bytesFromBase64(object.data) : new Uint8Array(0),
+      ops: globalThis.Array.isArray(object?.ops) ? object.ops.map((e: any) => DrawingOp.fromJSON(e)) : [],
+    };
+  },
+
+  toJSON(message: DrawingData): unknown {
+    const obj: any = {};
+    if (message.data.length !== 0) {
+      obj.data = base64FromBytes(message.data);
+    }
+    if (message.ops?.length) {
+      obj.ops = message.ops.map((e) => DrawingOp.toJSON(e));
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingData>, I>>(base?: I): DrawingData {
+    return DrawingData.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingData>, I>>(object: I): DrawingData {
+    const message = createBaseDrawingData();
+    message.data = object.data ?? new Uint8Array(0);
+    message.ops = object.ops?.map((e) => DrawingOp.fromPartial(e)) || [];
+    return message;
+  },
+};
+
+function createBaseDrawingOp(): DrawingOp {
+  return {
+    strokeBegin: undefined,
+    strokePoints: undefined,
+    strokeEnd: undefined,
+    fill: undefined,
+    clear: undefined,
+    undo: undefined,
+    redo: undefined,
+    setColor: undefined,
+    setBrushSize: undefined,
+  };
+}
+
+export const DrawingOp: MessageFns<DrawingOp> = {
+  encode(message: DrawingOp, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.strokeBegin !== undefined) {
+      DrawingOp_StrokeBegin.encode(message.strokeBegin, writer.uint32(10).fork()).join();
+    }
+    if (message.strokePoints !== undefined) {
+      DrawingOp_StrokePoints.encode(message.strokePoints, writer.uint32(18).fork()).join();
+    }
+    if (message.strokeEnd !== undefined) {
+      DrawingOp_StrokeEnd.encode(message.strokeEnd, writer.uint32(26).fork()).join();
+    }
+    if (message.fill !== undefined) {
+      DrawingOp_Fill.encode(message.fill, writer.uint32(34).fork()).join();
+    }
+    if (message.clear !== undefined) {
+      DrawingOp_Clear.encode(message.clear, writer.uint32(42).fork()).join();
+    }
+    if (message.undo !== undefined) {
+      DrawingOp_Undo.encode(message.undo, writer.uint32(50).fork()).join();
+    }
+    if (message.redo !== undefined) {
+      DrawingOp_Redo.encode(message.redo, writer.uint32(58).fork()).join();
+    }
+    if (message.setColor !== undefined) {
+      DrawingOp_SetColor.encode(message.setColor, writer.uint32(66).fork()).join();
+    }
+    if (message.setBrushSize !== undefined) {
+      DrawingOp_SetBrushSize.encode(message.setBrushSize, writer.uint32(74).fork()).join();
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.strokeBegin = DrawingOp_StrokeBegin.decode(reader, reader.uint32());
+          continue;
+        }
+        case 2: {
+          if (tag !== 18) {
+            break;
+          }
+
+          message.strokePoints = DrawingOp_StrokePoints.decode(reader, reader.uint32());
+          continue;
+        }
+        case 3: {
+          if (tag !== 26) {
+            break;
+          }
+
+          message.strokeEnd = DrawingOp_StrokeEnd.decode(reader, reader.uint32());
+          continue;
+        }
+        case 4: {
+          if (tag !== 34) {
+            break;
+          }
+
+          message.fill = DrawingOp_Fill.decode(reader, reader.uint32());
+          continue;
+        }
+        case 5: {
+          if (tag !== 42) {
+            break;
+          }
+
+          message.clear = DrawingOp_Clear.decode(reader, reader.uint32());
+          continue;
+        }
+        case 6: {
+          if (tag !== 50) {
+            break;
+          }
+
+          message.undo = DrawingOp_Undo.decode(reader, reader.uint32());
+          continue;
+        }
+        case 7: {
+          if (tag !== 58) {
+            break;
+          }
+
+          message.redo = DrawingOp_Redo.decode(reader, reader.uint32());
+          continue;
+        }
+        case 8: {
+          if (tag !== 66) {
+            break;
+          }
+
+          message.setColor = DrawingOp_SetColor.decode(reader, reader.uint32());
+          continue;
+        }
+        case 9: {
+          if (tag !== 74) {
+            break;
+          }
+
+          message.setBrushSize = DrawingOp_SetBrushSize.decode(reader, reader.uint32());
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp {
+    return {
+      strokeBegin: isSet(object.strokeBegin) ? DrawingOp_StrokeBegin.fromJSON(object.strokeBegin) : undefined,
+      strokePoints: isSet(object.strokePoints) ? DrawingOp_StrokePoints.fromJSON(object.strokePoints) : undefined,
+      strokeEnd: isSet(object.strokeEnd) ? DrawingOp_StrokeEnd.fromJSON(object.strokeEnd) : undefined,
+      fill: isSet(object.fill) ? DrawingOp_Fill.fromJSON(object.fill) : undefined,
+      clear: isSet(object.clear) ? DrawingOp_Clear.fromJSON(object.clear) : undefined,
+      undo: isSet(object.undo) ? DrawingOp_Undo.fromJSON(object.undo) : undefined,
+      redo: isSet(object.redo) ? DrawingOp_Redo.fromJSON(object.redo) : undefined,
+      setColor: isSet(object.setColor) ? DrawingOp_SetColor.fromJSON(object.setColor) : undefined,
+      setBrushSize: isSet(object.setBrushSize) ? DrawingOp_SetBrushSize.fromJSON(object.setBrushSize) : undefined,
+    };
+  },
+
+  toJSON(message: DrawingOp): unknown {
+    const obj: any = {};
+    if (message.strokeBegin !== undefined) {
+      obj.strokeBegin = DrawingOp_StrokeBegin.toJSON(message.strokeBegin);
+    }
+    if (message.strokePoints !== undefined) {
+      obj.strokePoints = DrawingOp_StrokePoints.toJSON(message.strokePoints);
+    }
+    if (message.strokeEnd !== undefined) {
+      obj.strokeEnd = DrawingOp_StrokeEnd.toJSON(message.strokeEnd);
+    }
+    if (message.fill !== undefined) {
+      obj.fill = DrawingOp_Fill.toJSON(message.fill);
+    }
+    if (message.clear !== undefined) {
+      obj.clear = DrawingOp_Clear.toJSON(message.clear);
+    }
+    if (message.undo !== undefined) {
+      obj.undo = DrawingOp_Undo.toJSON(message.undo);
+    }
+    if (message.redo !== undefined) {
+      obj.redo = DrawingOp_Redo.toJSON(message.redo);
+    }
+    if (message.setColor !== undefined) {
+      obj.setColor = DrawingOp_SetColor.toJSON(message.setColor);
+    }
+    if (message.setBrushSize !== undefined) {
+      obj.setBrushSize = DrawingOp_SetBrushSize.toJSON(message.setBrushSize);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp>, I>>(base?: I): DrawingOp {
+    return DrawingOp.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp>, I>>(object: I): DrawingOp {
+    const message = createBaseDrawingOp();
+    message.strokeBegin = (object.strokeBegin !== undefined && object.strokeBegin !== null)
+      ? DrawingOp_StrokeBegin.fromPartial(object.strokeBegin)
+      : undefined;
+    message.strokePoints = (object.strokePoints !== undefined && object.strokePoints !== null)
+      ? DrawingOp_StrokePoints.fromPartial(object.strokePoints)
+      : undefined;
+    message.strokeEnd = (object.strokeEnd !== undefined && object.strokeEnd !== null)
+      ? DrawingOp_StrokeEnd.fromPartial(object.strokeEnd)
+      : undefined;
+    message.fill = (object.fill !== undefined && object.fill !== null)
+      ? DrawingOp_Fill.fromPartial(object.fill)
+      : undefined;
+    message.clear = (object.clear !== undefined && object.clear !== null)
+      ? DrawingOp_Clear.fromPartial(object.clear)
+      : undefined;
+    message.undo = (object.undo !== undefined && object.undo !== null)
+      ? DrawingOp_Undo.fromPartial(object.undo)
+      : undefined;
+    message.redo = (object.redo !== undefined && object.redo !== null)
+      ? DrawingOp_Redo.fromPartial(object.redo)
+      : undefined;
+    message.setColor = (object.setColor !== undefined && object.setColor !== null)
+      ? DrawingOp_SetColor.fromPartial(object.setColor)
+      : undefined;
+    message.setBrushSize = (object.setBrushSize !== undefined && object.setBrushSize !== null)
+      ? DrawingOp_SetBrushSize.fromPartial(object.setBrushSize)
+      : undefined;
+    return message;
+  },
+};
+
+function createBaseDrawingOp_Point(): DrawingOp_Point {
+  return { x: 0, y: 0 };
+}
+
+export const DrawingOp_Point: MessageFns<DrawingOp_Point> = {
+  encode(message: DrawingOp_Point, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.x !== 0) {
+      writer.uint32(8).uint32(message.x);
+    }
+    if (message.y !== 0) {
+      writer.uint32(16).uint32(message.y);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_Point {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_Point();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 8) {
+            break;
+          }
+
+          message.x = reader.uint32();
+          continue;
+        }
+        case 2: {
+          if (tag !== 16) {
+            break;
+          }
+
+          message.y = reader.uint32();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_Point {
+    return {
+      x: isSet(object.x) ? globalThis.Number(object.x) : 0,
+      y: isSet(object.y) ? globalThis.Number(object.y) : 0,
+    };
+  },
+
+  toJSON(message: DrawingOp_Point): unknown {
+    const obj: any = {};
+    if (message.x !== 0) {
+      obj.x = Math.round(message.x);
+    }
+    if (message.y !== 0) {
+      obj.y = Math.round(message.y);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_Point>, I>>(base?: I): DrawingOp_Point {
+    return DrawingOp_Point.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_Point>, I>>(object: I): DrawingOp_Point {
+    const message = createBaseDrawingOp_Point();
+    message.x = object.x ?? 0;
+    message.y = object.y ?? 0;
+    return message;
+  },
+};
+
+function createBaseDrawingOp_StrokeBegin(): DrawingOp_StrokeBegin {
+  return { at: undefined };
+}
+
+export const DrawingOp_StrokeBegin: MessageFns<DrawingOp_StrokeBegin> = {
+  encode(message: DrawingOp_StrokeBegin, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.at !== undefined) {
+      DrawingOp_Point.encode(message.at, writer.uint32(10).fork()).join();
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_StrokeBegin {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_StrokeBegin();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.at = DrawingOp_Point.decode(reader, reader.uint32());
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_StrokeBegin {
+    return { at: isSet(object.at) ? DrawingOp_Point.fromJSON(object.at) : undefined };
+  },
+
+  toJSON(message: DrawingOp_StrokeBegin): unknown {
+    const obj: any = {};
+    if (message.at !== undefined) {
+      obj.at = DrawingOp_Point.toJSON(message.at);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_StrokeBegin>, I>>(base?: I): DrawingOp_StrokeBegin {
+    return DrawingOp_StrokeBegin.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_StrokeBegin>, I>>(object: I): DrawingOp_StrokeBegin {
+    const message = createBaseDrawingOp_StrokeBegin();
+    message.at = (object.at !== undefined && object.at !== null) ? DrawingOp_Point.fromPartial(object.at) : undefined;
+    return message;
+  },
+};
+
+function createBaseDrawingOp_StrokePoints(): DrawingOp_StrokePoints {
+  return { points: [] };
+}
+
+export const DrawingOp_StrokePoints: MessageFns<DrawingOp_StrokePoints> = {
+  encode(message: DrawingOp_StrokePoints, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    for (const v of message.points) {
+      DrawingOp_Point.encode(v!, writer.uint32(10).fork()).join();
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_StrokePoints {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_StrokePoints();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.points.push(DrawingOp_Point.decode(reader, reader.uint32()));
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_StrokePoints {
+    return {
+      points: globalThis.Array.isArray(object?.points)
+        ? object.points.map((e: any) => DrawingOp_Point.fromJSON(e))
+        : [],
+    };
+  },
+
+  toJSON(message: DrawingOp_StrokePoints): unknown {
+    const obj: any = {};
+    if (message.points?.length) {
+      obj.points = message.points.map((e) => DrawingOp_Point.toJSON(e));
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_StrokePoints>, I>>(base?: I): DrawingOp_StrokePoints {
+    return DrawingOp_StrokePoints.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_StrokePoints>, I>>(object: I): DrawingOp_StrokePoints {
+    const message = createBaseDrawingOp_StrokePoints();
+    message.points = object.points?.map((e) => DrawingOp_Point.fromPartial(e)) || [];
+    return message;
+  },
+};
+
+function createBaseDrawingOp_StrokeEnd(): DrawingOp_StrokeEnd {
+  return {};
+}
+
+export const DrawingOp_StrokeEnd: MessageFns<DrawingOp_StrokeEnd> = {
+  encode(_: DrawingOp_StrokeEnd, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_StrokeEnd {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_StrokeEnd();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(_: any): DrawingOp_StrokeEnd {
+    return {};
+  },
+
+  toJSON(_: DrawingOp_StrokeEnd): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_StrokeEnd>, I>>(base?: I): DrawingOp_StrokeEnd {
+    return DrawingOp_StrokeEnd.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_StrokeEnd>, I>>(_: I): DrawingOp_StrokeEnd {
+    const message = createBaseDrawingOp_StrokeEnd();
+    return message;
+  },
+};
+
+function createBaseDrawingOp_Fill(): DrawingOp_Fill {
+  return { at: undefined };
+}
+
+export const DrawingOp_Fill: MessageFns<DrawingOp_Fill> = {
+  encode(message: DrawingOp_Fill, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.at !== undefined) {
+      DrawingOp_Point.encode(message.at, writer.uint32(10).fork()).join();
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_Fill {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_Fill();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 10) {
+            break;
+          }
+
+          message.at = DrawingOp_Point.decode(reader, reader.uint32());
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_Fill {
+    return { at: isSet(object.at) ? DrawingOp_Point.fromJSON(object.at) : undefined };
+  },
+
+  toJSON(message: DrawingOp_Fill): unknown {
+    const obj: any = {};
+    if (message.at !== undefined) {
+      obj.at = DrawingOp_Point.toJSON(message.at);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_Fill>, I>>(base?: I): DrawingOp_Fill {
+    return DrawingOp_Fill.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_Fill>, I>>(object: I): DrawingOp_Fill {
+    const message = createBaseDrawingOp_Fill();
+    message.at = (object.at !== undefined && object.at !== null) ? DrawingOp_Point.fromPartial(object.at) : undefined;
+    return message;
+  },
+};
+
+function createBaseDrawingOp_Clear(): DrawingOp_Clear {
+  return {};
+}
+
+export const DrawingOp_Clear: MessageFns<DrawingOp_Clear> = {
+  encode(_: DrawingOp_Clear, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_Clear {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_Clear();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(_: any): DrawingOp_Clear {
+    return {};
+  },
+
+  toJSON(_: DrawingOp_Clear): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_Clear>, I>>(base?: I): DrawingOp_Clear {
+    return DrawingOp_Clear.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_Clear>, I>>(_: I): DrawingOp_Clear {
+    const message = createBaseDrawingOp_Clear();
+    return message;
+  },
+};
+
+function createBaseDrawingOp_Undo(): DrawingOp_Undo {
+  return {};
+}
+
+export const DrawingOp_Undo: MessageFns<DrawingOp_Undo> = {
+  encode(_: DrawingOp_Undo, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_Undo {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_Undo();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(_: any): DrawingOp_Undo {
+    return {};
+  },
+
+  toJSON(_: DrawingOp_Undo): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_Undo>, I>>(base?: I): DrawingOp_Undo {
+    return DrawingOp_Undo.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_Undo>, I>>(_: I): DrawingOp_Undo {
+    const message = createBaseDrawingOp_Undo();
+    return message;
+  },
+};
+
+function createBaseDrawingOp_Redo(): DrawingOp_Redo {
+  return {};
+}
+
+export const DrawingOp_Redo: MessageFns<DrawingOp_Redo> = {
+  encode(_: DrawingOp_Redo, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_Redo {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_Redo();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(_: any): DrawingOp_Redo {
+    return {};
+  },
+
+  toJSON(_: DrawingOp_Redo): unknown {
+    const obj: any = {};
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_Redo>, I>>(base?: I): DrawingOp_Redo {
+    return DrawingOp_Redo.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_Redo>, I>>(_: I): DrawingOp_Redo {
+    const message = createBaseDrawingOp_Redo();
+    return message;
+  },
+};
+
+function createBaseDrawingOp_SetColor(): DrawingOp_SetColor {
+  return { rgba: 0 };
+}
+
+export const DrawingOp_SetColor: MessageFns<DrawingOp_SetColor> = {
+  encode(message: DrawingOp_SetColor, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.rgba !== 0) {
+      writer.uint32(8).uint32(message.rgba);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_SetColor {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_SetColor();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 8) {
+            break;
+          }
+
+          message.rgba = reader.uint32();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_SetColor {
+    return { rgba: isSet(object.rgba) ? globalThis.Number(object.rgba) : 0 };
+  },
+
+  toJSON(message: DrawingOp_SetColor): unknown {
+    const obj: any = {};
+    if (message.rgba !== 0) {
+      obj.rgba = Math.round(message.rgba);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_SetColor>, I>>(base?: I): DrawingOp_SetColor {
+    return DrawingOp_SetColor.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_SetColor>, I>>(object: I): DrawingOp_SetColor {
+    const message = createBaseDrawingOp_SetColor();
+    message.rgba = object.rgba ?? 0;
+    return message;
+  },
+};
+
+function createBaseDrawingOp_SetBrushSize(): DrawingOp_SetBrushSize {
+  return { size: 0 };
+}
+
+export const DrawingOp_SetBrushSize: MessageFns<DrawingOp_SetBrushSize> = {
+  encode(message: DrawingOp_SetBrushSize, writer: BinaryWriter = new BinaryWriter()): BinaryWriter {
+    if (message.size !== 0) {
+      writer.uint32(8).uint32(message.size);
+    }
+    return writer;
+  },
+
+  decode(input: BinaryReader | Uint8Array, length?: number): DrawingOp_SetBrushSize {
+    const reader = input instanceof BinaryReader ? input : new BinaryReader(input);
+    const end = length === undefined ? reader.len : reader.pos + length;
+    const message = createBaseDrawingOp_SetBrushSize();
+    while (reader.pos < end) {
+      const tag = reader.uint32();
+      switch (tag >>> 3) {
+        case 1: {
+          if (tag !== 8) {
+            break;
+          }
+
+          message.size = reader.uint32();
+          continue;
+        }
+      }
+      if ((tag & 7) === 4 || tag === 0) {
+        break;
+      }
+      reader.skip(tag & 7);
+    }
+    return message;
+  },
+
+  fromJSON(object: any): DrawingOp_SetBrushSize {
+    return { size: isSet(object.size) ? globalThis.Number(object.size) : 0 };
+  },
+
+  toJSON(message: DrawingOp_SetBrushSize): unknown {
+    const obj: any = {};
+    if (message.size !== 0) {
+      obj.size = Math.round(message.size);
+    }
+    return obj;
+  },
+
+  create<I extends Exact<DeepPartial<DrawingOp_SetBrushSize>, I>>(base?: I): DrawingOp_SetBrushSize {
+    return DrawingOp_SetBrushSize.fromPartial(base ?? ({} as any));
+  },
+  fromPartial<I extends Exact<DeepPartial<DrawingOp_SetBrushSize>, I>>(object: I): DrawingOp_SetBrushSize {
+    const message = createBaseDrawingOp_SetBrushSize();
+    message.size = object.size ?? 0;
     return message;
   },
 };
@@ -4,6 +4,7 @@ import { createRouter, createWebHistory } from 'vue-router'
 import HomeView from '../views/HomeView.vue'
 import LoginView from '../views/LoginView.vue'
 import SignUpView from '../views/SignUpView.vue'
+import OidcSignUpView from '../views/OidcSignUpView.vue'
 import CreateGameView from '../views/CreateGameView.vue'
 import JoinGameView from '../views/JoinGameView.vue'
 import GameView from '../views/GameView.vue'
@@ -26,6 +27,11 @@ const router = createRouter({
       name: 'signup',
       component: SignUpView
     },
+    {
+      path: '/oidc-signup',
+      name: 'oidc-signup',
+      component: OidcSignUpView
+    },
     {
       path: '/game',
       name: 'game',